@@ -0,0 +1,48 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecache
+
+import "context"
+
+// BitmapCache 支持位图的缓存。
+// 位图就是普通的字符串，offset 为 0 的位是第一个字节的最高位，和 Redis 保持一致。
+// 本地缓存中位图保存为 []byte，也可以对 Set 写入的 string 和 []byte 执行位操作
+type BitmapCache interface {
+	Cache
+	// SetBit 设置 offset 位置的位，value 只能是 0 或者 1，返回该位置原本的值。
+	// 位图的长度不够的时候自动扩展，扩展的部分都是 0
+	SetBit(ctx context.Context, key string, offset int64, value int) (int64, error)
+	// GetBit 返回 offset 位置的位，超出位图长度或者 key 不存在的时候返回 0
+	GetBit(ctx context.Context, key string, offset int64) (int64, error)
+	// BitCount 统计第 start 个字节到第 end 个字节（包含）之间 1 的数量，负数表示从末尾开始计算，
+	// 例如 BitCount(ctx, key, 0, -1) 统计整个位图
+	BitCount(ctx context.Context, key string, start, end int64) (int64, error)
+	// BitOp 对 keys 执行位运算，结果写入 destKey，返回结果的字节数。
+	// 长度不同的位图按照最长的位图计算，不够的部分当作 0。BitOpNot 只能有一个 key
+	BitOp(ctx context.Context, op BitOperation, destKey string, keys ...string) (int64, error)
+	// BitPos 返回第一个值为 bit 的位的位置，pos 最多两个，分别是开始和结束的字节，含义和 BitCount 一样。
+	// 找不到的时候返回 -1；查找 0 并且没有指定结束字节的时候，位图右边被当作无限个 0
+	BitPos(ctx context.Context, key string, bit int64, pos ...int64) (int64, error)
+}
+
+// BitOperation BitOp 支持的位运算
+type BitOperation string
+
+const (
+	BitOpAnd BitOperation = "AND"
+	BitOpOr  BitOperation = "OR"
+	BitOpXor BitOperation = "XOR"
+	BitOpNot BitOperation = "NOT"
+)
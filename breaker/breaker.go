@@ -0,0 +1,198 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+)
+
+// State 熔断器的状态
+type State int32
+
+const (
+	// StateClosed 正常状态，所有请求都会发送给后端
+	StateClosed State = iota
+	// StateOpen 熔断状态，所有请求都会直接失败
+	StateOpen
+	// StateHalfOpen 半开状态，只放行少量的探测请求
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// breaker 基于最近 windowSize 次调用的结果统计失败率，慢调用也算作失败
+type breaker struct {
+	mutex sync.Mutex
+	state State
+
+	// outcomes 环形缓冲区，记录最近的调用是否失败
+	outcomes []bool
+	next     int
+	count    int
+	failures int
+
+	windowSize    int
+	minRequests   int
+	failureRate   float64
+	slowThreshold time.Duration
+
+	openTimeout time.Duration
+	openedAt    time.Time
+
+	// 半开状态下允许的探测请求数量，以及已经放行的和已经成功的探测请求数量
+	halfOpenProbes  int
+	halfOpenAllowed int
+	halfOpenSuccess int
+
+	onStateChange func(from, to State)
+	// changes 持有锁期间发生的状态变化，释放锁之后再通知 onStateChange
+	changes []stateChange
+	now     func() time.Time
+}
+
+type stateChange struct {
+	from State
+	to   State
+}
+
+func newBreaker() *breaker {
+	b := &breaker{
+		windowSize:     100,
+		minRequests:    10,
+		failureRate:    0.5,
+		openTimeout:    time.Second * 5,
+		halfOpenProbes: 3,
+		now:            time.Now,
+	}
+	b.outcomes = make([]bool, b.windowSize)
+	return b
+}
+
+// allow 判断当前是否允许发送请求
+func (b *breaker) allow() error {
+	b.mutex.Lock()
+	defer b.unlock()
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return errs.ErrCircuitOpen
+		}
+		b.transit(StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.halfOpenAllowed >= b.halfOpenProbes {
+			return errs.ErrCircuitOpen
+		}
+		b.halfOpenAllowed++
+	}
+	return nil
+}
+
+// record 记录一次调用的结果
+func (b *breaker) record(failed bool, latency time.Duration) {
+	if b.slowThreshold > 0 && latency >= b.slowThreshold {
+		failed = true
+	}
+	b.mutex.Lock()
+	defer b.unlock()
+	switch b.state {
+	case StateClosed:
+		b.addOutcome(failed)
+		if b.count >= b.minRequests &&
+			float64(b.failures)/float64(b.count) >= b.failureRate {
+			b.transit(StateOpen)
+		}
+	case StateHalfOpen:
+		if failed {
+			b.transit(StateOpen)
+			return
+		}
+		b.halfOpenSuccess++
+		if b.halfOpenSuccess >= b.halfOpenProbes {
+			b.transit(StateClosed)
+		}
+	default:
+		// 打开状态下返回的结果来自打开之前发出的请求，忽略
+	}
+}
+
+func (b *breaker) addOutcome(failed bool) {
+	if b.count == b.windowSize {
+		if b.outcomes[b.next] {
+			b.failures--
+		}
+	} else {
+		b.count++
+	}
+	b.outcomes[b.next] = failed
+	if failed {
+		b.failures++
+	}
+	b.next = (b.next + 1) % b.windowSize
+}
+
+// unlock 释放锁，然后通知持有锁期间发生的状态变化。
+// 回调在锁外面执行，所以回调里面可以调用 State、Allow 之类的方法
+func (b *breaker) unlock() {
+	changes := b.changes
+	b.changes = nil
+	b.mutex.Unlock()
+	if b.onStateChange == nil {
+		return
+	}
+	for _, change := range changes {
+		b.onStateChange(change.from, change.to)
+	}
+}
+
+// transit 切换状态，需要通过 unlock 释放锁才会通知 onStateChange【调用该方法必须先获得锁】
+func (b *breaker) transit(to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	switch to {
+	case StateOpen:
+		b.openedAt = b.now()
+	case StateHalfOpen:
+		b.halfOpenAllowed = 0
+		b.halfOpenSuccess = 0
+	case StateClosed:
+		b.outcomes = make([]bool, b.windowSize)
+		b.next, b.count, b.failures = 0, 0, 0
+	}
+	b.changes = append(b.changes, stateChange{from: from, to: to})
+}
+
+func (b *breaker) currentState() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+type transition struct {
+	from State
+	to   State
+}
+
+func newTestBreaker(now *time.Time, transitions *[]transition) *breaker {
+	b := newBreaker()
+	b.windowSize = 4
+	b.outcomes = make([]bool, 4)
+	b.minRequests = 4
+	b.failureRate = 0.5
+	b.openTimeout = time.Second
+	b.halfOpenProbes = 2
+	b.slowThreshold = time.Millisecond * 100
+	b.now = func() time.Time {
+		return *now
+	}
+	b.onStateChange = func(from, to State) {
+		*transitions = append(*transitions, transition{from: from, to: to})
+	}
+	return b
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	var transitions []transition
+	b := newTestBreaker(&now, &transitions)
+
+	// 没有达到最小请求数
+	assert.NoError(t, b.allow())
+	b.record(true, 0)
+	assert.Equal(t, StateClosed, b.currentState())
+
+	// 窗口 [失败, 成功, 成功, 成功]，失败率 25%
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, b.allow())
+		b.record(false, 0)
+	}
+	assert.Equal(t, StateClosed, b.currentState())
+
+	// 窗口滑动 [慢调用, 成功, 成功, 成功]，失败率依旧是 25%
+	b.record(false, time.Millisecond*200)
+	assert.Equal(t, StateClosed, b.currentState())
+	// [慢调用, 失败, 成功, 成功]，失败率 50%
+	b.record(true, 0)
+	assert.Equal(t, StateOpen, b.currentState())
+	assert.Equal(t, errs.ErrCircuitOpen, b.allow())
+
+	// 打开之后的结果被忽略
+	b.record(false, 0)
+	assert.Equal(t, StateOpen, b.currentState())
+
+	// 超时之后进入半开状态，只放行两个探测请求
+	now = now.Add(time.Second)
+	assert.NoError(t, b.allow())
+	assert.Equal(t, StateHalfOpen, b.currentState())
+	assert.NoError(t, b.allow())
+	assert.Equal(t, errs.ErrCircuitOpen, b.allow())
+
+	// 探测失败，重新打开
+	b.record(false, 0)
+	b.record(true, 0)
+	assert.Equal(t, StateOpen, b.currentState())
+
+	// 探测全部成功，关闭熔断器
+	now = now.Add(time.Second)
+	assert.NoError(t, b.allow())
+	assert.NoError(t, b.allow())
+	b.record(false, 0)
+	b.record(false, 0)
+	assert.Equal(t, StateClosed, b.currentState())
+	// 关闭之后重新统计
+	b.record(true, 0)
+	assert.Equal(t, StateClosed, b.currentState())
+
+	assert.Equal(t, []transition{
+		{from: StateClosed, to: StateOpen},
+		{from: StateOpen, to: StateHalfOpen},
+		{from: StateHalfOpen, to: StateOpen},
+		{from: StateOpen, to: StateHalfOpen},
+		{from: StateHalfOpen, to: StateClosed},
+	}, transitions)
+}
+
+// TestBreaker_CallbackReentrant 回调里面可以调用熔断器的方法，并且看到的是切换之后的状态
+func TestBreaker_CallbackReentrant(t *testing.T) {
+	now := time.Now()
+	var transitions []transition
+	b := newTestBreaker(&now, &transitions)
+	var states []State
+	b.onStateChange = func(from, to State) {
+		states = append(states, b.currentState())
+		_ = b.allow()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 4; i++ {
+			b.record(true, 0)
+		}
+		now = now.Add(time.Second)
+		_ = b.allow()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("回调里面调用熔断器的方法死锁了")
+	}
+	assert.Equal(t, []State{StateOpen, StateHalfOpen}, states)
+}
+
+func TestState_String(t *testing.T) {
+	assert.Equal(t, "closed", StateClosed.String())
+	assert.Equal(t, "open", StateOpen.String())
+	assert.Equal(t, "half-open", StateHalfOpen.String())
+	assert.Equal(t, "unknown", State(100).String())
+}
@@ -0,0 +1,382 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+var _ ecache.Cache = (*Cache)(nil)
+
+// Cache 带熔断的缓存装饰器，一般用于装饰 Redis 之类的远程缓存。
+// 当失败率（慢调用也算作失败）超过阈值之后，熔断器打开，所有请求直接失败：
+// 读请求当作 key 不存在处理，如果设置了 fallback 那么从 fallback 中读取；写请求返回错误。
+// 打开一段时间之后进入半开状态，放行少量的探测请求，全部成功之后恢复正常。
+// 每一个后端都应该使用独立的 Cache
+type Cache struct {
+	ecache.Cache
+	breaker   *breaker
+	isFailure func(err error) bool
+
+	fallback    ecache.Cache
+	fallbackTTL time.Duration
+}
+
+func NewCache(c ecache.Cache, opts ...option.Option[Cache]) *Cache {
+	res := &Cache{
+		Cache:     c,
+		breaker:   newBreaker(),
+		isFailure: isFailure,
+	}
+	option.Apply(res, opts...)
+	return res
+}
+
+// WithWindow 设置统计失败率的窗口大小，以及窗口内至少有多少次调用才会计算失败率。
+// size 必须大于 0，minRequests 不能小于 0，否则忽略该选项，继续使用默认的窗口
+func WithWindow(size, minRequests int) option.Option[Cache] {
+	return func(c *Cache) {
+		if size <= 0 || minRequests < 0 {
+			return
+		}
+		c.breaker.windowSize = size
+		c.breaker.minRequests = minRequests
+		c.breaker.outcomes = make([]bool, size)
+	}
+}
+
+// WithFailureRate 设置打开熔断器的失败率阈值，取值范围 (0, 1]
+func WithFailureRate(rate float64) option.Option[Cache] {
+	return func(c *Cache) {
+		c.breaker.failureRate = rate
+	}
+}
+
+// WithSlowCallThreshold 设置慢调用的阈值，耗时超过阈值的调用算作失败，0 表示不统计慢调用
+func WithSlowCallThreshold(threshold time.Duration) option.Option[Cache] {
+	return func(c *Cache) {
+		c.breaker.slowThreshold = threshold
+	}
+}
+
+// WithOpenTimeout 设置熔断器打开之后多久进入半开状态
+func WithOpenTimeout(timeout time.Duration) option.Option[Cache] {
+	return func(c *Cache) {
+		c.breaker.openTimeout = timeout
+	}
+}
+
+// WithHalfOpenProbes 设置半开状态下放行的探测请求数量，这些请求全部成功之后熔断器关闭
+func WithHalfOpenProbes(probes int) option.Option[Cache] {
+	return func(c *Cache) {
+		c.breaker.halfOpenProbes = probes
+	}
+}
+
+// WithStateChangeCallback 设置状态变化时的回调。
+// 回调是在释放熔断器内部的锁之后，由触发状态变化的调用同步执行的，所以回调里面可以调用 State。
+// 回调会阻塞触发状态变化的那一次调用，不要在回调里面执行耗时的操作
+func WithStateChangeCallback(callback func(from, to State)) option.Option[Cache] {
+	return func(c *Cache) {
+		c.breaker.onStateChange = callback
+	}
+}
+
+// WithFallback 设置本地的兜底缓存，例如 lru.Cache。
+// 正常状态下 Get 和 Set 成功的值会以 ttl 的过期时间写入 fallback，
+// 熔断器打开的时候 Get 从 fallback 中读取
+func WithFallback(fallback ecache.Cache, ttl time.Duration) option.Option[Cache] {
+	return func(c *Cache) {
+		c.fallback = fallback
+		c.fallbackTTL = ttl
+	}
+}
+
+// WithIsFailure 设置判断一个错误是否算作失败的方法。
+// 默认情况下 key 不存在和 context.Canceled 不算作失败
+func WithIsFailure(fn func(err error) bool) option.Option[Cache] {
+	return func(c *Cache) {
+		c.isFailure = fn
+	}
+}
+
+// State 返回熔断器当前的状态
+func (c *Cache) State() State {
+	return c.breaker.currentState()
+}
+
+func (c *Cache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	err := c.do(func() error {
+		return c.Cache.Set(ctx, key, val, expiration)
+	})
+	if err == nil && c.fallback != nil {
+		_ = c.fallback.Set(ctx, key, val, c.fallbackTTL)
+		return nil
+	}
+	c.invalidate(ctx, key)
+	return err
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (res bool, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.SetNX(ctx, key, val, expiration)
+		return err
+	})
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (res bool, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.SetXX(ctx, key, val, expiration)
+		return err
+	})
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (res bool, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.CompareAndSwap(ctx, key, old, new, expiration)
+		return err
+	})
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (val ecache.Value) {
+	err := c.do(func() error {
+		val = c.Cache.Get(ctx, key)
+		return val.Err
+	})
+	if errors.Is(err, errs.ErrCircuitOpen) {
+		return c.fallbackGet(ctx, key)
+	}
+	if err == nil && c.fallback != nil {
+		_ = c.fallback.Set(ctx, key, val.Val, c.fallbackTTL)
+	}
+	// 后端已经没有这个 key 了，熔断的时候也不能再从 fallback 中读到它
+	if val.KeyNotFound() {
+		c.invalidate(ctx, key)
+	}
+	return
+}
+
+func (c *Cache) GetSet(ctx context.Context, key string, val string) (res ecache.Value) {
+	err := c.do(func() error {
+		res = c.Cache.GetSet(ctx, key, val)
+		return res.Err
+	})
+	if errors.Is(err, errs.ErrCircuitOpen) {
+		res.Err = err
+	}
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) Delete(ctx context.Context, key ...string) (res int64, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.Delete(ctx, key...)
+		return err
+	})
+	c.invalidate(ctx, key...)
+	return
+}
+
+func (c *Cache) LPush(ctx context.Context, key string, val ...any) (res int64, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.LPush(ctx, key, val...)
+		return err
+	})
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) LPop(ctx context.Context, key string) (res ecache.Value) {
+	err := c.do(func() error {
+		res = c.Cache.LPop(ctx, key)
+		return res.Err
+	})
+	if errors.Is(err, errs.ErrCircuitOpen) {
+		res.Err = err
+	}
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) BLPop(ctx context.Context, key string, timeout time.Duration) (res ecache.Value) {
+	err := c.doBlocking(func() error {
+		res = c.Cache.BLPop(ctx, key, timeout)
+		return res.Err
+	})
+	if errors.Is(err, errs.ErrCircuitOpen) {
+		res.Err = err
+	}
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) BRPop(ctx context.Context, key string, timeout time.Duration) (res ecache.Value) {
+	err := c.doBlocking(func() error {
+		res = c.Cache.BRPop(ctx, key, timeout)
+		return res.Err
+	})
+	if errors.Is(err, errs.ErrCircuitOpen) {
+		res.Err = err
+	}
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) (res ecache.Value) {
+	err := c.doBlocking(func() error {
+		res = c.Cache.BLMove(ctx, source, destination, srcPos, destPos, timeout)
+		return res.Err
+	})
+	if errors.Is(err, errs.ErrCircuitOpen) {
+		res.Err = err
+	}
+	c.invalidate(ctx, source, destination)
+	return
+}
+
+func (c *Cache) LMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection) (res ecache.Value) {
+	err := c.do(func() error {
+		res = c.Cache.LMove(ctx, source, destination, srcPos, destPos)
+		return res.Err
+	})
+	if errors.Is(err, errs.ErrCircuitOpen) {
+		res.Err = err
+	}
+	c.invalidate(ctx, source, destination)
+	return
+}
+
+func (c *Cache) RPopLPush(ctx context.Context, source, destination string) (res ecache.Value) {
+	err := c.do(func() error {
+		res = c.Cache.RPopLPush(ctx, source, destination)
+		return res.Err
+	})
+	if errors.Is(err, errs.ErrCircuitOpen) {
+		res.Err = err
+	}
+	c.invalidate(ctx, source, destination)
+	return
+}
+
+func (c *Cache) LRem(ctx context.Context, key string, count int64, val any) (res int64, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.LRem(ctx, key, count, val)
+		return err
+	})
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) SAdd(ctx context.Context, key string, members ...any) (res int64, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.SAdd(ctx, key, members...)
+		return err
+	})
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) SRem(ctx context.Context, key string, members ...any) (res int64, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.SRem(ctx, key, members...)
+		return err
+	})
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) IncrBy(ctx context.Context, key string, value int64) (res int64, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.IncrBy(ctx, key, value)
+		return err
+	})
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) DecrBy(ctx context.Context, key string, value int64) (res int64, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.DecrBy(ctx, key, value)
+		return err
+	})
+	c.invalidate(ctx, key)
+	return
+}
+
+func (c *Cache) IncrByFloat(ctx context.Context, key string, value float64) (res float64, err error) {
+	err = c.do(func() error {
+		res, err = c.Cache.IncrByFloat(ctx, key, value)
+		return err
+	})
+	c.invalidate(ctx, key)
+	return
+}
+
+// do 在熔断器的保护下执行 fn，熔断器不允许执行的时候返回 errs.ErrCircuitOpen
+func (c *Cache) do(fn func() error) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := fn()
+	c.breaker.record(c.isFailure(err), time.Since(start))
+	return err
+}
+
+// doBlocking 用于 BLPop 之类的阻塞操作，等待的时间不代表后端变慢了，所以不统计慢调用
+func (c *Cache) doBlocking(fn func() error) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	c.breaker.record(c.isFailure(err), 0)
+	return err
+}
+
+func (c *Cache) fallbackGet(ctx context.Context, key string) ecache.Value {
+	if c.fallback == nil {
+		var val ecache.Value
+		val.Err = errs.ErrKeyNotExist
+		return val
+	}
+	return c.fallback.Get(ctx, key)
+}
+
+// invalidate 写操作之后从 fallback 中删除对应的 key，避免熔断的时候读到旧数据
+func (c *Cache) invalidate(ctx context.Context, key ...string) {
+	if c.fallback != nil {
+		_, _ = c.fallback.Delete(ctx, key...)
+	}
+}
+
+func isFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, errs.ErrKeyNotExist) &&
+		!errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,240 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errMock = errors.New("mock error")
+
+// faultyCache 测试用的缓存，err 不为 nil 的时候所有的读写都返回 err
+type faultyCache struct {
+	*lru.Cache
+	err error
+}
+
+func (f *faultyCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if f.err != nil {
+		return f.err
+	}
+	return f.Cache.Set(ctx, key, val, expiration)
+}
+
+func (f *faultyCache) Get(ctx context.Context, key string) ecache.Value {
+	if f.err != nil {
+		var val ecache.Value
+		val.Err = f.err
+		return val
+	}
+	return f.Cache.Get(ctx, key)
+}
+
+func (f *faultyCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	return f.Cache.IncrBy(ctx, key, value)
+}
+
+func TestCache(t *testing.T) {
+	backend := &faultyCache{Cache: lru.NewCache(100)}
+	fallback := lru.NewCache(100)
+	var states []State
+	c := NewCache(backend,
+		WithWindow(4, 2),
+		WithFailureRate(0.5),
+		WithOpenTimeout(time.Millisecond*50),
+		WithHalfOpenProbes(1),
+		WithFallback(fallback, time.Minute),
+		WithStateChangeCallback(func(from, to State) {
+			states = append(states, to)
+		}))
+	ctx := context.Background()
+
+	// 正常状态下写穿到 fallback
+	require.NoError(t, c.Set(ctx, "name", "大明", time.Minute))
+	assert.Equal(t, "大明", fallback.Get(ctx, "name").Val)
+	val := c.Get(ctx, "name")
+	assert.Equal(t, "大明", val.Val)
+	// key 不存在不算失败
+	val = c.Get(ctx, "not-exist")
+	assert.True(t, val.KeyNotFound())
+	_, err := c.IncrBy(ctx, "counter", 1)
+	require.NoError(t, err)
+	assert.Equal(t, StateClosed, c.State())
+
+	// 后端故障，熔断器打开
+	backend.err = errMock
+	val = c.Get(ctx, "name")
+	assert.Equal(t, errMock, val.Err)
+	_, err = c.IncrBy(ctx, "counter", 1)
+	assert.Equal(t, errMock, err)
+	assert.Equal(t, StateOpen, c.State())
+
+	// 打开之后读请求从 fallback 读取，不存在的当作 key 不存在
+	val = c.Get(ctx, "name")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "大明", val.Val)
+	val = c.Get(ctx, "counter")
+	assert.True(t, val.KeyNotFound())
+	// 写请求直接失败，并且 fallback 中的旧数据会被删除
+	err = c.Set(ctx, "name", "小明", time.Minute)
+	assert.Equal(t, errs.ErrCircuitOpen, err)
+	assert.True(t, fallback.Get(ctx, "name").KeyNotFound())
+	_, err = c.IncrBy(ctx, "counter", 1)
+	assert.Equal(t, errs.ErrCircuitOpen, err)
+
+	// 半开状态下探测成功，恢复正常
+	backend.err = nil
+	time.Sleep(time.Millisecond * 60)
+	require.NoError(t, c.Set(ctx, "name", "小明", time.Minute))
+	assert.Equal(t, StateClosed, c.State())
+	val = c.Get(ctx, "name")
+	assert.Equal(t, "小明", val.Val)
+
+	assert.Equal(t, []State{StateOpen, StateHalfOpen, StateClosed}, states)
+}
+
+// TestCache_GetNotExist 后端的 key 被别人删除之后，fallback 中的旧数据也会被删除
+func TestCache_GetNotExist(t *testing.T) {
+	backend := &faultyCache{Cache: lru.NewCache(100)}
+	fallback := lru.NewCache(100)
+	c := NewCache(backend, WithWindow(1, 1), WithFallback(fallback, time.Minute))
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "name", "大明", time.Minute))
+	_, err := backend.Delete(ctx, "name")
+	require.NoError(t, err)
+	assert.True(t, c.Get(ctx, "name").KeyNotFound())
+
+	backend.err = errMock
+	assert.Equal(t, errMock, c.Get(ctx, "name").Err)
+	assert.Equal(t, StateOpen, c.State())
+	assert.True(t, c.Get(ctx, "name").KeyNotFound())
+}
+
+func TestCache_InvalidWindow(t *testing.T) {
+	for _, window := range [][2]int{{0, 1}, {-1, 1}, {4, -1}} {
+		c := NewCache(lru.NewCache(100), WithWindow(window[0], window[1]))
+		assert.Equal(t, 100, c.breaker.windowSize)
+		assert.Equal(t, 10, c.breaker.minRequests)
+		// 不会因为窗口大小为 0 而 panic
+		assert.True(t, c.Get(context.Background(), "name").KeyNotFound())
+	}
+}
+
+func TestCache_WithoutFallback(t *testing.T) {
+	backend := &faultyCache{Cache: lru.NewCache(100), err: errMock}
+	c := NewCache(backend,
+		WithWindow(2, 1),
+		WithFailureRate(1),
+		WithIsFailure(func(err error) bool {
+			return err != nil
+		}))
+	ctx := context.Background()
+
+	val := c.Get(ctx, "name")
+	assert.Equal(t, errMock, val.Err)
+	assert.Equal(t, StateOpen, c.State())
+	val = c.Get(ctx, "name")
+	assert.True(t, val.KeyNotFound())
+	val = c.LPop(ctx, "list")
+	assert.Equal(t, errs.ErrCircuitOpen, val.Err)
+	val = c.GetSet(ctx, "name", "大明")
+	assert.Equal(t, errs.ErrCircuitOpen, val.Err)
+	val = c.BLPop(ctx, "list", time.Millisecond)
+	assert.Equal(t, errs.ErrCircuitOpen, val.Err)
+	val = c.BRPop(ctx, "list", time.Millisecond)
+	assert.Equal(t, errs.ErrCircuitOpen, val.Err)
+	val = c.BLMove(ctx, "list", "processing", ecache.ListRight, ecache.ListLeft, time.Millisecond)
+	assert.Equal(t, errs.ErrCircuitOpen, val.Err)
+	val = c.LMove(ctx, "processing", "list", ecache.ListLeft, ecache.ListRight)
+	assert.Equal(t, errs.ErrCircuitOpen, val.Err)
+	val = c.RPopLPush(ctx, "list", "processing")
+	assert.Equal(t, errs.ErrCircuitOpen, val.Err)
+	for _, fn := range []func() error{
+		func() error {
+			_, err := c.SetNX(ctx, "name", "大明", time.Minute)
+			return err
+		},
+		func() error {
+			_, err := c.Delete(ctx, "name")
+			return err
+		},
+		func() error {
+			_, err := c.LPush(ctx, "list", 1)
+			return err
+		},
+		func() error {
+			_, err := c.LRem(ctx, "list", 0, 1)
+			return err
+		},
+		func() error {
+			_, err := c.SAdd(ctx, "set", 1)
+			return err
+		},
+		func() error {
+			_, err := c.SRem(ctx, "set", 1)
+			return err
+		},
+		func() error {
+			_, err := c.DecrBy(ctx, "counter", 1)
+			return err
+		},
+		func() error {
+			_, err := c.IncrByFloat(ctx, "counter", 1)
+			return err
+		},
+	} {
+		assert.Equal(t, errs.ErrCircuitOpen, fn())
+	}
+}
+
+func TestCache_Blocking(t *testing.T) {
+	backend := lru.NewCache(100)
+	fallback := lru.NewCache(100)
+	c := NewCache(backend,
+		WithWindow(2, 1),
+		WithFailureRate(0.5),
+		WithSlowCallThreshold(time.Millisecond*10),
+		WithFallback(fallback, time.Minute))
+	ctx := context.Background()
+
+	// 阻塞等待的时间不算慢调用
+	val := c.BLPop(ctx, "list", time.Millisecond*20)
+	assert.True(t, val.KeyNotFound())
+	val = c.BRPop(ctx, "list", time.Millisecond*20)
+	assert.True(t, val.KeyNotFound())
+	assert.Equal(t, StateClosed, c.State())
+
+	_, err := c.LPush(ctx, "list", "a")
+	require.NoError(t, err)
+	require.NoError(t, fallback.Set(ctx, "processing", "old", time.Minute))
+	val = c.BLMove(ctx, "list", "processing", ecache.ListLeft, ecache.ListLeft, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	assert.True(t, fallback.Get(ctx, "processing").KeyNotFound())
+	assert.Equal(t, StateClosed, c.State())
+}
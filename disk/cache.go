@@ -0,0 +1,763 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/blocking"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/internal/resp"
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+var (
+	_ ecache.Cache = (*Cache)(nil)
+
+	// ErrCorrupted 日志文件中间的记录损坏了。为了避免丢失损坏的记录之后的数据，NewCache 不会自动修复，
+	// 只有文件末尾没有写完的记录会被丢弃
+	ErrCorrupted = errors.New("ecache: 日志文件已经损坏")
+	// ErrLocked 日志文件已经被别的进程打开了
+	ErrLocked = errors.New("ecache: 日志文件正在被别的进程使用")
+
+	errOnlyStringCanGet = errors.New("ecache: 只有 string 类型的数据，才能执行 Get 和 GetSet")
+	errOnlyListCanList  = errors.New("ecache: 只有 list 类型的数据，才能执行列表操作")
+	errOnlySetCanSet    = errors.New("ecache: 只有 set 类型的数据，才能执行 SAdd 和 SRem")
+	errOnlyNumCanIncr   = errors.New("ecache: 只有数字类型的数据，才能执行 IncrBy、DecrBy 和 IncrByFloat")
+)
+
+// kind 数据的类型，计数器和 Redis 一样是字符串
+type kind uint8
+
+const (
+	kindString kind = iota
+	kindList
+	kindSet
+)
+
+// entry 内存中的数据。entry 写入之后就不会再修改，修改 key 的时候总是创建一个新的 entry，
+// 这样在写日志失败的时候内存中的数据依旧和日志保持一致
+type entry struct {
+	kind      kind
+	str       string
+	list      []string
+	set       map[string]struct{}
+	expiresAt time.Time
+}
+
+func (e *entry) isExpired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !e.expiresAt.After(now)
+}
+
+// Cache 基于磁盘的缓存，重启之后数据依旧存在。
+// 所有的数据都保存在内存中，每次修改都会把 key 修改之后的完整状态追加到日志文件，
+// 启动的时候重放日志恢复数据。失效的记录超过 WithCompactThreshold 设置的数量，
+// 并且多于有效的 key 的时候，会把有效的数据重写到一个新的日志文件中，替换掉原来的日志。
+//
+// 和 Redis 一样，写入的值都会被转换为字符串，列表的元素和集合的成员也是字符串。
+// 修改列表、集合和计数器的时候会保留过期时间，列表和集合为空的时候 key 会被删除。
+// 因为每次修改列表和集合都会写入完整的列表和集合，所以它适合元素不多的场景。
+//
+// 默认情况下写入只保证进程崩溃之后数据不丢失，需要在机器掉电之后也不丢失数据的话使用 WithSync。
+//
+// 同一个日志文件同时只能被一个 Cache 打开，多个进程同时追加和压缩会互相破坏对方的日志。
+// NewCache 会对日志文件旁边的 .lock 文件加排他的 flock，已经被占用的时候返回 ErrLocked。
+// 没有 flock 的平台上不会检查，需要使用方自己保证
+type Cache struct {
+	lock sync.Mutex
+	path string
+	// file 为 nil 表示缓存已经关闭
+	file *os.File
+	// lockFile 持有 flock 的锁文件，关闭缓存的时候释放
+	lockFile *os.File
+	data     map[string]*entry
+	// size 日志文件当前的大小
+	size int64
+	// garbage 日志中已经失效的记录数量，压缩之后清零
+	garbage          int
+	compactThreshold int
+	sync             bool
+
+	// waiters 阻塞在 BLPop、BRPop 和 BLMove 上的调用者
+	waiters *blocking.Waiters
+}
+
+// NewCache 打开 path 对应的日志文件并且恢复数据，文件不存在的时候会创建一个新的。
+// 上一次崩溃的时候没有写完的记录会被丢弃，文件中间的记录损坏的时候返回 ErrCorrupted，
+// 日志文件已经被别的进程或者别的 Cache 打开的时候返回 ErrLocked
+func NewCache(path string, opts ...option.Option[Cache]) (*Cache, error) {
+	res := &Cache{
+		path:             path,
+		data:             make(map[string]*entry),
+		compactThreshold: 1024,
+	}
+	option.Apply(res, opts...)
+	res.waiters = blocking.NewWaiters(&res.lock)
+	// 必须在打开日志之前加锁，否则会删掉别的进程正在写的压缩文件
+	lf, err := lockFile(lockPath(path))
+	if err != nil {
+		return nil, err
+	}
+	f, size, err := openLog(path, res.load)
+	if err != nil {
+		_ = lf.Close()
+		return nil, err
+	}
+	res.file, res.size, res.lockFile = f, size, lf
+	now := time.Now()
+	for key, ent := range res.data {
+		if ent.isExpired(now) {
+			delete(res.data, key)
+			res.garbage++
+		}
+	}
+	return res, nil
+}
+
+// WithCompactThreshold 设置触发压缩的失效记录数量，默认是 1024
+func WithCompactThreshold(threshold int) option.Option[Cache] {
+	return func(c *Cache) {
+		c.compactThreshold = threshold
+	}
+}
+
+// WithSync 每次写入之后都调用 fsync，数据更加可靠，但是写入会慢很多
+func WithSync() option.Option[Cache] {
+	return func(c *Cache) {
+		c.sync = true
+	}
+}
+
+// load 重放一条记录，同一个 key 之前的记录都会失效
+func (c *Cache) load(rec record) {
+	if _, ok := c.data[rec.Key]; ok {
+		c.garbage++
+	}
+	ent := rec.entry()
+	if ent == nil {
+		delete(c.data, rec.Key)
+		c.garbage++
+		return
+	}
+	c.data[rec.Key] = ent
+}
+
+// Compact 立刻压缩日志，只保留有效的数据
+func (c *Cache) Compact() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.file == nil {
+		return errs.ErrCacheClosed
+	}
+	return c.compact()
+}
+
+func (c *Cache) compact() error {
+	now := time.Now()
+	f, size, err := writeSnapshot(c.path, func(fn func(rec record) error) error {
+		for key, ent := range c.data {
+			if ent.isExpired(now) {
+				delete(c.data, key)
+				continue
+			}
+			if err := fn(newRecord(key, ent)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if f == nil {
+		return err
+	}
+	old := c.file
+	c.file, c.size, c.garbage = f, size, 0
+	if er := old.Close(); err == nil {
+		err = er
+	}
+	return err
+}
+
+// Close 关闭日志文件，之后所有的操作都会返回 errs.ErrCacheClosed
+func (c *Cache) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	if er := c.lockFile.Close(); err == nil {
+		err = er
+	}
+	c.file = nil
+	// 唤醒阻塞在 BLPop 之类操作上的调用者，它们重试的时候会拿到 errs.ErrCacheClosed
+	c.waiters.NotifyAll()
+	return err
+}
+
+func (c *Cache) checkClosed() error {
+	if c.file == nil {
+		return errs.ErrCacheClosed
+	}
+	return nil
+}
+
+// put 先把 key 的新状态追加到日志，成功之后再修改内存，ent 为 nil 表示删除
+func (c *Cache) put(key string, ent *entry) error {
+	if err := c.checkClosed(); err != nil {
+		return err
+	}
+	buf, err := encodeRecord(newRecord(key, ent))
+	if err != nil {
+		return err
+	}
+	if _, err = c.file.Write(buf); err == nil && c.sync {
+		err = c.file.Sync()
+	}
+	if err != nil {
+		// 去掉可能写了一半的记录，否则重放的时候会丢掉它之后的所有记录
+		_ = c.file.Truncate(c.size)
+		_, _ = c.file.Seek(c.size, io.SeekStart)
+		return err
+	}
+	c.size += int64(len(buf))
+	if _, ok := c.data[key]; ok {
+		c.garbage++
+	}
+	if ent == nil {
+		delete(c.data, key)
+		c.garbage++
+	} else {
+		c.data[key] = ent
+	}
+	if c.garbage >= c.compactThreshold && c.garbage > len(c.data) {
+		// 写入已经成功了，压缩失败不影响这次写入，下一次写入的时候会再次尝试
+		_ = c.compact()
+	}
+	return nil
+}
+
+// getEntry 返回没有过期的数据，过期的数据会被直接丢弃，它的日志记录在重放的时候同样会被丢弃
+func (c *Cache) getEntry(key string) *entry {
+	ent, ok := c.data[key]
+	if !ok {
+		return nil
+	}
+	if ent.isExpired(time.Now()) {
+		delete(c.data, key)
+		c.garbage++
+		return nil
+	}
+	return ent
+}
+
+func deadline(expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expiration)
+}
+
+func newString(val any, expiration time.Duration) (*entry, error) {
+	str, err := resp.String(val)
+	if err != nil {
+		return nil, err
+	}
+	return &entry{kind: kindString, str: str, expiresAt: deadline(expiration)}, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	ent, err := newString(val, expiration)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.put(key, ent)
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	ent, err := newString(val, expiration)
+	if err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err = c.checkClosed(); err != nil || c.getEntry(key) != nil {
+		return false, err
+	}
+	return stored(c.put(key, ent))
+}
+
+func (c *Cache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	ent, err := newString(val, expiration)
+	if err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err = c.checkClosed(); err != nil || c.getEntry(key) == nil {
+		return false, err
+	}
+	return stored(c.put(key, ent))
+}
+
+// CompareAndSwap 和 Redis 一样比较的是转换为字符串之后的值
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	oldVal, err := resp.String(old)
+	if err != nil {
+		return false, err
+	}
+	ent, err := newString(new, expiration)
+	if err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err = c.checkClosed(); err != nil {
+		return false, err
+	}
+	cur := c.getEntry(key)
+	if cur == nil || cur.kind != kindString || cur.str != oldVal {
+		return false, nil
+	}
+	return stored(c.put(key, ent))
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (val ecache.Value) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if val.Err = c.checkClosed(); val.Err != nil {
+		return
+	}
+	ent := c.getEntry(key)
+	switch {
+	case ent == nil:
+		val.Err = errs.ErrKeyNotExist
+	case ent.kind != kindString:
+		val.Err = errOnlyStringCanGet
+	default:
+		val.Val = ent.str
+	}
+	return
+}
+
+// GetSet 和 Redis 一样，设置之后 key 永不过期
+func (c *Cache) GetSet(ctx context.Context, key string, val string) (result ecache.Value) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if result.Err = c.checkClosed(); result.Err != nil {
+		return
+	}
+	old := c.getEntry(key)
+	if old != nil && old.kind != kindString {
+		result.Err = errOnlyStringCanGet
+		return
+	}
+	if result.Err = c.put(key, &entry{kind: kindString, str: val}); result.Err != nil {
+		return
+	}
+	if old == nil {
+		result.Err = errs.ErrKeyNotExist
+		return
+	}
+	result.Val = old.str
+	return
+}
+
+func (c *Cache) Delete(ctx context.Context, key ...string) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	n := int64(0)
+	for _, k := range key {
+		if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		if err := c.checkClosed(); err != nil {
+			return n, err
+		}
+		if c.getEntry(k) == nil {
+			continue
+		}
+		if err := c.put(k, nil); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (c *Cache) LPush(ctx context.Context, key string, val ...any) (int64, error) {
+	items, err := toStrings(val)
+	if err != nil {
+		return 0, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	old, err := c.listOf(key)
+	if err != nil {
+		return 0, err
+	}
+	// 没有元素的时候不能写入一个空的列表，它违反了列表为空的时候 key 不存在的约定
+	if len(items) == 0 {
+		return int64(len(old.list)), nil
+	}
+	// 和 Redis 一样依次写入头部，LPush a b c 之后列表是 [c b a]
+	data := make([]string, 0, len(items)+len(old.list))
+	for i := len(items) - 1; i >= 0; i-- {
+		data = append(data, items[i])
+	}
+	data = append(data, old.list...)
+	if err = c.put(key, &entry{kind: kindList, list: data, expiresAt: old.expiresAt}); err != nil {
+		return 0, err
+	}
+	c.waiters.Notify(key, len(items))
+	return int64(len(data)), nil
+}
+
+func (c *Cache) LPop(ctx context.Context, key string) ecache.Value {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.pop(key, ecache.ListLeft)
+}
+
+func (c *Cache) BLPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return c.waiters.Wait(ctx, key, timeout, func() ecache.Value {
+		return c.pop(key, ecache.ListLeft)
+	})
+}
+
+func (c *Cache) BRPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return c.waiters.Wait(ctx, key, timeout, func() ecache.Value {
+		return c.pop(key, ecache.ListRight)
+	})
+}
+
+func (c *Cache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) ecache.Value {
+	return c.waiters.Wait(ctx, source, timeout, func() ecache.Value {
+		return c.move(source, destination, srcPos, destPos)
+	})
+}
+
+func (c *Cache) LMove(ctx context.Context, source, destination string, srcPos, destPos ecache.ListDirection) ecache.Value {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.move(source, destination, srcPos, destPos)
+}
+
+func (c *Cache) RPopLPush(ctx context.Context, source, destination string) ecache.Value {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.move(source, destination, ecache.ListRight, ecache.ListLeft)
+}
+
+// LRem 和 Redis 一样比较的是转换为字符串之后的值
+func (c *Cache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	target, err := resp.String(val)
+	if err != nil {
+		return 0, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	old, err := c.listOf(key)
+	if err != nil || len(old.list) == 0 {
+		return 0, err
+	}
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	removed := make(map[int]struct{}, 4)
+	for i := range old.list {
+		idx := i
+		if count < 0 {
+			idx = len(old.list) - 1 - i
+		}
+		if old.list[idx] != target {
+			continue
+		}
+		removed[idx] = struct{}{}
+		if limit > 0 && int64(len(removed)) == limit {
+			break
+		}
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+	data := make([]string, 0, len(old.list)-len(removed))
+	for i, item := range old.list {
+		if _, ok := removed[i]; !ok {
+			data = append(data, item)
+		}
+	}
+	if err = c.putList(key, data, old.expiresAt); err != nil {
+		return 0, err
+	}
+	return int64(len(removed)), nil
+}
+
+// listOf 返回 key 对应的列表，key 不存在的时候返回一个空的列表
+func (c *Cache) listOf(key string) (*entry, error) {
+	if err := c.checkClosed(); err != nil {
+		return nil, err
+	}
+	ent := c.getEntry(key)
+	if ent == nil {
+		return &entry{kind: kindList}, nil
+	}
+	if ent.kind != kindList {
+		return nil, errOnlyListCanList
+	}
+	return ent, nil
+}
+
+// putList 写入列表，列表为空的时候和 Redis 一样删除 key
+func (c *Cache) putList(key string, data []string, expiresAt time.Time) error {
+	if len(data) == 0 {
+		return c.put(key, nil)
+	}
+	return c.put(key, &entry{kind: kindList, list: data, expiresAt: expiresAt})
+}
+
+// pop 从列表的 dir 一端移除一个元素，列表不存在的时候返回 errs.ErrKeyNotExist
+func (c *Cache) pop(key string, dir ecache.ListDirection) (val ecache.Value) {
+	old, err := c.listOf(key)
+	if err != nil {
+		val.Err = err
+		return
+	}
+	if len(old.list) == 0 {
+		val.Err = errs.ErrKeyNotExist
+		return
+	}
+	var data []string
+	if dir == ecache.ListRight {
+		val.Val = old.list[len(old.list)-1]
+		data = append(data, old.list[:len(old.list)-1]...)
+	} else {
+		val.Val = old.list[0]
+		data = append(data, old.list[1:]...)
+	}
+	if val.Err = c.putList(key, data, old.expiresAt); val.Err != nil {
+		val.Val = nil
+	}
+	return
+}
+
+// push 向列表的 dir 一端写入一个元素，并且唤醒一个等待该列表的调用者
+func (c *Cache) push(key string, dir ecache.ListDirection, item string) error {
+	old, err := c.listOf(key)
+	if err != nil {
+		return err
+	}
+	data := make([]string, 0, len(old.list)+1)
+	if dir == ecache.ListRight {
+		data = append(append(data, old.list...), item)
+	} else {
+		data = append(append(data, item), old.list...)
+	}
+	if err = c.putList(key, data, old.expiresAt); err != nil {
+		return err
+	}
+	c.waiters.Notify(key, 1)
+	return nil
+}
+
+// move 将 source 的一个元素移动到 destination。和 Redis 一样，destination 类型不对的时候不会修改 source
+func (c *Cache) move(source, destination string, srcPos, destPos ecache.ListDirection) (val ecache.Value) {
+	if _, val.Err = c.listOf(destination); val.Err != nil {
+		return
+	}
+	val = c.pop(source, srcPos)
+	if val.Err != nil {
+		return
+	}
+	val.Err = c.push(destination, destPos, val.Val.(string))
+	return
+}
+
+// SAdd 返回新加入的成员数量
+func (c *Cache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
+	items, err := toStrings(members)
+	if err != nil {
+		return 0, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err = c.checkClosed(); err != nil {
+		return 0, err
+	}
+	old := c.getEntry(key)
+	if old == nil {
+		old = &entry{kind: kindSet}
+	}
+	if old.kind != kindSet {
+		return 0, errOnlySetCanSet
+	}
+	data := copySet(old.set, len(items))
+	var added int64
+	for _, item := range items {
+		if _, ok := data[item]; !ok {
+			data[item] = struct{}{}
+			added++
+		}
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	if err = c.put(key, &entry{kind: kindSet, set: data, expiresAt: old.expiresAt}); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// SRem 和 lru.Cache 一样，key 不存在的时候返回 errs.ErrKeyNotExist
+func (c *Cache) SRem(ctx context.Context, key string, members ...any) (int64, error) {
+	items, err := toStrings(members)
+	if err != nil {
+		return 0, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err = c.checkClosed(); err != nil {
+		return 0, err
+	}
+	old := c.getEntry(key)
+	if old == nil {
+		return 0, errs.ErrKeyNotExist
+	}
+	if old.kind != kindSet {
+		return 0, errOnlySetCanSet
+	}
+	data := copySet(old.set, 0)
+	var rems int64
+	for _, item := range items {
+		if _, ok := data[item]; ok {
+			delete(data, item)
+			rems++
+		}
+	}
+	if rems == 0 {
+		return 0, nil
+	}
+	// 和 Redis 一样，集合为空的时候删除 key
+	var ent *entry
+	if len(data) > 0 {
+		ent = &entry{kind: kindSet, set: data, expiresAt: old.expiresAt}
+	}
+	if err = c.put(key, ent); err != nil {
+		return 0, err
+	}
+	return rems, nil
+}
+
+func (c *Cache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.incrBy(key, value)
+}
+
+func (c *Cache) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.incrBy(key, -value)
+}
+
+func (c *Cache) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	old, err := c.counterOf(key)
+	if err != nil {
+		return 0, err
+	}
+	var cur float64
+	var expiresAt time.Time
+	if old != nil {
+		if cur, err = strconv.ParseFloat(old.str, 64); err != nil {
+			return 0, errOnlyNumCanIncr
+		}
+		expiresAt = old.expiresAt
+	}
+	res := cur + value
+	str := strconv.FormatFloat(res, 'f', -1, 64)
+	if err = c.put(key, &entry{kind: kindString, str: str, expiresAt: expiresAt}); err != nil {
+		return 0, err
+	}
+	return res, nil
+}
+
+// incrBy 修改计数器，保留原来的过期时间
+func (c *Cache) incrBy(key string, value int64) (int64, error) {
+	old, err := c.counterOf(key)
+	if err != nil {
+		return 0, err
+	}
+	var cur int64
+	var expiresAt time.Time
+	if old != nil {
+		if cur, err = strconv.ParseInt(old.str, 10, 64); err != nil {
+			return 0, errOnlyNumCanIncr
+		}
+		expiresAt = old.expiresAt
+	}
+	res := cur + value
+	if err = c.put(key, &entry{kind: kindString, str: strconv.FormatInt(res, 10), expiresAt: expiresAt}); err != nil {
+		return 0, err
+	}
+	return res, nil
+}
+
+// counterOf 返回 key 对应的计数器，key 不存在的时候返回 nil
+func (c *Cache) counterOf(key string) (*entry, error) {
+	if err := c.checkClosed(); err != nil {
+		return nil, err
+	}
+	ent := c.getEntry(key)
+	if ent != nil && ent.kind != kindString {
+		return nil, errOnlyNumCanIncr
+	}
+	return ent, nil
+}
+
+func copySet(src map[string]struct{}, extra int) map[string]struct{} {
+	res := make(map[string]struct{}, len(src)+extra)
+	for member := range src {
+		res[member] = struct{}{}
+	}
+	return res
+}
+
+func toStrings(vals []any) ([]string, error) {
+	res := make([]string, 0, len(vals))
+	for _, val := range vals {
+		str, err := resp.String(val)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, str)
+	}
+	return res, nil
+}
+
+func stored(err error) (bool, error) {
+	return err == nil, err
+}
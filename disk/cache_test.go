@@ -0,0 +1,487 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCache(t *testing.T) (*Cache, string) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	c, err := NewCache(path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	return c, path
+}
+
+// reopen 模拟重启，关闭之后重新打开同一个日志文件
+func reopen(t *testing.T, c *Cache, path string) *Cache {
+	require.NoError(t, c.Close())
+	res, err := NewCache(path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = res.Close()
+	})
+	return res
+}
+
+func TestCache_Set(t *testing.T) {
+	c, path := newTestCache(t)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "name", "大明", time.Minute))
+	require.NoError(t, c.Set(ctx, "age", 18, 0))
+	require.NoError(t, c.Set(ctx, "expired", "大明", time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	val := c.Get(ctx, "name")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "大明", val.Val)
+	assert.True(t, c.Get(ctx, "expired").KeyNotFound())
+
+	c = reopen(t, c, path)
+	assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+	assert.Equal(t, "18", c.Get(ctx, "age").Val)
+	assert.True(t, c.Get(ctx, "expired").KeyNotFound())
+	assert.InDelta(t, time.Minute, time.Until(c.data["name"].expiresAt), float64(time.Second))
+	assert.True(t, c.data["age"].expiresAt.IsZero())
+}
+
+func TestCache_ExpireAfterRestart(t *testing.T) {
+	c, path := newTestCache(t)
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "name", "大明", 50*time.Millisecond))
+
+	c = reopen(t, c, path)
+	assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, c.Get(ctx, "name").KeyNotFound())
+
+	// 过期的 key 在重启的时候同样会被丢弃
+	c = reopen(t, c, path)
+	assert.True(t, c.Get(ctx, "name").KeyNotFound())
+	assert.Empty(t, c.data)
+}
+
+func TestCache_SetNX(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	ok, err := c.SetNX(ctx, "name", "大明", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = c.SetNX(ctx, "name", "小明", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+
+	require.NoError(t, c.Set(ctx, "expired", "大明", time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+	ok, err = c.SetNX(ctx, "expired", "小明", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestCache_SetXX(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	ok, err := c.SetXX(ctx, "name", "大明", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.True(t, c.Get(ctx, "name").KeyNotFound())
+
+	require.NoError(t, c.Set(ctx, "name", "大明", time.Minute))
+	ok, err = c.SetXX(ctx, "name", "小明", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "小明", c.Get(ctx, "name").Val)
+}
+
+func TestCache_CompareAndSwap(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	ok, err := c.CompareAndSwap(ctx, "age", 18, 19, 0)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "age", 18, 0))
+	ok, err = c.CompareAndSwap(ctx, "age", 17, 19, 0)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	// 比较的是转换为字符串之后的值
+	ok, err = c.CompareAndSwap(ctx, "age", "18", 19, 0)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "19", c.Get(ctx, "age").Val)
+
+	_, err = c.LPush(ctx, "list", 1)
+	require.NoError(t, err)
+	ok, err = c.CompareAndSwap(ctx, "list", 1, 2, 0)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestCache_GetSet(t *testing.T) {
+	c, path := newTestCache(t)
+	ctx := context.Background()
+
+	val := c.GetSet(ctx, "name", "大明")
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+	assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+
+	require.NoError(t, c.Set(ctx, "name", "大明", time.Minute))
+	val = c.GetSet(ctx, "name", "小明")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "大明", val.Val)
+
+	_, err := c.SAdd(ctx, "set", 1)
+	require.NoError(t, err)
+	assert.Equal(t, errOnlyStringCanGet, c.GetSet(ctx, "set", "大明").Err)
+	assert.Equal(t, errOnlyStringCanGet, c.Get(ctx, "set").Err)
+
+	// 和 Redis 一样，GetSet 之后 key 永不过期
+	c = reopen(t, c, path)
+	assert.Equal(t, "小明", c.Get(ctx, "name").Val)
+	assert.True(t, c.data["name"].expiresAt.IsZero())
+}
+
+func TestCache_Delete(t *testing.T) {
+	c, path := newTestCache(t)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "name", "大明", 0))
+	require.NoError(t, c.Set(ctx, "age", 18, 0))
+	require.NoError(t, c.Set(ctx, "expired", "大明", time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	n, err := c.Delete(ctx, "name", "expired", "not-exist")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	c = reopen(t, c, path)
+	assert.True(t, c.Get(ctx, "name").KeyNotFound())
+	assert.Equal(t, "18", c.Get(ctx, "age").Val)
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.Delete(cancelCtx, "age")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestCache_List(t *testing.T) {
+	c, path := newTestCache(t)
+	ctx := context.Background()
+
+	n, err := c.LPush(ctx, "list", 1, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	n, err = c.LPush(ctx, "list", 4)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+
+	c = reopen(t, c, path)
+	// 列表是 [4 3 2 1]
+	assert.Equal(t, "4", c.LPop(ctx, "list").Val)
+	assert.Equal(t, "1", c.RPopLPush(ctx, "list", "other").Val)
+	assert.Equal(t, "3", c.LMove(ctx, "list", "other", ecache.ListLeft, ecache.ListRight).Val)
+
+	c = reopen(t, c, path)
+	assert.Equal(t, []string{"2"}, c.data["list"].list)
+	assert.Equal(t, []string{"1", "3"}, c.data["other"].list)
+
+	// 列表为空的时候删除 key
+	assert.Equal(t, "2", c.LPop(ctx, "list").Val)
+	assert.True(t, c.LPop(ctx, "list").KeyNotFound())
+	_, ok := c.data["list"]
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "name", "大明", 0))
+	_, err = c.LPush(ctx, "name", 1)
+	assert.Equal(t, errOnlyListCanList, err)
+	assert.Equal(t, errOnlyListCanList, c.LPop(ctx, "name").Err)
+	// destination 类型不对的时候不会修改 source
+	assert.Equal(t, errOnlyListCanList, c.LMove(ctx, "other", "name", ecache.ListLeft, ecache.ListLeft).Err)
+	assert.Equal(t, []string{"1", "3"}, c.data["other"].list)
+}
+
+func TestCache_LPushEmpty(t *testing.T) {
+	c, path := newTestCache(t)
+	ctx := context.Background()
+
+	n, err := c.LPush(ctx, "list")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.NotContains(t, c.data, "list")
+	assert.True(t, c.LPop(ctx, "list").KeyNotFound())
+
+	_, err = c.LPush(ctx, "list", "a")
+	require.NoError(t, err)
+	n, err = c.LPush(ctx, "list")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	// 没有写入任何记录
+	c = reopen(t, c, path)
+	assert.Equal(t, 0, c.garbage)
+}
+
+func TestCache_ListKeepExpiration(t *testing.T) {
+	c, path := newTestCache(t)
+	ctx := context.Background()
+
+	_, err := c.LPush(ctx, "list", 1, 2)
+	require.NoError(t, err)
+	// ecache.Cache 没有单独设置过期时间的方法，直接修改内存中的数据
+	expiresAt := time.Now().Add(time.Minute)
+	c.data["list"].expiresAt = expiresAt
+	assert.Equal(t, "2", c.LPop(ctx, "list").Val)
+	c = reopen(t, c, path)
+	assert.True(t, expiresAt.Equal(c.data["list"].expiresAt))
+}
+
+func TestCache_LRem(t *testing.T) {
+	testCases := []struct {
+		name  string
+		count int64
+
+		wantN    int64
+		wantList []string
+	}{
+		{
+			name:     "remove from head",
+			count:    2,
+			wantN:    2,
+			wantList: []string{"b", "b", "a", "b"},
+		},
+		{
+			name:     "remove from tail",
+			count:    -2,
+			wantN:    2,
+			wantList: []string{"a", "b", "b", "b"},
+		},
+		{
+			name:     "remove all",
+			count:    0,
+			wantN:    3,
+			wantList: []string{"b", "b", "b"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, path := newTestCache(t)
+			ctx := context.Background()
+			// 列表是 [a b a b a b]
+			_, err := c.LPush(ctx, "list", "b", "a", "b", "a", "b", "a")
+			require.NoError(t, err)
+			n, err := c.LRem(ctx, "list", tc.count, "a")
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantN, n)
+
+			c = reopen(t, c, path)
+			assert.Equal(t, tc.wantList, c.data["list"].list)
+		})
+	}
+}
+
+func TestCache_LRemNotExist(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	n, err := c.LRem(ctx, "list", 0, "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	_, err = c.LPush(ctx, "list", "a")
+	require.NoError(t, err)
+	n, err = c.LRem(ctx, "list", 0, "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, ok := c.data["list"]
+	assert.False(t, ok)
+}
+
+func TestCache_BLPop(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	val := c.BLPop(ctx, "list", 10*time.Millisecond)
+	assert.True(t, val.KeyNotFound())
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = c.LPush(ctx, "list", "大明")
+	}()
+	val = c.BLPop(ctx, "list", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "大明", val.Val)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = c.LPush(ctx, "source", "a", "b")
+	}()
+	val = c.BRPop(ctx, "source", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	val = c.BLMove(ctx, "source", "destination", ecache.ListLeft, ecache.ListLeft, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "b", val.Val)
+	assert.Equal(t, []string{"b"}, c.data["destination"].list)
+}
+
+func TestCache_Set_Members(t *testing.T) {
+	c, path := newTestCache(t)
+	ctx := context.Background()
+
+	n, err := c.SRem(ctx, "set", 1)
+	assert.Equal(t, errs.ErrKeyNotExist, err)
+	assert.Equal(t, int64(0), n)
+
+	n, err = c.SAdd(ctx, "set", 1, 2, "2", 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	n, err = c.SAdd(ctx, "set", 3, 4)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	c = reopen(t, c, path)
+	n, err = c.SRem(ctx, "set", 1, 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	c = reopen(t, c, path)
+	assert.Equal(t, map[string]struct{}{"2": {}, "3": {}, "4": {}}, c.data["set"].set)
+
+	n, err = c.SRem(ctx, "set", 2, 3, 4)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	_, ok := c.data["set"]
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "name", "大明", 0))
+	_, err = c.SAdd(ctx, "name", 1)
+	assert.Equal(t, errOnlySetCanSet, err)
+	_, err = c.SRem(ctx, "name", 1)
+	assert.Equal(t, errOnlySetCanSet, err)
+}
+
+func TestCache_Counter(t *testing.T) {
+	c, path := newTestCache(t)
+	ctx := context.Background()
+
+	res, err := c.IncrBy(ctx, "counter", 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), res)
+	res, err = c.DecrBy(ctx, "counter", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-3), res)
+	res, err = c.DecrBy(ctx, "new-counter", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-5), res)
+
+	f, err := c.IncrByFloat(ctx, "float", 1.5)
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+	f, err = c.IncrByFloat(ctx, "counter", 0.5)
+	require.NoError(t, err)
+	assert.Equal(t, -2.5, f)
+
+	c = reopen(t, c, path)
+	assert.Equal(t, "-2.5", c.Get(ctx, "counter").Val)
+	assert.Equal(t, "1.5", c.Get(ctx, "float").Val)
+	_, err = c.IncrBy(ctx, "counter", 1)
+	assert.Equal(t, errOnlyNumCanIncr, err)
+
+	require.NoError(t, c.Set(ctx, "name", "大明", 0))
+	_, err = c.IncrBy(ctx, "name", 1)
+	assert.Equal(t, errOnlyNumCanIncr, err)
+	_, err = c.IncrByFloat(ctx, "name", 1)
+	assert.Equal(t, errOnlyNumCanIncr, err)
+	_, err = c.LPush(ctx, "list", 1)
+	require.NoError(t, err)
+	_, err = c.IncrBy(ctx, "list", 1)
+	assert.Equal(t, errOnlyNumCanIncr, err)
+}
+
+func TestCache_CounterKeepExpiration(t *testing.T) {
+	c, path := newTestCache(t)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "counter", 1, time.Minute))
+	expiresAt := c.data["counter"].expiresAt
+	_, err := c.IncrBy(ctx, "counter", 1)
+	require.NoError(t, err)
+	_, err = c.IncrByFloat(ctx, "counter", 1)
+	require.NoError(t, err)
+
+	c = reopen(t, c, path)
+	assert.Equal(t, "3", c.Get(ctx, "counter").Val)
+	assert.True(t, expiresAt.Equal(c.data["counter"].expiresAt))
+}
+
+func TestCache_CloseWakeWaiters(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	results := make(chan error, 2)
+	go func() {
+		results <- c.BLPop(ctx, "list", 0).Err
+	}()
+	go func() {
+		results <- c.BLMove(ctx, "source", "destination", ecache.ListLeft, ecache.ListLeft, 0).Err
+	}()
+	// 等待两个调用者进入阻塞
+	time.Sleep(time.Millisecond * 50)
+
+	require.NoError(t, c.Close())
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-results:
+			assert.Equal(t, errs.ErrCacheClosed, err)
+		case <-time.After(time.Second):
+			t.Fatal("关闭之后阻塞的调用者没有返回")
+		}
+	}
+}
+
+func TestCache_Closed(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+	require.NoError(t, c.Close())
+	require.NoError(t, c.Close())
+
+	assert.Equal(t, errs.ErrCacheClosed, c.Set(ctx, "name", "大明", 0))
+	_, err := c.SetNX(ctx, "name", "大明", 0)
+	assert.Equal(t, errs.ErrCacheClosed, err)
+	assert.Equal(t, errs.ErrCacheClosed, c.Get(ctx, "name").Err)
+	_, err = c.LPush(ctx, "list", 1)
+	assert.Equal(t, errs.ErrCacheClosed, err)
+	assert.Equal(t, errs.ErrCacheClosed, c.BLPop(ctx, "list", time.Second).Err)
+	_, err = c.SAdd(ctx, "set", 1)
+	assert.Equal(t, errs.ErrCacheClosed, err)
+	_, err = c.IncrBy(ctx, "counter", 1)
+	assert.Equal(t, errs.ErrCacheClosed, err)
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package disk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCache_Locked 同一个日志文件不能同时被打开两次
+func TestCache_Locked(t *testing.T) {
+	c, path := newTestCache(t)
+	_, err := NewCache(path)
+	assert.Equal(t, ErrLocked, err)
+
+	// 关闭之后锁就被释放了
+	c = reopen(t, c, path)
+	require.NoError(t, c.Set(context.Background(), "name", "大明", 0))
+}
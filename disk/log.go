@@ -0,0 +1,250 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// headerSize 每条记录的头部，前 4 个字节是内容的长度，后 4 个字节是内容的 crc32
+const headerSize = 8
+
+// maxRecordSize 单条记录的上限，超过的长度只可能是写坏了的数据
+const maxRecordSize = 1 << 30
+
+// errTorn 记录没有写完，文件就结束了
+var errTorn = errors.New("ecache: 日志记录没有写完")
+
+// record 日志中的一条记录，保存的是 key 修改之后的完整状态，而不是修改的操作，
+// 所以重放的时候不依赖重放的时间，同一个 key 只有最后一条记录有效
+type record struct {
+	Key string `json:"k"`
+	// Deleted 为 true 表示 key 被删除了
+	Deleted bool     `json:"d,omitempty"`
+	Kind    kind     `json:"t,omitempty"`
+	Str     string   `json:"s,omitempty"`
+	List    []string `json:"l,omitempty"`
+	Set     []string `json:"m,omitempty"`
+	// ExpiresAt 过期时间的 unix 纳秒，0 表示永不过期
+	ExpiresAt int64 `json:"e,omitempty"`
+}
+
+func newRecord(key string, ent *entry) record {
+	if ent == nil {
+		return record{Key: key, Deleted: true}
+	}
+	rec := record{Key: key, Kind: ent.kind, Str: ent.str, List: ent.list}
+	if len(ent.set) > 0 {
+		rec.Set = make([]string, 0, len(ent.set))
+		for member := range ent.set {
+			rec.Set = append(rec.Set, member)
+		}
+	}
+	if !ent.expiresAt.IsZero() {
+		rec.ExpiresAt = ent.expiresAt.UnixNano()
+	}
+	return rec
+}
+
+// entry 把记录转换为内存中的数据，删除记录返回 nil
+func (r record) entry() *entry {
+	if r.Deleted {
+		return nil
+	}
+	ent := &entry{kind: r.Kind, str: r.Str, list: r.List}
+	if r.Kind == kindSet {
+		ent.set = make(map[string]struct{}, len(r.Set))
+		for _, member := range r.Set {
+			ent.set[member] = struct{}{}
+		}
+	}
+	if r.ExpiresAt != 0 {
+		ent.expiresAt = time.Unix(0, r.ExpiresAt)
+	}
+	return ent
+}
+
+func encodeRecord(rec record) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, headerSize+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(payload))
+	copy(buf[headerSize:], payload)
+	return buf, nil
+}
+
+// readRecord 读取一条记录，返回记录占用的字节数，读到了头部的时候即使出错也会返回记录声明的长度。
+// 文件正好结束的时候返回 io.EOF，记录没有写完文件就结束了返回 errTorn，校验失败的记录返回 ErrCorrupted
+func readRecord(r io.Reader) (record, int64, error) {
+	var rec record
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return rec, 0, io.EOF
+		}
+		return rec, 0, torn(err)
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > maxRecordSize {
+		// 长度本身就是坏的，没有办法知道记录到哪里结束，只算头部
+		return rec, headerSize, ErrCorrupted
+	}
+	n := int64(headerSize) + int64(size)
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return rec, n, torn(err)
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return rec, n, ErrCorrupted
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, n, ErrCorrupted
+	}
+	return rec, n, nil
+}
+
+// torn 写到一半崩溃的时候文件末尾只有一部分记录
+func torn(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errTorn
+	}
+	return err
+}
+
+// replay 从头读取日志，依次把记录交给 fn，返回最后一条完整记录结束的位置。
+// 只有文件末尾的记录允许损坏，它是崩溃的时候没有写完的数据，例如只写了一部分或者只有部分扇区落盘了；
+// 损坏的记录后面还有数据的话说明文件中间被破坏了，直接截断会丢失后面有效的记录，所以返回 ErrCorrupted
+func replay(f *os.File, fn func(rec record)) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		rec, n, err := readRecord(r)
+		switch {
+		case err == nil:
+			fn(rec)
+			offset += n
+		case errors.Is(err, io.EOF), errors.Is(err, errTorn):
+			return offset, nil
+		case errors.Is(err, ErrCorrupted) && offset+n >= info.Size():
+			return offset, nil
+		case errors.Is(err, ErrCorrupted):
+			return 0, fmt.Errorf("%w: 位置 %d", ErrCorrupted, offset)
+		default:
+			return 0, err
+		}
+	}
+}
+
+// openLog 打开日志文件并且重放，截掉末尾损坏的部分，之后的写入追加到文件末尾
+func openLog(path string, fn func(rec record)) (*os.File, int64, error) {
+	// 上一次压缩没有完成就崩溃了，原来的日志依旧完整，临时文件直接丢弃
+	if err := os.Remove(compactPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, 0, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset, err := replay(f, fn)
+	if err == nil {
+		err = f.Truncate(offset)
+	}
+	if err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, err
+	}
+	return f, offset, nil
+}
+
+// writeSnapshot 把所有的记录写入一个新的日志文件，然后原子地替换掉 path。
+// 在替换之前崩溃的话，原来的日志不受影响。替换成功之后返回的文件不为 nil，即使 error 不为 nil
+func writeSnapshot(path string, records func(fn func(rec record) error) error) (*os.File, int64, error) {
+	tmp := compactPath(path)
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, 0, err
+	}
+	var size int64
+	w := bufio.NewWriter(f)
+	err = records(func(rec record) error {
+		buf, er := encodeRecord(rec)
+		if er != nil {
+			return er
+		}
+		size += int64(len(buf))
+		_, er = w.Write(buf)
+		return er
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return nil, 0, err
+	}
+	// 确保重命名本身也已经落盘。重命名已经成功了，原来的日志已经被替换掉了，
+	// 所以即使失败也要返回新的文件，否则之后的写入会追加到已经被删除的旧文件上
+	return f, size, syncDir(filepath.Dir(path))
+}
+
+// syncDir 把目录的修改落盘，测试的时候会替换它来模拟失败
+var syncDir = func(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if er := d.Close(); err == nil {
+		err = er
+	}
+	return err
+}
+
+func compactPath(path string) string {
+	return path + ".compact"
+}
+
+func lockPath(path string) string {
+	return path + ".lock"
+}
@@ -0,0 +1,279 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord_Encode(t *testing.T) {
+	testCases := []struct {
+		name string
+		rec  record
+	}{
+		{
+			name: "string",
+			rec:  record{Key: "name", Str: "大明", ExpiresAt: time.Now().Add(time.Minute).UnixNano()},
+		},
+		{
+			name: "list",
+			rec:  record{Key: "list", Kind: kindList, List: []string{"a", "b"}},
+		},
+		{
+			name: "set",
+			rec:  record{Key: "set", Kind: kindSet, Set: []string{"a"}},
+		},
+		{
+			name: "deleted",
+			rec:  record{Key: "name", Deleted: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf, err := encodeRecord(tc.rec)
+			require.NoError(t, err)
+			rec, n, err := readRecord(bytes.NewReader(buf))
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(buf)), n)
+			assert.Equal(t, tc.rec, rec)
+			assert.Equal(t, newRecord(tc.rec.Key, tc.rec.entry()), rec)
+		})
+	}
+}
+
+func TestReadRecord_Corrupted(t *testing.T) {
+	buf, err := encodeRecord(record{Key: "name", Str: "大明"})
+	require.NoError(t, err)
+
+	_, _, err = readRecord(bytes.NewReader(nil))
+	assert.Equal(t, io.EOF, err)
+
+	// 任意位置截断都是写到一半的记录
+	for i := 1; i < len(buf); i++ {
+		_, _, err = readRecord(bytes.NewReader(buf[:i]))
+		assert.Equal(t, errTorn, err, "truncated at %d", i)
+	}
+
+	// 内容被修改了，校验失败，但是依旧能够知道记录的长度
+	broken := append([]byte{}, buf...)
+	broken[len(broken)-2] ^= 0xff
+	_, n, err := readRecord(bytes.NewReader(broken))
+	assert.Equal(t, ErrCorrupted, err)
+	assert.Equal(t, int64(len(buf)), n)
+}
+
+// writeLog 写入一些数据之后关闭缓存，返回日志的内容
+func writeLog(t *testing.T, path string) []byte {
+	c, err := NewCache(path)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "name", "大明", 0))
+	_, err = c.LPush(ctx, "list", "a", "b")
+	require.NoError(t, err)
+	_, err = c.IncrBy(ctx, "counter", 3)
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
+
+func TestCache_RecoverTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	data := writeLog(t, path)
+	last, err := encodeRecord(record{Key: "counter", Str: "3"})
+	require.NoError(t, err)
+	complete := len(data) - len(last)
+
+	// 在最后一条记录的任意位置崩溃，之前的数据都能恢复，最后一条记录被丢弃
+	for i := complete; i < len(data); i++ {
+		require.NoError(t, os.WriteFile(path, data[:i], 0o644))
+		c, err := NewCache(path)
+		require.NoError(t, err)
+		ctx := context.Background()
+		assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+		assert.Equal(t, "b", c.LPop(ctx, "list").Val)
+		assert.True(t, c.Get(ctx, "counter").KeyNotFound())
+
+		// 损坏的部分被截掉了，之后的写入在重启之后依旧有效
+		_, err = c.IncrBy(ctx, "counter", 1)
+		require.NoError(t, err)
+		require.NoError(t, c.Close())
+
+		c, err = NewCache(path)
+		require.NoError(t, err)
+		assert.Equal(t, "1", c.Get(ctx, "counter").Val)
+		assert.Equal(t, "a", c.LPop(ctx, "list").Val)
+		require.NoError(t, c.Close())
+	}
+}
+
+func TestCache_RecoverCorruptedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	data := writeLog(t, path)
+	// 最后一条记录的内容损坏了，例如掉电的时候只有部分扇区写入成功
+	data[len(data)-2] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	c, err := NewCache(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = c.Close()
+	}()
+	ctx := context.Background()
+	assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+	assert.True(t, c.Get(ctx, "counter").KeyNotFound())
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, c.size, info.Size())
+}
+
+func TestCache_CorruptedInTheMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	data := writeLog(t, path)
+	// 第一条记录损坏了，后面还有有效的记录，不能直接截断
+	data[headerSize+2] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	_, err := NewCache(path)
+	assert.ErrorIs(t, err, ErrCorrupted)
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, data, after)
+
+	// 长度被写坏了，同样不能截断
+	require.NoError(t, os.Remove(path))
+	data = writeLog(t, path)
+	data[0] = 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	_, err = NewCache(path)
+	assert.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestCache_CompactSyncDirFailed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	c, err := NewCache(path)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "name", "大明", 0))
+
+	old := syncDir
+	syncDir = func(dir string) error {
+		return errors.New("mock error")
+	}
+	err = c.Compact()
+	syncDir = old
+	assert.Equal(t, errors.New("mock error"), err)
+
+	// 重命名已经成功了，之后的写入必须写到新的日志上
+	require.NoError(t, c.Set(ctx, "age", 18, 0))
+	require.NoError(t, c.Close())
+	c, err = NewCache(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = c.Close()
+	}()
+	assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+	assert.Equal(t, "18", c.Get(ctx, "age").Val)
+}
+
+func TestCache_RecoverInterruptedCompaction(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.log")
+	data := writeLog(t, path)
+	// 压缩到一半崩溃，只留下了写了一半的临时文件
+	require.NoError(t, os.WriteFile(compactPath(path), data[:len(data)/2], 0o644))
+
+	c, err := NewCache(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = c.Close()
+	}()
+	ctx := context.Background()
+	assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+	assert.Equal(t, "3", c.Get(ctx, "counter").Val)
+	_, err = os.Stat(compactPath(path))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCache_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	c, err := NewCache(path)
+	require.NoError(t, err)
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		_, err = c.IncrBy(ctx, "counter", 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, c.Set(ctx, "expired", "大明", time.Millisecond))
+	require.NoError(t, c.Set(ctx, "name", "大明", time.Minute))
+	time.Sleep(10 * time.Millisecond)
+
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, c.Compact())
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, after.Size(), before.Size())
+	assert.Equal(t, 0, c.garbage)
+
+	// 压缩之后的写入追加在新的日志上
+	_, err = c.IncrBy(ctx, "counter", 1)
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+	assert.Equal(t, errs.ErrCacheClosed, c.Compact())
+
+	c, err = NewCache(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = c.Close()
+	}()
+	assert.Equal(t, "101", c.Get(ctx, "counter").Val)
+	assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+	assert.True(t, c.Get(ctx, "expired").KeyNotFound())
+	assert.Len(t, c.data, 2)
+}
+
+func TestCache_AutoCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	c, err := NewCache(path, WithCompactThreshold(10))
+	require.NoError(t, err)
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		_, err = c.IncrBy(ctx, "counter", 1)
+		require.NoError(t, err)
+		assert.Less(t, c.garbage, 10)
+	}
+	require.NoError(t, c.Close())
+
+	c, err = NewCache(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = c.Close()
+	}()
+	assert.Equal(t, "100", c.Get(ctx, "counter").Val)
+	assert.Less(t, c.garbage, 10)
+}
@@ -13,3 +13,20 @@
 // limitations under the License.
 
 package ecache
+
+import "github.com/ecodeclub/ecache/internal/errs"
+
+var (
+	// ErrTxConflict 事务执行的时候，被监视的 key 已经被其他人修改了
+	ErrTxConflict = errs.ErrTxConflict
+	// ErrNotSupported 当前的实现不支持该操作
+	ErrNotSupported = errs.ErrNotSupported
+	// ErrSubscriptionClosed 订阅已经关闭了
+	ErrSubscriptionClosed = errs.ErrSubscriptionClosed
+	// ErrStreamGroupExist 创建消费者组的时候，消费者组已经存在了
+	ErrStreamGroupExist = errs.ErrStreamGroupExist
+	// ErrStreamGroupNotExist 消费者组不存在，需要先通过 XGroupCreate 创建
+	ErrStreamGroupNotExist = errs.ErrStreamGroupNotExist
+	// ErrInvalidSoftTTL SetWithSoftTTL 的软过期时间大于等于硬过期时间
+	ErrInvalidSoftTTL = errs.ErrInvalidSoftTTL
+)
@@ -0,0 +1,79 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecache
+
+import "context"
+
+// GeoCache 支持地理位置的缓存。
+// 和 Redis 一样，坐标被编码为 52 位的 geohash 保存，所以读出来的坐标和写入的坐标会有微小的误差
+type GeoCache interface {
+	Cache
+	// GeoAdd 添加或者更新成员的位置，返回新添加的成员的数量。
+	// 经度的范围是 [-180, 180]，纬度的范围是 [-85.05112878, 85.05112878]
+	GeoAdd(ctx context.Context, key string, locations ...GeoLocation) (int64, error)
+	// GeoDist 返回两个成员之间的距离，任何一个成员不存在的时候返回 errs.ErrKeyNotExist
+	GeoDist(ctx context.Context, key string, member1, member2 string, unit GeoUnit) (float64, error)
+	// GeoSearch 查找圆形或者矩形区域内的成员，返回的 GeoLocation 中包含坐标以及到中心点的距离。
+	// key 不存在的时候返回空切片，以成员为中心但是成员不存在的时候返回 errs.ErrKeyNotExist
+	GeoSearch(ctx context.Context, key string, query GeoSearchQuery) ([]GeoLocation, error)
+}
+
+// GeoLocation 成员的位置
+type GeoLocation struct {
+	Member    string
+	Longitude float64
+	Latitude  float64
+	// Dist 到查询中心点的距离，单位和查询的单位一致，只有 GeoSearch 会返回
+	Dist float64
+}
+
+// GeoUnit 距离的单位
+type GeoUnit string
+
+const (
+	GeoUnitM  GeoUnit = "m"
+	GeoUnitKM GeoUnit = "km"
+	GeoUnitMI GeoUnit = "mi"
+	GeoUnitFT GeoUnit = "ft"
+)
+
+// GeoSort 查询结果的排序方式
+type GeoSort string
+
+const (
+	// GeoSortNone 不排序，但是指定了 Count 的时候和 Redis 一样按照距离从近到远排序
+	GeoSortNone GeoSort = ""
+	GeoSortAsc  GeoSort = "ASC"
+	GeoSortDesc GeoSort = "DESC"
+)
+
+// GeoSearchQuery GeoSearch 的查询条件
+type GeoSearchQuery struct {
+	// Member 以成员的位置为中心，为空的时候以 Longitude 和 Latitude 为中心
+	Member    string
+	Longitude float64
+	Latitude  float64
+
+	// Radius 大于 0 的时候查找圆形区域，否则查找宽为 Width、高为 Height 的矩形区域
+	Radius float64
+	Width  float64
+	Height float64
+	// Unit Radius、Width、Height 以及返回的距离的单位，默认为米
+	Unit GeoUnit
+
+	Sort GeoSort
+	// Count 最多返回多少个成员，0 表示不限制
+	Count int
+}
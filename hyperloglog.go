@@ -0,0 +1,29 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecache
+
+import "context"
+
+// HyperLogLogCache 支持 HyperLogLog 的缓存，用固定大小的内存估算集合的基数，标准误差约为 0.81%。
+// 元素按照 Redis 的规则转换为字符串之后参与计算
+type HyperLogLogCache interface {
+	Cache
+	// PFAdd 添加元素，key 不存在的时候创建。有寄存器发生变化，也就是估算的基数可能变化的时候返回 1，否则返回 0
+	PFAdd(ctx context.Context, key string, els ...any) (int64, error)
+	// PFCount 返回所有 key 的并集的基数估算值，不存在的 key 当作空集
+	PFCount(ctx context.Context, keys ...string) (int64, error)
+	// PFMerge 把 keys 合并到 dest 中，dest 已经存在的时候它原本的元素也会保留
+	PFMerge(ctx context.Context, dest string, keys ...string) error
+}
@@ -0,0 +1,215 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bitmap 本地缓存使用的位图操作，语义和 Redis 保持一致
+package bitmap
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/ecodeclub/ecache"
+)
+
+// maxOffset 和 Redis 一样，位图最大 512MB
+const maxOffset = 1<<32 - 1
+
+var (
+	ErrInvalidOffset = errors.New("ecache: offset 必须在 [0, 2^32) 之间")
+	ErrInvalidBit    = errors.New("ecache: bit 只能是 0 或者 1")
+	ErrInvalidOp     = errors.New("ecache: 不支持的位运算，或者 NOT 的 key 不是一个")
+	ErrInvalidPos    = errors.New("ecache: BitPos 最多只能指定开始和结束两个位置")
+)
+
+// Bytes 把缓存中的值转换为位图，只有 string 和 []byte 可以当作位图
+func Bytes(val any) ([]byte, bool) {
+	switch v := val.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	default:
+		return nil, false
+	}
+}
+
+// CheckOffset 检查 offset 是否合法
+func CheckOffset(offset int64) error {
+	if offset < 0 || offset > maxOffset {
+		return ErrInvalidOffset
+	}
+	return nil
+}
+
+// CheckBit 检查位的值是否合法
+func CheckBit(bit int64) error {
+	if bit != 0 && bit != 1 {
+		return ErrInvalidBit
+	}
+	return nil
+}
+
+// GetBit 返回 offset 位置的位，offset 为 0 的位是第一个字节的最高位
+func GetBit(data []byte, offset int64) int64 {
+	idx := offset >> 3
+	if idx >= int64(len(data)) {
+		return 0
+	}
+	return int64(data[idx]>>(7-offset&7)) & 1
+}
+
+// SetBit 设置 offset 位置的位，返回新的位图和原本的值。长度不够的时候在后面补 0。
+// data 可能是调用方通过 Set 写入的切片，也可能已经通过 Get 返回给了调用方，所以总是修改一份副本
+func SetBit(data []byte, offset int64, value int) ([]byte, int64) {
+	idx := offset >> 3
+	size := int64(len(data))
+	if idx >= size {
+		size = idx + 1
+	}
+	res := make([]byte, size)
+	copy(res, data)
+	data = res
+	old := GetBit(data, offset)
+	mask := byte(1) << (7 - offset&7)
+	if value == 1 {
+		data[idx] |= mask
+	} else {
+		data[idx] &^= mask
+	}
+	return data, old
+}
+
+// Count 统计 [start, end] 字节之间 1 的数量
+func Count(data []byte, start, end int64) int64 {
+	start, end, ok := byteRange(int64(len(data)), start, end)
+	if !ok {
+		return 0
+	}
+	var cnt int
+	for _, b := range data[start : end+1] {
+		cnt += bits.OnesCount8(b)
+	}
+	return int64(cnt)
+}
+
+// Op 执行位运算，srcs 中的 nil 表示 key 不存在
+func Op(op ecache.BitOperation, srcs [][]byte) ([]byte, error) {
+	if op == ecache.BitOpNot {
+		if len(srcs) != 1 {
+			return nil, ErrInvalidOp
+		}
+		res := make([]byte, len(srcs[0]))
+		for i, b := range srcs[0] {
+			res[i] = ^b
+		}
+		return res, nil
+	}
+	if op != ecache.BitOpAnd && op != ecache.BitOpOr && op != ecache.BitOpXor {
+		return nil, ErrInvalidOp
+	}
+
+	var length int
+	for _, src := range srcs {
+		if len(src) > length {
+			length = len(src)
+		}
+	}
+	res := make([]byte, length)
+	for i := range res {
+		var b byte
+		for j, src := range srcs {
+			var cur byte
+			if i < len(src) {
+				cur = src[i]
+			}
+			switch {
+			case j == 0:
+				b = cur
+			case op == ecache.BitOpAnd:
+				b &= cur
+			case op == ecache.BitOpOr:
+				b |= cur
+			default:
+				b ^= cur
+			}
+		}
+		res[i] = b
+	}
+	return res, nil
+}
+
+// Pos 返回第一个值为 bit 的位的位置，pos 是可选的开始和结束字节
+func Pos(data []byte, bit int64, pos ...int64) (int64, error) {
+	if err := CheckBit(bit); err != nil {
+		return 0, err
+	}
+	if len(pos) > 2 {
+		return 0, ErrInvalidPos
+	}
+	// Redis 中不存在空的位图，key 不存在的时候查找 0 返回 0，查找 1 返回 -1
+	if len(data) == 0 {
+		if bit == 0 {
+			return 0, nil
+		}
+		return -1, nil
+	}
+	start, end := int64(0), int64(len(data)-1)
+	if len(pos) > 0 {
+		start = pos[0]
+	}
+	if len(pos) > 1 {
+		end = pos[1]
+	}
+	start, end, ok := byteRange(int64(len(data)), start, end)
+	if !ok {
+		return -1, nil
+	}
+	for i := start; i <= end; i++ {
+		b := data[i]
+		if bit == 0 {
+			b = ^b
+		}
+		if b != 0 {
+			return i*8 + int64(bits.LeadingZeros8(b)), nil
+		}
+	}
+	// 查找 0 并且没有指定结束位置的时候，认为位图的右边都是 0
+	if bit == 0 && len(pos) < 2 {
+		return (end + 1) * 8, nil
+	}
+	return -1, nil
+}
+
+// byteRange 按照 Redis 的规则处理负数和越界的下标，区间为空的时候返回 false
+func byteRange(length, start, end int64) (int64, int64, bool) {
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if length == 0 || start > end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
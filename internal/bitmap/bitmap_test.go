@@ -0,0 +1,171 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bitmap
+
+import (
+	"testing"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetBit(t *testing.T) {
+	// offset 为 0 的位是第一个字节的最高位
+	data, old := SetBit(nil, 0, 1)
+	assert.Equal(t, []byte{0x80}, data)
+	assert.Equal(t, int64(0), old)
+	data, old = SetBit(data, 7, 1)
+	assert.Equal(t, []byte{0x81}, data)
+	assert.Equal(t, int64(0), old)
+	// 自动扩展
+	data, _ = SetBit(data, 17, 1)
+	assert.Equal(t, []byte{0x81, 0x00, 0x40}, data)
+	data, old = SetBit(data, 0, 0)
+	assert.Equal(t, []byte{0x01, 0x00, 0x40}, data)
+	assert.Equal(t, int64(1), old)
+
+	assert.Equal(t, int64(1), GetBit(data, 7))
+	assert.Equal(t, int64(1), GetBit(data, 17))
+	assert.Equal(t, int64(0), GetBit(data, 16))
+	assert.Equal(t, int64(0), GetBit(data, 100))
+
+	// 不修改传入的切片
+	src := []byte{0x00}
+	data, _ = SetBit(src, 0, 1)
+	assert.Equal(t, []byte{0x80}, data)
+	assert.Equal(t, []byte{0x00}, src)
+
+	assert.Equal(t, ErrInvalidOffset, CheckOffset(-1))
+	assert.Equal(t, ErrInvalidOffset, CheckOffset(1<<32))
+	assert.NoError(t, CheckOffset(1<<32-1))
+}
+
+func TestCount(t *testing.T) {
+	testCases := []struct {
+		name       string
+		data       string
+		start, end int64
+		want       int64
+	}{
+		{name: "all", data: "foobar", start: 0, end: -1, want: 26},
+		{name: "first byte", data: "foobar", start: 0, end: 0, want: 4},
+		{name: "second byte", data: "foobar", start: 1, end: 1, want: 6},
+		{name: "negative", data: "foobar", start: -2, end: -1, want: 7},
+		{name: "out of range", data: "foobar", start: -100, end: 100, want: 26},
+		{name: "start after end", data: "foobar", start: 3, end: 1, want: 0},
+		{name: "empty", data: "", start: 0, end: -1, want: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Count([]byte(tc.data), tc.start, tc.end))
+		})
+	}
+}
+
+func TestOp(t *testing.T) {
+	testCases := []struct {
+		name    string
+		op      ecache.BitOperation
+		srcs    [][]byte
+		want    []byte
+		wantErr error
+	}{
+		{
+			name: "and",
+			op:   ecache.BitOpAnd,
+			srcs: [][]byte{[]byte("foobar"), []byte("abcdef")},
+			want: []byte("`bc`ab"),
+		},
+		{
+			name: "or with shorter",
+			op:   ecache.BitOpOr,
+			srcs: [][]byte{{0x0f}, {0xf0, 0x01}},
+			want: []byte{0xff, 0x01},
+		},
+		{
+			name: "and with missing key",
+			op:   ecache.BitOpAnd,
+			srcs: [][]byte{{0xff, 0xff}, nil},
+			want: []byte{0x00, 0x00},
+		},
+		{
+			name: "xor",
+			op:   ecache.BitOpXor,
+			srcs: [][]byte{{0xff}, {0x0f}, {0x01}},
+			want: []byte{0xf1},
+		},
+		{
+			name: "not",
+			op:   ecache.BitOpNot,
+			srcs: [][]byte{{0xf0, 0x00}},
+			want: []byte{0x0f, 0xff},
+		},
+		{
+			name:    "not with two keys",
+			op:      ecache.BitOpNot,
+			srcs:    [][]byte{{0xf0}, {0x00}},
+			wantErr: ErrInvalidOp,
+		},
+		{
+			name:    "unknown",
+			op:      "NAND",
+			srcs:    [][]byte{{0xf0}},
+			wantErr: ErrInvalidOp,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := Op(tc.op, tc.srcs)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestPos(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    []byte
+		bit     int64
+		pos     []int64
+		want    int64
+		wantErr error
+	}{
+		{name: "first zero", data: []byte{0xff, 0xf0, 0x00}, bit: 0, want: 12},
+		{name: "first one from start", data: []byte{0x00, 0xff, 0xf0}, bit: 1, pos: []int64{0}, want: 8},
+		{name: "first one from byte 2", data: []byte{0x00, 0xff, 0xf0}, bit: 1, pos: []int64{2}, want: 16},
+		{name: "negative range", data: []byte{0x00, 0xff, 0xf0}, bit: 1, pos: []int64{-1, -1}, want: 16},
+		{name: "no one", data: []byte{0x00, 0x00, 0x00}, bit: 1, want: -1},
+		// 没有指定结束位置的时候，右边当作无限个 0
+		{name: "all ones", data: []byte{0xff, 0xff}, bit: 0, want: 16},
+		{name: "all ones with end", data: []byte{0xff, 0xff}, bit: 0, pos: []int64{0, -1}, want: -1},
+		{name: "empty range", data: []byte{0x00}, bit: 0, pos: []int64{2}, want: -1},
+		{name: "missing key zero", bit: 0, want: 0},
+		{name: "missing key one", bit: 1, want: -1},
+		{name: "invalid bit", data: []byte{0x00}, bit: 2, wantErr: ErrInvalidBit},
+		{name: "too many pos", data: []byte{0x00}, bit: 1, pos: []int64{0, 1, 2}, wantErr: ErrInvalidPos},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := Pos(tc.data, tc.bit, tc.pos...)
+			require.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, res)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blocking
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+)
+
+// Waiters 记录阻塞在列表上的调用者，本地缓存用它来实现 BLPop 之类的阻塞操作。
+// Waiters 和缓存共用一把锁，除了 Wait 之外的方法都必须在持有锁的时候调用
+type Waiters struct {
+	lock sync.Locker
+	// queues 每个 key 上按照先来后到的顺序排列的调用者
+	queues map[string][]chan struct{}
+}
+
+func NewWaiters(lock sync.Locker) *Waiters {
+	return &Waiters{
+		lock:   lock,
+		queues: make(map[string][]chan struct{}),
+	}
+}
+
+// Wait 在锁里面执行 pop，如果 pop 返回 ErrKeyNotExist 就等待 Notify 之后重试，
+// 直到 pop 成功、超过 timeout 或者 ctx 结束。timeout 为 0 表示只受 ctx 控制。
+// 超时返回 pop 最后一次的结果，ctx 结束返回 ctx.Err()
+func (w *Waiters) Wait(ctx context.Context, key string, timeout time.Duration, pop func() ecache.Value) ecache.Value {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		w.lock.Lock()
+		val := pop()
+		var ch chan struct{}
+		if val.KeyNotFound() {
+			ch = w.add(key)
+		}
+		w.lock.Unlock()
+		if ch == nil {
+			return val
+		}
+		select {
+		case <-ch:
+		case <-expired:
+			w.cancel(key, ch)
+			return val
+		case <-ctx.Done():
+			w.cancel(key, ch)
+			val.Err = ctx.Err()
+			return val
+		}
+	}
+}
+
+// Notify 按照先来后到的顺序唤醒最多 n 个等待 key 的调用者，一般 n 就是写入的元素个数
+func (w *Waiters) Notify(key string, n int) {
+	q, ok := w.queues[key]
+	if !ok {
+		return
+	}
+	for ; n > 0 && len(q) > 0; n-- {
+		q[0] <- struct{}{}
+		q = q[1:]
+	}
+	if len(q) == 0 {
+		delete(w.queues, key)
+		return
+	}
+	w.queues[key] = q
+}
+
+// NotifyAll 唤醒所有的调用者，一般用于缓存关闭的时候，让它们重试之后拿到关闭的错误返回
+func (w *Waiters) NotifyAll() {
+	for key, q := range w.queues {
+		for _, ch := range q {
+			ch <- struct{}{}
+		}
+		delete(w.queues, key)
+	}
+}
+
+func (w *Waiters) add(key string) chan struct{} {
+	// 缓冲为 1，Notify 的时候不会阻塞
+	ch := make(chan struct{}, 1)
+	w.queues[key] = append(w.queues[key], ch)
+	return ch
+}
+
+// cancel 放弃等待。如果在放弃之前已经被唤醒了，那么把机会让给下一个调用者，
+// 否则写入的元素会一直没有人取走
+func (w *Waiters) cancel(key string, ch chan struct{}) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	q := w.queues[key]
+	for i, c := range q {
+		if c == ch {
+			q = append(q[:i], q[i+1:]...)
+			if len(q) == 0 {
+				delete(w.queues, key)
+			} else {
+				w.queues[key] = q
+			}
+			return
+		}
+	}
+	w.Notify(key, 1)
+}
@@ -0,0 +1,185 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blocking
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queue 测试用的列表，通过 Waiters 实现阻塞读取
+type queue struct {
+	mutex   sync.Mutex
+	items   []string
+	waiters *Waiters
+}
+
+func newQueue() *queue {
+	q := &queue{}
+	q.waiters = NewWaiters(&q.mutex)
+	return q
+}
+
+func (q *queue) push(items ...string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.items = append(q.items, items...)
+	q.waiters.Notify("queue", len(items))
+}
+
+func (q *queue) pop(ctx context.Context, timeout time.Duration) ecache.Value {
+	return q.waiters.Wait(ctx, "queue", timeout, func() ecache.Value {
+		var val ecache.Value
+		if len(q.items) == 0 {
+			val.Err = errs.ErrKeyNotExist
+			return val
+		}
+		val.Val = q.items[0]
+		q.items = q.items[1:]
+		return val
+	})
+}
+
+func (q *queue) waiting() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.waiters.queues["queue"])
+}
+
+func TestWaiters_Wait(t *testing.T) {
+	ctx := context.Background()
+	q := newQueue()
+
+	// 有数据的时候不会阻塞
+	q.push("a")
+	val := q.pop(ctx, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+
+	// 超时
+	val = q.pop(ctx, time.Millisecond*10)
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+	assert.Equal(t, 0, q.waiting())
+
+	// ctx 结束
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	val = q.pop(tctx, 0)
+	assert.Equal(t, context.DeadlineExceeded, val.Err)
+	assert.Equal(t, 0, q.waiting())
+
+	// 其他错误直接返回
+	val = q.waiters.Wait(ctx, "queue", 0, func() ecache.Value {
+		var val ecache.Value
+		val.Err = errors.New("mock error")
+		return val
+	})
+	assert.Equal(t, errors.New("mock error"), val.Err)
+}
+
+func TestWaiters_Notify(t *testing.T) {
+	ctx := context.Background()
+	q := newQueue()
+
+	results := make(chan any, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			results <- q.pop(ctx, time.Second).Val
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return q.waiting() == 3
+	}, time.Second, time.Millisecond)
+
+	// 写入一个元素只唤醒一个调用者
+	q.push("a")
+	assert.Equal(t, "a", <-results)
+	assert.Equal(t, 2, q.waiting())
+
+	q.push("b", "c")
+	assert.ElementsMatch(t, []any{"b", "c"}, []any{<-results, <-results})
+	assert.Equal(t, 0, q.waiting())
+}
+
+func TestWaiters_CancelAfterNotify(t *testing.T) {
+	q := newQueue()
+	first := q.waiters.add("queue")
+	second := q.waiters.add("queue")
+
+	// first 被唤醒之后放弃了等待，唤醒的机会要让给 second
+	q.waiters.Notify("queue", 1)
+	<-first
+	q.waiters.cancel("queue", first)
+	select {
+	case <-second:
+	default:
+		t.Fatal("second 没有被唤醒")
+	}
+	assert.Equal(t, 0, q.waiting())
+
+	// 还没有被唤醒的直接从队列中移除
+	third := q.waiters.add("queue")
+	fourth := q.waiters.add("queue")
+	q.waiters.cancel("queue", third)
+	q.waiters.Notify("queue", 1)
+	select {
+	case <-fourth:
+	default:
+		t.Fatal("fourth 没有被唤醒")
+	}
+}
+
+func TestWaiters_NotifyAll(t *testing.T) {
+	ctx := context.Background()
+	q := newQueue()
+	closed := false
+	results := make(chan error, 3)
+	for _, key := range []string{"a", "a", "b"} {
+		go func(key string) {
+			val := q.waiters.Wait(ctx, key, 0, func() ecache.Value {
+				var val ecache.Value
+				val.Err = errs.ErrKeyNotExist
+				if closed {
+					val.Err = errs.ErrCacheClosed
+				}
+				return val
+			})
+			results <- val.Err
+		}(key)
+	}
+	require.Eventually(t, func() bool {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		return len(q.waiters.queues["a"]) == 2 && len(q.waiters.queues["b"]) == 1
+	}, time.Second, time.Millisecond)
+
+	// 关闭之后唤醒所有的调用者，它们重试之后拿到关闭的错误
+	q.mutex.Lock()
+	closed = true
+	q.waiters.NotifyAll()
+	q.mutex.Unlock()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, errs.ErrCacheClosed, <-results)
+	}
+	assert.Empty(t, q.waiters.queues)
+}
@@ -20,4 +20,13 @@ var (
 	ErrKeyNotExist                = errors.New("key 不存在")
 	ErrDeleteKeyFailed            = errors.New("删除key失败")
 	ErrKeyNeverExpireNotSupported = errors.New("不支持key永不过期")
+	ErrCacheClosed                = errors.New("缓存已经关闭")
+	ErrCircuitOpen                = errors.New("熔断器已经打开")
+	ErrPipelineNotExecuted        = errors.New("pipeline 还没有执行")
+	ErrTxConflict                 = errors.New("事务冲突，被监视的 key 已经被修改")
+	ErrNotSupported               = errors.New("不支持该操作")
+	ErrSubscriptionClosed         = errors.New("订阅已经关闭")
+	ErrStreamGroupExist           = errors.New("消费者组已经存在")
+	ErrStreamGroupNotExist        = errors.New("消费者组不存在")
+	ErrInvalidSoftTTL             = errors.New("软过期时间必须小于硬过期时间")
 )
@@ -0,0 +1,397 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package geo 参考 Redis 的 geo.c 实现了基于 geohash 的地理位置索引。
+// 坐标被编码为 52 位的 geohash，成员按照 geohash 排序，
+// 查找的时候只扫描中心点所在的格子以及周围八个格子，再按照距离精确过滤
+package geo
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+)
+
+const (
+	// step 经度和纬度各自编码的位数
+	step = 26
+
+	lonMin = -180.0
+	lonMax = 180.0
+	latMin = -85.05112878
+	latMax = 85.05112878
+
+	// earthRadius 和 Redis 一样的地球半径，单位为米
+	earthRadius = 6372797.560856
+)
+
+var (
+	ErrInvalidLocation = errors.New("ecache: 经度必须在 [-180, 180] 之间，纬度必须在 [-85.05112878, 85.05112878] 之间")
+	ErrInvalidUnit     = errors.New("ecache: 距离单位只能是 m、km、mi 或者 ft")
+	ErrInvalidShape    = errors.New("ecache: Radius 必须大于 0，或者 Width 和 Height 都必须大于 0")
+)
+
+// Set 按照 geohash 排序的成员集合
+type Set struct {
+	scores  map[string]uint64
+	entries []entry
+}
+
+type entry struct {
+	score  uint64
+	member string
+}
+
+func NewSet() *Set {
+	return &Set{scores: make(map[string]uint64)}
+}
+
+func (s *Set) Len() int {
+	return len(s.entries)
+}
+
+// Add 添加或者更新成员的位置，返回新添加的成员数量。
+// 只要有一个位置不合法就什么都不做
+func (s *Set) Add(locations ...ecache.GeoLocation) (int64, error) {
+	scores := make([]uint64, 0, len(locations))
+	for _, loc := range locations {
+		score, err := Encode(loc.Longitude, loc.Latitude)
+		if err != nil {
+			return 0, err
+		}
+		scores = append(scores, score)
+	}
+	var added int64
+	for i, loc := range locations {
+		if old, ok := s.scores[loc.Member]; ok {
+			s.remove(old, loc.Member)
+		} else {
+			added++
+		}
+		s.insert(scores[i], loc.Member)
+	}
+	return added, nil
+}
+
+// Pos 返回成员的坐标，也就是 geohash 格子的中心
+func (s *Set) Pos(member string) (lon, lat float64, ok bool) {
+	score, ok := s.scores[member]
+	if !ok {
+		return 0, 0, false
+	}
+	lon, lat = Decode(score)
+	return lon, lat, true
+}
+
+// Dist 返回两个成员之间的距离，任何一个成员不存在都返回 errs.ErrKeyNotExist
+func (s *Set) Dist(member1, member2 string, unit ecache.GeoUnit) (float64, error) {
+	conv, err := Meters(unit)
+	if err != nil {
+		return 0, err
+	}
+	lon1, lat1, ok1 := s.Pos(member1)
+	lon2, lat2, ok2 := s.Pos(member2)
+	if !ok1 || !ok2 {
+		return 0, errs.ErrKeyNotExist
+	}
+	return round(Distance(lon1, lat1, lon2, lat2) / conv), nil
+}
+
+// Search 查找圆形或者矩形区域内的成员，语义和 Redis 的 GEOSEARCH ... WITHCOORD WITHDIST 一致
+func (s *Set) Search(q ecache.GeoSearchQuery) ([]ecache.GeoLocation, error) {
+	conv, err := Meters(q.Unit)
+	if err != nil {
+		return nil, err
+	}
+	circle := q.Radius > 0
+	if !circle && (q.Width <= 0 || q.Height <= 0) {
+		return nil, ErrInvalidShape
+	}
+	lon, lat := q.Longitude, q.Latitude
+	if q.Member != "" {
+		var ok bool
+		if lon, lat, ok = s.Pos(q.Member); !ok {
+			return nil, errs.ErrKeyNotExist
+		}
+	} else if !valid(lon, lat) {
+		return nil, ErrInvalidLocation
+	}
+
+	sh := shape{lon: lon, lat: lat, circle: circle,
+		radius: q.Radius * conv, width: q.Width * conv, height: q.Height * conv}
+	var res []ecache.GeoLocation
+	for _, rg := range sh.ranges() {
+		i := sort.Search(len(s.entries), func(i int) bool { return s.entries[i].score >= rg[0] })
+		for ; i < len(s.entries) && s.entries[i].score < rg[1]; i++ {
+			e := s.entries[i]
+			elon, elat := Decode(e.score)
+			dist, ok := sh.contains(elon, elat)
+			if !ok {
+				continue
+			}
+			res = append(res, ecache.GeoLocation{
+				Member:    e.member,
+				Longitude: elon,
+				Latitude:  elat,
+				Dist:      round(dist / conv),
+			})
+		}
+	}
+
+	sortBy := q.Sort
+	// 和 Redis 一样，指定了 Count 但是没有指定排序的时候按照距离从近到远排序
+	if q.Count > 0 && sortBy == ecache.GeoSortNone {
+		sortBy = ecache.GeoSortAsc
+	}
+	switch sortBy {
+	case ecache.GeoSortAsc:
+		sort.SliceStable(res, func(i, j int) bool { return res[i].Dist < res[j].Dist })
+	case ecache.GeoSortDesc:
+		sort.SliceStable(res, func(i, j int) bool { return res[i].Dist > res[j].Dist })
+	}
+	if q.Count > 0 && len(res) > q.Count {
+		res = res[:q.Count]
+	}
+	return res, nil
+}
+
+func (s *Set) insert(score uint64, member string) {
+	i := s.search(score, member)
+	s.entries = append(s.entries, entry{})
+	copy(s.entries[i+1:], s.entries[i:])
+	s.entries[i] = entry{score: score, member: member}
+	s.scores[member] = score
+}
+
+func (s *Set) remove(score uint64, member string) {
+	i := s.search(score, member)
+	s.entries = append(s.entries[:i], s.entries[i+1:]...)
+	delete(s.scores, member)
+}
+
+// search 返回 (score, member) 在 entries 中的位置，不存在的时候返回应该插入的位置
+func (s *Set) search(score uint64, member string) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		e := s.entries[i]
+		return e.score > score || (e.score == score && e.member >= member)
+	})
+}
+
+// shape 查询的区域，距离的单位都是米
+type shape struct {
+	lon, lat float64
+	circle   bool
+	radius   float64
+	width    float64
+	height   float64
+}
+
+// contains 判断坐标是否在区域内，在的话返回到中心点的距离
+func (sh shape) contains(lon, lat float64) (float64, bool) {
+	if sh.circle {
+		dist := Distance(sh.lon, sh.lat, lon, lat)
+		return dist, dist <= sh.radius
+	}
+	if latDistance(sh.lat, lat) > sh.height/2 {
+		return 0, false
+	}
+	if Distance(sh.lon, lat, lon, lat) > sh.width/2 {
+		return 0, false
+	}
+	return Distance(sh.lon, sh.lat, lon, lat), true
+}
+
+// ranges 返回需要扫描的 geohash 区间，左闭右开
+func (sh shape) ranges() [][2]uint64 {
+	minLon, maxLon, minLat, maxLat, ok := sh.bounds()
+	if !ok {
+		return [][2]uint64{{0, 1 << (2 * step)}}
+	}
+	// 从最精确的格子开始，找到中心格子加上周围八个格子能够覆盖整个区域的精度
+	for st := uint(step); st >= 1; st-- {
+		cells := uint32(1) << st
+		cellLat := (latMax - latMin) / float64(cells)
+		cellLon := (lonMax - lonMin) / float64(cells)
+		latIdx, lonIdx := cellOf(sh.lon, sh.lat, st)
+		south := latMin + float64(latIdx)*cellLat - cellLat
+		north := latMin + float64(latIdx+1)*cellLat + cellLat
+		west := lonMin + float64(lonIdx)*cellLon - cellLon
+		east := lonMin + float64(lonIdx+1)*cellLon + cellLon
+		// 最南边和最北边的格子没有更外面的邻居，也不可能有成员在外面
+		if (latIdx > 0 && minLat < south) || (latIdx < cells-1 && maxLat > north) ||
+			minLon < west || maxLon > east {
+			continue
+		}
+		res := make([][2]uint64, 0, 9)
+		seen := make(map[uint64]struct{}, 9)
+		shift := 2 * (step - st)
+		for dLat := -1; dLat <= 1; dLat++ {
+			la := int64(latIdx) + int64(dLat)
+			if la < 0 || la >= int64(cells) {
+				continue
+			}
+			for dLon := -1; dLon <= 1; dLon++ {
+				// 经度是首尾相连的
+				lo := (int64(lonIdx) + int64(dLon) + int64(cells)) % int64(cells)
+				cell := interleave(uint32(la), uint32(lo))
+				if _, ok := seen[cell]; ok {
+					continue
+				}
+				seen[cell] = struct{}{}
+				res = append(res, [2]uint64{cell << shift, (cell + 1) << shift})
+			}
+		}
+		return res
+	}
+	return [][2]uint64{{0, 1 << (2 * step)}}
+}
+
+// bounds 返回区域的经纬度范围，经度可能超出 [-180, 180]。
+// 区域覆盖了所有经度的时候返回 false
+func (sh shape) bounds() (minLon, maxLon, minLat, maxLat float64, ok bool) {
+	var latDelta, lonDelta float64
+	if sh.circle {
+		d := sh.radius / earthRadius
+		latDelta = d
+		maxAbsLat := math.Abs(rad(sh.lat)) + d
+		if maxAbsLat >= math.Pi/2 {
+			return 0, 0, 0, 0, false
+		}
+		// 球冠上的点和中心点最大的经度差
+		v := math.Sin(d) / math.Cos(rad(sh.lat))
+		if v >= 1 {
+			return 0, 0, 0, 0, false
+		}
+		lonDelta = math.Asin(v)
+	} else {
+		latDelta = sh.height / 2 / earthRadius
+		maxAbsLat := math.Abs(rad(sh.lat)) + latDelta
+		halfWidth := sh.width / 4 / earthRadius
+		if maxAbsLat >= math.Pi/2 || halfWidth >= math.Pi/2 {
+			return 0, 0, 0, 0, false
+		}
+		// 同一纬度上两点的距离不超过 width/2 时，最大的经度差出现在离赤道最远的纬度上
+		v := math.Sin(halfWidth) / math.Cos(maxAbsLat)
+		if v >= 1 {
+			return 0, 0, 0, 0, false
+		}
+		lonDelta = 2 * math.Asin(v)
+	}
+	latDelta, lonDelta = deg(latDelta), deg(lonDelta)
+	if lonDelta >= 180 {
+		return 0, 0, 0, 0, false
+	}
+	return sh.lon - lonDelta, sh.lon + lonDelta, sh.lat - latDelta, sh.lat + latDelta, true
+}
+
+// Meters 返回一个单位等于多少米，空的单位当作米
+func Meters(unit ecache.GeoUnit) (float64, error) {
+	switch unit {
+	case ecache.GeoUnitM, "":
+		return 1, nil
+	case ecache.GeoUnitKM:
+		return 1000, nil
+	case ecache.GeoUnitMI:
+		return 1609.34, nil
+	case ecache.GeoUnitFT:
+		return 0.3048, nil
+	default:
+		return 0, ErrInvalidUnit
+	}
+}
+
+// Encode 把坐标编码为 52 位的 geohash
+func Encode(lon, lat float64) (uint64, error) {
+	if !valid(lon, lat) {
+		return 0, ErrInvalidLocation
+	}
+	latIdx, lonIdx := cellOf(lon, lat, step)
+	return interleave(latIdx, lonIdx), nil
+}
+
+// Decode 返回 geohash 对应的格子的中心坐标
+func Decode(hash uint64) (lon, lat float64) {
+	latIdx, lonIdx := deinterleave(hash)
+	cells := float64(uint32(1) << step)
+	lon = lonMin + (float64(lonIdx)+0.5)/cells*(lonMax-lonMin)
+	lat = latMin + (float64(latIdx)+0.5)/cells*(latMax-latMin)
+	return clamp(lon, lonMin, lonMax), clamp(lat, latMin, latMax)
+}
+
+// Distance 使用 haversine 公式计算两点之间的距离，单位为米
+func Distance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lat2r := rad(lat1), rad(lat2)
+	v := math.Sin((rad(lon2) - rad(lon1)) / 2)
+	if v == 0 {
+		return latDistance(lat1, lat2)
+	}
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func latDistance(lat1, lat2 float64) float64 {
+	return earthRadius * math.Abs(rad(lat2)-rad(lat1))
+}
+
+func valid(lon, lat float64) bool {
+	return lon >= lonMin && lon <= lonMax && lat >= latMin && lat <= latMax
+}
+
+// cellOf 返回坐标在指定精度下所在格子的纬度和经度下标
+func cellOf(lon, lat float64, st uint) (latIdx, lonIdx uint32) {
+	cells := float64(uint64(1) << st)
+	maxIdx := uint32(1)<<st - 1
+	latIdx = uint32(math.Min((lat-latMin)/(latMax-latMin)*cells, float64(maxIdx)))
+	lonIdx = uint32(math.Min((lon-lonMin)/(lonMax-lonMin)*cells, float64(maxIdx)))
+	return latIdx, lonIdx
+}
+
+// interleave 纬度放在偶数位，经度放在奇数位，和 Redis 保持一致
+func interleave(lat, lon uint32) uint64 {
+	var res uint64
+	for i := 0; i < 32; i++ {
+		res |= uint64(lat>>i&1) << (2 * i)
+		res |= uint64(lon>>i&1) << (2*i + 1)
+	}
+	return res
+}
+
+func deinterleave(hash uint64) (lat, lon uint32) {
+	for i := 0; i < 32; i++ {
+		lat |= uint32(hash>>(2*i)&1) << i
+		lon |= uint32(hash>>(2*i+1)&1) << i
+	}
+	return lat, lon
+}
+
+// round 和 Redis 一样，距离保留四位小数
+func round(dist float64) float64 {
+	return math.Round(dist*10000) / 10000
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+func rad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func deg(r float64) float64 {
+	return r * 180 / math.Pi
+}
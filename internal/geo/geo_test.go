@@ -0,0 +1,242 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geo
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试数据来自 Redis 文档中 GEOADD、GEODIST 和 GEOSEARCH 的例子
+func newSicily(t *testing.T) *Set {
+	s := NewSet()
+	n, err := s.Add(
+		ecache.GeoLocation{Member: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+		ecache.GeoLocation{Member: "Catania", Longitude: 15.087269, Latitude: 37.502669},
+		ecache.GeoLocation{Member: "edge1", Longitude: 12.758489, Latitude: 38.788135},
+		ecache.GeoLocation{Member: "edge2", Longitude: 17.241510, Latitude: 38.788135},
+	)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+	return s
+}
+
+func TestEncode(t *testing.T) {
+	hash, err := Encode(13.361389, 38.115556)
+	require.NoError(t, err)
+	// GEOHASH 之前的原始分值，ZSCORE Sicily Palermo
+	assert.Equal(t, uint64(3479099956230698), hash)
+	lon, lat := Decode(hash)
+	assert.InDelta(t, 13.36138933897018433, lon, 1e-12)
+	assert.InDelta(t, 38.11555639549629859, lat, 1e-12)
+
+	_, err = Encode(181, 0)
+	assert.Equal(t, ErrInvalidLocation, err)
+	_, err = Encode(0, 86)
+	assert.Equal(t, ErrInvalidLocation, err)
+	_, err = Encode(180, latMax)
+	assert.NoError(t, err)
+}
+
+func TestSet_Add(t *testing.T) {
+	s := newSicily(t)
+	// 更新已有的成员不计数
+	n, err := s.Add(
+		ecache.GeoLocation{Member: "Palermo", Longitude: 13, Latitude: 38},
+		ecache.GeoLocation{Member: "Rome", Longitude: 12.496366, Latitude: 41.902782},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 5, s.Len())
+	lon, lat, ok := s.Pos("Palermo")
+	assert.True(t, ok)
+	assert.InDelta(t, 13, lon, 1e-5)
+	assert.InDelta(t, 38, lat, 1e-5)
+
+	// 任何一个位置不合法都不会修改
+	_, err = s.Add(
+		ecache.GeoLocation{Member: "Milan", Longitude: 9.18, Latitude: 45.46},
+		ecache.GeoLocation{Member: "North Pole", Longitude: 0, Latitude: 90},
+	)
+	assert.Equal(t, ErrInvalidLocation, err)
+	_, _, ok = s.Pos("Milan")
+	assert.False(t, ok)
+	assert.Equal(t, 5, s.Len())
+}
+
+func TestSet_Dist(t *testing.T) {
+	s := newSicily(t)
+	testCases := []struct {
+		name    string
+		unit    ecache.GeoUnit
+		want    float64
+		wantErr error
+	}{
+		{name: "default", want: 166274.1516},
+		{name: "m", unit: ecache.GeoUnitM, want: 166274.1516},
+		{name: "km", unit: ecache.GeoUnitKM, want: 166.2742},
+		{name: "mi", unit: ecache.GeoUnitMI, want: 103.3182},
+		{name: "invalid unit", unit: "yard", wantErr: ErrInvalidUnit},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dist, err := s.Dist("Palermo", "Catania", tc.unit)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, dist)
+		})
+	}
+	_, err := s.Dist("Palermo", "Foo", ecache.GeoUnitKM)
+	assert.Equal(t, errs.ErrKeyNotExist, err)
+}
+
+func TestSet_Search(t *testing.T) {
+	s := newSicily(t)
+	testCases := []struct {
+		name    string
+		query   ecache.GeoSearchQuery
+		want    []string
+		dists   []float64
+		wantErr error
+	}{
+		{
+			name: "radius",
+			query: ecache.GeoSearchQuery{Longitude: 15, Latitude: 37,
+				Radius: 200, Unit: ecache.GeoUnitKM, Sort: ecache.GeoSortAsc},
+			want:  []string{"Catania", "Palermo"},
+			dists: []float64{56.4413, 190.4424},
+		},
+		{
+			name: "box",
+			query: ecache.GeoSearchQuery{Longitude: 15, Latitude: 37,
+				Width: 400, Height: 400, Unit: ecache.GeoUnitKM, Sort: ecache.GeoSortAsc},
+			want:  []string{"Catania", "Palermo", "edge2", "edge1"},
+			dists: []float64{56.4413, 190.4424, 279.7403, 279.7405},
+		},
+		{
+			name: "desc",
+			query: ecache.GeoSearchQuery{Longitude: 15, Latitude: 37,
+				Radius: 200, Unit: ecache.GeoUnitKM, Sort: ecache.GeoSortDesc},
+			want:  []string{"Palermo", "Catania"},
+			dists: []float64{190.4424, 56.4413},
+		},
+		{
+			name: "count sorts asc",
+			query: ecache.GeoSearchQuery{Longitude: 15, Latitude: 37,
+				Width: 400, Height: 400, Unit: ecache.GeoUnitKM, Count: 1},
+			want:  []string{"Catania"},
+			dists: []float64{56.4413},
+		},
+		{
+			name: "from member",
+			query: ecache.GeoSearchQuery{Member: "Palermo",
+				Radius: 100, Unit: ecache.GeoUnitKM},
+			want:  []string{"Palermo", "edge1"},
+			dists: []float64{0, 90.9778},
+		},
+		{
+			name:    "member not exist",
+			query:   ecache.GeoSearchQuery{Member: "Foo", Radius: 100},
+			wantErr: errs.ErrKeyNotExist,
+		},
+		{
+			name:    "invalid shape",
+			query:   ecache.GeoSearchQuery{Longitude: 15, Latitude: 37, Width: 100},
+			wantErr: ErrInvalidShape,
+		},
+		{
+			name:    "invalid center",
+			query:   ecache.GeoSearchQuery{Longitude: 15, Latitude: 89, Radius: 100},
+			wantErr: ErrInvalidLocation,
+		},
+		{
+			name:    "invalid unit",
+			query:   ecache.GeoSearchQuery{Longitude: 15, Latitude: 37, Radius: 100, Unit: "yard"},
+			wantErr: ErrInvalidUnit,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := s.Search(tc.query)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			members := make([]string, 0, len(res))
+			dists := make([]float64, 0, len(res))
+			for _, loc := range res {
+				members = append(members, loc.Member)
+				dists = append(dists, loc.Dist)
+			}
+			if tc.query.Sort == ecache.GeoSortNone && tc.query.Count == 0 {
+				assert.ElementsMatch(t, tc.want, members)
+				return
+			}
+			assert.Equal(t, tc.want, members)
+			assert.Equal(t, tc.dists, dists)
+		})
+	}
+}
+
+// TestSet_SearchCoverage 和暴力扫描对比，确保只扫描周围的格子不会漏掉成员
+func TestSet_SearchCoverage(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := NewSet()
+	for i := 0; i < 5000; i++ {
+		_, err := s.Add(ecache.GeoLocation{
+			Member:    string(rune('a'+i%26)) + string(rune('0'+i/26%10)) + string(rune('A'+i/260)),
+			Longitude: r.Float64()*360 - 180,
+			Latitude:  r.Float64()*2*latMax - latMax,
+		})
+		require.NoError(t, err)
+	}
+	for i := 0; i < 200; i++ {
+		q := ecache.GeoSearchQuery{
+			Longitude: r.Float64()*360 - 180,
+			Latitude:  r.Float64()*2*latMax - latMax,
+			Unit:      ecache.GeoUnitKM,
+		}
+		// 半径从 1km 到 10000km 不等
+		size := []float64{1, 100, 1000, 5000, 10000}[i%5] * (0.5 + r.Float64())
+		if i%2 == 0 {
+			q.Radius = size
+		} else {
+			q.Width, q.Height = size, size*(0.5+r.Float64())
+		}
+		res, err := s.Search(q)
+		require.NoError(t, err)
+		got := make([]string, 0, len(res))
+		for _, loc := range res {
+			got = append(got, loc.Member)
+		}
+
+		sh := shape{lon: q.Longitude, lat: q.Latitude, circle: q.Radius > 0,
+			radius: q.Radius * 1000, width: q.Width * 1000, height: q.Height * 1000}
+		want := make([]string, 0, len(got))
+		for _, e := range s.entries {
+			if _, ok := sh.contains(Decode(e.score)); ok {
+				want = append(want, e.member)
+			}
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		assert.Equal(t, want, got, "query %+v", q)
+	}
+}
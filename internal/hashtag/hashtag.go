@@ -0,0 +1,41 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hashtag 实现了 Redis Cluster 的 hash tag 规则，
+// key 中第一个 {} 里面的内容相同的 key 会被分配到同一个分片上
+package hashtag
+
+import "strings"
+
+// Key 返回 key 中第一个 {} 里面的内容，没有或者内容为空的时候返回整个 key
+func Key(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+// Prefix 返回命名空间在 key 中的前缀。
+// hashTag 为 true 的时候命名空间会被当作 hash tag，例如 app1: 的前缀是 {app1:}
+func Prefix(namespace string, hashTag bool) string {
+	if hashTag {
+		return "{" + namespace + "}"
+	}
+	return namespace
+}
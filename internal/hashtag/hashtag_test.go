@@ -0,0 +1,48 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hashtag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	// 测试数据来自 Redis Cluster 的规范文档
+	testCases := []struct {
+		key  string
+		want string
+	}{
+		{key: "foo", want: "foo"},
+		{key: "{user1000}.following", want: "user1000"},
+		{key: "foo{}{bar}", want: "foo{}{bar}"},
+		{key: "foo{{bar}}zap", want: "{bar"},
+		{key: "foo{bar}{zap}", want: "bar"},
+		{key: "foo{bar", want: "foo{bar"},
+		{key: "", want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			assert.Equal(t, tc.want, Key(tc.key))
+		})
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	assert.Equal(t, "app1:", Prefix("app1:", false))
+	assert.Equal(t, "{app1:}", Prefix("app1:", true))
+	assert.Equal(t, "app1:", Key(Prefix("app1:", true)+"key"))
+}
@@ -0,0 +1,224 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hll 本地缓存使用的 HyperLogLog。
+// 哈希函数、寄存器的数量以及估算方法都和 Redis 保持一致，标准误差约为 0.81%
+package hll
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	precision = 14
+	registers = 1 << precision
+	// q 哈希值中用于计算连续 0 的位数
+	q = 64 - precision
+	// sparseMax 稀疏表示最多保存多少个寄存器，超过之后转为稠密表示。
+	// 每个寄存器占用 4 个字节，和稠密表示的 16KB 相比节省了大部分内存
+	sparseMax = 3000
+	// seed Redis 使用的哈希种子
+	seed     = 0xadc83b19
+	alphaInf = 0.721347520444481703680
+)
+
+// HyperLogLog 基数估算。
+// 基数比较小的时候只保存非 0 的寄存器，按照下标排序，每一项的高位是下标，低 8 位是寄存器的值
+type HyperLogLog struct {
+	sparse []uint32
+	dense  []uint8
+}
+
+func New() *HyperLogLog {
+	return &HyperLogLog{}
+}
+
+// Add 添加一个元素，任何一个寄存器发生变化都返回 true
+func (h *HyperLogLog) Add(data []byte) bool {
+	hash := murmurHash64A(data, seed)
+	idx := uint32(hash & (registers - 1))
+	// 加上最高位保证一定能够找到 1
+	hash = hash>>precision | 1<<q
+	return h.set(idx, uint8(bits.TrailingZeros64(hash)+1))
+}
+
+// Merge 合并 other，每个寄存器取两者中的最大值
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	other.each(func(idx uint32, val uint8) {
+		h.set(idx, val)
+	})
+}
+
+// Clone 复制一份
+func (h *HyperLogLog) Clone() *HyperLogLog {
+	res := &HyperLogLog{}
+	if h.dense != nil {
+		res.dense = append([]uint8(nil), h.dense...)
+	} else {
+		res.sparse = append([]uint32(nil), h.sparse...)
+	}
+	return res
+}
+
+// Count 估算基数，使用的是 Otmar Ertl 提出的改进方法，和 Redis 一样
+func (h *HyperLogLog) Count() int64 {
+	var hist [q + 2]int
+	zeros := registers
+	h.each(func(_ uint32, val uint8) {
+		hist[val]++
+		zeros--
+	})
+	hist[0] = zeros
+
+	m := float64(registers)
+	z := m * tau((m-float64(hist[q+1]))/m)
+	for k := q; k >= 1; k-- {
+		z += float64(hist[k])
+		z *= 0.5
+	}
+	z += m * sigma(float64(hist[0])/m)
+	return int64(math.Round(alphaInf * m * m / z))
+}
+
+func (h *HyperLogLog) set(idx uint32, val uint8) bool {
+	if h.dense != nil {
+		if h.dense[idx] >= val {
+			return false
+		}
+		h.dense[idx] = val
+		return true
+	}
+	i := sort.Search(len(h.sparse), func(i int) bool {
+		return h.sparse[i]>>8 >= idx
+	})
+	if i < len(h.sparse) && h.sparse[i]>>8 == idx {
+		if uint8(h.sparse[i]) >= val {
+			return false
+		}
+		h.sparse[i] = idx<<8 | uint32(val)
+		return true
+	}
+	if len(h.sparse) >= sparseMax {
+		h.toDense()
+		h.dense[idx] = val
+		return true
+	}
+	h.sparse = append(h.sparse, 0)
+	copy(h.sparse[i+1:], h.sparse[i:])
+	h.sparse[i] = idx<<8 | uint32(val)
+	return true
+}
+
+func (h *HyperLogLog) toDense() {
+	h.dense = make([]uint8, registers)
+	for _, item := range h.sparse {
+		h.dense[item>>8] = uint8(item)
+	}
+	h.sparse = nil
+}
+
+// each 遍历所有非 0 的寄存器
+func (h *HyperLogLog) each(fn func(idx uint32, val uint8)) {
+	if h.dense == nil {
+		for _, item := range h.sparse {
+			fn(item>>8, uint8(item))
+		}
+		return
+	}
+	for idx, val := range h.dense {
+		if val > 0 {
+			fn(uint32(idx), val)
+		}
+	}
+}
+
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+// murmurHash64A Redis 使用的哈希函数
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const (
+		m = 0xc6a4a7935bd1e995
+		r = 47
+	)
+	h := seed ^ uint64(len(key))*m
+	for len(key) >= 8 {
+		k := binary.LittleEndian.Uint64(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		key = key[8:]
+	}
+	switch len(key) {
+	case 7:
+		h ^= uint64(key[6]) << 48
+		fallthrough
+	case 6:
+		h ^= uint64(key[5]) << 40
+		fallthrough
+	case 5:
+		h ^= uint64(key[4]) << 32
+		fallthrough
+	case 4:
+		h ^= uint64(key[3]) << 24
+		fallthrough
+	case 3:
+		h ^= uint64(key[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint64(key[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint64(key[0])
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
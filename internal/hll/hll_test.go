@@ -0,0 +1,89 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hll
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHyperLogLog_Count(t *testing.T) {
+	for _, n := range []int{0, 1, 100, 1000, 10000, 100000, 1000000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			h := New()
+			for i := 0; i < n; i++ {
+				h.Add([]byte("user:" + strconv.Itoa(i)))
+			}
+			// 重复的元素不影响结果
+			for i := 0; i < n; i += 10 {
+				h.Add([]byte("user:" + strconv.Itoa(i)))
+			}
+			// 标准误差是 0.81%，这里允许三倍的误差
+			delta := math.Max(1, float64(n)*0.0081*3)
+			assert.InDelta(t, n, h.Count(), delta)
+		})
+	}
+}
+
+func TestHyperLogLog_Add(t *testing.T) {
+	h := New()
+	assert.True(t, h.Add([]byte("a")))
+	assert.False(t, h.Add([]byte("a")))
+	assert.Equal(t, int64(1), h.Count())
+
+	// 超过 sparseMax 之后转为稠密表示，和一开始就是稠密表示的结果完全一样
+	dense := New()
+	dense.toDense()
+	dense.Add([]byte("a"))
+	for i := 0; i < 10000; i++ {
+		data := []byte(strconv.Itoa(i))
+		h.Add(data)
+		dense.Add(data)
+		if i == sparseMax/2 {
+			assert.Nil(t, h.dense)
+			assert.Equal(t, dense.Count(), h.Count())
+		}
+	}
+	assert.Nil(t, h.sparse)
+	assert.Equal(t, dense.dense, h.dense)
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	a, b := New(), New()
+	for i := 0; i < 6000; i++ {
+		a.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 4000; i < 10000; i++ {
+		b.Add([]byte(strconv.Itoa(i)))
+	}
+	union := a.Clone()
+	union.Merge(b)
+	assert.InDelta(t, 10000, union.Count(), 10000*0.0081*3)
+	// Clone 出来的不影响原本的
+	assert.InDelta(t, 6000, a.Count(), 6000*0.0081*3)
+
+	// 稀疏表示合并到稠密表示，结果和直接添加一样
+	all := New()
+	for i := 0; i < 10000; i++ {
+		all.Add([]byte(strconv.Itoa(i)))
+	}
+	small := New()
+	small.Add([]byte("1"))
+	all.Merge(small)
+	assert.Equal(t, union.Count(), all.Count())
+}
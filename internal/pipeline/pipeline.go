@@ -0,0 +1,160 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+)
+
+var _ ecache.Pipeline = (*Pipeline)(nil)
+
+// Pipeline 本地缓存通用的 ecache.Pipeline 实现。
+// 排队的操作在 Exec 的时候通过 run 一次性执行，
+// run 负责加锁，并且提供一个不会再次加锁的 ecache.Cache 给所有的操作使用
+type Pipeline struct {
+	cmds []func(ctx context.Context, c ecache.Cache) error
+	run  func(fn func(c ecache.Cache)) error
+}
+
+func New(run func(fn func(c ecache.Cache))) *Pipeline {
+	return newPipeline(func(fn func(c ecache.Cache)) error {
+		run(fn)
+		return nil
+	})
+}
+
+// newPipeline run 可以拒绝执行，例如事务冲突的时候
+func newPipeline(run func(fn func(c ecache.Cache)) error) *Pipeline {
+	return &Pipeline{run: run}
+}
+
+// queue 把操作放进队列，执行之后把结果设置到 Future 里面
+func queue[T any](p *Pipeline, fn func(ctx context.Context, c ecache.Cache) (T, error)) *ecache.Future[T] {
+	f := &ecache.Future[T]{}
+	p.cmds = append(p.cmds, func(ctx context.Context, c ecache.Cache) error {
+		val, err := fn(ctx, c)
+		f.Resolve(val, err)
+		return err
+	})
+	return f
+}
+
+func queueValue(p *Pipeline, fn func(ctx context.Context, c ecache.Cache) ecache.Value) *ecache.Future[ecache.Value] {
+	return queue(p, func(ctx context.Context, c ecache.Cache) (ecache.Value, error) {
+		val := fn(ctx, c)
+		return val, val.Err
+	})
+}
+
+func (p *Pipeline) Set(key string, val any, expiration time.Duration) *ecache.Future[struct{}] {
+	return queue(p, func(ctx context.Context, c ecache.Cache) (struct{}, error) {
+		return struct{}{}, c.Set(ctx, key, val, expiration)
+	})
+}
+
+func (p *Pipeline) SetNX(key string, val any, expiration time.Duration) *ecache.Future[bool] {
+	return queue(p, func(ctx context.Context, c ecache.Cache) (bool, error) {
+		return c.SetNX(ctx, key, val, expiration)
+	})
+}
+
+func (p *Pipeline) Get(key string) *ecache.Future[ecache.Value] {
+	return queueValue(p, func(ctx context.Context, c ecache.Cache) ecache.Value {
+		return c.Get(ctx, key)
+	})
+}
+
+func (p *Pipeline) GetSet(key string, val string) *ecache.Future[ecache.Value] {
+	return queueValue(p, func(ctx context.Context, c ecache.Cache) ecache.Value {
+		return c.GetSet(ctx, key, val)
+	})
+}
+
+func (p *Pipeline) Delete(key ...string) *ecache.Future[int64] {
+	return queue(p, func(ctx context.Context, c ecache.Cache) (int64, error) {
+		return c.Delete(ctx, key...)
+	})
+}
+
+func (p *Pipeline) LPush(key string, val ...any) *ecache.Future[int64] {
+	return queue(p, func(ctx context.Context, c ecache.Cache) (int64, error) {
+		return c.LPush(ctx, key, val...)
+	})
+}
+
+func (p *Pipeline) LPop(key string) *ecache.Future[ecache.Value] {
+	return queueValue(p, func(ctx context.Context, c ecache.Cache) ecache.Value {
+		return c.LPop(ctx, key)
+	})
+}
+
+func (p *Pipeline) SAdd(key string, members ...any) *ecache.Future[int64] {
+	return queue(p, func(ctx context.Context, c ecache.Cache) (int64, error) {
+		return c.SAdd(ctx, key, members...)
+	})
+}
+
+func (p *Pipeline) SRem(key string, members ...any) *ecache.Future[int64] {
+	return queue(p, func(ctx context.Context, c ecache.Cache) (int64, error) {
+		return c.SRem(ctx, key, members...)
+	})
+}
+
+func (p *Pipeline) IncrBy(key string, value int64) *ecache.Future[int64] {
+	return queue(p, func(ctx context.Context, c ecache.Cache) (int64, error) {
+		return c.IncrBy(ctx, key, value)
+	})
+}
+
+func (p *Pipeline) DecrBy(key string, value int64) *ecache.Future[int64] {
+	return queue(p, func(ctx context.Context, c ecache.Cache) (int64, error) {
+		return c.DecrBy(ctx, key, value)
+	})
+}
+
+func (p *Pipeline) IncrByFloat(key string, value float64) *ecache.Future[float64] {
+	return queue(p, func(ctx context.Context, c ecache.Cache) (float64, error) {
+		return c.IncrByFloat(ctx, key, value)
+	})
+}
+
+func (p *Pipeline) Exec(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	cmds := p.cmds
+	p.cmds = nil
+	if len(cmds) == 0 {
+		return nil
+	}
+	var firstErr error
+	err := p.run(func(c ecache.Cache) {
+		for _, cmd := range cmds {
+			err := cmd(ctx, c)
+			if err != nil && firstErr == nil && !errors.Is(err, errs.ErrKeyNotExist) {
+				firstErr = err
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return firstErr
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/ecodeclub/ecache/memory/priority"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPipeliners(t *testing.T) map[string]ecache.Pipeliner {
+	rbTree, err := priority.NewRBTreePriorityCache()
+	require.NoError(t, err)
+	return map[string]ecache.Pipeliner{
+		"lru":      lru.NewCache(100),
+		"priority": rbTree,
+	}
+}
+
+func TestPipeline_Exec(t *testing.T) {
+	for name, c := range newPipeliners(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			p := c.Pipeline()
+			set := p.Set("name", "大明", time.Minute)
+			setNX := p.SetNX("name", "小明", time.Minute)
+			get := p.Get("name")
+			getSet := p.GetSet("name", "小明")
+			miss := p.Get("not-exist")
+			incr := p.IncrBy("cnt", 3)
+			decr := p.DecrBy("cnt", 1)
+			incrFloat := p.IncrByFloat("price", 1.5)
+			push := p.LPush("list", "a")
+			pop := p.LPop("list")
+			sadd := p.SAdd("set", "a", "b")
+			srem := p.SRem("set", "a")
+			del := p.Delete("name", "cnt")
+
+			// 还没有执行
+			assert.Equal(t, errs.ErrPipelineNotExecuted, get.Err())
+
+			require.NoError(t, p.Exec(ctx))
+			assert.NoError(t, set.Err())
+			ok, err := setNX.Result()
+			require.NoError(t, err)
+			assert.False(t, ok)
+			assert.Equal(t, "大明", get.Val().Val)
+			assert.Equal(t, "大明", getSet.Val().Val)
+			assert.True(t, miss.Val().KeyNotFound())
+			assert.Equal(t, errs.ErrKeyNotExist, miss.Err())
+			assert.Equal(t, int64(3), incr.Val())
+			assert.Equal(t, int64(2), decr.Val())
+			assert.Equal(t, 1.5, incrFloat.Val())
+			assert.Equal(t, int64(1), push.Val())
+			assert.NoError(t, pop.Err())
+			assert.Equal(t, int64(2), sadd.Val())
+			assert.Equal(t, int64(1), srem.Val())
+			assert.Equal(t, int64(2), del.Val())
+
+			// 队列已经清空，可以继续使用
+			get = p.Get("price")
+			require.NoError(t, p.Exec(ctx))
+			assert.Equal(t, 1.5, get.Val().Val)
+			assert.NoError(t, p.Exec(ctx))
+		})
+	}
+}
+
+func TestPipeline_ExecError(t *testing.T) {
+	c := lru.NewCache(100)
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "name", "大明", time.Minute))
+
+	p := c.Pipeline()
+	incr := p.IncrBy("name", 1)
+	set := p.Set("cnt", int64(1), time.Minute)
+	// 返回第一个错误，但是后面的操作依旧执行了
+	assert.Error(t, p.Exec(ctx))
+	assert.Error(t, incr.Err())
+	assert.NoError(t, set.Err())
+	assert.Equal(t, int64(1), c.Get(ctx, "cnt").Val)
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	set = p.Set("cnt", int64(2), time.Minute)
+	assert.Equal(t, context.Canceled, p.Exec(cctx))
+	assert.Equal(t, errs.ErrPipelineNotExecuted, set.Err())
+}
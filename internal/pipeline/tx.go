@@ -0,0 +1,81 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"context"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+)
+
+var _ ecache.Tx = (*tx)(nil)
+
+// Watch 本地缓存通用的乐观锁事务实现。
+// run 负责加锁，并且提供一个不会再次加锁的 ecache.Cache，
+// version 返回 key 当前的版本，key 每次被修改版本都会变化，它只会在 run 里面被调用
+func Watch(ctx context.Context, c ecache.Cache, run func(fn func(c ecache.Cache)),
+	version func(key string) uint64, fn func(tx ecache.Tx) error, keys ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	versions := make([]uint64, len(keys))
+	run(func(ecache.Cache) {
+		for i, key := range keys {
+			versions[i] = version(key)
+		}
+	})
+	return fn(&tx{
+		cache:    c,
+		run:      run,
+		version:  version,
+		keys:     keys,
+		versions: versions,
+	})
+}
+
+type tx struct {
+	cache    ecache.Cache
+	run      func(fn func(c ecache.Cache))
+	version  func(key string) uint64
+	keys     []string
+	versions []uint64
+}
+
+func (t *tx) Get(ctx context.Context, key string) ecache.Value {
+	return t.cache.Get(ctx, key)
+}
+
+func (t *tx) Pipelined(ctx context.Context, fn func(p ecache.Pipeline) error) error {
+	p := newPipeline(func(exec func(c ecache.Cache)) error {
+		var err error
+		t.run(func(c ecache.Cache) {
+			for i, key := range t.keys {
+				if t.version(key) != t.versions[i] {
+					err = errs.ErrTxConflict
+					return
+				}
+			}
+			exec(c)
+		})
+		// 和 Redis 的 EXEC 一样，执行之后就不再监视了
+		t.keys = nil
+		return err
+	})
+	if err := fn(p); err != nil {
+		return err
+	}
+	return p.Exec(ctx)
+}
@@ -0,0 +1,173 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/ecodeclub/ecache/memory/priority"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type transactionalCache interface {
+	ecache.Cache
+	ecache.Transactional
+}
+
+func newTransactionalCaches(t *testing.T) map[string]transactionalCache {
+	rbTree, err := priority.NewRBTreePriorityCache()
+	require.NoError(t, err)
+	return map[string]transactionalCache{
+		"lru":      lru.NewCache(100),
+		"priority": rbTree,
+	}
+}
+
+func TestWatch(t *testing.T) {
+	testCases := []struct {
+		name string
+		// modify 在读取之后，提交之前修改数据，模拟并发修改
+		modify func(ctx context.Context, c ecache.Cache) error
+
+		wantErr error
+		wantVal int64
+	}{
+		{
+			name:    "committed",
+			modify:  func(ctx context.Context, c ecache.Cache) error { return nil },
+			wantVal: 11,
+		},
+		{
+			name: "modified",
+			modify: func(ctx context.Context, c ecache.Cache) error {
+				_, err := c.IncrBy(ctx, "cnt", 5)
+				return err
+			},
+			wantErr: errs.ErrTxConflict,
+			wantVal: 15,
+		},
+		{
+			name: "deleted and set again",
+			modify: func(ctx context.Context, c ecache.Cache) error {
+				if _, err := c.Delete(ctx, "cnt"); err != nil {
+					return err
+				}
+				_, err := c.IncrBy(ctx, "cnt", 10)
+				return err
+			},
+			wantErr: errs.ErrTxConflict,
+			wantVal: 10,
+		},
+		{
+			name: "other key modified",
+			modify: func(ctx context.Context, c ecache.Cache) error {
+				return c.Set(ctx, "other", "value", time.Minute)
+			},
+			wantVal: 11,
+		},
+	}
+	for _, tc := range testCases {
+		for name, c := range newTransactionalCaches(t) {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				ctx := context.Background()
+				_, err := c.IncrBy(ctx, "cnt", 10)
+				require.NoError(t, err)
+
+				var incr *ecache.Future[int64]
+				err = c.Watch(ctx, func(tx ecache.Tx) error {
+					val, err := tx.Get(ctx, "cnt").Int64()
+					require.NoError(t, err)
+					assert.Equal(t, int64(10), val)
+					require.NoError(t, tc.modify(ctx, c))
+					return tx.Pipelined(ctx, func(p ecache.Pipeline) error {
+						incr = p.IncrBy("cnt", 1)
+						return nil
+					})
+				}, "cnt")
+				assert.Equal(t, tc.wantErr, err)
+				if err == nil {
+					assert.Equal(t, tc.wantVal, incr.Val())
+				} else {
+					assert.Equal(t, errs.ErrPipelineNotExecuted, incr.Err())
+				}
+				val, err := c.Get(ctx, "cnt").Int64()
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantVal, val)
+			})
+		}
+	}
+}
+
+func TestWatch_Unwatched(t *testing.T) {
+	for name, c := range newTransactionalCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			err := c.Watch(ctx, func(tx ecache.Tx) error {
+				require.NoError(t, tx.Pipelined(ctx, func(p ecache.Pipeline) error {
+					p.Set("name", "大明", time.Minute)
+					return nil
+				}))
+				// 提交之后就不再监视了
+				require.NoError(t, c.Set(ctx, "name", "小明", time.Minute))
+				return tx.Pipelined(ctx, func(p ecache.Pipeline) error {
+					p.Set("name", "中明", time.Minute)
+					return nil
+				})
+			}, "name")
+			require.NoError(t, err)
+			assert.Equal(t, "中明", c.Get(ctx, "name").Val)
+
+			// fn 返回错误的时候不会执行
+			err = c.Watch(ctx, func(tx ecache.Tx) error {
+				return tx.Pipelined(ctx, func(p ecache.Pipeline) error {
+					p.Set("name", "大明", time.Minute)
+					return errors.New("mock error")
+				})
+			}, "name")
+			assert.Equal(t, errors.New("mock error"), err)
+			assert.Equal(t, "中明", c.Get(ctx, "name").Val)
+		})
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	for name, c := range newTransactionalCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			attempts := 0
+			err := ecache.Transaction(ctx, c, 3, func(tx ecache.Tx) error {
+				attempts++
+				if attempts == 1 {
+					// 第一次执行的时候模拟并发修改
+					require.NoError(t, c.Set(ctx, "name", "小明", time.Minute))
+				}
+				return tx.Pipelined(ctx, func(p ecache.Pipeline) error {
+					p.Set("name", "大明", time.Minute)
+					return nil
+				})
+			}, "name")
+			require.NoError(t, err)
+			assert.Equal(t, 2, attempts)
+			assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refresh
+
+import "sync"
+
+// Group 用于控制后台刷新任务，保证同一个 key 同一时刻最多只有一个刷新任务在执行
+type Group struct {
+	mutex   sync.Mutex
+	running map[string]struct{}
+	// limit 同时执行的刷新任务上限，小于等于 0 表示不限制
+	limit int
+}
+
+func NewGroup(limit int) *Group {
+	return &Group{
+		running: make(map[string]struct{}),
+		limit:   limit,
+	}
+}
+
+// Go 如果 key 当前没有正在执行的刷新任务，并且没有达到并发上限，
+// 那么异步执行 fn 并且返回 true，否则直接返回 false
+func (g *Group) Go(key string, fn func()) bool {
+	g.mutex.Lock()
+	if _, ok := g.running[key]; ok {
+		g.mutex.Unlock()
+		return false
+	}
+	if g.limit > 0 && len(g.running) >= g.limit {
+		g.mutex.Unlock()
+		return false
+	}
+	g.running[key] = struct{}{}
+	g.mutex.Unlock()
+
+	go func() {
+		defer g.done(key)
+		fn()
+	}()
+	return true
+}
+
+func (g *Group) done(key string) {
+	g.mutex.Lock()
+	delete(g.running, key)
+	g.mutex.Unlock()
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refresh
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_Go(t *testing.T) {
+	g := NewGroup(0)
+	block := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ok := g.Go("key1", func() {
+		defer wg.Done()
+		<-block
+	})
+	assert.True(t, ok)
+
+	// 同一个 key 正在刷新
+	ok = g.Go("key1", func() {
+		t.Error("不应该执行")
+	})
+	assert.False(t, ok)
+
+	// 不同的 key 互不影响
+	wg.Add(1)
+	ok = g.Go("key2", wg.Done)
+	assert.True(t, ok)
+
+	close(block)
+	wg.Wait()
+
+	// 刷新结束之后可以再次刷新，这里等待 done 执行完毕
+	for {
+		g.mutex.Lock()
+		n := len(g.running)
+		g.mutex.Unlock()
+		if n == 0 {
+			break
+		}
+	}
+	wg.Add(1)
+	ok = g.Go("key1", wg.Done)
+	assert.True(t, ok)
+	wg.Wait()
+}
+
+func TestGroup_GoWithLimit(t *testing.T) {
+	g := NewGroup(1)
+	block := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ok := g.Go("key1", func() {
+		defer wg.Done()
+		<-block
+	})
+	assert.True(t, ok)
+
+	// 达到并发上限
+	ok = g.Go("key2", func() {
+		t.Error("不应该执行")
+	})
+	assert.False(t, ok)
+	close(block)
+	wg.Wait()
+}
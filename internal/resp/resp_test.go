@@ -0,0 +1,79 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type binary string
+
+func (b binary) MarshalBinary() ([]byte, error) {
+	if b == "" {
+		return nil, errors.New("mock error")
+	}
+	return []byte("binary:" + b), nil
+}
+
+func TestString(t *testing.T) {
+	// 期望的结果和 go-redis 写入参数的规则保持一致
+	testCases := []struct {
+		name    string
+		val     any
+		want    string
+		wantErr error
+	}{
+		{name: "nil", val: nil, want: ""},
+		{name: "string", val: "abc", want: "abc"},
+		{name: "bytes", val: []byte("abc"), want: "abc"},
+		{name: "int", val: -12, want: "-12"},
+		{name: "int8", val: int8(-8), want: "-8"},
+		{name: "int16", val: int16(16), want: "16"},
+		{name: "int32", val: int32(32), want: "32"},
+		{name: "int64", val: int64(64), want: "64"},
+		{name: "uint", val: uint(1), want: "1"},
+		{name: "uint8", val: uint8(8), want: "8"},
+		{name: "uint16", val: uint16(16), want: "16"},
+		{name: "uint32", val: uint32(32), want: "32"},
+		{name: "uint64", val: uint64(18446744073709551615), want: "18446744073709551615"},
+		{name: "float32", val: float32(1.5), want: "1.5"},
+		{name: "float64", val: 0.1, want: "0.1"},
+		{name: "float64 large", val: 1e21, want: "1000000000000000000000"},
+		{name: "true", val: true, want: "1"},
+		{name: "false", val: false, want: "0"},
+		{name: "time", val: time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC), want: "2023-01-02T03:04:05.000000006Z"},
+		{name: "duration", val: time.Second, want: "1000000000"},
+		{name: "binary marshaler", val: binary("abc"), want: "binary:abc"},
+		{name: "binary marshaler error", val: binary(""), wantErr: errors.New("mock error")},
+		{name: "ip", val: net.IP{127, 0, 0, 1}, want: string([]byte{127, 0, 0, 1})},
+		{
+			name:    "not supported",
+			val:     struct{}{},
+			wantErr: errors.New("ecache: 无法转换 struct {} 类型的值，需要实现 encoding.BinaryMarshaler"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := String(tc.val)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ecodeclub/ekit/retry"
+)
+
+// Client 分布式锁的客户端
+type Client struct {
+	store    store
+	newToken func() (string, error)
+}
+
+func newClient(s store) *Client {
+	return &Client{
+		store:    s,
+		newToken: randomToken,
+	}
+}
+
+// TryLock 尝试加锁一次，锁被别人持有的时候返回 ErrFailedToPreemptLock。
+// expiration 必须大于 0，否则返回 ErrInvalidExpiration
+func (c *Client) TryLock(ctx context.Context, key string, expiration time.Duration) (*Lock, error) {
+	if expiration <= 0 {
+		return nil, ErrInvalidExpiration
+	}
+	token, err := c.newToken()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := c.store.acquire(ctx, key, token, expiration)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrFailedToPreemptLock
+	}
+	return newLock(c, key, token, expiration), nil
+}
+
+// Lock 加锁，失败的时候按照 strategy 重试，直到加锁成功、重试次数耗尽或者 ctx 结束。
+// timeout 是每一次加锁的超时时间。
+// 重试的时候使用的是同一个 token，所以即便上一次加锁超时但是实际上已经成功了，重试也能拿到锁
+func (c *Client) Lock(ctx context.Context, key string, expiration time.Duration,
+	strategy retry.Strategy, timeout time.Duration) (*Lock, error) {
+	if expiration <= 0 {
+		return nil, ErrInvalidExpiration
+	}
+	token, err := c.newToken()
+	if err != nil {
+		return nil, err
+	}
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		lctx, cancel := context.WithTimeout(ctx, timeout)
+		ok, err := c.store.acquire(lctx, key, token, expiration)
+		cancel()
+		if err == nil && ok {
+			return newLock(c, key, token, expiration), nil
+		}
+		if err == nil {
+			err = ErrFailedToPreemptLock
+		} else if !errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+
+		interval, next := strategy.Next()
+		if !next {
+			return nil, err
+		}
+		if timer == nil {
+			timer = time.NewTimer(interval)
+		} else {
+			timer.Reset(interval)
+		}
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Lock 已经持有的锁
+type Lock struct {
+	client     *Client
+	key        string
+	token      string
+	expiration time.Duration
+	// expireAt 最近一次加锁或者续约成功之后锁的过期时间，单位纳秒
+	expireAt atomic.Int64
+
+	unlockOnce sync.Once
+	unlocked   chan struct{}
+}
+
+func newLock(c *Client, key string, token string, expiration time.Duration) *Lock {
+	l := &Lock{
+		client:     c,
+		key:        key,
+		token:      token,
+		expiration: expiration,
+		unlocked:   make(chan struct{}),
+	}
+	l.expireAt.Store(time.Now().Add(expiration).UnixNano())
+	return l
+}
+
+// Key 返回锁对应的 key
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Unlock 释放锁，如果锁已经过期或者被别人持有，返回 ErrLockNotHold
+func (l *Lock) Unlock(ctx context.Context) error {
+	ok, err := l.client.store.release(ctx, l.key, l.token)
+	l.unlockOnce.Do(func() {
+		close(l.unlocked)
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHold
+	}
+	return nil
+}
+
+// Refresh 续约，把过期时间重置为加锁时的 expiration
+func (l *Lock) Refresh(ctx context.Context) error {
+	if l.expiration <= 0 {
+		return ErrInvalidExpiration
+	}
+	// 按照发起续约的时间计算过期时间，宁可早也不能晚
+	start := time.Now()
+	ok, err := l.client.store.refresh(ctx, l.key, l.token, l.expiration)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHold
+	}
+	l.expireAt.Store(start.Add(l.expiration).UnixNano())
+	return nil
+}
+
+// AutoRefresh 看门狗，每隔 interval 续约一次，timeout 是每一次续约的超时时间。
+// 续约超时会按照指数退避重试，间隔从 interval 的十分之一开始，最长为 interval；
+// 如果重试的时候锁已经过期，返回 ErrLockNotHold。其余的错误会直接返回；调用 Unlock 之后返回 nil。
+// 该方法会一直阻塞，一般在单独的 goroutine 里面调用，interval 应该明显小于 expiration
+func (l *Lock) AutoRefresh(interval time.Duration, timeout time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	timer := time.NewTimer(interval)
+	stopTimer(timer)
+	defer timer.Stop()
+	var (
+		strategy retry.Strategy
+		// retryCh 只有在续约超时之后才不为 nil
+		retryCh <-chan time.Time
+	)
+	for {
+		select {
+		case <-ticker.C:
+		case <-retryCh:
+		case <-l.unlocked:
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := l.Refresh(ctx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			if time.Now().UnixNano() >= l.expireAt.Load() {
+				return ErrLockNotHold
+			}
+			if strategy == nil {
+				strategy, err = newRefreshStrategy(interval)
+				if err != nil {
+					return err
+				}
+			}
+			next, _ := strategy.Next()
+			stopTimer(timer)
+			timer.Reset(next)
+			retryCh = timer.C
+			continue
+		}
+		strategy, retryCh = nil, nil
+		stopTimer(timer)
+		if err != nil {
+			select {
+			case <-l.unlocked:
+				// 续约的同时释放了锁
+				return nil
+			default:
+				return err
+			}
+		}
+	}
+}
+
+// stopTimer 停止 timer 并且丢弃已经触发但是没有被读取的信号，保证 Reset 之后不会立刻触发
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
+
+// newRefreshStrategy 续约超时之后的重试策略，不限制次数，由锁的过期时间兜底
+func newRefreshStrategy(interval time.Duration) (retry.Strategy, error) {
+	initial := interval / 10
+	if initial <= 0 {
+		initial = interval
+	}
+	return retry.NewExponentialBackoffRetryStrategy(initial, interval, math.MaxInt32)
+}
+
+func randomToken() (string, error) {
+	bs := make([]byte, 16)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bs), nil
+}
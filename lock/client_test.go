@@ -0,0 +1,262 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/ecodeclub/ecache/memory/priority"
+	"github.com/ecodeclub/ekit/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryCaches(t *testing.T) map[string]ecache.TransactionalCache {
+	rbTree, err := priority.NewRBTreePriorityCache()
+	require.NoError(t, err)
+	return map[string]ecache.TransactionalCache{
+		"lru":      lru.NewCache(100),
+		"priority": rbTree,
+	}
+}
+
+func TestClient_TryLock(t *testing.T) {
+	for name, cache := range newMemoryCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			client := NewMemoryClient(cache)
+			ctx := context.Background()
+
+			l, err := client.TryLock(ctx, "lock", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, "lock", l.Key())
+
+			// 别人已经持有锁
+			_, err = client.TryLock(ctx, "lock", time.Minute)
+			assert.Equal(t, ErrFailedToPreemptLock, err)
+
+			// 续约
+			require.NoError(t, l.Refresh(ctx))
+
+			// 释放之后可以再次加锁
+			require.NoError(t, l.Unlock(ctx))
+			assert.Equal(t, ErrLockNotHold, l.Unlock(ctx))
+			assert.Equal(t, ErrLockNotHold, l.Refresh(ctx))
+			l2, err := client.TryLock(ctx, "lock", time.Minute)
+			require.NoError(t, err)
+			// 只能释放自己的锁
+			assert.Equal(t, ErrLockNotHold, l.Unlock(ctx))
+			require.NoError(t, l2.Unlock(ctx))
+		})
+	}
+}
+
+func TestClient_TryLockExpired(t *testing.T) {
+	client := NewMemoryClient(lru.NewCache(100))
+	ctx := context.Background()
+
+	l, err := client.TryLock(ctx, "lock", time.Millisecond*10)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 20)
+
+	// 锁过期之后别人可以拿到锁，并且原本的持有者不能释放
+	l2, err := client.TryLock(ctx, "lock", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ErrLockNotHold, l.Unlock(ctx))
+	assert.NoError(t, l2.Unlock(ctx))
+}
+
+func TestClient_Lock(t *testing.T) {
+	client := NewMemoryClient(lru.NewCache(100))
+	ctx := context.Background()
+
+	held, err := client.TryLock(ctx, "lock", time.Minute)
+	require.NoError(t, err)
+
+	// 重试次数耗尽
+	strategy, err := retry.NewFixedIntervalRetryStrategy(time.Millisecond, 3)
+	require.NoError(t, err)
+	_, err = client.Lock(ctx, "lock", time.Minute, strategy, time.Second)
+	assert.Equal(t, ErrFailedToPreemptLock, err)
+
+	// ctx 结束
+	strategy, err = retry.NewFixedIntervalRetryStrategy(time.Millisecond*10, 100)
+	require.NoError(t, err)
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*30)
+	_, err = client.Lock(tctx, "lock", time.Minute, strategy, time.Second)
+	cancel()
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// 重试的过程中锁被释放
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_ = held.Unlock(ctx)
+	}()
+	strategy, err = retry.NewFixedIntervalRetryStrategy(time.Millisecond*10, 100)
+	require.NoError(t, err)
+	l, err := client.Lock(ctx, "lock", time.Minute, strategy, time.Second)
+	require.NoError(t, err)
+	assert.NoError(t, l.Unlock(ctx))
+}
+
+func TestClient_LockError(t *testing.T) {
+	client := newClient(&errStore{err: errors.New("mock error")})
+	strategy, err := retry.NewFixedIntervalRetryStrategy(time.Millisecond, 3)
+	require.NoError(t, err)
+	_, err = client.Lock(context.Background(), "lock", time.Minute, strategy, time.Second)
+	assert.Equal(t, errors.New("mock error"), err)
+
+	// 超时会重试
+	client = newClient(&errStore{err: context.DeadlineExceeded})
+	strategy, err = retry.NewFixedIntervalRetryStrategy(time.Millisecond, 3)
+	require.NoError(t, err)
+	_, err = client.Lock(context.Background(), "lock", time.Minute, strategy, time.Second)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 4, client.store.(*errStore).calls)
+
+	client.newToken = func() (string, error) {
+		return "", errors.New("token error")
+	}
+	_, err = client.TryLock(context.Background(), "lock", time.Minute)
+	assert.Equal(t, errors.New("token error"), err)
+}
+
+func TestLock_AutoRefresh(t *testing.T) {
+	client := NewMemoryClient(lru.NewCache(100))
+	ctx := context.Background()
+
+	l, err := client.TryLock(ctx, "lock", time.Millisecond*50)
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		done <- l.AutoRefresh(time.Millisecond*10, time.Second)
+	}()
+	// 超过了 expiration 依旧持有锁
+	time.Sleep(time.Millisecond * 100)
+	_, err = client.TryLock(ctx, "lock", time.Minute)
+	assert.Equal(t, ErrFailedToPreemptLock, err)
+
+	require.NoError(t, l.Unlock(ctx))
+	assert.NoError(t, <-done)
+
+	// 锁被别人抢走之后续约失败
+	l, err = client.TryLock(ctx, "lock", time.Millisecond*10)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 20)
+	_, err = client.TryLock(ctx, "lock", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ErrLockNotHold, l.AutoRefresh(time.Millisecond, time.Second))
+}
+
+// TestLock_AutoRefreshTimeout 续约一直超时的时候退避重试，锁过期之后返回
+func TestLock_AutoRefreshTimeout(t *testing.T) {
+	client := newClient(&errStore{err: context.DeadlineExceeded})
+	l := newLock(client, "lock", "token", time.Millisecond*100)
+
+	start := time.Now()
+	assert.Equal(t, ErrLockNotHold, l.AutoRefresh(time.Millisecond*20, time.Second))
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*100)
+	assert.Less(t, time.Since(start), time.Millisecond*500)
+	// 没有退避的话会在锁过期之前重试成千上万次
+	assert.Less(t, client.store.(*errStore).calls, 30)
+}
+
+func TestClient_InvalidExpiration(t *testing.T) {
+	client := newClient(&errStore{})
+	ctx := context.Background()
+
+	_, err := client.TryLock(ctx, "lock", 0)
+	assert.Equal(t, ErrInvalidExpiration, err)
+	strategy, err := retry.NewFixedIntervalRetryStrategy(time.Millisecond, 3)
+	require.NoError(t, err)
+	_, err = client.Lock(ctx, "lock", -time.Second, strategy, time.Second)
+	assert.Equal(t, ErrInvalidExpiration, err)
+	assert.Equal(t, ErrInvalidExpiration, newLock(client, "lock", "token", 0).Refresh(ctx))
+	assert.Equal(t, 0, client.store.(*errStore).calls)
+}
+
+// TestClient_MultipleClients 同一个缓存上的锁，即便通过不同的 Client 来操作也是互斥的
+func TestClient_MultipleClients(t *testing.T) {
+	for name, cache := range newMemoryCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			clients := []*Client{NewMemoryClient(cache), NewMemoryClient(cache)}
+			var holders, overlapped int64
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(client *Client) {
+					defer wg.Done()
+					ctx := context.Background()
+					strategy, err := retry.NewFixedIntervalRetryStrategy(time.Millisecond, 1000)
+					require.NoError(t, err)
+					l, err := client.Lock(ctx, "lock", time.Minute, strategy, time.Second)
+					if !assert.NoError(t, err) {
+						return
+					}
+					if atomic.AddInt64(&holders, 1) > 1 {
+						atomic.AddInt64(&overlapped, 1)
+					}
+					time.Sleep(time.Millisecond)
+					atomic.AddInt64(&holders, -1)
+					assert.NoError(t, l.Unlock(ctx))
+				}(clients[i%len(clients)])
+			}
+			wg.Wait()
+			assert.Equal(t, int64(0), overlapped)
+		})
+	}
+}
+
+// TestMemoryStore_AcquireRetry 加锁超时之后使用同一个 token 重试，可以拿到已经是自己的锁
+func TestMemoryStore_AcquireRetry(t *testing.T) {
+	s := &memoryStore{cache: lru.NewCache(100)}
+	ctx := context.Background()
+
+	ok, err := s.acquire(ctx, "lock", "token", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = s.acquire(ctx, "lock", "token", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = s.acquire(ctx, "lock", "other", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// errStore 测试用的，所有操作都返回 err
+type errStore struct {
+	err   error
+	calls int
+}
+
+func (s *errStore) acquire(ctx context.Context, key string, token string, expiration time.Duration) (bool, error) {
+	s.calls++
+	return false, s.err
+}
+
+func (s *errStore) release(ctx context.Context, key string, token string) (bool, error) {
+	return false, s.err
+}
+
+func (s *errStore) refresh(ctx context.Context, key string, token string, expiration time.Duration) (bool, error) {
+	s.calls++
+	return false, s.err
+}
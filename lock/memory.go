@@ -0,0 +1,81 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+)
+
+var _ store = (*memoryStore)(nil)
+
+// memoryStore 基于本地缓存的实现，例如 lru.Cache 和 RBTreePriorityCache，一般用于单元测试或者单机部署。
+// 比较和修改的原子性依赖于缓存本身的 SetNX、CompareAndSwap 和乐观锁事务，
+// 所以同一个缓存上的锁即便通过不同的 Client 来操作也是互斥的
+type memoryStore struct {
+	cache ecache.TransactionalCache
+}
+
+// NewMemoryClient 创建一个基于本地缓存的分布式锁客户端
+func NewMemoryClient(cache ecache.TransactionalCache) *Client {
+	return newClient(&memoryStore{cache: cache})
+}
+
+func (s *memoryStore) acquire(ctx context.Context, key string, token string, expiration time.Duration) (bool, error) {
+	ok, err := s.cache.SetNX(ctx, key, token, expiration)
+	if err != nil || ok {
+		return ok, err
+	}
+	// 加锁超时之后的重试，锁可能已经是自己的了
+	return s.cache.CompareAndSwap(ctx, key, token, token, expiration)
+}
+
+func (s *memoryStore) release(ctx context.Context, key string, token string) (bool, error) {
+	var released bool
+	// 总有一个事务能够提交成功，所以冲突的时候一直重试直到 ctx 被取消
+	err := ecache.Transaction(ctx, s.cache, math.MaxInt, func(tx ecache.Tx) error {
+		released = false
+		held, err := holding(ctx, tx, key, token)
+		if err != nil || !held {
+			return err
+		}
+		err = tx.Pipelined(ctx, func(p ecache.Pipeline) error {
+			p.Delete(key)
+			return nil
+		})
+		released = err == nil
+		return err
+	}, key)
+	return released, err
+}
+
+func (s *memoryStore) refresh(ctx context.Context, key string, token string, expiration time.Duration) (bool, error) {
+	return s.cache.CompareAndSwap(ctx, key, token, token, expiration)
+}
+
+// holding 判断 key 对应的值是不是 token
+func holding(ctx context.Context, tx ecache.Tx, key string, token string) (bool, error) {
+	val := tx.Get(ctx, key)
+	if val.KeyNotFound() {
+		return false, nil
+	}
+	if val.Err != nil {
+		return false, val.Err
+	}
+	return val.Val == token, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ecache/redis"
+)
+
+var (
+	acquireScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+elseif redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+    return 1
+else
+    return 0
+end`)
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+else
+    return 0
+end`)
+	refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+else
+    return 0
+end`)
+)
+
+var _ store = (*redisStore)(nil)
+
+// redisStore 使用 Lua 脚本保证比较和修改是原子的
+type redisStore struct {
+	cache *redis.Cache
+}
+
+// NewRedisClient 创建一个基于 Redis 的分布式锁客户端
+func NewRedisClient(cache *redis.Cache) *Client {
+	return newClient(&redisStore{cache: cache})
+}
+
+func (s *redisStore) acquire(ctx context.Context, key string, token string, expiration time.Duration) (bool, error) {
+	return s.run(ctx, acquireScript, key, token, milliseconds(expiration))
+}
+
+func (s *redisStore) release(ctx context.Context, key string, token string) (bool, error) {
+	return s.run(ctx, releaseScript, key, token)
+}
+
+func (s *redisStore) refresh(ctx context.Context, key string, token string, expiration time.Duration) (bool, error) {
+	return s.run(ctx, refreshScript, key, token, milliseconds(expiration))
+}
+
+func (s *redisStore) run(ctx context.Context, script *redis.Script, key string, args ...any) (bool, error) {
+	res, err := s.cache.Run(ctx, script, []string{key}, args...).Int64()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// milliseconds 不足一毫秒的按照一毫秒处理，PX 和 PEXPIRE 不接受 0
+func milliseconds(expiration time.Duration) int64 {
+	ms := expiration.Milliseconds()
+	if expiration > 0 && ms == 0 {
+		ms = 1
+	}
+	return ms
+}
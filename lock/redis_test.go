@@ -0,0 +1,138 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/mocks"
+	rcache "github.com/ecodeclub/ecache/redis"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRedisClient(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+
+		wantLockErr   error
+		wantUnlockErr error
+	}{
+		{
+			name: "lock and unlock",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), acquireScript.Hash(), []string{"lock"},
+					gomock.Any(), int64(60000)).Return(intCmd(1, nil))
+				cmd.EXPECT().EvalSha(gomock.Any(), releaseScript.Hash(), []string{"lock"},
+					gomock.Any()).Return(intCmd(1, nil))
+				return cmd
+			},
+		},
+		{
+			name: "script not loaded",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), acquireScript.Hash(), []string{"lock"},
+					gomock.Any(), int64(60000)).Return(intCmd(0, redisError("NOSCRIPT No matching script")))
+				cmd.EXPECT().Eval(gomock.Any(), gomock.Any(), []string{"lock"},
+					gomock.Any(), int64(60000)).Return(intCmd(1, nil))
+				cmd.EXPECT().EvalSha(gomock.Any(), releaseScript.Hash(), []string{"lock"},
+					gomock.Any()).Return(intCmd(0, nil))
+				return cmd
+			},
+			wantUnlockErr: ErrLockNotHold,
+		},
+		{
+			name: "preempted",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), acquireScript.Hash(), []string{"lock"},
+					gomock.Any(), int64(60000)).Return(intCmd(0, nil))
+				return cmd
+			},
+			wantLockErr: ErrFailedToPreemptLock,
+		},
+		{
+			name: "network error",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), acquireScript.Hash(), []string{"lock"},
+					gomock.Any(), int64(60000)).Return(intCmd(0, context.DeadlineExceeded))
+				return cmd
+			},
+			wantLockErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			client := NewRedisClient(rcache.NewCache(tc.mock(ctrl)))
+			l, err := client.TryLock(context.Background(), "lock", time.Minute)
+			assert.Equal(t, tc.wantLockErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantUnlockErr, l.Unlock(context.Background()))
+		})
+	}
+}
+
+func TestRedisClient_Refresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	client := NewRedisClient(rcache.NewCache(cmd))
+	l := newLock(client, "lock", "token", time.Minute)
+
+	cmd.EXPECT().EvalSha(gomock.Any(), refreshScript.Hash(), []string{"lock"},
+		"token", int64(60000)).Return(intCmd(1, nil))
+	assert.NoError(t, l.Refresh(context.Background()))
+
+	cmd.EXPECT().EvalSha(gomock.Any(), refreshScript.Hash(), []string{"lock"},
+		"token", int64(60000)).Return(intCmd(0, nil))
+	assert.Equal(t, ErrLockNotHold, l.Refresh(context.Background()))
+
+	// 不足一毫秒的按照一毫秒处理，不能把 0 传给 Redis
+	l = newLock(client, "lock", "token", time.Microsecond)
+	cmd.EXPECT().EvalSha(gomock.Any(), refreshScript.Hash(), []string{"lock"},
+		"token", int64(1)).Return(intCmd(1, nil))
+	assert.NoError(t, l.Refresh(context.Background()))
+	cmd.EXPECT().EvalSha(gomock.Any(), acquireScript.Hash(), []string{"lock"},
+		gomock.Any(), int64(1)).Return(intCmd(1, nil))
+	_, err := client.TryLock(context.Background(), "lock", time.Microsecond)
+	assert.NoError(t, err)
+}
+
+func intCmd(val int64, err error) *redis.Cmd {
+	cmd := redis.NewCmd(context.Background())
+	cmd.SetVal(val)
+	cmd.SetErr(err)
+	return cmd
+}
+
+// redisError 模拟 Redis 服务端返回的错误
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+func (redisError) RedisError() {}
@@ -0,0 +1,41 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrFailedToPreemptLock 锁已经被别人持有
+	ErrFailedToPreemptLock = errors.New("ecache: 抢锁失败")
+	// ErrLockNotHold 锁已经过期或者被别人持有，不能释放或者续约
+	ErrLockNotHold = errors.New("ecache: 未持有锁")
+	// ErrInvalidExpiration 锁的过期时间必须大于 0，否则锁永远不会过期或者立刻过期
+	ErrInvalidExpiration = errors.New("ecache: 锁的过期时间必须大于 0")
+)
+
+// store 分布式锁依赖的存储，所有的操作都必须是原子的
+type store interface {
+	// acquire key 不存在的时候写入 token，如果 key 已经是 token 那么刷新过期时间。
+	// 后者用于加锁超时之后的重试，抢锁成功返回 true
+	acquire(ctx context.Context, key string, token string, expiration time.Duration) (bool, error)
+	// release 只有 key 对应的值是 token 的时候才删除
+	release(ctx context.Context, key string, token string) (bool, error)
+	// refresh 只有 key 对应的值是 token 的时候才刷新过期时间
+	refresh(ctx context.Context, key string, token string, expiration time.Duration) (bool, error)
+}
@@ -0,0 +1,362 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memcached
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/internal/resp"
+)
+
+var (
+	_ ecache.Cache = (*Cache)(nil)
+	_ Client       = (*memcache.Client)(nil)
+
+	// ErrOutOfRange 增量或者计数器的值超出了 int64 的范围
+	ErrOutOfRange = errors.New("ecache: 增量或者计数器的值超出了 int64 的范围")
+)
+
+// maxRelativeExpiration 超过 30 天的过期时间会被 memcached 当作 unix 时间戳
+const maxRelativeExpiration = 60 * 60 * 24 * 30
+
+// Client memcached 客户端，*memcache.Client 实现了该接口。
+// 多个 memcached 之间的分片由 memcache.New 传入多个地址实现
+type Client interface {
+	Get(key string) (*memcache.Item, error)
+	Set(item *memcache.Item) error
+	Add(item *memcache.Item) error
+	Replace(item *memcache.Item) error
+	CompareAndSwap(item *memcache.Item) error
+	Delete(key string) error
+	Increment(key string, delta uint64) (uint64, error)
+	Decrement(key string, delta uint64) (uint64, error)
+}
+
+// Cache 基于 memcached 的缓存，只支持字符串、计数器和删除，列表和集合的操作返回 errs.ErrNotSupported。
+// 和 Redis 一样，写入的值会被转换为字符串，Get 返回的都是字符串。
+// 和 Redis 的区别：
+//   - 过期时间的精度是秒，不足一秒的按照一秒处理；
+//   - 计数器是无符号的，减到 0 以下的时候结果为 0；
+//     IncrBy 和 DecrBy 的增量不能是 math.MinInt64，计数器超过 math.MaxInt64 的时候返回 ErrOutOfRange，
+//     不过这个时候 memcached 中的值已经被修改了；
+//   - memcached 没有办法读取过期时间，所以 IncrByFloat 之后 key 永不过期。
+//
+// memcached 客户端不支持 context，超时由客户端的 Timeout 控制，ctx 只用于中断 CAS 重试
+type Cache struct {
+	client Client
+}
+
+func NewCache(client Client) *Cache {
+	return &Cache{client: client}
+}
+
+func (c *Cache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	item, err := newItem(key, val, expiration)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(item)
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	item, err := newItem(key, val, expiration)
+	if err != nil {
+		return false, err
+	}
+	return stored(c.client.Add(item))
+}
+
+func (c *Cache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	item, err := newItem(key, val, expiration)
+	if err != nil {
+		return false, err
+	}
+	return stored(c.client.Replace(item))
+}
+
+// CompareAndSwap 通过 gets 和 cas 实现，比较的是转换为字符串之后的值
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	oldVal, err := resp.String(old)
+	if err != nil {
+		return false, err
+	}
+	newVal, err := resp.String(new)
+	if err != nil {
+		return false, err
+	}
+	item, err := c.client.Get(key)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if string(item.Value) != oldVal {
+		return false, nil
+	}
+	item.Value = []byte(newVal)
+	item.Expiration = seconds(expiration)
+	// 在 gets 和 cas 之间被其他人修改或者删除了
+	return stored(c.client.CompareAndSwap(item))
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (val ecache.Value) {
+	item, err := c.client.Get(key)
+	if err != nil {
+		val.Err = convertErr(err)
+		return
+	}
+	val.Val = string(item.Value)
+	return
+}
+
+// GetSet 通过 CAS 循环实现，和 Redis 一样设置之后 key 永不过期
+func (c *Cache) GetSet(ctx context.Context, key string, val string) (res ecache.Value) {
+	for {
+		if res.Err = ctx.Err(); res.Err != nil {
+			return
+		}
+		item, err := c.client.Get(key)
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			err = c.client.Add(&memcache.Item{Key: key, Value: []byte(val)})
+			if errors.Is(err, memcache.ErrNotStored) {
+				continue
+			}
+			res.Err = err
+			if err == nil {
+				res.Err = errs.ErrKeyNotExist
+			}
+			return
+		}
+		if err != nil {
+			res.Err = err
+			return
+		}
+		old := string(item.Value)
+		item.Value = []byte(val)
+		item.Expiration = 0
+		err = c.client.CompareAndSwap(item)
+		if errors.Is(err, memcache.ErrCASConflict) || errors.Is(err, memcache.ErrNotStored) {
+			continue
+		}
+		if err != nil {
+			res.Err = err
+			return
+		}
+		res.Val = old
+		return
+	}
+}
+
+// Delete 逐个删除，部分失败的时候返回成功删除的数量和第一个错误
+func (c *Cache) Delete(ctx context.Context, key ...string) (int64, error) {
+	var cnt int64
+	var firstErr error
+	for _, k := range key {
+		err := c.client.Delete(k)
+		switch {
+		case err == nil:
+			cnt++
+		case errors.Is(err, memcache.ErrCacheMiss):
+		case firstErr == nil:
+			firstErr = err
+		}
+	}
+	return cnt, firstErr
+}
+
+func (c *Cache) LPush(ctx context.Context, key string, val ...any) (int64, error) {
+	return 0, errs.ErrNotSupported
+}
+
+func (c *Cache) LPop(ctx context.Context, key string) (val ecache.Value) {
+	val.Err = errs.ErrNotSupported
+	return
+}
+
+func (c *Cache) BLPop(ctx context.Context, key string, timeout time.Duration) (val ecache.Value) {
+	val.Err = errs.ErrNotSupported
+	return
+}
+
+func (c *Cache) BRPop(ctx context.Context, key string, timeout time.Duration) (val ecache.Value) {
+	val.Err = errs.ErrNotSupported
+	return
+}
+
+func (c *Cache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) (val ecache.Value) {
+	val.Err = errs.ErrNotSupported
+	return
+}
+
+func (c *Cache) LMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection) (val ecache.Value) {
+	val.Err = errs.ErrNotSupported
+	return
+}
+
+func (c *Cache) RPopLPush(ctx context.Context, source, destination string) (val ecache.Value) {
+	val.Err = errs.ErrNotSupported
+	return
+}
+
+func (c *Cache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	return 0, errs.ErrNotSupported
+}
+
+func (c *Cache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
+	return 0, errs.ErrNotSupported
+}
+
+func (c *Cache) SRem(ctx context.Context, key string, members ...any) (int64, error) {
+	return 0, errs.ErrNotSupported
+}
+
+func (c *Cache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	return c.incrBy(ctx, key, value)
+}
+
+func (c *Cache) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
+	// -math.MinInt64 会溢出
+	if value == math.MinInt64 {
+		return 0, ErrOutOfRange
+	}
+	return c.incrBy(ctx, key, -value)
+}
+
+// IncrByFloat 通过 CAS 循环实现，memcached 没有办法读取过期时间，所以之后 key 永不过期
+func (c *Cache) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		item, err := c.client.Get(key)
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			err = c.client.Add(&memcache.Item{Key: key, Value: formatFloat(value)})
+			if errors.Is(err, memcache.ErrNotStored) {
+				continue
+			}
+			return value, err
+		}
+		if err != nil {
+			return 0, err
+		}
+		old, err := strconv.ParseFloat(string(item.Value), 64)
+		if err != nil {
+			return 0, err
+		}
+		res := old + value
+		item.Value = formatFloat(res)
+		item.Expiration = 0
+		err = c.client.CompareAndSwap(item)
+		if errors.Is(err, memcache.ErrCASConflict) || errors.Is(err, memcache.ErrNotStored) {
+			continue
+		}
+		return res, err
+	}
+}
+
+// incrBy 使用 memcached 原生的 incr 和 decr，保留过期时间。
+// key 不存在的时候和 Redis 一样从 0 开始计算
+func (c *Cache) incrBy(ctx context.Context, key string, value int64) (int64, error) {
+	if value == math.MinInt64 {
+		return 0, ErrOutOfRange
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		var res uint64
+		var err error
+		if value >= 0 {
+			res, err = c.client.Increment(key, uint64(value))
+		} else {
+			res, err = c.client.Decrement(key, uint64(-value))
+		}
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			if err == nil && res > math.MaxInt64 {
+				return 0, ErrOutOfRange
+			}
+			return int64(res), err
+		}
+		init := value
+		if init < 0 {
+			init = 0
+		}
+		err = c.client.Add(&memcache.Item{Key: key, Value: []byte(strconv.FormatInt(init, 10))})
+		// 被其他人抢先创建了，重新执行 incr
+		if errors.Is(err, memcache.ErrNotStored) {
+			continue
+		}
+		return init, err
+	}
+}
+
+func newItem(key string, val any, expiration time.Duration) (*memcache.Item, error) {
+	str, err := resp.String(val)
+	if err != nil {
+		return nil, err
+	}
+	return &memcache.Item{
+		Key:        key,
+		Value:      []byte(str),
+		Expiration: seconds(expiration),
+	}, nil
+}
+
+// seconds 把过期时间转换为 memcached 的格式，0 表示永不过期
+func seconds(expiration time.Duration) int32 {
+	if expiration <= 0 {
+		return 0
+	}
+	// 不足一秒的按照一秒处理，避免变成永不过期
+	secs := int64((expiration + time.Second - 1) / time.Second)
+	if secs > maxRelativeExpiration {
+		return int32(time.Now().Unix() + secs)
+	}
+	return int32(secs)
+}
+
+// stored 把 add、replace 和 cas 没有写入的错误转换为 false
+func stored(err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, memcache.ErrNotStored), errors.Is(err, memcache.ErrCASConflict),
+		errors.Is(err, memcache.ErrCacheMiss):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func convertErr(err error) error {
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return errs.ErrKeyNotExist
+	}
+	return err
+}
+
+func formatFloat(val float64) []byte {
+	return []byte(strconv.FormatFloat(val, 'f', -1, 64))
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package memcached
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemcachedClient(t *testing.T) *memcache.Client {
+	client := memcache.New("localhost:11211")
+	require.NoError(t, client.Ping())
+	return client
+}
+
+func TestCache_e2e_String(t *testing.T) {
+	client := newMemcachedClient(t)
+	c := NewCache(client)
+	ctx := context.Background()
+	defer func() {
+		_, _ = c.Delete(ctx, "name")
+	}()
+
+	val := c.Get(ctx, "name")
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+
+	ok, err := c.SetXX(ctx, "name", "大明", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = c.SetNX(ctx, "name", "大明", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = c.SetNX(ctx, "name", "小明", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = c.CompareAndSwap(ctx, "name", "小明", "中明", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = c.CompareAndSwap(ctx, "name", "大明", "中明", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	val = c.GetSet(ctx, "name", "小明")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "中明", val.Val)
+	val = c.Get(ctx, "name")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "小明", val.Val)
+
+	n, err := c.Delete(ctx, "name", "not-exist")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestCache_e2e_Expiration(t *testing.T) {
+	client := newMemcachedClient(t)
+	c := NewCache(client)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "expiring", 18, time.Second))
+	val := c.Get(ctx, "expiring")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "18", val.Val)
+
+	time.Sleep(2 * time.Second)
+	val = c.Get(ctx, "expiring")
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+}
+
+func TestCache_e2e_Counter(t *testing.T) {
+	client := newMemcachedClient(t)
+	c := NewCache(client)
+	ctx := context.Background()
+	defer func() {
+		_, _ = c.Delete(ctx, "counter", "float-counter")
+	}()
+
+	res, err := c.IncrBy(ctx, "counter", 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), res)
+	res, err = c.IncrBy(ctx, "counter", 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), res)
+	res, err = c.DecrBy(ctx, "counter", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), res)
+
+	f, err := c.IncrByFloat(ctx, "float-counter", 1.5)
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+	f, err = c.IncrByFloat(ctx, "float-counter", 1.25)
+	require.NoError(t, err)
+	assert.Equal(t, 2.75, f)
+}
+
+func TestCache_e2e_NotSupported(t *testing.T) {
+	client := newMemcachedClient(t)
+	c := NewCache(client)
+	ctx := context.Background()
+
+	_, err := c.LPush(ctx, "list", 1)
+	assert.ErrorIs(t, err, ecache.ErrNotSupported)
+	_, err = c.SAdd(ctx, "set", 1)
+	assert.ErrorIs(t, err, ecache.ErrNotSupported)
+}
@@ -0,0 +1,758 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memcached
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCache_Set(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(*gomock.Controller) Client
+
+		key        string
+		val        any
+		expiration time.Duration
+
+		wantErr error
+	}{
+		{
+			name: "set value",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Set(&memcache.Item{Key: "name", Value: []byte("大明"), Expiration: 60}).
+					Return(nil)
+				return client
+			},
+			key:        "name",
+			val:        "大明",
+			expiration: time.Minute,
+		},
+		{
+			name: "set int",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Set(&memcache.Item{Key: "age", Value: []byte("18")}).
+					Return(nil)
+				return client
+			},
+			key: "age",
+			val: 18,
+		},
+		{
+			name: "server error",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Set(&memcache.Item{Key: "name", Value: []byte("大明"), Expiration: 1}).
+					Return(memcache.ErrServerError)
+				return client
+			},
+			key:        "name",
+			val:        "大明",
+			expiration: time.Millisecond,
+
+			wantErr: memcache.ErrServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			err := c.Set(context.Background(), tc.key, tc.val, tc.expiration)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestCache_SetNX(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(*gomock.Controller) Client
+
+		wantRes bool
+		wantErr error
+	}{
+		{
+			name: "stored",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Add(&memcache.Item{Key: "name", Value: []byte("大明"), Expiration: 60}).
+					Return(nil)
+				return client
+			},
+			wantRes: true,
+		},
+		{
+			name: "key exist",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Add(&memcache.Item{Key: "name", Value: []byte("大明"), Expiration: 60}).
+					Return(memcache.ErrNotStored)
+				return client
+			},
+		},
+		{
+			name: "server error",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Add(&memcache.Item{Key: "name", Value: []byte("大明"), Expiration: 60}).
+					Return(memcache.ErrServerError)
+				return client
+			},
+			wantErr: memcache.ErrServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			res, err := c.SetNX(context.Background(), "name", "大明", time.Minute)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestCache_SetXX(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(*gomock.Controller) Client
+
+		wantRes bool
+		wantErr error
+	}{
+		{
+			name: "stored",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Replace(&memcache.Item{Key: "name", Value: []byte("大明"), Expiration: 60}).
+					Return(nil)
+				return client
+			},
+			wantRes: true,
+		},
+		{
+			name: "key not exist",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Replace(&memcache.Item{Key: "name", Value: []byte("大明"), Expiration: 60}).
+					Return(memcache.ErrNotStored)
+				return client
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			res, err := c.SetXX(context.Background(), "name", "大明", time.Minute)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestCache_CompareAndSwap(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(*gomock.Controller) Client
+
+		old any
+
+		wantRes bool
+		wantErr error
+	}{
+		{
+			name: "swapped",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("name").
+					Return(&memcache.Item{Key: "name", Value: []byte("大明")}, nil)
+				client.EXPECT().CompareAndSwap(&memcache.Item{Key: "name", Value: []byte("小明"), Expiration: 60}).
+					Return(nil)
+				return client
+			},
+			old:     "大明",
+			wantRes: true,
+		},
+		{
+			name: "value mismatch",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("name").
+					Return(&memcache.Item{Key: "name", Value: []byte("中明")}, nil)
+				return client
+			},
+			old: "大明",
+		},
+		{
+			name: "key not exist",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("name").Return(nil, memcache.ErrCacheMiss)
+				return client
+			},
+			old: "大明",
+		},
+		{
+			name: "modified concurrently",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("name").
+					Return(&memcache.Item{Key: "name", Value: []byte("大明")}, nil)
+				client.EXPECT().CompareAndSwap(gomock.Any()).Return(memcache.ErrCASConflict)
+				return client
+			},
+			old: "大明",
+		},
+		{
+			name: "get error",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("name").Return(nil, memcache.ErrServerError)
+				return client
+			},
+			old:     "大明",
+			wantErr: memcache.ErrServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			res, err := c.CompareAndSwap(context.Background(), "name", tc.old, "小明", time.Minute)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestCache_Get(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(*gomock.Controller) Client
+
+		wantVal any
+		wantErr error
+	}{
+		{
+			name: "get value",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("name").
+					Return(&memcache.Item{Key: "name", Value: []byte("大明")}, nil)
+				return client
+			},
+			wantVal: "大明",
+		},
+		{
+			name: "key not exist",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("name").Return(nil, memcache.ErrCacheMiss)
+				return client
+			},
+			wantErr: errs.ErrKeyNotExist,
+		},
+		{
+			name: "server error",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("name").Return(nil, memcache.ErrServerError)
+				return client
+			},
+			wantErr: memcache.ErrServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			val := c.Get(context.Background(), "name")
+			assert.Equal(t, tc.wantErr, val.Err)
+			assert.Equal(t, tc.wantVal, val.Val)
+		})
+	}
+}
+
+func TestCache_GetSet(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(*gomock.Controller) Client
+
+		wantVal any
+		wantErr error
+	}{
+		{
+			name: "get and set",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("name").
+					Return(&memcache.Item{Key: "name", Value: []byte("大明"), Expiration: 60}, nil)
+				client.EXPECT().CompareAndSwap(&memcache.Item{Key: "name", Value: []byte("小明")}).
+					Return(nil)
+				return client
+			},
+			wantVal: "大明",
+		},
+		{
+			name: "key not exist",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("name").Return(nil, memcache.ErrCacheMiss)
+				client.EXPECT().Add(&memcache.Item{Key: "name", Value: []byte("小明")}).
+					Return(nil)
+				return client
+			},
+			wantErr: errs.ErrKeyNotExist,
+		},
+		{
+			name: "retry on conflict",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				gomock.InOrder(
+					client.EXPECT().Get("name").
+						Return(&memcache.Item{Key: "name", Value: []byte("大明")}, nil),
+					client.EXPECT().CompareAndSwap(gomock.Any()).Return(memcache.ErrCASConflict),
+					client.EXPECT().Get("name").
+						Return(&memcache.Item{Key: "name", Value: []byte("中明")}, nil),
+					client.EXPECT().CompareAndSwap(gomock.Any()).Return(nil),
+				)
+				return client
+			},
+			wantVal: "中明",
+		},
+		{
+			name: "retry when created concurrently",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				gomock.InOrder(
+					client.EXPECT().Get("name").Return(nil, memcache.ErrCacheMiss),
+					client.EXPECT().Add(gomock.Any()).Return(memcache.ErrNotStored),
+					client.EXPECT().Get("name").
+						Return(&memcache.Item{Key: "name", Value: []byte("中明")}, nil),
+					client.EXPECT().CompareAndSwap(gomock.Any()).Return(nil),
+				)
+				return client
+			},
+			wantVal: "中明",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			val := c.GetSet(context.Background(), "name", "小明")
+			assert.Equal(t, tc.wantErr, val.Err)
+			assert.Equal(t, tc.wantVal, val.Val)
+		})
+	}
+}
+
+func TestCache_Delete(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(*gomock.Controller) Client
+
+		keys []string
+
+		wantN   int64
+		wantErr error
+	}{
+		{
+			name: "delete keys",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Delete("name").Return(nil)
+				client.EXPECT().Delete("age").Return(nil)
+				return client
+			},
+			keys:  []string{"name", "age"},
+			wantN: 2,
+		},
+		{
+			name: "key not exist",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Delete("name").Return(nil)
+				client.EXPECT().Delete("age").Return(memcache.ErrCacheMiss)
+				return client
+			},
+			keys:  []string{"name", "age"},
+			wantN: 1,
+		},
+		{
+			name: "partial failure",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Delete("name").Return(memcache.ErrServerError)
+				client.EXPECT().Delete("age").Return(nil)
+				return client
+			},
+			keys:    []string{"name", "age"},
+			wantN:   1,
+			wantErr: memcache.ErrServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			n, err := c.Delete(context.Background(), tc.keys...)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantN, n)
+		})
+	}
+}
+
+func TestCache_IncrBy(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(*gomock.Controller) Client
+
+		value int64
+
+		wantRes int64
+		wantErr error
+	}{
+		{
+			name: "incr",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Increment("counter", uint64(2)).Return(uint64(3), nil)
+				return client
+			},
+			value:   2,
+			wantRes: 3,
+		},
+		{
+			name: "negative value",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Decrement("counter", uint64(2)).Return(uint64(1), nil)
+				return client
+			},
+			value:   -2,
+			wantRes: 1,
+		},
+		{
+			name: "key not exist",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Increment("counter", uint64(2)).Return(uint64(0), memcache.ErrCacheMiss)
+				client.EXPECT().Add(&memcache.Item{Key: "counter", Value: []byte("2")}).Return(nil)
+				return client
+			},
+			value:   2,
+			wantRes: 2,
+		},
+		{
+			name: "retry when created concurrently",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				gomock.InOrder(
+					client.EXPECT().Increment("counter", uint64(2)).Return(uint64(0), memcache.ErrCacheMiss),
+					client.EXPECT().Add(gomock.Any()).Return(memcache.ErrNotStored),
+					client.EXPECT().Increment("counter", uint64(2)).Return(uint64(5), nil),
+				)
+				return client
+			},
+			value:   2,
+			wantRes: 5,
+		},
+		{
+			name: "not a number",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Increment("counter", uint64(2)).Return(uint64(0), memcache.ErrServerError)
+				return client
+			},
+			value:   2,
+			wantErr: memcache.ErrServerError,
+		},
+		{
+			name: "min int64",
+			mock: func(ctrl *gomock.Controller) Client {
+				return mocks.NewMockMemcachedClient(ctrl)
+			},
+			value:   math.MinInt64,
+			wantErr: ErrOutOfRange,
+		},
+		{
+			name: "exceed max int64",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Increment("counter", uint64(2)).Return(uint64(math.MaxInt64)+1, nil)
+				return client
+			},
+			value:   2,
+			wantErr: ErrOutOfRange,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			res, err := c.IncrBy(context.Background(), "counter", tc.value)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestCache_DecrBy(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(*gomock.Controller) Client
+
+		wantRes int64
+		wantErr error
+	}{
+		{
+			name: "decr",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Decrement("counter", uint64(2)).Return(uint64(1), nil)
+				return client
+			},
+			wantRes: 1,
+		},
+		{
+			name: "key not exist",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Decrement("counter", uint64(2)).Return(uint64(0), memcache.ErrCacheMiss)
+				client.EXPECT().Add(&memcache.Item{Key: "counter", Value: []byte("0")}).Return(nil)
+				return client
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			res, err := c.DecrBy(context.Background(), "counter", 2)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+	// 取反之后会溢出
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	_, err := NewCache(mocks.NewMockMemcachedClient(ctrl)).DecrBy(context.Background(), "counter", math.MinInt64)
+	assert.Equal(t, ErrOutOfRange, err)
+}
+
+func TestCache_IncrByFloat(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(*gomock.Controller) Client
+
+		wantRes float64
+		wantErr bool
+	}{
+		{
+			name: "incr",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("counter").
+					Return(&memcache.Item{Key: "counter", Value: []byte("1.5"), Expiration: 60}, nil)
+				client.EXPECT().CompareAndSwap(&memcache.Item{Key: "counter", Value: []byte("2")}).
+					Return(nil)
+				return client
+			},
+			wantRes: 2,
+		},
+		{
+			name: "key not exist",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("counter").Return(nil, memcache.ErrCacheMiss)
+				client.EXPECT().Add(&memcache.Item{Key: "counter", Value: []byte("0.5")}).Return(nil)
+				return client
+			},
+			wantRes: 0.5,
+		},
+		{
+			name: "retry on conflict",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				gomock.InOrder(
+					client.EXPECT().Get("counter").
+						Return(&memcache.Item{Key: "counter", Value: []byte("1")}, nil),
+					client.EXPECT().CompareAndSwap(gomock.Any()).Return(memcache.ErrCASConflict),
+					client.EXPECT().Get("counter").
+						Return(&memcache.Item{Key: "counter", Value: []byte("2")}, nil),
+					client.EXPECT().CompareAndSwap(gomock.Any()).Return(nil),
+				)
+				return client
+			},
+			wantRes: 2.5,
+		},
+		{
+			name: "not a number",
+			mock: func(ctrl *gomock.Controller) Client {
+				client := mocks.NewMockMemcachedClient(ctrl)
+				client.EXPECT().Get("counter").
+					Return(&memcache.Item{Key: "counter", Value: []byte("大明")}, nil)
+				return client
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			res, err := c.IncrByFloat(context.Background(), "counter", 0.5)
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestCache_CanceledContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	c := NewCache(mocks.NewMockMemcachedClient(ctrl))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.IncrBy(ctx, "counter", 1)
+	assert.Equal(t, context.Canceled, err)
+	_, err = c.IncrByFloat(ctx, "counter", 1)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, context.Canceled, c.GetSet(ctx, "name", "大明").Err)
+}
+
+func TestCache_NotSupported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	c := NewCache(mocks.NewMockMemcachedClient(ctrl))
+	ctx := context.Background()
+
+	ops := map[string]func() error{
+		"LPush": func() error {
+			_, err := c.LPush(ctx, "list", 1)
+			return err
+		},
+		"LPop":  func() error { return c.LPop(ctx, "list").Err },
+		"BLPop": func() error { return c.BLPop(ctx, "list", time.Second).Err },
+		"BRPop": func() error { return c.BRPop(ctx, "list", time.Second).Err },
+		"BLMove": func() error {
+			return c.BLMove(ctx, "src", "dst", ecache.ListLeft, ecache.ListRight, time.Second).Err
+		},
+		"LMove": func() error {
+			return c.LMove(ctx, "src", "dst", ecache.ListLeft, ecache.ListRight).Err
+		},
+		"RPopLPush": func() error { return c.RPopLPush(ctx, "src", "dst").Err },
+		"LRem": func() error {
+			_, err := c.LRem(ctx, "list", 0, 1)
+			return err
+		},
+		"SAdd": func() error {
+			_, err := c.SAdd(ctx, "set", 1)
+			return err
+		},
+		"SRem": func() error {
+			_, err := c.SRem(ctx, "set", 1)
+			return err
+		},
+	}
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			err := op()
+			assert.True(t, errors.Is(err, ecache.ErrNotSupported))
+		})
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	testCases := []struct {
+		name       string
+		expiration time.Duration
+		want       int32
+	}{
+		{
+			name: "no expiration",
+			want: 0,
+		},
+		{
+			name:       "negative",
+			expiration: -time.Second,
+			want:       0,
+		},
+		{
+			name:       "less than a second",
+			expiration: time.Millisecond,
+			want:       1,
+		},
+		{
+			name:       "round up",
+			expiration: 1500 * time.Millisecond,
+			want:       2,
+		},
+		{
+			name:       "thirty days",
+			expiration: 30 * 24 * time.Hour,
+			want:       maxRelativeExpiration,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, seconds(tc.expiration))
+		})
+	}
+}
+
+func TestSeconds_Absolute(t *testing.T) {
+	expiration := 31 * 24 * time.Hour
+	now := time.Now().Unix()
+	got := int64(seconds(expiration))
+	assert.GreaterOrEqual(t, got, now+int64(expiration/time.Second))
+	assert.LessOrEqual(t, got, time.Now().Unix()+int64(expiration/time.Second))
+}
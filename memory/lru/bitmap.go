@@ -0,0 +1,137 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lru
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/bitmap"
+)
+
+var errNotBitmap = errors.New("当前key不是bitmap类型")
+
+func (c *Cache) SetBit(ctx context.Context, key string, offset int64, value int) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.SetBit(ctx, key, offset, value)
+}
+
+func (c unlocked) SetBit(ctx context.Context, key string, offset int64, value int) (int64, error) {
+	if err := bitmap.CheckOffset(offset); err != nil {
+		return 0, err
+	}
+	if err := bitmap.CheckBit(int64(value)); err != nil {
+		return 0, err
+	}
+	data, ok, err := c.bitmapOf(key)
+	if err != nil {
+		return 0, err
+	}
+	data, old := bitmap.SetBit(data, offset, value)
+	if ok {
+		c.cache.replace(key, data)
+	} else {
+		c.cache.add(key, data)
+	}
+	return old, nil
+}
+
+func (c *Cache) GetBit(ctx context.Context, key string, offset int64) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.GetBit(ctx, key, offset)
+}
+
+func (c unlocked) GetBit(ctx context.Context, key string, offset int64) (int64, error) {
+	if err := bitmap.CheckOffset(offset); err != nil {
+		return 0, err
+	}
+	data, _, err := c.bitmapOf(key)
+	if err != nil {
+		return 0, err
+	}
+	return bitmap.GetBit(data, offset), nil
+}
+
+func (c *Cache) BitCount(ctx context.Context, key string, start, end int64) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.BitCount(ctx, key, start, end)
+}
+
+func (c unlocked) BitCount(ctx context.Context, key string, start, end int64) (int64, error) {
+	data, _, err := c.bitmapOf(key)
+	if err != nil {
+		return 0, err
+	}
+	return bitmap.Count(data, start, end), nil
+}
+
+func (c *Cache) BitOp(ctx context.Context, op ecache.BitOperation, destKey string, keys ...string) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.BitOp(ctx, op, destKey, keys...)
+}
+
+func (c unlocked) BitOp(ctx context.Context, op ecache.BitOperation, destKey string, keys ...string) (int64, error) {
+	srcs := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		data, _, err := c.bitmapOf(key)
+		if err != nil {
+			return 0, err
+		}
+		srcs = append(srcs, data)
+	}
+	res, err := bitmap.Op(op, srcs)
+	if err != nil {
+		return 0, err
+	}
+	// 和 Redis 一样，结果为空的时候删除 destKey，否则覆盖 destKey 并且去掉过期时间
+	if len(res) == 0 {
+		c.cache.remove(destKey)
+		return 0, nil
+	}
+	c.cache.add(destKey, res)
+	return int64(len(res)), nil
+}
+
+func (c *Cache) BitPos(ctx context.Context, key string, bit int64, pos ...int64) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.BitPos(ctx, key, bit, pos...)
+}
+
+func (c unlocked) BitPos(ctx context.Context, key string, bit int64, pos ...int64) (int64, error) {
+	data, _, err := c.bitmapOf(key)
+	if err != nil {
+		return 0, err
+	}
+	return bitmap.Pos(data, bit, pos...)
+}
+
+// bitmapOf 返回 key 对应的位图，key 不存在的时候返回 nil 和 false
+func (c unlocked) bitmapOf(key string) ([]byte, bool, error) {
+	val, ok := c.cache.get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	data, ok := bitmap.Bytes(val)
+	if !ok {
+		return nil, false, errNotBitmap
+	}
+	return data, true, nil
+}
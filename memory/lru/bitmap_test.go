@@ -0,0 +1,128 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lru
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/bitmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_SetBit(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+
+	old, err := c.SetBit(ctx, "dau", 7, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), old)
+	old, err = c.SetBit(ctx, "dau", 7, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), old)
+	assert.Equal(t, []byte{0x01}, c.Get(ctx, "dau").Val)
+
+	bit, err := c.GetBit(ctx, "dau", 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), bit)
+	bit, err = c.GetBit(ctx, "not-exist", 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), bit)
+
+	// 可以对 Set 写入的字符串执行位操作，并且保留过期时间
+	require.NoError(t, c.Set(ctx, "flags", "a", time.Millisecond*50))
+	old, err = c.SetBit(ctx, "flags", 6, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), old)
+	assert.Equal(t, []byte("c"), c.Get(ctx, "flags").Val)
+	time.Sleep(time.Millisecond * 100)
+	assert.True(t, c.Get(ctx, "flags").KeyNotFound())
+
+	_, err = c.SetBit(ctx, "dau", -1, 1)
+	assert.Equal(t, bitmap.ErrInvalidOffset, err)
+	_, err = c.SetBit(ctx, "dau", 1, 2)
+	assert.Equal(t, bitmap.ErrInvalidBit, err)
+	_, err = c.IncrBy(ctx, "counter", 1)
+	require.NoError(t, err)
+	_, err = c.SetBit(ctx, "counter", 1, 1)
+	assert.Equal(t, errNotBitmap, err)
+	_, err = c.GetBit(ctx, "counter", 1)
+	assert.Equal(t, errNotBitmap, err)
+}
+
+// TestCache_SetBitCopy SetBit 不会修改调用方写入或者读到的切片
+func TestCache_SetBitCopy(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+
+	src := []byte{0x00}
+	require.NoError(t, c.Set(ctx, "flags", src, 0))
+	_, err := c.SetBit(ctx, "flags", 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00}, src)
+
+	got := c.Get(ctx, "flags").Val
+	_, err = c.SetBit(ctx, "flags", 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x80}, got)
+	assert.Equal(t, []byte{0xC0}, c.Get(ctx, "flags").Val)
+}
+
+func TestCache_BitCount(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+	require.NoError(t, c.Set(ctx, "key", "foobar", time.Minute))
+
+	cnt, err := c.BitCount(ctx, "key", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(26), cnt)
+	cnt, err = c.BitCount(ctx, "key", 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), cnt)
+	cnt, err = c.BitCount(ctx, "not-exist", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+
+	pos, err := c.BitPos(ctx, "key", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pos)
+	pos, err = c.BitPos(ctx, "not-exist", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), pos)
+}
+
+func TestCache_BitOp(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+	require.NoError(t, c.Set(ctx, "key1", "foobar", time.Minute))
+	require.NoError(t, c.Set(ctx, "key2", "abcdef", time.Minute))
+
+	n, err := c.BitOp(ctx, ecache.BitOpAnd, "dest", "key1", "key2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+	assert.Equal(t, []byte("`bc`ab"), c.Get(ctx, "dest").Val)
+
+	// 所有的 key 都不存在的时候删除 destKey
+	n, err = c.BitOp(ctx, ecache.BitOpOr, "dest", "not-exist")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.True(t, c.Get(ctx, "dest").KeyNotFound())
+
+	_, err = c.BitOp(ctx, ecache.BitOpNot, "dest", "key1", "key2")
+	assert.Equal(t, bitmap.ErrInvalidOp, err)
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
@@ -26,23 +27,47 @@ import (
 	"github.com/ecodeclub/ekit/list"
 
 	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/blocking"
 	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/internal/pipeline"
+	"github.com/ecodeclub/ecache/internal/refresh"
 )
 
 var (
-	_ ecache.Cache = (*Cache)(nil)
+	_ ecache.Cache            = (*Cache)(nil)
+	_ ecache.VersionedCache   = (*Cache)(nil)
+	_ ecache.BitmapCache      = (*Cache)(nil)
+	_ ecache.HyperLogLogCache = (*Cache)(nil)
+	_ ecache.GeoCache         = (*Cache)(nil)
+	_ ecache.Cache            = unlocked{}
+	_ ecache.BitmapCache      = unlocked{}
+	_ ecache.HyperLogLogCache = unlocked{}
+	_ ecache.GeoCache         = unlocked{}
+	_ ecache.Pipeliner        = (*Cache)(nil)
+	_ ecache.Transactional    = (*Cache)(nil)
 )
 
 type entry struct {
 	key       string
 	value     any
 	expiresAt time.Time
+	// staleAt 软过期时间，零值表示没有设置软过期
+	staleAt time.Time
+	// softTTL 和 hardTTL 记录写入时的过期时间，后台刷新的时候复用
+	softTTL time.Duration
+	hardTTL time.Duration
+	// version 每次修改都会更新，用于乐观锁事务
+	version uint64
 }
 
 func (e entry) isExpired() bool {
 	return !e.expiresAt.IsZero() && e.expiresAt.Before(time.Now())
 }
 
+func (e entry) isStale() bool {
+	return !e.staleAt.IsZero() && e.staleAt.Before(time.Now())
+}
+
 type EvictCallback func(key string, value any)
 
 type Option func(l *Cache)
@@ -59,6 +84,30 @@ func WithCycleInterval(interval time.Duration) Option {
 	}
 }
 
+// WithLoader 设置加载数据的方法，软过期的 key 被读取的时候会通过它在后台刷新
+func WithLoader(loader ecache.Loader) Option {
+	return func(l *Cache) {
+		l.loader = loader
+	}
+}
+
+// WithRefreshAhead 开启提前刷新。
+// 当 key 在过期前 window 时间内被读取的时候，会通过 WithLoader 设置的 loader 在后台异步刷新，
+// concurrency 限制了同时执行的刷新任务数量，超过的刷新请求会被直接忽略，小于等于 0 表示不限制
+func WithRefreshAhead(window time.Duration, concurrency int) Option {
+	return func(l *Cache) {
+		l.refreshAhead = window
+		l.refreshLimit = concurrency
+	}
+}
+
+// WithRefreshErrorHandler 设置后台刷新失败时的回调
+func WithRefreshErrorHandler(handler func(key string, err error)) Option {
+	return func(l *Cache) {
+		l.refreshErrHandler = handler
+	}
+}
+
 type Cache struct {
 	lock          sync.RWMutex
 	capacity      int
@@ -66,6 +115,25 @@ type Cache struct {
 	data          map[string]*element[entry]
 	callback      EvictCallback
 	cycleInterval time.Duration
+	// version 全局递增的写入序号，每次修改都会分配一个新的序号给对应的 entry。
+	// 因为序号不会重复，所以 key 被删除之后再写入也能检测出来
+	version uint64
+
+	loader            ecache.Loader
+	refreshGroup      *refresh.Group
+	refreshAhead      time.Duration
+	refreshLimit      int
+	refreshErrHandler func(key string, err error)
+
+	// waiters 阻塞在 BLPop、BRPop 和 BLMove 上的调用者
+	waiters *blocking.Waiters
+}
+
+// unlocked 不加锁的 Cache，调用它的方法之前必须先获得锁。
+// 所有 ecache.Cache 的方法都必须在 unlocked 上重新实现，否则会调用到 Cache 上加锁的方法导致死锁
+// 缓存不是内嵌的，所以漏掉了某个方法的时候编译就会失败，而不是悄悄地调用到加锁的方法
+type unlocked struct {
+	cache *Cache
 }
 
 func NewCache(capacity int, options ...Option) *Cache {
@@ -78,6 +146,8 @@ func NewCache(capacity int, options ...Option) *Cache {
 	for _, opt := range options {
 		opt(res)
 	}
+	res.refreshGroup = refresh.NewGroup(res.refreshLimit)
+	res.waiters = blocking.NewWaiters(&res.lock)
 	res.cleanCycle()
 	return res
 }
@@ -105,6 +175,8 @@ func (c *Cache) cleanCycle() {
 }
 
 func (c *Cache) pushEntry(key string, ent entry) bool {
+	c.version++
+	ent.version = c.version
 	if len(c.data) >= c.capacity && c.len() >= c.capacity {
 		if elem, ok := c.data[key]; ok {
 			elem.Value = ent
@@ -125,7 +197,23 @@ func (c *Cache) pushEntry(key string, ent entry) bool {
 
 func (c *Cache) addTTL(key string, value any, expiration time.Duration) bool {
 	ent := entry{key: key, value: value,
-		expiresAt: time.Now().Add(expiration)}
+		expiresAt: time.Now().Add(expiration),
+		hardTTL:   expiration,
+	}
+	return c.pushEntry(key, ent)
+}
+
+// addSoftTTL 写入一个带软过期时间的值，hardTTL 为 0 的时候表示永不过期
+func (c *Cache) addSoftTTL(key string, value any, softTTL, hardTTL time.Duration) bool {
+	now := time.Now()
+	ent := entry{key: key, value: value,
+		staleAt: now.Add(softTTL),
+		softTTL: softTTL,
+		hardTTL: hardTTL,
+	}
+	if hardTTL > 0 {
+		ent.expiresAt = now.Add(hardTTL)
+	}
 	return c.pushEntry(key, ent)
 }
 
@@ -135,18 +223,81 @@ func (c *Cache) add(key string, value any) bool {
 }
 
 func (c *Cache) get(key string) (value any, ok bool) {
+	ent, ok := c.getEntry(key)
+	return ent.value, ok
+}
+
+func (c *Cache) getEntry(key string) (ent entry, ok bool) {
 	if elem, exist := c.data[key]; exist {
-		ent := elem.Value
+		ent = elem.Value
 		if ent.isExpired() {
 			c.removeElement(elem)
-			return
+			return entry{}, false
 		}
 		c.list.moveToFront(elem)
-		return ent.value, true
+		return ent, true
 	}
 	return
 }
 
+// shouldRefreshAhead 判断 key 是否已经进入了提前刷新的窗口
+func (c *Cache) shouldRefreshAhead(ent entry) bool {
+	return c.refreshAhead > 0 && !ent.expiresAt.IsZero() &&
+		time.Until(ent.expiresAt) <= c.refreshAhead
+}
+
+// refresh 通过 loader 在后台重新加载 key，同一个 key 同时只会有一个刷新任务
+func (c *Cache) refresh(ent entry) {
+	if c.loader == nil {
+		return
+	}
+	c.refreshGroup.Go(ent.key, func() {
+		val, err := c.loader(context.Background(), ent.key)
+		if err != nil {
+			// 刷新失败就继续使用旧值，直到过期
+			if c.refreshErrHandler != nil {
+				c.refreshErrHandler(ent.key, err)
+			}
+			return
+		}
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		// 加载期间 key 被删除、被覆盖或者已经过期了，加载的结果已经是旧数据了，直接丢弃
+		if c.versionOf(ent.key) != ent.version {
+			return
+		}
+		if ent.staleAt.IsZero() {
+			c.addTTL(ent.key, val, ent.hardTTL)
+			return
+		}
+		c.addSoftTTL(ent.key, val, ent.softTTL, ent.hardTTL)
+	})
+}
+
+// touch 原地修改了 key 对应的值之后，更新它的版本
+func (c *Cache) touch(key string) {
+	if elem, ok := c.data[key]; ok {
+		c.version++
+		elem.Value.version = c.version
+	}
+}
+
+// replace 原地修改 key 的值，保留过期时间
+func (c *Cache) replace(key string, value any) {
+	if elem, ok := c.data[key]; ok {
+		elem.Value.value = value
+		c.touch(key)
+	}
+}
+
+// versionOf 返回 key 当前的版本，key 不存在或者已经过期的时候返回 0
+func (c *Cache) versionOf(key string) uint64 {
+	if elem, ok := c.data[key]; ok && !elem.Value.isExpired() {
+		return elem.Value.version
+	}
+	return 0
+}
+
 func (c *Cache) removeOldest() {
 	if elem := c.list.back(); elem != nil {
 		c.removeElement(elem)
@@ -201,46 +352,121 @@ func (c *Cache) len() int {
 func (c *Cache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	c.addTTL(key, val, expiration)
+	return unlocked{c}.Set(ctx, key, val, expiration)
+}
+
+func (c unlocked) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	c.cache.addTTL(key, val, expiration)
 	return nil
 }
 
 func (c *Cache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return unlocked{c}.SetNX(ctx, key, val, expiration)
+}
 
-	if c.contains(key) {
+func (c unlocked) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	if c.cache.contains(key) {
 		return false, nil
 	}
 
-	c.addTTL(key, val, expiration)
+	c.cache.addTTL(key, val, expiration)
+
+	return true, nil
+}
+
+func (c *Cache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.SetXX(ctx, key, val, expiration)
+}
 
+func (c unlocked) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	if !c.cache.contains(key) {
+		return false, nil
+	}
+	c.cache.addTTL(key, val, expiration)
 	return true, nil
 }
 
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.CompareAndSwap(ctx, key, old, new, expiration)
+}
+
+func (c unlocked) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	cur, ok := c.cache.get(key)
+	if !ok || !reflect.DeepEqual(cur, old) {
+		return false, nil
+	}
+	c.cache.addTTL(key, new, expiration)
+	return true, nil
+}
+
+// SetIfVersion 只有在 key 当前的版本等于 version 的时候才写入，version 为 0 表示 key 不存在
+func (c *Cache) SetIfVersion(ctx context.Context, key string, val any, version uint64, expiration time.Duration) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.versionOf(key) != version {
+		return false, nil
+	}
+	c.addTTL(key, val, expiration)
+	return true, nil
+}
+
+// SetWithSoftTTL 设置一个同时带有软过期时间和硬过期时间的键值对。
+// 过了软过期时间之后，Get 依旧会返回旧值，但是会把 Value.Stale 标记为 true，
+// 并且在后台通过 WithLoader 设置的 loader 刷新；过了硬过期时间之后就等同于 key 不存在。
+// 当 hardTTL 为 0 时，表示永不过期，否则 softTTL 必须小于 hardTTL，不然返回 ecache.ErrInvalidSoftTTL
+func (c *Cache) SetWithSoftTTL(ctx context.Context, key string, val any, softTTL, hardTTL time.Duration) error {
+	if hardTTL > 0 && softTTL >= hardTTL {
+		return errs.ErrInvalidSoftTTL
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.addSoftTTL(key, val, softTTL, hardTTL)
+	return nil
+}
+
 func (c *Cache) Get(ctx context.Context, key string) (val ecache.Value) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	var ok bool
-	val.Val, ok = c.get(key)
+	return unlocked{c}.Get(ctx, key)
+}
+
+func (c unlocked) Get(ctx context.Context, key string) (val ecache.Value) {
+	ent, ok := c.cache.getEntry(key)
 	if !ok {
 		val.Err = errs.ErrKeyNotExist
+		return
+	}
+	val.Val = ent.value
+	val.Version = ent.version
+	if ent.isStale() {
+		val.Stale = true
+		c.cache.refresh(ent)
+	} else if c.cache.shouldRefreshAhead(ent) {
+		c.cache.refresh(ent)
 	}
-
 	return
 }
 
 func (c *Cache) GetSet(ctx context.Context, key string, val string) (result ecache.Value) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return unlocked{c}.GetSet(ctx, key, val)
+}
 
+func (c unlocked) GetSet(ctx context.Context, key string, val string) (result ecache.Value) {
 	var ok bool
-	result.Val, ok = c.get(key)
+	result.Val, ok = c.cache.get(key)
 	if !ok {
 		result.Err = errs.ErrKeyNotExist
 	}
 
-	c.add(key, val)
+	c.cache.add(key, val)
 
 	return
 }
@@ -248,17 +474,20 @@ func (c *Cache) GetSet(ctx context.Context, key string, val string) (result ecac
 func (c *Cache) Delete(ctx context.Context, key ...string) (int64, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return unlocked{c}.Delete(ctx, key...)
+}
 
+func (c unlocked) Delete(ctx context.Context, key ...string) (int64, error) {
 	n := int64(0)
 	for _, k := range key {
 		if ctx.Err() != nil {
 			return n, ctx.Err()
 		}
-		_, ok := c.get(k)
+		_, ok := c.cache.get(k)
 		if !ok {
 			continue
 		}
-		if c.remove(k) {
+		if c.cache.remove(k) {
 			n++
 		} else {
 			return n, fmt.Errorf("%w: key = %s", errs.ErrDeleteKeyFailed, k)
@@ -267,6 +496,23 @@ func (c *Cache) Delete(ctx context.Context, key ...string) (int64, error) {
 	return n, nil
 }
 
+// Pipeline 所有排队的操作在执行的时候只会加一次锁
+func (c *Cache) Pipeline() ecache.Pipeline {
+	return pipeline.New(c.run)
+}
+
+// Watch 乐观锁事务，每次修改 key 都会更新它的版本，提交的时候在锁里面比较版本
+func (c *Cache) Watch(ctx context.Context, fn func(tx ecache.Tx) error, keys ...string) error {
+	return pipeline.Watch(ctx, c, c.run, c.versionOf, fn, keys...)
+}
+
+// run 加锁之后通过不加锁的 unlocked 执行 fn
+func (c *Cache) run(fn func(c ecache.Cache)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	fn(unlocked{c})
+}
+
 // anySliceToValueSlice 公共转换
 func (c *Cache) anySliceToValueSlice(data ...any) []ecache.Value {
 	newVal := make([]ecache.Value, len(data), cap(data))
@@ -281,18 +527,19 @@ func (c *Cache) anySliceToValueSlice(data ...any) []ecache.Value {
 func (c *Cache) LPush(ctx context.Context, key string, val ...any) (int64, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return unlocked{c}.LPush(ctx, key, val...)
+}
 
+func (c unlocked) LPush(ctx context.Context, key string, val ...any) (int64, error) {
 	var (
 		ok     bool
 		result = ecache.Value{}
 	)
-	result.Val, ok = c.get(key)
+	result.Val, ok = c.cache.get(key)
 	if !ok {
-		l := &list.ConcurrentList[ecache.Value]{
-			List: list.NewLinkedListOf[ecache.Value](c.anySliceToValueSlice(val...)),
+		result.Val = &list.ConcurrentList[ecache.Value]{
+			List: list.NewLinkedList[ecache.Value](),
 		}
-		c.add(key, l)
-		return int64(l.Len()), nil
 	}
 
 	data, ok := result.Val.(list.List[ecache.Value])
@@ -300,23 +547,29 @@ func (c *Cache) LPush(ctx context.Context, key string, val ...any) (int64, error
 		return 0, errors.New("当前key不是list类型")
 	}
 
-	err := data.Append(c.anySliceToValueSlice(val)...)
-	if err != nil {
-		return 0, err
+	// 和 Redis 一样依次写入头部，LPush a b c 之后列表是 [c b a]
+	for _, item := range c.cache.anySliceToValueSlice(val...) {
+		if err := data.Add(0, item); err != nil {
+			return 0, err
+		}
 	}
 
-	c.add(key, data)
+	c.cache.add(key, data)
+	c.cache.waiters.Notify(key, len(val))
 	return int64(data.Len()), nil
 }
 
 func (c *Cache) LPop(ctx context.Context, key string) (val ecache.Value) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return unlocked{c}.LPop(ctx, key)
+}
 
+func (c unlocked) LPop(ctx context.Context, key string) (val ecache.Value) {
 	var (
 		ok bool
 	)
-	val.Val, ok = c.get(key)
+	val.Val, ok = c.cache.get(key)
 	if !ok {
 		val.Err = errs.ErrKeyNotExist
 		return
@@ -333,20 +586,199 @@ func (c *Cache) LPop(ctx context.Context, key string) (val ecache.Value) {
 		val.Err = err
 		return
 	}
+	c.cache.touch(key)
 
 	val = value
 	return
 }
 
+func (c *Cache) BLPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return c.waiters.Wait(ctx, key, timeout, func() ecache.Value {
+		return unlocked{c}.pop(key, ecache.ListLeft)
+	})
+}
+
+// BLPop 在 Pipeline 和事务中和 Redis 一样不会阻塞
+func (c unlocked) BLPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return c.pop(key, ecache.ListLeft)
+}
+
+func (c *Cache) BRPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return c.waiters.Wait(ctx, key, timeout, func() ecache.Value {
+		return unlocked{c}.pop(key, ecache.ListRight)
+	})
+}
+
+func (c unlocked) BRPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return c.pop(key, ecache.ListRight)
+}
+
+func (c *Cache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) ecache.Value {
+	return c.waiters.Wait(ctx, source, timeout, func() ecache.Value {
+		return unlocked{c}.move(source, destination, srcPos, destPos)
+	})
+}
+
+func (c unlocked) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) ecache.Value {
+	return c.move(source, destination, srcPos, destPos)
+}
+
+func (c *Cache) LMove(ctx context.Context, source, destination string, srcPos, destPos ecache.ListDirection) ecache.Value {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.LMove(ctx, source, destination, srcPos, destPos)
+}
+
+func (c unlocked) LMove(ctx context.Context, source, destination string, srcPos, destPos ecache.ListDirection) ecache.Value {
+	return c.move(source, destination, srcPos, destPos)
+}
+
+func (c *Cache) RPopLPush(ctx context.Context, source, destination string) ecache.Value {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.RPopLPush(ctx, source, destination)
+}
+
+func (c unlocked) RPopLPush(ctx context.Context, source, destination string) ecache.Value {
+	return c.move(source, destination, ecache.ListRight, ecache.ListLeft)
+}
+
+func (c *Cache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.LRem(ctx, key, count, val)
+}
+
+func (c unlocked) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	data, err := c.listOf(key)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	indexes := matchIndexes(data.AsSlice(), count, val)
+	// 从后往前删除，前面元素的下标不会变化
+	for i := range indexes {
+		idx := indexes[i]
+		if count >= 0 {
+			idx = indexes[len(indexes)-1-i]
+		}
+		if _, err = data.Delete(idx); err != nil {
+			return 0, err
+		}
+	}
+	if len(indexes) > 0 {
+		c.cache.touch(key)
+	}
+	return int64(len(indexes)), nil
+}
+
+// matchIndexes 按照 LRem 的 count 语义返回需要删除的元素下标
+func matchIndexes(items []ecache.Value, count int64, val any) []int {
+	res := make([]int, 0, 4)
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	for i := range items {
+		idx := i
+		if count < 0 {
+			idx = len(items) - 1 - i
+		}
+		if !reflect.DeepEqual(items[idx].Val, val) {
+			continue
+		}
+		res = append(res, idx)
+		if limit > 0 && int64(len(res)) == limit {
+			break
+		}
+	}
+	return res
+}
+
+// listOf 返回 key 对应的列表，key 不存在的时候返回 nil
+func (c unlocked) listOf(key string) (list.List[ecache.Value], error) {
+	val, ok := c.cache.get(key)
+	if !ok {
+		return nil, nil
+	}
+	data, ok := val.(list.List[ecache.Value])
+	if !ok {
+		return nil, errors.New("当前key不是list类型")
+	}
+	return data, nil
+}
+
+// pop 从列表的 dir 一端移除一个元素，列表不存在或者为空的时候返回 errs.ErrKeyNotExist
+func (c unlocked) pop(key string, dir ecache.ListDirection) (val ecache.Value) {
+	data, err := c.listOf(key)
+	if err != nil {
+		val.Err = err
+		return
+	}
+	if data == nil || data.Len() == 0 {
+		val.Err = errs.ErrKeyNotExist
+		return
+	}
+	idx := 0
+	if dir == ecache.ListRight {
+		idx = data.Len() - 1
+	}
+	val, val.Err = data.Delete(idx)
+	c.cache.touch(key)
+	return
+}
+
+// push 向列表的 dir 一端写入一个元素，并且唤醒一个等待该列表的调用者
+func (c unlocked) push(key string, dir ecache.ListDirection, val ecache.Value) error {
+	data, err := c.listOf(key)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		c.cache.add(key, &list.ConcurrentList[ecache.Value]{
+			List: list.NewLinkedListOf[ecache.Value]([]ecache.Value{val}),
+		})
+	} else {
+		if dir == ecache.ListRight {
+			err = data.Append(val)
+		} else {
+			err = data.Add(0, val)
+		}
+		if err != nil {
+			return err
+		}
+		c.cache.touch(key)
+	}
+	c.cache.waiters.Notify(key, 1)
+	return nil
+}
+
+// move 将 source 的一个元素移动到 destination。和 Redis 一样，destination 类型不对的时候不会修改 source
+func (c unlocked) move(source, destination string, srcPos, destPos ecache.ListDirection) (val ecache.Value) {
+	if _, val.Err = c.listOf(destination); val.Err != nil {
+		return
+	}
+	val = c.pop(source, srcPos)
+	if val.Err != nil {
+		return
+	}
+	val.Err = c.push(destination, destPos, val)
+	return
+}
+
 func (c *Cache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return unlocked{c}.SAdd(ctx, key, members...)
+}
 
+func (c unlocked) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
 	var (
 		ok     bool
 		result = ecache.Value{}
 	)
-	result.Val, ok = c.get(key)
+	result.Val, ok = c.cache.get(key)
 	if !ok {
 		result.Val = set.NewMapSet[any](8)
 	}
@@ -359,7 +791,7 @@ func (c *Cache) SAdd(ctx context.Context, key string, members ...any) (int64, er
 	for _, value := range members {
 		s.Add(value)
 	}
-	c.add(key, s)
+	c.cache.add(key, s)
 
 	return int64(len(s.Keys())), nil
 }
@@ -367,8 +799,11 @@ func (c *Cache) SAdd(ctx context.Context, key string, members ...any) (int64, er
 func (c *Cache) SRem(ctx context.Context, key string, members ...any) (int64, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return unlocked{c}.SRem(ctx, key, members...)
+}
 
-	result, ok := c.get(key)
+func (c unlocked) SRem(ctx context.Context, key string, members ...any) (int64, error) {
+	result, ok := c.cache.get(key)
 	if !ok {
 		return 0, errs.ErrKeyNotExist
 	}
@@ -385,20 +820,26 @@ func (c *Cache) SRem(ctx context.Context, key string, members ...any) (int64, er
 			rems++
 		}
 	}
+	if rems > 0 {
+		c.cache.touch(key)
+	}
 	return rems, nil
 }
 
 func (c *Cache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return unlocked{c}.IncrBy(ctx, key, value)
+}
 
+func (c unlocked) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
 	var (
 		ok     bool
 		result = ecache.Value{}
 	)
-	result.Val, ok = c.get(key)
+	result.Val, ok = c.cache.get(key)
 	if !ok {
-		c.add(key, value)
+		c.cache.add(key, value)
 		return value, nil
 	}
 
@@ -408,7 +849,7 @@ func (c *Cache) IncrBy(ctx context.Context, key string, value int64) (int64, err
 	}
 
 	newVal := incr + value
-	c.add(key, newVal)
+	c.cache.add(key, newVal)
 
 	return newVal, nil
 }
@@ -416,14 +857,17 @@ func (c *Cache) IncrBy(ctx context.Context, key string, value int64) (int64, err
 func (c *Cache) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return unlocked{c}.DecrBy(ctx, key, value)
+}
 
+func (c unlocked) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
 	var (
 		ok     bool
 		result = ecache.Value{}
 	)
-	result.Val, ok = c.get(key)
+	result.Val, ok = c.cache.get(key)
 	if !ok {
-		c.add(key, -value)
+		c.cache.add(key, -value)
 		return -value, nil
 	}
 
@@ -433,7 +877,7 @@ func (c *Cache) DecrBy(ctx context.Context, key string, value int64) (int64, err
 	}
 
 	newVal := decr - value
-	c.add(key, newVal)
+	c.cache.add(key, newVal)
 
 	return newVal, nil
 }
@@ -441,14 +885,17 @@ func (c *Cache) DecrBy(ctx context.Context, key string, value int64) (int64, err
 func (c *Cache) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return unlocked{c}.IncrByFloat(ctx, key, value)
+}
 
+func (c unlocked) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
 	var (
 		ok     bool
 		result = ecache.Value{}
 	)
-	result.Val, ok = c.get(key)
+	result.Val, ok = c.cache.get(key)
 	if !ok {
-		c.add(key, value)
+		c.cache.add(key, value)
 		return value, nil
 	}
 
@@ -458,7 +905,7 @@ func (c *Cache) IncrByFloat(ctx context.Context, key string, value float64) (flo
 	}
 
 	newVal := val + value
-	c.add(key, newVal)
+	c.cache.add(key, newVal)
 
 	return newVal, nil
 }
@@ -17,6 +17,8 @@ package lru
 import (
 	"context"
 	"errors"
+	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -1017,3 +1019,540 @@ func TestCache_IncrByFloat(t *testing.T) {
 		})
 	}
 }
+
+func TestCache_SetWithSoftTTL(t *testing.T) {
+	var loadCnt int64
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (any, error) {
+		atomic.AddInt64(&loadCnt, 1)
+		<-release
+		if key == "fail" {
+			return nil, errors.New("mock error")
+		}
+		return "new value", nil
+	}
+	c := NewCache(10, WithLoader(loader))
+	ctx := context.Background()
+
+	// 还没到软过期时间
+	err := c.SetWithSoftTTL(ctx, "fresh", "value", time.Minute, time.Hour)
+	require.NoError(t, err)
+	val := c.Get(ctx, "fresh")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "value", val.Val)
+	assert.False(t, val.Stale)
+
+	// 软过期之后返回旧值，并且刷新完成之前只会触发一次刷新
+	err = c.SetWithSoftTTL(ctx, "stale", "old value", time.Millisecond*50, time.Hour)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 60)
+	val = c.Get(ctx, "stale")
+	assert.Equal(t, "old value", val.Val)
+	assert.True(t, val.Stale)
+	val = c.Get(ctx, "stale")
+	assert.Equal(t, "old value", val.Val)
+	assert.True(t, val.Stale)
+	release <- struct{}{}
+	assert.Eventually(t, func() bool {
+		val = c.Get(ctx, "stale")
+		return val.Val == "new value" && !val.Stale
+	}, time.Second, time.Millisecond*5)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&loadCnt))
+
+	// 刷新失败的时候继续返回旧值
+	err = c.SetWithSoftTTL(ctx, "fail", "old value", time.Millisecond, time.Hour)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 5)
+	val = c.Get(ctx, "fail")
+	assert.True(t, val.Stale)
+	release <- struct{}{}
+	val = c.Get(ctx, "fail")
+	assert.Equal(t, "old value", val.Val)
+
+	// 硬过期之后等同于 key 不存在
+	err = c.SetWithSoftTTL(ctx, "expired", "value", time.Millisecond, time.Millisecond*5)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 10)
+	val = c.Get(ctx, "expired")
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+
+	// hardTTL 为 0 表示永不过期
+	err = c.SetWithSoftTTL(ctx, "forever", "value", time.Hour, 0)
+	require.NoError(t, err)
+	val = c.Get(ctx, "forever")
+	assert.Equal(t, "value", val.Val)
+	close(release)
+}
+
+func TestCache_SetWithSoftTTL_Invalid(t *testing.T) {
+	c := NewCache(10)
+	ctx := context.Background()
+	err := c.SetWithSoftTTL(ctx, "key", "value", time.Minute, time.Minute)
+	assert.Equal(t, errs.ErrInvalidSoftTTL, err)
+	err = c.SetWithSoftTTL(ctx, "key", "value", time.Hour, time.Minute)
+	assert.Equal(t, errs.ErrInvalidSoftTTL, err)
+	assert.True(t, c.Get(ctx, "key").KeyNotFound())
+}
+
+// TestCache_RefreshDiscarded 加载期间 key 被删除或者被覆盖了，加载的结果直接丢弃
+func TestCache_RefreshDiscarded(t *testing.T) {
+	loading := make(chan struct{}, 2)
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (any, error) {
+		loading <- struct{}{}
+		<-release
+		return "loaded value", nil
+	}
+	c := NewCache(10, WithLoader(loader))
+	ctx := context.Background()
+
+	require.NoError(t, c.SetWithSoftTTL(ctx, "deleted", "old value", time.Millisecond, time.Hour))
+	require.NoError(t, c.SetWithSoftTTL(ctx, "overwritten", "old value", time.Millisecond, time.Hour))
+	time.Sleep(time.Millisecond * 5)
+	for _, key := range []string{"deleted", "overwritten"} {
+		assert.True(t, c.Get(ctx, key).Stale)
+		<-loading
+	}
+
+	_, err := c.Delete(ctx, "deleted")
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, "overwritten", "new value", time.Minute))
+	close(release)
+	// 等待后台的刷新完成
+	time.Sleep(time.Millisecond * 20)
+
+	assert.True(t, c.Get(ctx, "deleted").KeyNotFound())
+	assert.Equal(t, "new value", c.Get(ctx, "overwritten").Val)
+}
+
+func TestCache_RefreshAhead(t *testing.T) {
+	loaded := make(chan string, 10)
+	loader := func(ctx context.Context, key string) (any, error) {
+		defer func() {
+			loaded <- key
+		}()
+		if key == "fail" {
+			return nil, errors.New("mock error")
+		}
+		return "new value", nil
+	}
+	failed := make(chan string, 10)
+	c := NewCache(10, WithLoader(loader),
+		WithRefreshAhead(time.Millisecond*100, 2),
+		WithRefreshErrorHandler(func(key string, err error) {
+			failed <- key
+		}))
+	ctx := context.Background()
+
+	// 还没进入刷新窗口
+	err := c.Set(ctx, "far", "value", time.Minute)
+	require.NoError(t, err)
+	val := c.Get(ctx, "far")
+	assert.Equal(t, "value", val.Val)
+
+	// 进入刷新窗口，返回旧值并且在后台刷新，刷新的时候沿用原本的过期时间
+	err = c.Set(ctx, "near", "old value", time.Millisecond*150)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 60)
+	val = c.Get(ctx, "near")
+	assert.Equal(t, "old value", val.Val)
+	assert.False(t, val.Stale)
+	assert.Equal(t, "near", <-loaded)
+	assert.Eventually(t, func() bool {
+		return c.Get(ctx, "near").Val == "new value"
+	}, time.Second, time.Millisecond*5)
+
+	// 刷新失败会回调
+	err = c.Set(ctx, "fail", "old value", time.Millisecond*50)
+	require.NoError(t, err)
+	val = c.Get(ctx, "fail")
+	assert.Equal(t, "old value", val.Val)
+	assert.Equal(t, "fail", <-failed)
+
+	select {
+	case key := <-loaded:
+		if key != "near" && key != "fail" {
+			t.Fatalf("不应该刷新 %s", key)
+		}
+	default:
+	}
+}
+
+func TestCache_SetXX(t *testing.T) {
+	testCases := []struct {
+		name   string
+		before func(t *testing.T, c *Cache)
+
+		wantOk  bool
+		wantVal any
+	}{
+		{
+			name:   "key not exist",
+			before: func(t *testing.T, c *Cache) {},
+		},
+		{
+			name: "key expired",
+			before: func(t *testing.T, c *Cache) {
+				c.addTTL("test", "hello ecache", -time.Second)
+			},
+		},
+		{
+			name: "key exist",
+			before: func(t *testing.T, c *Cache) {
+				c.addTTL("test", "hello ecache", time.Minute)
+			},
+			wantOk:  true,
+			wantVal: "hello world",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := NewCache(10)
+			tc.before(t, c)
+			ok, err := c.SetXX(ctx, "test", "hello world", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantVal, c.Get(ctx, "test").Val)
+		})
+	}
+}
+
+func TestCache_CompareAndSwap(t *testing.T) {
+	testCases := []struct {
+		name   string
+		before func(t *testing.T, c *Cache)
+		old    any
+
+		wantOk  bool
+		wantVal any
+	}{
+		{
+			name:   "key not exist",
+			before: func(t *testing.T, c *Cache) {},
+			old:    "hello ecache",
+		},
+		{
+			name: "not equal",
+			before: func(t *testing.T, c *Cache) {
+				c.addTTL("test", "hello ecache", time.Minute)
+			},
+			old:     "hello",
+			wantVal: "hello ecache",
+		},
+		{
+			name: "equal",
+			before: func(t *testing.T, c *Cache) {
+				c.addTTL("test", "hello ecache", time.Minute)
+			},
+			old:     "hello ecache",
+			wantOk:  true,
+			wantVal: "hello world",
+		},
+		{
+			name: "equal slice",
+			before: func(t *testing.T, c *Cache) {
+				c.addTTL("test", []string{"hello"}, time.Minute)
+			},
+			old:     []string{"hello"},
+			wantOk:  true,
+			wantVal: "hello world",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := NewCache(10)
+			tc.before(t, c)
+			ok, err := c.CompareAndSwap(ctx, "test", tc.old, "hello world", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantVal, c.Get(ctx, "test").Val)
+		})
+	}
+}
+
+func TestCache_SetIfVersion(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+
+	// 不存在的 key 版本为 0
+	val := c.Get(ctx, "test")
+	assert.Equal(t, uint64(0), val.Version)
+	ok, err := c.SetIfVersion(ctx, "test", "v1", val.Version, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	val = c.Get(ctx, "test")
+	assert.NotEqual(t, uint64(0), val.Version)
+	// 版本过期了
+	ok, err = c.SetIfVersion(ctx, "test", "v2", val.Version+1, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	// 原地修改也会更新版本
+	_, err = c.LPush(ctx, "list", "a", "b")
+	require.NoError(t, err)
+	list := c.Get(ctx, "list")
+	require.NoError(t, c.LPop(ctx, "list").Err)
+	ok, err = c.SetIfVersion(ctx, "list", "v2", list.Version, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = c.SetIfVersion(ctx, "test", "v2", val.Version, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "v2", c.Get(ctx, "test").Val)
+}
+
+func TestCache_BLPop(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+
+	// LPush 从头部写入，列表是 [c b a]
+	_, err := c.LPush(ctx, "list", "a", "b", "c")
+	require.NoError(t, err)
+	val := c.BLPop(ctx, "list", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "c", val.Val)
+	val = c.BRPop(ctx, "list", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	val = c.BRPop(ctx, "list", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "b", val.Val)
+
+	// 列表为空的时候超时
+	val = c.BLPop(ctx, "list", time.Millisecond*10)
+	assert.True(t, val.KeyNotFound())
+	val = c.BRPop(ctx, "not-exist", time.Millisecond*10)
+	assert.True(t, val.KeyNotFound())
+	// ctx 结束
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	val = c.BLPop(tctx, "list", 0)
+	assert.Equal(t, context.DeadlineExceeded, val.Err)
+
+	require.NoError(t, c.Set(ctx, "string", "value", time.Minute))
+	val = c.BLPop(ctx, "string", time.Second)
+	assert.Equal(t, errors.New("当前key不是list类型"), val.Err)
+
+	// 在 Pipeline 和事务中不会阻塞
+	c.run(func(c ecache.Cache) {
+		val = c.BLPop(ctx, "list", time.Hour)
+	})
+	assert.True(t, val.KeyNotFound())
+}
+
+func TestCache_BLPopWakeUp(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+
+	var popped int32
+	results := make(chan ecache.Value, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			val := c.BLPop(ctx, "list", time.Millisecond*200)
+			atomic.AddInt32(&popped, 1)
+			results <- val
+		}()
+	}
+	time.Sleep(time.Millisecond * 20)
+
+	// 一次 LPush 只唤醒一个调用者，另一个超时
+	_, err := c.LPush(ctx, "list", "a")
+	require.NoError(t, err)
+	val := <-results
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&popped))
+	val = <-results
+	assert.True(t, val.KeyNotFound())
+}
+
+func TestCache_BLMove(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+
+	_, err := c.LPush(ctx, "jobs", "a", "b")
+	require.NoError(t, err)
+	val := c.BLMove(ctx, "jobs", "processing", ecache.ListRight, ecache.ListLeft, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	val = c.BLMove(ctx, "jobs", "processing", ecache.ListLeft, ecache.ListLeft, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "b", val.Val)
+	assert.Equal(t, "a", c.BRPop(ctx, "processing", time.Second).Val)
+	assert.Equal(t, "b", c.BRPop(ctx, "processing", time.Second).Val)
+
+	// destination 类型不对的时候不会修改 source
+	_, err = c.LPush(ctx, "jobs", "c")
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, "string", "value", time.Minute))
+	val = c.BLMove(ctx, "jobs", "string", ecache.ListLeft, ecache.ListLeft, time.Second)
+	assert.Equal(t, errors.New("当前key不是list类型"), val.Err)
+	assert.Equal(t, "c", c.BLPop(ctx, "jobs", time.Second).Val)
+
+	// 阻塞直到 source 有数据，并且移动之后唤醒等待 destination 的调用者
+	done := make(chan ecache.Value, 1)
+	go func() {
+		done <- c.BLPop(ctx, "processing", time.Second)
+	}()
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_, _ = c.LPush(ctx, "jobs", "d")
+	}()
+	val = c.BLMove(ctx, "jobs", "processing", ecache.ListLeft, ecache.ListRight, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "d", val.Val)
+	val = <-done
+	require.NoError(t, val.Err)
+	assert.Equal(t, "d", val.Val)
+
+	val = c.BLMove(ctx, "jobs", "processing", ecache.ListLeft, ecache.ListRight, time.Millisecond*10)
+	assert.True(t, val.KeyNotFound())
+}
+
+func TestCache_LMove(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+
+	_, err := c.LPush(ctx, "jobs", "a", "b")
+	require.NoError(t, err)
+	val := c.RPopLPush(ctx, "jobs", "processing")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	// source 和 destination 是同一个列表的时候轮转列表
+	val = c.RPopLPush(ctx, "jobs", "jobs")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "b", val.Val)
+	val = c.LMove(ctx, "processing", "jobs", ecache.ListLeft, ecache.ListRight)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	assert.Equal(t, "a", c.BRPop(ctx, "jobs", time.Second).Val)
+
+	val = c.LMove(ctx, "processing", "jobs", ecache.ListLeft, ecache.ListRight)
+	assert.True(t, val.KeyNotFound())
+	val = c.RPopLPush(ctx, "not-exist", "jobs")
+	assert.True(t, val.KeyNotFound())
+
+	require.NoError(t, c.Set(ctx, "string", "value", time.Minute))
+	val = c.RPopLPush(ctx, "jobs", "string")
+	assert.Equal(t, errors.New("当前key不是list类型"), val.Err)
+	assert.Equal(t, "b", c.BLPop(ctx, "jobs", time.Second).Val)
+}
+
+func TestCache_LRem(t *testing.T) {
+	testCases := []struct {
+		name  string
+		count int64
+
+		wantCnt  int64
+		wantList []any
+	}{
+		{
+			name:     "remove all",
+			count:    0,
+			wantCnt:  3,
+			wantList: []any{"b", "c"},
+		},
+		{
+			name:     "remove from head",
+			count:    2,
+			wantCnt:  2,
+			wantList: []any{"b", "c", "a"},
+		},
+		{
+			name:     "remove from tail",
+			count:    -2,
+			wantCnt:  2,
+			wantList: []any{"a", "b", "c"},
+		},
+		{
+			name:     "count larger than matches",
+			count:    -5,
+			wantCnt:  3,
+			wantList: []any{"b", "c"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := NewCache(10)
+			// 列表是 [a b a c a]
+			_, err := c.LPush(ctx, "list", "a", "c", "a", "b", "a")
+			require.NoError(t, err)
+			cnt, err := c.LRem(ctx, "list", tc.count, "a")
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantCnt, cnt)
+
+			var res []any
+			for val := c.LPop(ctx, "list"); val.Err == nil; val = c.LPop(ctx, "list") {
+				res = append(res, val.Val)
+			}
+			assert.Equal(t, tc.wantList, res)
+		})
+	}
+
+	ctx := context.Background()
+	c := NewCache(10)
+	cnt, err := c.LRem(ctx, "not-exist", 0, "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+	require.NoError(t, c.Set(ctx, "string", "value", time.Minute))
+	_, err = c.LRem(ctx, "string", 0, "a")
+	assert.Equal(t, errors.New("当前key不是list类型"), err)
+}
+
+// TestCache_UnlockedMethods 在锁里面通过 unlocked 调用所有的方法，也就是 Pipeline.Exec 和事务提交时的执行路径，
+// 任何一个方法调用到了加锁的方法都会死锁
+func TestCache_UnlockedMethods(t *testing.T) {
+	c := NewCache(100)
+	var called []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.run(func(u ecache.Cache) {
+			called = callAllMethods(u)
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("unlocked 的方法调用到了加锁的方法")
+	}
+	assert.Equal(t, reflect.TypeOf(unlocked{}).NumMethod(), len(called))
+	assert.GreaterOrEqual(t, len(called), reflect.TypeOf((*ecache.GeoCache)(nil)).Elem().NumMethod())
+}
+
+// callAllMethods 使用默认的参数调用 c 上所有导出的方法，返回调用过的方法
+func callAllMethods(c ecache.Cache) []string {
+	val := reflect.ValueOf(c)
+	typ := val.Type()
+	called := make([]string, 0, typ.NumMethod())
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := val.Method(i)
+		mt := method.Type()
+		args := make([]reflect.Value, 0, mt.NumIn())
+		for j := 0; j < mt.NumIn(); j++ {
+			in := mt.In(j)
+			if mt.IsVariadic() && j == mt.NumIn()-1 {
+				break
+			}
+			switch {
+			case in == reflect.TypeOf((*context.Context)(nil)).Elem():
+				args = append(args, reflect.ValueOf(context.Background()))
+			case in == reflect.TypeOf(ecache.ListLeft):
+				args = append(args, reflect.ValueOf(ecache.ListLeft))
+			case in.Kind() == reflect.String:
+				args = append(args, reflect.ValueOf("key").Convert(in))
+			case in.Kind() == reflect.Interface:
+				args = append(args, reflect.ValueOf("val"))
+			default:
+				args = append(args, reflect.Zero(in))
+			}
+		}
+		method.Call(args)
+		called = append(called, typ.Method(i).Name)
+	}
+	return called
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lru
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/geo"
+)
+
+var errNotGeo = errors.New("当前key不是geo类型")
+
+func (c *Cache) GeoAdd(ctx context.Context, key string, locations ...ecache.GeoLocation) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.GeoAdd(ctx, key, locations...)
+}
+
+func (c unlocked) GeoAdd(ctx context.Context, key string, locations ...ecache.GeoLocation) (int64, error) {
+	s, err := c.geoOf(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(locations) == 0 {
+		return 0, nil
+	}
+	created := s == nil
+	if created {
+		s = geo.NewSet()
+	}
+	n, err := s.Add(locations...)
+	if err != nil {
+		return 0, err
+	}
+	if created {
+		c.cache.add(key, s)
+	} else {
+		c.cache.touch(key)
+	}
+	return n, nil
+}
+
+func (c *Cache) GeoDist(ctx context.Context, key string, member1, member2 string, unit ecache.GeoUnit) (float64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.GeoDist(ctx, key, member1, member2, unit)
+}
+
+func (c unlocked) GeoDist(ctx context.Context, key string, member1, member2 string, unit ecache.GeoUnit) (float64, error) {
+	s, err := c.geoOf(key)
+	if err != nil {
+		return 0, err
+	}
+	if s == nil {
+		s = geo.NewSet()
+	}
+	return s.Dist(member1, member2, unit)
+}
+
+func (c *Cache) GeoSearch(ctx context.Context, key string, query ecache.GeoSearchQuery) ([]ecache.GeoLocation, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.GeoSearch(ctx, key, query)
+}
+
+func (c unlocked) GeoSearch(ctx context.Context, key string, query ecache.GeoSearchQuery) ([]ecache.GeoLocation, error) {
+	s, err := c.geoOf(key)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s = geo.NewSet()
+	}
+	return s.Search(query)
+}
+
+// geoOf 返回 key 对应的 geo 集合，key 不存在的时候返回 nil
+func (c unlocked) geoOf(key string) (*geo.Set, error) {
+	val, ok := c.cache.get(key)
+	if !ok {
+		return nil, nil
+	}
+	s, ok := val.(*geo.Set)
+	if !ok {
+		return nil, errNotGeo
+	}
+	return s, nil
+}
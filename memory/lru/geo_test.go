@@ -0,0 +1,109 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lru
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/internal/geo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_GeoAdd(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+
+	n, err := c.GeoAdd(ctx, "Sicily",
+		ecache.GeoLocation{Member: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+		ecache.GeoLocation{Member: "Catania", Longitude: 15.087269, Latitude: 37.502669})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	// 更新已有的成员不计数
+	n, err = c.GeoAdd(ctx, "Sicily",
+		ecache.GeoLocation{Member: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+		ecache.GeoLocation{Member: "Agrigento", Longitude: 13.583333, Latitude: 37.316667})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	// 位置不合法的时候不会创建 key
+	_, err = c.GeoAdd(ctx, "invalid", ecache.GeoLocation{Member: "North Pole", Longitude: 0, Latitude: 90})
+	assert.Equal(t, geo.ErrInvalidLocation, err)
+	assert.Equal(t, errs.ErrKeyNotExist, c.Get(ctx, "invalid").Err)
+
+	require.NoError(t, c.Set(ctx, "string", "value", time.Minute))
+	_, err = c.GeoAdd(ctx, "string", ecache.GeoLocation{Member: "Palermo", Longitude: 13, Latitude: 38})
+	assert.Equal(t, errNotGeo, err)
+	_, err = c.GeoDist(ctx, "string", "a", "b", ecache.GeoUnitM)
+	assert.Equal(t, errNotGeo, err)
+	_, err = c.GeoSearch(ctx, "string", ecache.GeoSearchQuery{Member: "a", Radius: 1})
+	assert.Equal(t, errNotGeo, err)
+}
+
+func TestCache_GeoDist(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+	_, err := c.GeoAdd(ctx, "Sicily",
+		ecache.GeoLocation{Member: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+		ecache.GeoLocation{Member: "Catania", Longitude: 15.087269, Latitude: 37.502669})
+	require.NoError(t, err)
+
+	dist, err := c.GeoDist(ctx, "Sicily", "Palermo", "Catania", ecache.GeoUnitKM)
+	require.NoError(t, err)
+	assert.Equal(t, 166.2742, dist)
+	_, err = c.GeoDist(ctx, "Sicily", "Palermo", "Rome", ecache.GeoUnitKM)
+	assert.Equal(t, errs.ErrKeyNotExist, err)
+	_, err = c.GeoDist(ctx, "not-exist", "Palermo", "Catania", ecache.GeoUnitKM)
+	assert.Equal(t, errs.ErrKeyNotExist, err)
+}
+
+func TestCache_GeoSearch(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+	_, err := c.GeoAdd(ctx, "Sicily",
+		ecache.GeoLocation{Member: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+		ecache.GeoLocation{Member: "Catania", Longitude: 15.087269, Latitude: 37.502669},
+		ecache.GeoLocation{Member: "edge1", Longitude: 12.758489, Latitude: 38.788135},
+		ecache.GeoLocation{Member: "edge2", Longitude: 17.241510, Latitude: 38.788135})
+	require.NoError(t, err)
+
+	res, err := c.GeoSearch(ctx, "Sicily", ecache.GeoSearchQuery{
+		Longitude: 15, Latitude: 37, Radius: 200, Unit: ecache.GeoUnitKM, Sort: ecache.GeoSortAsc})
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, "Catania", res[0].Member)
+	assert.Equal(t, 56.4413, res[0].Dist)
+	assert.InDelta(t, 15.087269, res[0].Longitude, 1e-5)
+	assert.InDelta(t, 37.502669, res[0].Latitude, 1e-5)
+	assert.Equal(t, "Palermo", res[1].Member)
+	assert.Equal(t, 190.4424, res[1].Dist)
+
+	res, err = c.GeoSearch(ctx, "Sicily", ecache.GeoSearchQuery{
+		Longitude: 15, Latitude: 37, Width: 400, Height: 400, Unit: ecache.GeoUnitKM, Sort: ecache.GeoSortDesc, Count: 2})
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, "edge1", res[0].Member)
+	assert.Equal(t, "edge2", res[1].Member)
+
+	_, err = c.GeoSearch(ctx, "Sicily", ecache.GeoSearchQuery{Member: "Rome", Radius: 100})
+	assert.Equal(t, errs.ErrKeyNotExist, err)
+	res, err = c.GeoSearch(ctx, "not-exist", ecache.GeoSearchQuery{Longitude: 15, Latitude: 37, Radius: 100})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lru
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecodeclub/ecache/internal/hll"
+	"github.com/ecodeclub/ecache/internal/resp"
+)
+
+var errNotHyperLogLog = errors.New("当前key不是HyperLogLog类型")
+
+func (c *Cache) PFAdd(ctx context.Context, key string, els ...any) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.PFAdd(ctx, key, els...)
+}
+
+func (c unlocked) PFAdd(ctx context.Context, key string, els ...any) (int64, error) {
+	h, err := c.hyperLogLogOf(key)
+	if err != nil {
+		return 0, err
+	}
+	changed := h == nil
+	if h == nil {
+		h = hll.New()
+		c.cache.add(key, h)
+	}
+	for _, el := range els {
+		str, err := resp.String(el)
+		if err != nil {
+			return 0, err
+		}
+		if h.Add([]byte(str)) {
+			changed = true
+		}
+	}
+	if !changed {
+		return 0, nil
+	}
+	c.cache.touch(key)
+	return 1, nil
+}
+
+func (c *Cache) PFCount(ctx context.Context, keys ...string) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.PFCount(ctx, keys...)
+}
+
+func (c unlocked) PFCount(ctx context.Context, keys ...string) (int64, error) {
+	union := hll.New()
+	for _, key := range keys {
+		h, err := c.hyperLogLogOf(key)
+		if err != nil {
+			return 0, err
+		}
+		if h != nil {
+			union.Merge(h)
+		}
+	}
+	return union.Count(), nil
+}
+
+func (c *Cache) PFMerge(ctx context.Context, dest string, keys ...string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return unlocked{c}.PFMerge(ctx, dest, keys...)
+}
+
+func (c unlocked) PFMerge(ctx context.Context, dest string, keys ...string) error {
+	srcs := make([]*hll.HyperLogLog, 0, len(keys))
+	for _, key := range keys {
+		h, err := c.hyperLogLogOf(key)
+		if err != nil {
+			return err
+		}
+		if h != nil {
+			srcs = append(srcs, h)
+		}
+	}
+	res, err := c.hyperLogLogOf(dest)
+	if err != nil {
+		return err
+	}
+	// dest 已经存在的时候原地合并，保留过期时间
+	if res == nil {
+		res = hll.New()
+		c.cache.add(dest, res)
+	}
+	for _, src := range srcs {
+		res.Merge(src)
+	}
+	c.cache.touch(dest)
+	return nil
+}
+
+// hyperLogLogOf 返回 key 对应的 HyperLogLog，key 不存在的时候返回 nil
+func (c unlocked) hyperLogLogOf(key string) (*hll.HyperLogLog, error) {
+	val, ok := c.cache.get(key)
+	if !ok {
+		return nil, nil
+	}
+	h, ok := val.(*hll.HyperLogLog)
+	if !ok {
+		return nil, errNotHyperLogLog
+	}
+	return h, nil
+}
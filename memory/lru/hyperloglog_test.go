@@ -0,0 +1,87 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lru
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_PFAdd(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+
+	// 创建空的 HyperLogLog 也算作变化
+	changed, err := c.PFAdd(ctx, "uv")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), changed)
+	changed, err = c.PFAdd(ctx, "uv", "a", "b", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), changed)
+	changed, err = c.PFAdd(ctx, "uv", "a", "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), changed)
+
+	cnt, err := c.PFCount(ctx, "uv")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), cnt)
+	cnt, err = c.PFCount(ctx, "not-exist")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+
+	require.NoError(t, c.Set(ctx, "string", "value", time.Minute))
+	_, err = c.PFAdd(ctx, "string", "a")
+	assert.Equal(t, errNotHyperLogLog, err)
+	_, err = c.PFCount(ctx, "uv", "string")
+	assert.Equal(t, errNotHyperLogLog, err)
+}
+
+func TestCache_PFMerge(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(10)
+	for i := 0; i < 6000; i++ {
+		_, err := c.PFAdd(ctx, "uv1", "user:"+strconv.Itoa(i))
+		require.NoError(t, err)
+	}
+	for i := 4000; i < 10000; i++ {
+		_, err := c.PFAdd(ctx, "uv2", "user:"+strconv.Itoa(i))
+		require.NoError(t, err)
+	}
+
+	union, err := c.PFCount(ctx, "uv1", "uv2")
+	require.NoError(t, err)
+	assert.InDelta(t, 10000, union, 10000*0.0081*3)
+	// PFCount 不会修改原本的 key
+	cnt, err := c.PFCount(ctx, "uv1")
+	require.NoError(t, err)
+	assert.InDelta(t, 6000, cnt, 6000*0.0081*3)
+
+	// dest 原本的元素也会保留
+	_, err = c.PFAdd(ctx, "dest", "user:10000")
+	require.NoError(t, err)
+	require.NoError(t, c.PFMerge(ctx, "dest", "uv1", "uv2", "not-exist"))
+	cnt, err = c.PFCount(ctx, "dest")
+	require.NoError(t, err)
+	assert.InDelta(t, 10001, cnt, 10001*0.0081*3)
+
+	require.NoError(t, c.Set(ctx, "string", "value", time.Minute))
+	assert.Equal(t, errNotHyperLogLog, c.PFMerge(ctx, "string", "uv1"))
+	assert.Equal(t, errNotHyperLogLog, c.PFMerge(ctx, "dest", "string"))
+}
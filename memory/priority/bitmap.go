@@ -0,0 +1,147 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package priority
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/bitmap"
+)
+
+func (r *RBTreePriorityCache) SetBit(ctx context.Context, key string, offset int64, value int) (int64, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.SetBit(ctx, key, offset, value)
+}
+
+func (r unlocked) SetBit(ctx context.Context, key string, offset int64, value int) (int64, error) {
+	if err := bitmap.CheckOffset(offset); err != nil {
+		return 0, err
+	}
+	if err := bitmap.CheckBit(int64(value)); err != nil {
+		return 0, err
+	}
+	node, data, err := r.bitmapOf(key)
+	if err != nil {
+		return 0, err
+	}
+	if node == nil {
+		node = r.cache.findOrCreateNode(key, func() any { return []byte(nil) })
+	}
+
+	// 直接修改结点的值，保留过期时间
+	data, old := bitmap.SetBit(data, offset, value)
+	node.value = data
+	r.cache.touch(node)
+
+	return old, nil
+}
+
+func (r *RBTreePriorityCache) GetBit(ctx context.Context, key string, offset int64) (int64, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.GetBit(ctx, key, offset)
+}
+
+func (r unlocked) GetBit(ctx context.Context, key string, offset int64) (int64, error) {
+	if err := bitmap.CheckOffset(offset); err != nil {
+		return 0, err
+	}
+	_, data, err := r.bitmapOf(key)
+	if err != nil {
+		return 0, err
+	}
+	return bitmap.GetBit(data, offset), nil
+}
+
+func (r *RBTreePriorityCache) BitCount(ctx context.Context, key string, start, end int64) (int64, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.BitCount(ctx, key, start, end)
+}
+
+func (r unlocked) BitCount(ctx context.Context, key string, start, end int64) (int64, error) {
+	_, data, err := r.bitmapOf(key)
+	if err != nil {
+		return 0, err
+	}
+	return bitmap.Count(data, start, end), nil
+}
+
+func (r *RBTreePriorityCache) BitOp(ctx context.Context, op ecache.BitOperation, destKey string, keys ...string) (int64, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.BitOp(ctx, op, destKey, keys...)
+}
+
+func (r unlocked) BitOp(ctx context.Context, op ecache.BitOperation, destKey string, keys ...string) (int64, error) {
+	srcs := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		_, data, err := r.bitmapOf(key)
+		if err != nil {
+			return 0, err
+		}
+		srcs = append(srcs, data)
+	}
+	res, err := bitmap.Op(op, srcs)
+	if err != nil {
+		return 0, err
+	}
+
+	// 和 Redis 一样，结果为空的时候删除 destKey，否则覆盖 destKey 并且去掉过期时间
+	if node, cacheErr := r.cache.cacheData.Find(destKey); cacheErr == nil {
+		r.cache.deleteNode(node)
+	}
+	if len(res) == 0 {
+		return 0, nil
+	}
+	node := r.cache.findOrCreateNode(destKey, func() any { return res })
+	r.cache.touch(node)
+
+	return int64(len(res)), nil
+}
+
+func (r *RBTreePriorityCache) BitPos(ctx context.Context, key string, bit int64, pos ...int64) (int64, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.BitPos(ctx, key, bit, pos...)
+}
+
+func (r unlocked) BitPos(ctx context.Context, key string, bit int64, pos ...int64) (int64, error) {
+	_, data, err := r.bitmapOf(key)
+	if err != nil {
+		return 0, err
+	}
+	return bitmap.Pos(data, bit, pos...)
+}
+
+// bitmapOf 返回 key 对应的结点和位图，key 不存在或者已经过期的时候结点为 nil【调用该方法必须先获得锁】
+func (r unlocked) bitmapOf(key string) (*rbTreeCacheNode, []byte, error) {
+	node, cacheErr := r.cache.cacheData.Find(key)
+	if cacheErr != nil {
+		return nil, nil, nil
+	}
+	if !node.beforeDeadline(time.Now()) {
+		r.cache.deleteNode(node)
+		return nil, nil, nil
+	}
+	data, ok := bitmap.Bytes(node.value)
+	if !ok {
+		return nil, nil, errOnlyBytesCanBit
+	}
+	return node, data, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package priority
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/bitmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRBTreePriorityCache_SetBit(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := NewRBTreePriorityCache()
+
+	old, err := cache.SetBit(ctx, "dau", 7, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), old)
+	old, err = cache.SetBit(ctx, "dau", 7, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), old)
+	assert.Equal(t, []byte{0x01}, cache.Get(ctx, "dau").Val)
+
+	bit, err := cache.GetBit(ctx, "dau", 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), bit)
+	bit, err = cache.GetBit(ctx, "not-exist", 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), bit)
+
+	// 可以对 Set 写入的字符串执行位操作，并且保留过期时间
+	require.NoError(t, cache.Set(ctx, "flags", "a", time.Millisecond*50))
+	old, err = cache.SetBit(ctx, "flags", 6, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), old)
+	assert.Equal(t, []byte("c"), cache.Get(ctx, "flags").Val)
+	time.Sleep(time.Millisecond * 100)
+	// 过期之后当作 key 不存在
+	bit, err = cache.GetBit(ctx, "flags", 6)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), bit)
+
+	_, err = cache.SetBit(ctx, "dau", 1<<32, 1)
+	assert.Equal(t, bitmap.ErrInvalidOffset, err)
+	_, err = cache.SetBit(ctx, "dau", 1, -1)
+	assert.Equal(t, bitmap.ErrInvalidBit, err)
+	_, err = cache.IncrBy(ctx, "counter", 1)
+	require.NoError(t, err)
+	_, err = cache.SetBit(ctx, "counter", 1, 1)
+	assert.Equal(t, errOnlyBytesCanBit, err)
+}
+
+func TestRBTreePriorityCache_BitCount(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := NewRBTreePriorityCache()
+	require.NoError(t, cache.Set(ctx, "key", []byte{0xff, 0xf0, 0x00}, time.Minute))
+
+	cnt, err := cache.BitCount(ctx, "key", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), cnt)
+	cnt, err = cache.BitCount(ctx, "key", -2, -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), cnt)
+
+	pos, err := cache.BitPos(ctx, "key", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), pos)
+	pos, err = cache.BitPos(ctx, "key", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), pos)
+}
+
+func TestRBTreePriorityCache_BitOp(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := NewRBTreePriorityCache()
+	require.NoError(t, cache.Set(ctx, "key1", "foobar", time.Minute))
+	require.NoError(t, cache.Set(ctx, "key2", "abcdef", time.Minute))
+	require.NoError(t, cache.Set(ctx, "dest", "old", time.Minute))
+
+	n, err := cache.BitOp(ctx, ecache.BitOpAnd, "dest", "key1", "key2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+	assert.Equal(t, []byte("`bc`ab"), cache.Get(ctx, "dest").Val)
+
+	n, err = cache.BitOp(ctx, ecache.BitOpNot, "dest", "not-exist")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.True(t, cache.Get(ctx, "dest").KeyNotFound())
+
+	_, err = cache.BitOp(ctx, "NAND", "dest", "key1")
+	assert.Equal(t, bitmap.ErrInvalidOp, err)
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package priority
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/geo"
+)
+
+func (r *RBTreePriorityCache) GeoAdd(ctx context.Context, key string, locations ...ecache.GeoLocation) (int64, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.GeoAdd(ctx, key, locations...)
+}
+
+func (r unlocked) GeoAdd(ctx context.Context, key string, locations ...ecache.GeoLocation) (int64, error) {
+	s, node, err := r.geoOf(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(locations) == 0 {
+		return 0, nil
+	}
+	n, err := s.Add(locations...)
+	if err != nil {
+		return 0, err
+	}
+	// 位置都合法之后才创建结点
+	if node == nil {
+		node = r.cache.findOrCreateNode(key, func() any { return s })
+	}
+	r.cache.touch(node)
+
+	return n, nil
+}
+
+func (r *RBTreePriorityCache) GeoDist(ctx context.Context, key string, member1, member2 string, unit ecache.GeoUnit) (float64, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.GeoDist(ctx, key, member1, member2, unit)
+}
+
+func (r unlocked) GeoDist(ctx context.Context, key string, member1, member2 string, unit ecache.GeoUnit) (float64, error) {
+	s, _, err := r.geoOf(key)
+	if err != nil {
+		return 0, err
+	}
+	return s.Dist(member1, member2, unit)
+}
+
+func (r *RBTreePriorityCache) GeoSearch(ctx context.Context, key string, query ecache.GeoSearchQuery) ([]ecache.GeoLocation, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.GeoSearch(ctx, key, query)
+}
+
+func (r unlocked) GeoSearch(ctx context.Context, key string, query ecache.GeoSearchQuery) ([]ecache.GeoLocation, error) {
+	s, _, err := r.geoOf(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.Search(query)
+}
+
+// geoOf 返回 key 对应的 geo 集合和结点，key 不存在或者已经过期的时候返回空的集合和 nil 结点【调用该方法必须先获得锁】
+func (r unlocked) geoOf(key string) (*geo.Set, *rbTreeCacheNode, error) {
+	node, cacheErr := r.cache.cacheData.Find(key)
+	if cacheErr != nil {
+		return geo.NewSet(), nil, nil
+	}
+	if !node.beforeDeadline(time.Now()) {
+		r.cache.deleteNode(node)
+		return geo.NewSet(), nil, nil
+	}
+	s, ok := node.value.(*geo.Set)
+	if !ok {
+		return nil, nil, errOnlyGeoCanGeo
+	}
+	return s, node, nil
+}
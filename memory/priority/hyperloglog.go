@@ -0,0 +1,128 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package priority
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ecache/internal/hll"
+	"github.com/ecodeclub/ecache/internal/resp"
+)
+
+func (r *RBTreePriorityCache) PFAdd(ctx context.Context, key string, els ...any) (int64, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.PFAdd(ctx, key, els...)
+}
+
+func (r unlocked) PFAdd(ctx context.Context, key string, els ...any) (int64, error) {
+	node, err := r.hyperLogLogOf(key)
+	if err != nil {
+		return 0, err
+	}
+	changed := node == nil
+	if node == nil {
+		node = r.cache.findOrCreateNode(key, func() any { return hll.New() })
+	}
+
+	h := node.value.(*hll.HyperLogLog)
+	for _, el := range els {
+		str, err := resp.String(el)
+		if err != nil {
+			return 0, err
+		}
+		if h.Add([]byte(str)) {
+			changed = true
+		}
+	}
+	if !changed {
+		return 0, nil
+	}
+	r.cache.touch(node)
+
+	return 1, nil
+}
+
+func (r *RBTreePriorityCache) PFCount(ctx context.Context, keys ...string) (int64, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.PFCount(ctx, keys...)
+}
+
+func (r unlocked) PFCount(ctx context.Context, keys ...string) (int64, error) {
+	union := hll.New()
+	for _, key := range keys {
+		node, err := r.hyperLogLogOf(key)
+		if err != nil {
+			return 0, err
+		}
+		if node != nil {
+			union.Merge(node.value.(*hll.HyperLogLog))
+		}
+	}
+	return union.Count(), nil
+}
+
+func (r *RBTreePriorityCache) PFMerge(ctx context.Context, dest string, keys ...string) error {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.PFMerge(ctx, dest, keys...)
+}
+
+func (r unlocked) PFMerge(ctx context.Context, dest string, keys ...string) error {
+	srcs := make([]*hll.HyperLogLog, 0, len(keys))
+	for _, key := range keys {
+		node, err := r.hyperLogLogOf(key)
+		if err != nil {
+			return err
+		}
+		if node != nil {
+			srcs = append(srcs, node.value.(*hll.HyperLogLog))
+		}
+	}
+	node, err := r.hyperLogLogOf(dest)
+	if err != nil {
+		return err
+	}
+	// dest 已经存在的时候原地合并，保留过期时间
+	if node == nil {
+		node = r.cache.findOrCreateNode(dest, func() any { return hll.New() })
+	}
+
+	h := node.value.(*hll.HyperLogLog)
+	for _, src := range srcs {
+		h.Merge(src)
+	}
+	r.cache.touch(node)
+
+	return nil
+}
+
+// hyperLogLogOf 返回 key 对应的结点，key 不存在或者已经过期的时候返回 nil【调用该方法必须先获得锁】
+func (r unlocked) hyperLogLogOf(key string) (*rbTreeCacheNode, error) {
+	node, cacheErr := r.cache.cacheData.Find(key)
+	if cacheErr != nil {
+		return nil, nil
+	}
+	if !node.beforeDeadline(time.Now()) {
+		r.cache.deleteNode(node)
+		return nil, nil
+	}
+	if _, ok := node.value.(*hll.HyperLogLog); !ok {
+		return nil, errOnlyHLLCanPF
+	}
+	return node, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package priority
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRBTreePriorityCache_PFAdd(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := NewRBTreePriorityCache()
+
+	changed, err := cache.PFAdd(ctx, "uv", "a", "b", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), changed)
+	changed, err = cache.PFAdd(ctx, "uv", "b", "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), changed)
+	cnt, err := cache.PFCount(ctx, "uv", "not-exist")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), cnt)
+
+	require.NoError(t, cache.Set(ctx, "string", "value", time.Minute))
+	_, err = cache.PFAdd(ctx, "string", "a")
+	assert.Equal(t, errOnlyHLLCanPF, err)
+}
+
+func TestRBTreePriorityCache_PFMerge(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := NewRBTreePriorityCache()
+	for i := 0; i < 6000; i++ {
+		_, err := cache.PFAdd(ctx, "uv1", "user:"+strconv.Itoa(i))
+		require.NoError(t, err)
+	}
+	for i := 4000; i < 10000; i++ {
+		_, err := cache.PFAdd(ctx, "uv2", "user:"+strconv.Itoa(i))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, cache.PFMerge(ctx, "dest", "uv1", "uv2"))
+	merged, err := cache.PFCount(ctx, "dest")
+	require.NoError(t, err)
+	union, err := cache.PFCount(ctx, "uv1", "uv2")
+	require.NoError(t, err)
+	assert.Equal(t, union, merged)
+	assert.InDelta(t, 10000, merged, 10000*0.0081*3)
+
+	require.NoError(t, cache.Set(ctx, "string", "value", time.Minute))
+	assert.Equal(t, errOnlyHLLCanPF, cache.PFMerge(ctx, "dest", "string"))
+}
@@ -25,11 +25,13 @@ import (
 
 // rbTreeCacheNode 缓存结点
 type rbTreeCacheNode struct {
-	key       string    //键
-	value     any       //值
-	deadline  time.Time //有效期，默认0，永不过期
-	priority  int       //优先级
-	isDeleted bool      //是否被删除
+	key        string        //键
+	value      any           //值
+	deadline   time.Time     //有效期，默认0，永不过期
+	expiration time.Duration //设置的过期时间，后台刷新的时候复用
+	priority   int           //优先级
+	isDeleted  bool          //是否被删除
+	version    uint64        //版本，每次修改都会更新
 }
 
 // newRBTreeCacheNode 创建红黑树节点，注意如果是容器类型节点要value传递初始化一个零值
@@ -69,6 +71,7 @@ func (node *rbTreeCacheNode) setExpiration(expiration time.Duration) {
 		deadline = time.Now().Add(expiration)
 	}
 	node.deadline = deadline
+	node.expiration = expiration
 }
 
 // replace 重新设置缓存结点的value和有效期
@@ -18,13 +18,17 @@ import (
 	"context"
 	"errors"
 	"math"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/ecodeclub/ekit/queue"
 
 	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/blocking"
 	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/internal/pipeline"
+	"github.com/ecodeclub/ecache/internal/refresh"
 	"github.com/ecodeclub/ekit/bean/option"
 	"github.com/ecodeclub/ekit/list"
 	"github.com/ecodeclub/ekit/set"
@@ -34,10 +38,30 @@ import (
 var (
 	errOnlyListCanLPUSH = errors.New("ecache: 只有 list 类型的数据，才能执行 LPush")
 	errOnlyListCanLPOP  = errors.New("ecache: 只有 list 类型的数据，才能执行 LPop")
+	errOnlyListCanRPOP  = errors.New("ecache: 只有 list 类型的数据，才能执行 RPop")
+	errOnlyListCanLMove = errors.New("ecache: 只有 list 类型的数据，才能执行 LMove")
+	errOnlyListCanLRem  = errors.New("ecache: 只有 list 类型的数据，才能执行 LRem")
 	errOnlySetCanSAdd   = errors.New("ecache: 只有 set 类型的数据，才能执行 SAdd")
 	errOnlySetCanSRem   = errors.New("ecache: 只有 set 类型的数据，才能执行 SRem")
 	errOnlyNumCanIncrBy = errors.New("ecache: 只有数字类型的数据，才能执行 IncrBy")
 	errOnlyNumCanDecrBy = errors.New("ecache: 只有数字类型的数据，才能执行 DecrBy")
+	errOnlyBytesCanBit  = errors.New("ecache: 只有 string 或者 []byte 类型的数据，才能执行位操作")
+	errOnlyHLLCanPF     = errors.New("ecache: 只有 HyperLogLog 类型的数据，才能执行 PFAdd、PFCount 和 PFMerge")
+	errOnlyGeoCanGeo    = errors.New("ecache: 只有 geo 类型的数据，才能执行 GeoAdd、GeoDist 和 GeoSearch")
+)
+
+var (
+	_ ecache.Cache            = (*RBTreePriorityCache)(nil)
+	_ ecache.VersionedCache   = (*RBTreePriorityCache)(nil)
+	_ ecache.BitmapCache      = (*RBTreePriorityCache)(nil)
+	_ ecache.HyperLogLogCache = (*RBTreePriorityCache)(nil)
+	_ ecache.GeoCache         = (*RBTreePriorityCache)(nil)
+	_ ecache.Cache            = unlocked{}
+	_ ecache.BitmapCache      = unlocked{}
+	_ ecache.HyperLogLogCache = unlocked{}
+	_ ecache.GeoCache         = unlocked{}
+	_ ecache.Pipeliner        = (*RBTreePriorityCache)(nil)
+	_ ecache.Transactional    = (*RBTreePriorityCache)(nil)
 )
 
 type RBTreePriorityCache struct {
@@ -50,6 +74,23 @@ type RBTreePriorityCache struct {
 	cleanInterval   time.Duration
 	// 集合类型的值的初始化容量
 	collectionCap int
+
+	loader            ecache.Loader               //提前刷新时使用的加载方法
+	refreshGroup      *refresh.Group              //后台刷新任务
+	refreshAhead      time.Duration               //提前刷新的窗口，0表示不提前刷新
+	refreshLimit      int                         //同时执行的刷新任务上限
+	refreshErrHandler func(key string, err error) //刷新失败的回调
+
+	version uint64 //全局递增的写入序号，每次修改都会分配新的序号给对应的缓存结点，用于乐观锁事务
+
+	waiters *blocking.Waiters //阻塞在 BLPop、BRPop 和 BLMove 上的调用者
+}
+
+// unlocked 不加锁的 RBTreePriorityCache，调用它的方法之前必须先获得 globalLock。
+// 所有 ecache.Cache 的方法都必须在 unlocked 上重新实现，否则会调用到加锁的方法导致死锁
+// 缓存不是内嵌的，所以漏掉了某个方法的时候编译就会失败，而不是悄悄地调用到加锁的方法
+type unlocked struct {
+	cache *RBTreePriorityCache
 }
 
 func NewRBTreePriorityCache(opts ...option.Option[RBTreePriorityCache]) (*RBTreePriorityCache, error) {
@@ -77,6 +118,8 @@ func newRBTreePriorityCache(opts ...option.Option[RBTreePriorityCache]) (*RBTree
 		collectionCap: collectionDefaultCap,
 	}
 	option.Apply(cache, opts...)
+	cache.refreshGroup = refresh.NewGroup(cache.refreshLimit)
+	cache.waiters = blocking.NewWaiters(cache.globalLock)
 
 	return cache, nil
 }
@@ -94,21 +137,98 @@ func WithDefaultPriority(priority int) option.Option[RBTreePriorityCache] {
 	}
 }
 
-func (r *RBTreePriorityCache) Set(_ context.Context, key string, val any, expiration time.Duration) error {
+// WithLoader 设置提前刷新时加载数据的方法
+func WithLoader(loader ecache.Loader) option.Option[RBTreePriorityCache] {
+	return func(opt *RBTreePriorityCache) {
+		opt.loader = loader
+	}
+}
+
+// WithRefreshAhead 开启提前刷新。
+// 当 key 在过期前 window 时间内被读取的时候，会通过 WithLoader 设置的 loader 在后台异步刷新，
+// concurrency 限制了同时执行的刷新任务数量，超过的刷新请求会被直接忽略，小于等于 0 表示不限制
+func WithRefreshAhead(window time.Duration, concurrency int) option.Option[RBTreePriorityCache] {
+	return func(opt *RBTreePriorityCache) {
+		opt.refreshAhead = window
+		opt.refreshLimit = concurrency
+	}
+}
+
+// WithRefreshErrorHandler 设置后台刷新失败时的回调
+func WithRefreshErrorHandler(handler func(key string, err error)) option.Option[RBTreePriorityCache] {
+	return func(opt *RBTreePriorityCache) {
+		opt.refreshErrHandler = handler
+	}
+}
+
+func (r *RBTreePriorityCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.Set(ctx, key, val, expiration)
+}
 
-	node := r.findOrCreateNode(key, func() any { return val })
+func (r unlocked) Set(_ context.Context, key string, val any, expiration time.Duration) error {
+	node := r.cache.findOrCreateNode(key, func() any { return val })
 
 	node.replace(val, expiration)
+	r.cache.touch(node)
 	return nil
 }
 
+func (r *RBTreePriorityCache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.SetXX(ctx, key, val, expiration)
+}
+
+func (r unlocked) SetXX(_ context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	node, cacheErr := r.cache.cacheData.Find(key)
+	if cacheErr != nil || !node.beforeDeadline(time.Now()) {
+		return false, nil
+	}
+
+	node.replace(val, expiration)
+	r.cache.touch(node)
+	return true, nil
+}
+
+func (r *RBTreePriorityCache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.CompareAndSwap(ctx, key, old, new, expiration)
+}
+
+func (r unlocked) CompareAndSwap(_ context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	node, cacheErr := r.cache.cacheData.Find(key)
+	if cacheErr != nil || !node.beforeDeadline(time.Now()) || !reflect.DeepEqual(node.value, old) {
+		return false, nil
+	}
+
+	node.replace(new, expiration)
+	r.cache.touch(node)
+	return true, nil
+}
+
+// SetIfVersion 只有在 key 当前的版本等于 version 的时候才写入，version 为 0 表示 key 不存在
+func (r *RBTreePriorityCache) SetIfVersion(_ context.Context, key string, val any, version uint64, expiration time.Duration) (bool, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+
+	if r.versionOf(key) != version {
+		return false, nil
+	}
+	node := r.findOrCreateNode(key, func() any { return val })
+	node.replace(val, expiration)
+	r.touch(node)
+	return true, nil
+}
+
 // addNode 把缓存结点添加到缓存结构中
 func (r *RBTreePriorityCache) addNode(node *rbTreeCacheNode) {
 	_ = r.cacheData.Add(node.key, node) //这里的error理论上不会出现
 	r.cacheNum++
 	r.addNodeToPriority(node)
+	r.touch(node)
 }
 
 // deleteNode 把缓存结点从缓存结构中移除
@@ -121,17 +241,21 @@ func (r *RBTreePriorityCache) deleteNode(node *rbTreeCacheNode) {
 func (r *RBTreePriorityCache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.SetNX(ctx, key, val, expiration)
+}
 
-	node, cacheErr := r.cacheData.Find(key)
+func (r unlocked) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	node, cacheErr := r.cache.cacheData.Find(key)
 	if cacheErr != nil {
 		node = newKVRBTreeCacheNode(key, val, expiration)
-		r.addNode(node)
+		r.cache.addNode(node)
 
 		return true, nil
 	}
 
 	if !node.beforeDeadline(time.Now()) {
 		node.replace(val, expiration) //过期的，key一样，直接覆盖
+		r.cache.touch(node)
 
 		return true, nil
 	}
@@ -141,7 +265,7 @@ func (r *RBTreePriorityCache) SetNX(ctx context.Context, key string, val any, ex
 
 func (r *RBTreePriorityCache) Get(ctx context.Context, key string) (val ecache.Value) {
 	r.globalLock.RLock()
-	node, cacheErr := r.cacheData.Find(key)
+	_, cacheErr := r.cacheData.Find(key)
 	r.globalLock.RUnlock()
 
 	if cacheErr != nil {
@@ -152,18 +276,66 @@ func (r *RBTreePriorityCache) Get(ctx context.Context, key string) (val ecache.V
 
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.Get(ctx, key)
+}
+
+func (r unlocked) Get(ctx context.Context, key string) (val ecache.Value) {
+	node, cacheErr := r.cache.cacheData.Find(key)
+	if cacheErr != nil {
+		val.Err = errs.ErrKeyNotExist // 被抢先删除了
+
+		return
+	}
+
 	now := time.Now()
 	if !node.beforeDeadline(now) {
-		r.doubleCheckWhenExpire(node, now)
+		r.cache.doubleCheckWhenExpire(node, now)
 		val.Err = errs.ErrKeyNotExist // 缓存过期归类为找不到
 
 		return
 	}
 	val.Val = node.value
+	val.Version = node.version
+	if r.cache.shouldRefreshAhead(node, now) {
+		r.cache.refresh(node.key, node.version, node.expiration)
+	}
 
 	return
 }
 
+// shouldRefreshAhead 判断缓存结点是否已经进入了提前刷新的窗口
+func (r *RBTreePriorityCache) shouldRefreshAhead(node *rbTreeCacheNode, now time.Time) bool {
+	return r.refreshAhead > 0 && !node.deadline.IsZero() &&
+		node.deadline.Sub(now) <= r.refreshAhead
+}
+
+// refresh 通过 loader 在后台重新加载 key，同一个 key 同时只会有一个刷新任务。
+// 加载期间 key 被删除或者被覆盖了的话，加载的结果直接丢弃；
+// 写回的时候原地替换结点的值，保留结点原本的优先级
+func (r *RBTreePriorityCache) refresh(key string, version uint64, expiration time.Duration) {
+	if r.loader == nil {
+		return
+	}
+	r.refreshGroup.Go(key, func() {
+		ctx := context.Background()
+		val, err := r.loader(ctx, key)
+		if err != nil {
+			if r.refreshErrHandler != nil {
+				r.refreshErrHandler(key, err)
+			}
+			return
+		}
+		r.globalLock.Lock()
+		defer r.globalLock.Unlock()
+		if r.versionOf(key) != version {
+			return
+		}
+		node, _ := r.cacheData.Find(key)
+		node.replace(val, expiration)
+		r.touch(node)
+	})
+}
+
 // doubleCheckWhenExpire 缓存过期时的二次校验，防止被抢先删除了【调用该方法必须先获得锁】
 func (r *RBTreePriorityCache) doubleCheckWhenExpire(node *rbTreeCacheNode, now time.Time) {
 	checkNode, checkCacheErr := r.cacheData.Find(node.key)
@@ -178,17 +350,20 @@ func (r *RBTreePriorityCache) doubleCheckWhenExpire(node *rbTreeCacheNode, now t
 func (r *RBTreePriorityCache) GetSet(ctx context.Context, key string, val string) ecache.Value {
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.GetSet(ctx, key, val)
+}
 
+func (r unlocked) GetSet(ctx context.Context, key string, val string) ecache.Value {
 	var retVal ecache.Value
 
-	node, cacheErr := r.cacheData.Find(key)
+	node, cacheErr := r.cache.cacheData.Find(key)
 	if cacheErr != nil {
 		retVal.Err = errs.ErrKeyNotExist
-		if r.isFull() {
-			r.deleteNodeByPriority()
+		if r.cache.isFull() {
+			r.cache.deleteNodeByPriority()
 		}
 		node = newKVRBTreeCacheNode(key, val, 0)
-		r.addNode(node)
+		r.cache.addNode(node)
 
 		return retVal
 	}
@@ -196,6 +371,7 @@ func (r *RBTreePriorityCache) GetSet(ctx context.Context, key string, val string
 	//这里不需要判断缓存过期没有，取出旧值放入新值就完事了
 	retVal.Val = node.value
 	node.value = val
+	r.cache.touch(node)
 
 	return retVal
 }
@@ -203,8 +379,11 @@ func (r *RBTreePriorityCache) GetSet(ctx context.Context, key string, val string
 func (r *RBTreePriorityCache) LPush(ctx context.Context, key string, val ...any) (int64, error) {
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.LPush(ctx, key, val...)
+}
 
-	node := r.findOrCreateNode(key, func() any {
+func (r unlocked) LPush(ctx context.Context, key string, val ...any) (int64, error) {
+	node := r.cache.findOrCreateNode(key, func() any {
 		return list.NewLinkedList[any]()
 	})
 	nodeVal, ok := node.value.(*list.LinkedList[any])
@@ -213,10 +392,12 @@ func (r *RBTreePriorityCache) LPush(ctx context.Context, key string, val ...any)
 	}
 
 	var successNum int64
-	for item := range val {
+	for _, item := range val {
 		_ = nodeVal.Add(0, item) //这里的error理论上是不会出现的
 		successNum++
 	}
+	r.cache.touch(node)
+	r.cache.waiters.Notify(key, len(val))
 
 	return successNum, nil
 }
@@ -224,10 +405,13 @@ func (r *RBTreePriorityCache) LPush(ctx context.Context, key string, val ...any)
 func (r *RBTreePriorityCache) LPop(ctx context.Context, key string) ecache.Value {
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.LPop(ctx, key)
+}
 
+func (r unlocked) LPop(ctx context.Context, key string) ecache.Value {
 	var retVal ecache.Value
 
-	node, cacheErr := r.cacheData.Find(key)
+	node, cacheErr := r.cache.cacheData.Find(key)
 	if cacheErr != nil {
 		retVal.Err = errs.ErrKeyNotExist
 
@@ -242,20 +426,201 @@ func (r *RBTreePriorityCache) LPop(ctx context.Context, key string) ecache.Value
 	}
 
 	retVal.Val, retVal.Err = nodeVal.Delete(0) //lpop就是删除并获取list的第一个元素
+	r.cache.touch(node)
+
+	if nodeVal.Len() == 0 {
+		r.cache.deleteNode(node) //如果列表为空就删除缓存结点
+	}
+
+	return retVal
+}
+
+func (r *RBTreePriorityCache) BLPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return r.waiters.Wait(ctx, key, timeout, func() ecache.Value {
+		return unlocked{r}.pop(key, ecache.ListLeft, errOnlyListCanLPOP)
+	})
+}
+
+// BLPop 在 Pipeline 和事务中和 Redis 一样不会阻塞
+func (r unlocked) BLPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return r.pop(key, ecache.ListLeft, errOnlyListCanLPOP)
+}
+
+func (r *RBTreePriorityCache) BRPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return r.waiters.Wait(ctx, key, timeout, func() ecache.Value {
+		return unlocked{r}.pop(key, ecache.ListRight, errOnlyListCanRPOP)
+	})
+}
+
+func (r unlocked) BRPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return r.pop(key, ecache.ListRight, errOnlyListCanRPOP)
+}
+
+func (r *RBTreePriorityCache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) ecache.Value {
+	return r.waiters.Wait(ctx, source, timeout, func() ecache.Value {
+		return unlocked{r}.move(source, destination, srcPos, destPos)
+	})
+}
+
+func (r unlocked) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) ecache.Value {
+	return r.move(source, destination, srcPos, destPos)
+}
+
+func (r *RBTreePriorityCache) LMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection) ecache.Value {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.LMove(ctx, source, destination, srcPos, destPos)
+}
 
+func (r unlocked) LMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection) ecache.Value {
+	return r.move(source, destination, srcPos, destPos)
+}
+
+func (r *RBTreePriorityCache) RPopLPush(ctx context.Context, source, destination string) ecache.Value {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.RPopLPush(ctx, source, destination)
+}
+
+func (r unlocked) RPopLPush(ctx context.Context, source, destination string) ecache.Value {
+	return r.move(source, destination, ecache.ListRight, ecache.ListLeft)
+}
+
+func (r *RBTreePriorityCache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	return unlocked{r}.LRem(ctx, key, count, val)
+}
+
+func (r unlocked) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	node, cacheErr := r.cache.cacheData.Find(key)
+	if cacheErr != nil {
+		return 0, nil
+	}
+
+	nodeVal, ok := node.value.(*list.LinkedList[any])
+	if !ok {
+		return 0, errOnlyListCanLRem
+	}
+
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	items := nodeVal.AsSlice()
+	var removed int64
+	// count < 0 的时候从尾部开始找，删除之后前面元素的下标不会变化；
+	// 从头部开始找的时候，每删除一个元素，后面元素的下标都要减一
+	for i := range items {
+		idx := i
+		if count < 0 {
+			idx = len(items) - 1 - i
+		}
+		if !reflect.DeepEqual(items[idx], val) {
+			continue
+		}
+		if count >= 0 {
+			idx -= int(removed)
+		}
+		_, _ = nodeVal.Delete(idx) //这里的error理论上是不会出现的
+		removed++
+		if removed == limit {
+			break
+		}
+	}
+	if removed > 0 {
+		r.cache.touch(node)
+	}
+
+	if nodeVal.Len() == 0 {
+		r.cache.deleteNode(node) //如果列表为空就删除缓存结点
+	}
+
+	return removed, nil
+}
+
+// pop 从列表的 dir 一端移除一个元素，列表为空的时候删除缓存结点【调用该方法必须先获得锁】
+func (r unlocked) pop(key string, dir ecache.ListDirection, typeErr error) ecache.Value {
+	var retVal ecache.Value
+
+	node, cacheErr := r.cache.cacheData.Find(key)
+	if cacheErr != nil {
+		retVal.Err = errs.ErrKeyNotExist
+
+		return retVal
+	}
+
+	nodeVal, ok := node.value.(*list.LinkedList[any])
+	if !ok {
+		retVal.Err = typeErr
+
+		return retVal
+	}
 	if nodeVal.Len() == 0 {
-		r.deleteNode(node) //如果列表为空就删除缓存结点
+		retVal.Err = errs.ErrKeyNotExist
+
+		return retVal
 	}
 
+	idx := 0
+	if dir == ecache.ListRight {
+		idx = nodeVal.Len() - 1
+	}
+	retVal.Val, retVal.Err = nodeVal.Delete(idx)
+	r.cache.touch(node)
+
+	if nodeVal.Len() == 0 {
+		r.cache.deleteNode(node)
+	}
+
+	return retVal
+}
+
+// move 将 source 的一个元素移动到 destination，并且唤醒一个等待 destination 的调用者【调用该方法必须先获得锁】
+func (r unlocked) move(source, destination string, srcPos, destPos ecache.ListDirection) ecache.Value {
+	var retVal ecache.Value
+
+	// 和 Redis 一样，destination 类型不对的时候不会修改 source
+	if node, cacheErr := r.cache.cacheData.Find(destination); cacheErr == nil {
+		if _, ok := node.value.(*list.LinkedList[any]); !ok {
+			retVal.Err = errOnlyListCanLMove
+
+			return retVal
+		}
+	}
+	retVal = r.pop(source, srcPos, errOnlyListCanLMove)
+	if retVal.Err != nil {
+		return retVal
+	}
+
+	node := r.cache.findOrCreateNode(destination, func() any {
+		return list.NewLinkedList[any]()
+	})
+	nodeVal := node.value.(*list.LinkedList[any])
+	if destPos == ecache.ListRight {
+		_ = nodeVal.Append(retVal.Val)
+	} else {
+		_ = nodeVal.Add(0, retVal.Val)
+	}
+	r.cache.touch(node)
+	r.cache.waiters.Notify(destination, 1)
+
 	return retVal
 }
 
 func (r *RBTreePriorityCache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.SAdd(ctx, key, members...)
+}
 
-	node := r.findOrCreateNode(key, func() any {
-		return set.NewMapSet[any](r.collectionCap)
+func (r unlocked) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
+	node := r.cache.findOrCreateNode(key, func() any {
+		return set.NewMapSet[any](r.cache.collectionCap)
 	})
 	nodeVal, ok := node.value.(*set.MapSet[any])
 	if !ok {
@@ -270,15 +635,21 @@ func (r *RBTreePriorityCache) SAdd(ctx context.Context, key string, members ...a
 			successNum++
 		}
 	}
+	if successNum > 0 {
+		r.cache.touch(node)
+	}
 
 	return successNum, nil
 }
 
-func (r *RBTreePriorityCache) SRem(_ context.Context, key string, members ...any) (int64, error) {
+func (r *RBTreePriorityCache) SRem(ctx context.Context, key string, members ...any) (int64, error) {
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.SRem(ctx, key, members...)
+}
 
-	node, cacheErr := r.cacheData.Find(key)
+func (r unlocked) SRem(_ context.Context, key string, members ...any) (int64, error) {
+	node, cacheErr := r.cache.cacheData.Find(key)
 	if cacheErr != nil {
 		return 0, errs.ErrKeyNotExist
 	}
@@ -296,9 +667,12 @@ func (r *RBTreePriorityCache) SRem(_ context.Context, key string, members ...any
 			successNum++
 		}
 	}
+	if successNum > 0 {
+		r.cache.touch(node)
+	}
 
 	if len(nodeVal.Keys()) == 0 {
-		r.deleteNode(node) //如果集合为空，删除缓存结点
+		r.cache.deleteNode(node) //如果集合为空，删除缓存结点
 	}
 	return successNum, nil
 }
@@ -306,8 +680,11 @@ func (r *RBTreePriorityCache) SRem(_ context.Context, key string, members ...any
 func (r *RBTreePriorityCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.IncrBy(ctx, key, value)
+}
 
-	node := r.findOrCreateNode(key, func() any { return int64(0) })
+func (r unlocked) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	node := r.cache.findOrCreateNode(key, func() any { return int64(0) })
 
 	nodeVal, ok := node.value.(int64)
 	if !ok {
@@ -316,6 +693,7 @@ func (r *RBTreePriorityCache) IncrBy(ctx context.Context, key string, value int6
 
 	newVal := nodeVal + value
 	node.value = newVal
+	r.cache.touch(node)
 
 	return newVal, nil
 }
@@ -323,8 +701,11 @@ func (r *RBTreePriorityCache) IncrBy(ctx context.Context, key string, value int6
 func (r *RBTreePriorityCache) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.IncrByFloat(ctx, key, value)
+}
 
-	node := r.findOrCreateNode(key, func() any { return float64(0) })
+func (r unlocked) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
+	node := r.cache.findOrCreateNode(key, func() any { return float64(0) })
 	nodeVal, ok := node.value.(float64)
 	if !ok {
 		//如果是int类型可以尝试转换
@@ -337,13 +718,13 @@ func (r *RBTreePriorityCache) IncrByFloat(ctx context.Context, key string, value
 
 	newVal := nodeVal + value
 	node.value = newVal
+	r.cache.touch(node)
 
 	return newVal, nil
 }
 
 func (r *RBTreePriorityCache) Delete(ctx context.Context, keys ...string) (int64, error) {
 	delCount := int64(0)
-	now := time.Now()
 	for _, key := range keys {
 		r.globalLock.RLock()
 		_, cacheErr := r.cacheData.Find(key)
@@ -352,23 +733,29 @@ func (r *RBTreePriorityCache) Delete(ctx context.Context, keys ...string) (int64
 			continue
 		}
 
+		// 每个 key 单独加锁，避免长时间持有锁
 		r.globalLock.Lock()
-		node, cacheErr := r.cacheData.Find(key)
+		cnt, _ := unlocked{r}.Delete(ctx, key)
+		r.globalLock.Unlock()
+		delCount += cnt
+	}
+	return delCount, nil
+}
+
+func (r unlocked) Delete(ctx context.Context, keys ...string) (int64, error) {
+	delCount := int64(0)
+	now := time.Now()
+	for _, key := range keys {
+		node, cacheErr := r.cache.cacheData.Find(key)
 		if cacheErr != nil {
-			r.globalLock.Unlock()
 			continue
 		}
 
+		r.cache.deleteNode(node)
 		// 过期删除不添加计数
-		if !node.beforeDeadline(now) {
-			r.deleteNode(node)
-			r.globalLock.Unlock()
-			continue
+		if node.beforeDeadline(now) {
+			delCount++
 		}
-
-		r.deleteNode(node)
-		r.globalLock.Unlock()
-		delCount++
 	}
 	return delCount, nil
 }
@@ -376,8 +763,11 @@ func (r *RBTreePriorityCache) Delete(ctx context.Context, keys ...string) (int64
 func (r *RBTreePriorityCache) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
 	r.globalLock.Lock()
 	defer r.globalLock.Unlock()
+	return unlocked{r}.DecrBy(ctx, key, value)
+}
 
-	node := r.findOrCreateNode(key, func() any { return int64(0) })
+func (r unlocked) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
+	node := r.cache.findOrCreateNode(key, func() any { return int64(0) })
 
 	nodeVal, ok := node.value.(int64)
 	if !ok {
@@ -386,10 +776,28 @@ func (r *RBTreePriorityCache) DecrBy(ctx context.Context, key string, value int6
 
 	newVal := nodeVal - value
 	node.value = newVal
+	r.cache.touch(node)
 
 	return newVal, nil
 }
 
+// Pipeline 所有排队的操作在执行的时候只会加一次锁
+func (r *RBTreePriorityCache) Pipeline() ecache.Pipeline {
+	return pipeline.New(r.run)
+}
+
+// Watch 乐观锁事务，每次修改 key 都会更新它的版本，提交的时候在锁里面比较版本
+func (r *RBTreePriorityCache) Watch(ctx context.Context, fn func(tx ecache.Tx) error, keys ...string) error {
+	return pipeline.Watch(ctx, r, r.run, r.versionOf, fn, keys...)
+}
+
+// run 加锁之后通过不加锁的 unlocked 执行 fn
+func (r *RBTreePriorityCache) run(fn func(c ecache.Cache)) {
+	r.globalLock.Lock()
+	defer r.globalLock.Unlock()
+	fn(unlocked{r})
+}
+
 // calculatePriority 获取缓存数据的优先级权重
 func (r *RBTreePriorityCache) calculatePriority(node *rbTreeCacheNode) int {
 	priority := r.defaultPriority
@@ -417,6 +825,21 @@ func (r *RBTreePriorityCache) deleteNodeFromPriority(node *rbTreeCacheNode) {
 	node.truncate()
 }
 
+// touch 修改了缓存结点之后，更新它的版本【调用该方法必须先获得锁】
+func (r *RBTreePriorityCache) touch(node *rbTreeCacheNode) {
+	r.version++
+	node.version = r.version
+}
+
+// versionOf 返回 key 当前的版本，key 不存在或者已经过期的时候返回 0【调用该方法必须先获得锁】
+func (r *RBTreePriorityCache) versionOf(key string) uint64 {
+	node, cacheErr := r.cacheData.Find(key)
+	if cacheErr != nil || !node.beforeDeadline(time.Now()) {
+		return 0
+	}
+	return node.version
+}
+
 // isFull 键值对数量满了没有
 func (r *RBTreePriorityCache) isFull() bool {
 	return r.cacheNum >= r.cacheLimit
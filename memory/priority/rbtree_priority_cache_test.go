@@ -16,13 +16,17 @@ package priority
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/ecodeclub/ecache"
 	"github.com/ecodeclub/ecache/internal/errs"
 	"github.com/ecodeclub/ekit/list"
 	"github.com/ecodeclub/ekit/set"
@@ -1844,3 +1848,469 @@ func TestRBTreePriorityCache_IncrByFloat(t *testing.T) {
 		})
 	}
 }
+
+func TestRBTreePriorityCache_RefreshAhead(t *testing.T) {
+	release := make(chan struct{})
+	var loadCnt int64
+	loader := func(ctx context.Context, key string) (any, error) {
+		atomic.AddInt64(&loadCnt, 1)
+		<-release
+		if key == "fail" {
+			return nil, errors.New("mock error")
+		}
+		return "new value", nil
+	}
+	failed := make(chan string, 1)
+	cache, _ := newRBTreePriorityCache(WithLoader(loader),
+		WithRefreshAhead(time.Millisecond*100, 1),
+		WithRefreshErrorHandler(func(key string, err error) {
+			failed <- key
+		}))
+	ctx := context.Background()
+
+	// 还没进入刷新窗口
+	err := cache.Set(ctx, "far", "value", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "value", cache.Get(ctx, "far").Val)
+
+	// 永不过期的 key 不会刷新
+	err = cache.Set(ctx, "forever", "value", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "value", cache.Get(ctx, "forever").Val)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&loadCnt))
+
+	// 进入刷新窗口，返回旧值并且在后台刷新
+	err = cache.Set(ctx, "near", "old value", time.Millisecond*150)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 60)
+	assert.Equal(t, "old value", cache.Get(ctx, "near").Val)
+	assert.Equal(t, "old value", cache.Get(ctx, "near").Val)
+
+	// 达到并发上限，不会刷新
+	err = cache.Set(ctx, "fail", "old value", time.Millisecond*50)
+	require.NoError(t, err)
+	assert.Equal(t, "old value", cache.Get(ctx, "fail").Val)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&loadCnt) == 1
+	}, time.Second, time.Millisecond*5)
+
+	release <- struct{}{}
+	assert.Eventually(t, func() bool {
+		return cache.Get(ctx, "near").Val == "new value"
+	}, time.Second, time.Millisecond*5)
+
+	// 刷新失败会回调
+	err = cache.Set(ctx, "fail", "old value", time.Millisecond*50)
+	require.NoError(t, err)
+	assert.Equal(t, "old value", cache.Get(ctx, "fail").Val)
+	release <- struct{}{}
+	assert.Equal(t, "fail", <-failed)
+	close(release)
+}
+
+// TestRBTreePriorityCache_RefreshDiscarded 加载期间 key 被删除或者被覆盖了，加载的结果直接丢弃
+func TestRBTreePriorityCache_RefreshDiscarded(t *testing.T) {
+	loading := make(chan struct{}, 2)
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (any, error) {
+		loading <- struct{}{}
+		<-release
+		return "loaded value", nil
+	}
+	cache, _ := newRBTreePriorityCache(WithLoader(loader), WithRefreshAhead(time.Minute, 2))
+	ctx := context.Background()
+
+	for _, key := range []string{"deleted", "overwritten"} {
+		require.NoError(t, cache.Set(ctx, key, "old value", time.Second))
+		assert.Equal(t, "old value", cache.Get(ctx, key).Val)
+		<-loading
+	}
+
+	_, err := cache.Delete(ctx, "deleted")
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(ctx, "overwritten", "new value", time.Minute))
+	close(release)
+	// 等待后台的刷新完成
+	time.Sleep(time.Millisecond * 20)
+
+	assert.True(t, cache.Get(ctx, "deleted").KeyNotFound())
+	assert.Equal(t, "new value", cache.Get(ctx, "overwritten").Val)
+}
+
+// TestRBTreePriorityCache_RefreshKeepPriority 刷新之后结点保留原本的优先级
+func TestRBTreePriorityCache_RefreshKeepPriority(t *testing.T) {
+	loader := func(ctx context.Context, key string) (any, error) {
+		return testStructForPriority{priority: 100}, nil
+	}
+	cache, _ := newRBTreePriorityCache(WithLoader(loader), WithRefreshAhead(time.Minute, 1))
+	ctx := context.Background()
+
+	require.NoError(t, cache.Set(ctx, "key1", testStructForPriority{priority: 1}, time.Second))
+	assert.Equal(t, testStructForPriority{priority: 1}, cache.Get(ctx, "key1").Val)
+	assert.Eventually(t, func() bool {
+		return cache.Get(ctx, "key1").Val == testStructForPriority{priority: 100}
+	}, time.Second, time.Millisecond*5)
+
+	cache.globalLock.RLock()
+	defer cache.globalLock.RUnlock()
+	node, err := cache.cacheData.Find("key1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, node.priority)
+}
+
+func TestRBTreePriorityCache_SetXX(t *testing.T) {
+	testCases := []struct {
+		name   string
+		before func(t *testing.T, c *RBTreePriorityCache)
+
+		wantOk  bool
+		wantVal any
+	}{
+		{
+			name:   "key not exist",
+			before: func(t *testing.T, c *RBTreePriorityCache) {},
+		},
+		{
+			name: "key expired",
+			before: func(t *testing.T, c *RBTreePriorityCache) {
+				c.addNode(newKVRBTreeCacheNode("key1", "value1", -time.Second))
+			},
+		},
+		{
+			name: "key exist",
+			before: func(t *testing.T, c *RBTreePriorityCache) {
+				c.addNode(newKVRBTreeCacheNode("key1", "value1", time.Minute))
+			},
+			wantOk:  true,
+			wantVal: "value2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, _ := NewRBTreePriorityCache()
+			tc.before(t, c)
+			ok, err := c.SetXX(ctx, "key1", "value2", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantVal, c.Get(ctx, "key1").Val)
+		})
+	}
+}
+
+func TestRBTreePriorityCache_CompareAndSwap(t *testing.T) {
+	testCases := []struct {
+		name   string
+		before func(t *testing.T, c *RBTreePriorityCache)
+		old    any
+
+		wantOk  bool
+		wantVal any
+	}{
+		{
+			name:   "key not exist",
+			before: func(t *testing.T, c *RBTreePriorityCache) {},
+			old:    "value1",
+		},
+		{
+			name: "key expired",
+			before: func(t *testing.T, c *RBTreePriorityCache) {
+				c.addNode(newKVRBTreeCacheNode("key1", "value1", -time.Second))
+			},
+			old: "value1",
+		},
+		{
+			name: "not equal",
+			before: func(t *testing.T, c *RBTreePriorityCache) {
+				c.addNode(newKVRBTreeCacheNode("key1", "value1", time.Minute))
+			},
+			old:     "value",
+			wantVal: "value1",
+		},
+		{
+			name: "equal",
+			before: func(t *testing.T, c *RBTreePriorityCache) {
+				c.addNode(newKVRBTreeCacheNode("key1", "value1", time.Minute))
+			},
+			old:     "value1",
+			wantOk:  true,
+			wantVal: "value2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, _ := NewRBTreePriorityCache()
+			tc.before(t, c)
+			ok, err := c.CompareAndSwap(ctx, "key1", tc.old, "value2", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantVal, c.Get(ctx, "key1").Val)
+		})
+	}
+}
+
+func TestRBTreePriorityCache_SetIfVersion(t *testing.T) {
+	ctx := context.Background()
+	c, _ := NewRBTreePriorityCache()
+
+	// 不存在的 key 版本为 0
+	val := c.Get(ctx, "key1")
+	assert.Equal(t, uint64(0), val.Version)
+	ok, err := c.SetIfVersion(ctx, "key1", "value1", val.Version, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	val = c.Get(ctx, "key1")
+	assert.NotEqual(t, uint64(0), val.Version)
+	ok, err = c.SetIfVersion(ctx, "key1", "value2", 0, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	// 原地修改也会更新版本
+	_, err = c.IncrBy(ctx, "cnt", 1)
+	require.NoError(t, err)
+	cnt := c.Get(ctx, "cnt")
+	_, err = c.IncrBy(ctx, "cnt", 1)
+	require.NoError(t, err)
+	ok, err = c.SetIfVersion(ctx, "cnt", int64(10), cnt.Version, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = c.SetIfVersion(ctx, "key1", "value2", val.Version, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "value2", c.Get(ctx, "key1").Val)
+}
+
+func TestRBTreePriorityCache_BLPop(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := NewRBTreePriorityCache()
+
+	// LPush 从头部写入，列表是 [c b a]
+	_, err := cache.LPush(ctx, "list", "a", "b", "c")
+	require.NoError(t, err)
+	val := cache.BLPop(ctx, "list", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "c", val.Val)
+	val = cache.BRPop(ctx, "list", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	val = cache.BRPop(ctx, "list", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "b", val.Val)
+	// 列表为空之后删除缓存结点
+	assert.Equal(t, 0, cache.cacheNum)
+
+	val = cache.BLPop(ctx, "list", time.Millisecond*10)
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	val = cache.BRPop(tctx, "list", 0)
+	assert.Equal(t, context.DeadlineExceeded, val.Err)
+
+	require.NoError(t, cache.Set(ctx, "string", "value", time.Minute))
+	assert.Equal(t, errOnlyListCanLPOP, cache.BLPop(ctx, "string", time.Second).Err)
+	assert.Equal(t, errOnlyListCanRPOP, cache.BRPop(ctx, "string", time.Second).Err)
+
+	// 被其他人的 LPush 唤醒
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_, _ = cache.LPush(ctx, "list", "d")
+	}()
+	val = cache.BLPop(ctx, "list", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "d", val.Val)
+
+	// 在 Pipeline 和事务中不会阻塞
+	cache.run(func(c ecache.Cache) {
+		val = c.BLPop(ctx, "list", time.Hour)
+	})
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+}
+
+func TestRBTreePriorityCache_BLMove(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := NewRBTreePriorityCache()
+
+	_, err := cache.LPush(ctx, "jobs", "a", "b")
+	require.NoError(t, err)
+	val := cache.BLMove(ctx, "jobs", "processing", ecache.ListRight, ecache.ListLeft, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	val = cache.BLMove(ctx, "jobs", "processing", ecache.ListLeft, ecache.ListRight, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "b", val.Val)
+	assert.Equal(t, "a", cache.BLPop(ctx, "processing", time.Second).Val)
+	assert.Equal(t, "b", cache.BLPop(ctx, "processing", time.Second).Val)
+
+	// destination 类型不对的时候不会修改 source
+	_, err = cache.LPush(ctx, "jobs", "c")
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(ctx, "string", "value", time.Minute))
+	val = cache.BLMove(ctx, "jobs", "string", ecache.ListLeft, ecache.ListLeft, time.Second)
+	assert.Equal(t, errOnlyListCanLMove, val.Err)
+	assert.Equal(t, "c", cache.BLPop(ctx, "jobs", time.Second).Val)
+
+	// 阻塞直到 source 有数据，并且移动之后唤醒等待 destination 的调用者
+	done := make(chan ecache.Value, 1)
+	go func() {
+		done <- cache.BLPop(ctx, "processing", time.Second)
+	}()
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_, _ = cache.LPush(ctx, "jobs", "d")
+	}()
+	val = cache.BLMove(ctx, "jobs", "processing", ecache.ListLeft, ecache.ListLeft, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "d", val.Val)
+	val = <-done
+	require.NoError(t, val.Err)
+	assert.Equal(t, "d", val.Val)
+}
+
+func TestRBTreePriorityCache_LMove(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := NewRBTreePriorityCache()
+
+	_, err := cache.LPush(ctx, "jobs", "a", "b")
+	require.NoError(t, err)
+	val := cache.RPopLPush(ctx, "jobs", "processing")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	// 只有一个元素的列表轮转之后依旧存在
+	val = cache.RPopLPush(ctx, "jobs", "jobs")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "b", val.Val)
+	val = cache.LMove(ctx, "processing", "jobs", ecache.ListLeft, ecache.ListRight)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	assert.Equal(t, "a", cache.BRPop(ctx, "jobs", time.Second).Val)
+
+	// 列表为空的时候结点已经被删除
+	val = cache.LMove(ctx, "processing", "jobs", ecache.ListLeft, ecache.ListRight)
+	assert.True(t, val.KeyNotFound())
+
+	require.NoError(t, cache.Set(ctx, "string", "value", time.Minute))
+	val = cache.RPopLPush(ctx, "jobs", "string")
+	assert.Equal(t, errOnlyListCanLMove, val.Err)
+	assert.Equal(t, "b", cache.BLPop(ctx, "jobs", time.Second).Val)
+}
+
+func TestRBTreePriorityCache_LRem(t *testing.T) {
+	testCases := []struct {
+		name  string
+		count int64
+
+		wantCnt  int64
+		wantList []any
+	}{
+		{
+			name:     "remove all",
+			count:    0,
+			wantCnt:  3,
+			wantList: []any{"b", "c"},
+		},
+		{
+			name:     "remove from head",
+			count:    2,
+			wantCnt:  2,
+			wantList: []any{"b", "c", "a"},
+		},
+		{
+			name:     "remove from tail",
+			count:    -2,
+			wantCnt:  2,
+			wantList: []any{"a", "b", "c"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			cache, _ := NewRBTreePriorityCache()
+			// 列表是 [a b a c a]
+			_, err := cache.LPush(ctx, "list", "a", "c", "a", "b", "a")
+			require.NoError(t, err)
+			cnt, err := cache.LRem(ctx, "list", tc.count, "a")
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantCnt, cnt)
+
+			var res []any
+			for val := cache.LPop(ctx, "list"); val.Err == nil; val = cache.LPop(ctx, "list") {
+				res = append(res, val.Val)
+			}
+			assert.Equal(t, tc.wantList, res)
+		})
+	}
+
+	ctx := context.Background()
+	cache, _ := NewRBTreePriorityCache()
+	// 删除所有的元素之后结点也被删除
+	_, err := cache.LPush(ctx, "list", "a", "a")
+	require.NoError(t, err)
+	cnt, err := cache.LRem(ctx, "list", 0, "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+	assert.True(t, cache.Get(ctx, "list").KeyNotFound())
+
+	cnt, err = cache.LRem(ctx, "not-exist", 0, "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+	require.NoError(t, cache.Set(ctx, "string", "value", time.Minute))
+	_, err = cache.LRem(ctx, "string", 0, "a")
+	assert.Equal(t, errOnlyListCanLRem, err)
+}
+
+// TestRBTreePriorityCache_UnlockedMethods 在锁里面通过 unlocked 调用所有的方法，也就是 Pipeline.Exec 和事务提交时的执行路径，
+// 任何一个方法调用到了加锁的方法都会死锁
+func TestRBTreePriorityCache_UnlockedMethods(t *testing.T) {
+	c, err := newRBTreePriorityCache()
+	require.NoError(t, err)
+	var called []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.run(func(u ecache.Cache) {
+			called = callAllMethods(u)
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("unlocked 的方法调用到了加锁的方法")
+	}
+	assert.Equal(t, reflect.TypeOf(unlocked{}).NumMethod(), len(called))
+	assert.GreaterOrEqual(t, len(called), reflect.TypeOf((*ecache.GeoCache)(nil)).Elem().NumMethod())
+}
+
+// callAllMethods 使用默认的参数调用 c 上所有导出的方法，返回调用过的方法
+func callAllMethods(c ecache.Cache) []string {
+	val := reflect.ValueOf(c)
+	typ := val.Type()
+	called := make([]string, 0, typ.NumMethod())
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := val.Method(i)
+		mt := method.Type()
+		args := make([]reflect.Value, 0, mt.NumIn())
+		for j := 0; j < mt.NumIn(); j++ {
+			in := mt.In(j)
+			if mt.IsVariadic() && j == mt.NumIn()-1 {
+				break
+			}
+			switch {
+			case in == reflect.TypeOf((*context.Context)(nil)).Elem():
+				args = append(args, reflect.ValueOf(context.Background()))
+			case in == reflect.TypeOf(ecache.ListLeft):
+				args = append(args, reflect.ValueOf(ecache.ListLeft))
+			case in.Kind() == reflect.String:
+				args = append(args, reflect.ValueOf("key").Convert(in))
+			case in.Kind() == reflect.Interface:
+				args = append(args, reflect.ValueOf("val"))
+			default:
+				args = append(args, reflect.Zero(in))
+			}
+		}
+		method.Call(args)
+		called = append(called, typ.Method(i).Name)
+	}
+	return called
+}
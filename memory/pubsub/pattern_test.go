@@ -0,0 +1,56 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPattern(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		str     string
+		want    bool
+	}{
+		{pattern: "user", str: "user", want: true},
+		{pattern: "user", str: "users", want: false},
+		{pattern: "user.*", str: "user.deleted", want: true},
+		{pattern: "user.*", str: "user.", want: true},
+		{pattern: "user.*", str: "order.deleted", want: false},
+		{pattern: "*", str: "", want: true},
+		{pattern: "**.deleted", str: "user.deleted", want: true},
+		{pattern: "*.deleted", str: "user.created", want: false},
+		{pattern: "h?llo", str: "hello", want: true},
+		{pattern: "h?llo", str: "hllo", want: false},
+		{pattern: "h[ae]llo", str: "hallo", want: true},
+		{pattern: "h[ae]llo", str: "hillo", want: false},
+		{pattern: "h[^e]llo", str: "hallo", want: true},
+		{pattern: "h[^e]llo", str: "hello", want: false},
+		{pattern: "h[a-c]llo", str: "hbllo", want: true},
+		{pattern: "h[c-a]llo", str: "hbllo", want: true},
+		{pattern: "h[a-c]llo", str: "hdllo", want: false},
+		{pattern: "h[\\]]llo", str: "h]llo", want: true},
+		{pattern: "h[ae]llo", str: "h", want: false},
+		{pattern: "user\\*", str: "user*", want: true},
+		{pattern: "user\\*", str: "users", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.pattern+"/"+tc.str, func(t *testing.T) {
+			assert.Equal(t, tc.want, matchPattern(tc.pattern, tc.str))
+		})
+	}
+}
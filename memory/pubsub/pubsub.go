@@ -0,0 +1,157 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/internal/resp"
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+var (
+	_ ecache.PubSub       = (*PubSub)(nil)
+	_ ecache.Subscription = (*subscription)(nil)
+)
+
+// PubSub 进程内的发布订阅，一般用于单元测试或者单机部署。
+// 和 Redis 一样，消息不会持久化，发布的时候没有订阅者的消息会被丢弃
+type PubSub struct {
+	mutex      sync.RWMutex
+	subs       map[*subscription]struct{}
+	bufferSize int
+}
+
+// WithBufferSize 设置每个订阅缓存的消息数量，默认是 1024。
+// 缓存满了之后，新的消息会被丢弃，避免慢的订阅者阻塞发布者
+func WithBufferSize(size int) option.Option[PubSub] {
+	return func(p *PubSub) {
+		p.bufferSize = size
+	}
+}
+
+func NewPubSub(opts ...option.Option[PubSub]) *PubSub {
+	res := &PubSub{
+		subs:       make(map[*subscription]struct{}),
+		bufferSize: 1024,
+	}
+	option.Apply(res, opts...)
+	return res
+}
+
+func (p *PubSub) Publish(ctx context.Context, channel string, msg any) (int64, error) {
+	payload, err := resp.String(msg)
+	if err != nil {
+		return 0, err
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	var cnt int64
+	for sub := range p.subs {
+		for _, pattern := range sub.match(channel) {
+			if sub.deliver(ecache.Message{Channel: channel, Pattern: pattern, Payload: payload}) {
+				cnt++
+			}
+		}
+	}
+	return cnt, nil
+}
+
+func (p *PubSub) Subscribe(ctx context.Context, channels ...string) (ecache.Subscription, error) {
+	return p.subscribe(ctx, &subscription{channels: channels})
+}
+
+func (p *PubSub) PSubscribe(ctx context.Context, patterns ...string) (ecache.Subscription, error) {
+	return p.subscribe(ctx, &subscription{patterns: patterns})
+}
+
+func (p *PubSub) subscribe(ctx context.Context, sub *subscription) (ecache.Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sub.ps = p
+	sub.msgs = make(chan ecache.Message, p.bufferSize)
+	sub.closed = make(chan struct{})
+	p.mutex.Lock()
+	p.subs[sub] = struct{}{}
+	p.mutex.Unlock()
+	return sub, nil
+}
+
+type subscription struct {
+	ps       *PubSub
+	channels []string
+	patterns []string
+	msgs     chan ecache.Message
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// match 返回匹配上的模式，通过频道订阅的用空字符串表示。
+// 同时通过频道和模式匹配上的时候会返回多个，和 Redis 一样每个都会收到一条消息
+func (s *subscription) match(channel string) []string {
+	var res []string
+	for _, c := range s.channels {
+		if c == channel {
+			res = append(res, "")
+			break
+		}
+	}
+	for _, pattern := range s.patterns {
+		if matchPattern(pattern, channel) {
+			res = append(res, pattern)
+		}
+	}
+	return res
+}
+
+// deliver 缓存满了的时候丢弃消息，返回是否投递成功
+func (s *subscription) deliver(msg ecache.Message) bool {
+	select {
+	case s.msgs <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *subscription) Next(ctx context.Context) (ecache.Message, error) {
+	select {
+	case <-s.closed:
+		return ecache.Message{}, errs.ErrSubscriptionClosed
+	default:
+	}
+	select {
+	case msg := <-s.msgs:
+		return msg, nil
+	case <-s.closed:
+		return ecache.Message{}, errs.ErrSubscriptionClosed
+	case <-ctx.Done():
+		return ecache.Message{}, ctx.Err()
+	}
+}
+
+func (s *subscription) Close() error {
+	s.once.Do(func() {
+		s.ps.mutex.Lock()
+		delete(s.ps.subs, s)
+		s.ps.mutex.Unlock()
+		close(s.closed)
+	})
+	return nil
+}
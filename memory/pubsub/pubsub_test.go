@@ -0,0 +1,120 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubSub(t *testing.T) {
+	ctx := context.Background()
+	p := NewPubSub()
+	sub, err := p.Subscribe(ctx, "user.deleted", "user.created")
+	require.NoError(t, err)
+	psub, err := p.PSubscribe(ctx, "user.*", "*.deleted")
+	require.NoError(t, err)
+
+	cnt, err := p.Publish(ctx, "user.deleted", 123)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), cnt)
+	cnt, err = p.Publish(ctx, "order.created", []byte("456"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+
+	msg, err := sub.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ecache.Message{Channel: "user.deleted", Payload: "123"}, msg)
+	msg, err = psub.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ecache.Message{Channel: "user.deleted", Pattern: "user.*", Payload: "123"}, msg)
+	msg, err = psub.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ecache.Message{Channel: "user.deleted", Pattern: "*.deleted", Payload: "123"}, msg)
+
+	// 没有新消息
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	_, err = sub.Next(tctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// 关闭之后不再收到消息
+	require.NoError(t, sub.Close())
+	require.NoError(t, sub.Close())
+	_, err = sub.Next(ctx)
+	assert.Equal(t, errs.ErrSubscriptionClosed, err)
+	cnt, err = p.Publish(ctx, "user.created", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+	msg, err = psub.Next(ctx)
+	require.NoError(t, err)
+	// 和 go-redis 一样，time.Duration 会被转换为纳秒
+	assert.Equal(t, "1000000000", msg.Payload)
+}
+
+func TestPubSub_NextBlocking(t *testing.T) {
+	ctx := context.Background()
+	p := NewPubSub()
+	sub, err := p.Subscribe(ctx, "user")
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		_, _ = p.Publish(ctx, "user", "hello")
+	}()
+	msg, err := sub.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", msg.Payload)
+
+	// 阻塞的时候被关闭
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		_ = sub.Close()
+	}()
+	_, err = sub.Next(ctx)
+	assert.Equal(t, errs.ErrSubscriptionClosed, err)
+}
+
+func TestPubSub_BufferFull(t *testing.T) {
+	ctx := context.Background()
+	p := NewPubSub(WithBufferSize(1))
+	sub, err := p.Subscribe(ctx, "user")
+	require.NoError(t, err)
+
+	cnt, err := p.Publish(ctx, "user", "first")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+	// 缓存满了，消息被丢弃
+	cnt, err = p.Publish(ctx, "user", "second")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+
+	msg, err := sub.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "first", msg.Payload)
+}
+
+func TestPubSub_SubscribeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := NewPubSub().Subscribe(ctx, "user")
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,411 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/internal/resp"
+)
+
+var _ ecache.Stream = (*Stream)(nil)
+
+var (
+	errInvalidID = errors.New("ecache: stream ID 不合法")
+	errSmallerID = errors.New("ecache: stream ID 必须大于最后一条消息的 ID")
+	errNoValues  = errors.New("ecache: XAdd 至少需要一个字段")
+)
+
+// Stream 进程内的 Streams 实现，一般用于单元测试或者单机部署。
+// 和 Redis 一样支持消费者组、pending 列表和 XClaim，但是消息不会持久化
+type Stream struct {
+	mutex   sync.Mutex
+	streams map[string]*stream
+	// notify 每次 XAdd 都会关闭并且替换，用于唤醒阻塞读取的调用者
+	notify chan struct{}
+	now    func() time.Time
+}
+
+func NewStream() *Stream {
+	return &Stream{
+		streams: make(map[string]*stream),
+		notify:  make(chan struct{}),
+		now:     time.Now,
+	}
+}
+
+type stream struct {
+	// entries 按照 ID 从小到大排列
+	entries []entry
+	// last 最后生成的 ID，XTrim 之后也不会变小
+	last   streamID
+	groups map[string]*group
+}
+
+type entry struct {
+	id     streamID
+	values map[string]string
+}
+
+type group struct {
+	// lastID 最后投递的消息
+	lastID  streamID
+	pending map[streamID]*pendingEntry
+}
+
+type pendingEntry struct {
+	consumer    string
+	deliveredAt time.Time
+	count       int64
+}
+
+func (s *Stream) XAdd(ctx context.Context, key, id string, values map[string]any) (string, error) {
+	if len(values) == 0 {
+		return "", errNoValues
+	}
+	vals := make(map[string]string, len(values))
+	for k, v := range values {
+		str, err := resp.String(v)
+		if err != nil {
+			return "", err
+		}
+		vals[k] = str
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	st := s.getOrCreate(key)
+	var newID streamID
+	if id == "" || id == "*" {
+		newID = st.last.next(uint64(s.now().UnixMilli()))
+	} else {
+		var err error
+		newID, err = parseID(id)
+		if err != nil {
+			return "", err
+		}
+		if !st.last.less(newID) {
+			return "", errSmallerID
+		}
+	}
+	st.entries = append(st.entries, entry{id: newID, values: vals})
+	st.last = newID
+	close(s.notify)
+	s.notify = make(chan struct{})
+	return newID.String(), nil
+}
+
+func (s *Stream) XRead(ctx context.Context, key, id string, count int64, block time.Duration) ([]ecache.StreamMessage, error) {
+	s.mutex.Lock()
+	var start streamID
+	if id == "$" {
+		if st, ok := s.streams[key]; ok {
+			start = st.last
+		}
+	} else {
+		var err error
+		start, err = parseID(id)
+		if err != nil {
+			s.mutex.Unlock()
+			return nil, err
+		}
+	}
+	s.mutex.Unlock()
+	return s.read(ctx, block, func() ([]ecache.StreamMessage, error) {
+		st, ok := s.streams[key]
+		if !ok {
+			return nil, nil
+		}
+		entries := st.after(start, count)
+		res := make([]ecache.StreamMessage, 0, len(entries))
+		for _, e := range entries {
+			res = append(res, e.message())
+		}
+		return res, nil
+	})
+}
+
+func (s *Stream) XGroupCreate(ctx context.Context, key, name, start string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	st := s.getOrCreate(key)
+	if _, ok := st.groups[name]; ok {
+		return errs.ErrStreamGroupExist
+	}
+	lastID := st.last
+	if start != "$" {
+		var err error
+		lastID, err = parseID(start)
+		if err != nil {
+			return err
+		}
+	}
+	st.groups[name] = &group{
+		lastID:  lastID,
+		pending: make(map[streamID]*pendingEntry),
+	}
+	return nil
+}
+
+func (s *Stream) XReadGroup(ctx context.Context, key, name, consumer string,
+	count int64, block time.Duration) ([]ecache.StreamMessage, error) {
+	return s.read(ctx, block, func() ([]ecache.StreamMessage, error) {
+		st, g, err := s.group(key, name)
+		if err != nil {
+			return nil, err
+		}
+		entries := st.after(g.lastID, count)
+		now := s.now()
+		res := make([]ecache.StreamMessage, 0, len(entries))
+		for _, e := range entries {
+			g.pending[e.id] = &pendingEntry{consumer: consumer, deliveredAt: now, count: 1}
+			g.lastID = e.id
+			res = append(res, e.message())
+		}
+		return res, nil
+	})
+}
+
+// read 在锁里面执行 fn，没有读到消息并且 block > 0 的时候等待下一次 XAdd 之后重试
+func (s *Stream) read(ctx context.Context, block time.Duration,
+	fn func() ([]ecache.StreamMessage, error)) ([]ecache.StreamMessage, error) {
+	var timeout <-chan time.Time
+	if block > 0 {
+		timer := time.NewTimer(block)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		s.mutex.Lock()
+		res, err := fn()
+		notify := s.notify
+		s.mutex.Unlock()
+		if err != nil || len(res) > 0 || block <= 0 {
+			if res == nil && err == nil {
+				res = []ecache.StreamMessage{}
+			}
+			return res, err
+		}
+		select {
+		case <-notify:
+		case <-timeout:
+			return []ecache.StreamMessage{}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *Stream) XAck(ctx context.Context, key, name string, ids ...string) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, g, err := s.group(key, name)
+	if err != nil {
+		// 和 Redis 一样，消费者组不存在的时候确认数量为 0
+		return 0, nil
+	}
+	var cnt int64
+	for _, id := range ids {
+		sid, err := parseID(id)
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := g.pending[sid]; ok {
+			delete(g.pending, sid)
+			cnt++
+		}
+	}
+	return cnt, nil
+}
+
+func (s *Stream) XPending(ctx context.Context, key, name string, count int64) ([]ecache.PendingMessage, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, g, err := s.group(key, name)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]streamID, 0, len(g.pending))
+	for id := range g.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].less(ids[j])
+	})
+	if count > 0 && int64(len(ids)) > count {
+		ids = ids[:count]
+	}
+	now := s.now()
+	res := make([]ecache.PendingMessage, 0, len(ids))
+	for _, id := range ids {
+		p := g.pending[id]
+		res = append(res, ecache.PendingMessage{
+			ID:         id.String(),
+			Consumer:   p.consumer,
+			Idle:       now.Sub(p.deliveredAt),
+			RetryCount: p.count,
+		})
+	}
+	return res, nil
+}
+
+func (s *Stream) XClaim(ctx context.Context, key, name, consumer string,
+	minIdle time.Duration, ids ...string) ([]ecache.StreamMessage, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	st, g, err := s.group(key, name)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	res := make([]ecache.StreamMessage, 0, len(ids))
+	for _, id := range ids {
+		sid, err := parseID(id)
+		if err != nil {
+			return nil, err
+		}
+		p, ok := g.pending[sid]
+		if !ok || now.Sub(p.deliveredAt) < minIdle {
+			continue
+		}
+		e, ok := st.find(sid)
+		if !ok {
+			// 消息已经被删除了
+			delete(g.pending, sid)
+			continue
+		}
+		p.consumer = consumer
+		p.deliveredAt = now
+		p.count++
+		res = append(res, e.message())
+	}
+	return res, nil
+}
+
+func (s *Stream) XTrim(ctx context.Context, key string, maxLen int64) (int64, error) {
+	if maxLen < 0 {
+		maxLen = 0
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	st, ok := s.streams[key]
+	if !ok || int64(len(st.entries)) <= maxLen {
+		return 0, nil
+	}
+	cnt := int64(len(st.entries)) - maxLen
+	st.entries = append([]entry(nil), st.entries[cnt:]...)
+	return cnt, nil
+}
+
+func (s *Stream) getOrCreate(key string) *stream {
+	st, ok := s.streams[key]
+	if !ok {
+		st = &stream{groups: make(map[string]*group)}
+		s.streams[key] = st
+	}
+	return st
+}
+
+func (s *Stream) group(key, name string) (*stream, *group, error) {
+	st, ok := s.streams[key]
+	if !ok {
+		return nil, nil, errs.ErrStreamGroupNotExist
+	}
+	g, ok := st.groups[name]
+	if !ok {
+		return nil, nil, errs.ErrStreamGroupNotExist
+	}
+	return st, g, nil
+}
+
+// after 返回 ID 大于 id 的消息，最多 count 条
+func (st *stream) after(id streamID, count int64) []entry {
+	idx := sort.Search(len(st.entries), func(i int) bool {
+		return id.less(st.entries[i].id)
+	})
+	res := st.entries[idx:]
+	if count > 0 && int64(len(res)) > count {
+		res = res[:count]
+	}
+	return res
+}
+
+func (st *stream) find(id streamID) (entry, bool) {
+	idx := sort.Search(len(st.entries), func(i int) bool {
+		return !st.entries[i].id.less(id)
+	})
+	if idx < len(st.entries) && st.entries[idx].id == id {
+		return st.entries[idx], true
+	}
+	return entry{}, false
+}
+
+func (e entry) message() ecache.StreamMessage {
+	values := make(map[string]string, len(e.values))
+	for k, v := range e.values {
+		values[k] = v
+	}
+	return ecache.StreamMessage{ID: e.id.String(), Values: values}
+}
+
+// streamID 和 Redis 一样由毫秒时间戳和序号组成
+type streamID struct {
+	ms  uint64
+	seq uint64
+}
+
+// parseID 解析 "<ms>-<seq>" 或者 "<ms>" 格式的 ID
+func parseID(id string) (streamID, error) {
+	msStr, seqStr, hasSeq := strings.Cut(id, "-")
+	ms, err := strconv.ParseUint(msStr, 10, 64)
+	if err != nil {
+		return streamID{}, fmt.Errorf("%w: %s", errInvalidID, id)
+	}
+	var seq uint64
+	if hasSeq {
+		seq, err = strconv.ParseUint(seqStr, 10, 64)
+		if err != nil {
+			return streamID{}, fmt.Errorf("%w: %s", errInvalidID, id)
+		}
+	}
+	return streamID{ms: ms, seq: seq}, nil
+}
+
+// next 生成下一个 ID，时钟回拨的时候沿用上一个时间戳
+func (id streamID) next(ms uint64) streamID {
+	if ms > id.ms {
+		return streamID{ms: ms}
+	}
+	return streamID{ms: id.ms, seq: id.seq + 1}
+}
+
+func (id streamID) less(other streamID) bool {
+	if id.ms != other.ms {
+		return id.ms < other.ms
+	}
+	return id.seq < other.seq
+}
+
+func (id streamID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
@@ -0,0 +1,293 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_XAdd(t *testing.T) {
+	ctx := context.Background()
+	s := NewStream()
+	now := time.UnixMilli(1000)
+	s.now = func() time.Time {
+		return now
+	}
+
+	id, err := s.XAdd(ctx, "orders", "*", map[string]any{"id": 1})
+	require.NoError(t, err)
+	assert.Equal(t, "1000-0", id)
+	// 同一毫秒内
+	id, err = s.XAdd(ctx, "orders", "", map[string]any{"id": 2})
+	require.NoError(t, err)
+	assert.Equal(t, "1000-1", id)
+	// 时钟回拨
+	now = time.UnixMilli(900)
+	id, err = s.XAdd(ctx, "orders", "*", map[string]any{"id": 3})
+	require.NoError(t, err)
+	assert.Equal(t, "1000-2", id)
+
+	id, err = s.XAdd(ctx, "orders", "2000", map[string]any{"id": 4})
+	require.NoError(t, err)
+	assert.Equal(t, "2000-0", id)
+	_, err = s.XAdd(ctx, "orders", "2000-0", map[string]any{"id": 5})
+	assert.Equal(t, errSmallerID, err)
+	_, err = s.XAdd(ctx, "orders", "abc", map[string]any{"id": 5})
+	assert.True(t, errors.Is(err, errInvalidID))
+	_, err = s.XAdd(ctx, "orders", "*", nil)
+	assert.Equal(t, errNoValues, err)
+	_, err = s.XAdd(ctx, "empty", "0-0", map[string]any{"id": 1})
+	assert.Equal(t, errSmallerID, err)
+
+	msgs, err := s.XRead(ctx, "orders", "0", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.StreamMessage{
+		{ID: "1000-0", Values: map[string]string{"id": "1"}},
+		{ID: "1000-1", Values: map[string]string{"id": "2"}},
+		{ID: "1000-2", Values: map[string]string{"id": "3"}},
+		{ID: "2000-0", Values: map[string]string{"id": "4"}},
+	}, msgs)
+}
+
+func TestStream_XRead(t *testing.T) {
+	ctx := context.Background()
+	s := NewStream()
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		_, err := s.XAdd(ctx, "orders", id, map[string]any{"id": id})
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name  string
+		key   string
+		id    string
+		count int64
+
+		wantIDs []string
+		wantErr error
+	}{
+		{
+			name:    "from start",
+			key:     "orders",
+			id:      "0-0",
+			wantIDs: []string{"1-0", "2-0", "3-0"},
+		},
+		{
+			name:    "after id",
+			key:     "orders",
+			id:      "1-0",
+			wantIDs: []string{"2-0", "3-0"},
+		},
+		{
+			name:    "count",
+			key:     "orders",
+			id:      "0",
+			count:   2,
+			wantIDs: []string{"1-0", "2-0"},
+		},
+		{
+			name:    "no new message",
+			key:     "orders",
+			id:      "$",
+			wantIDs: []string{},
+		},
+		{
+			name:    "stream not exist",
+			key:     "users",
+			id:      "0",
+			wantIDs: []string{},
+		},
+		{
+			name:    "invalid id",
+			key:     "orders",
+			id:      "1-x",
+			wantErr: errInvalidID,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			msgs, err := s.XRead(ctx, tc.key, tc.id, tc.count, 0)
+			assert.True(t, errors.Is(err, tc.wantErr))
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantIDs, ids(msgs))
+		})
+	}
+}
+
+func TestStream_XReadBlock(t *testing.T) {
+	ctx := context.Background()
+	s := NewStream()
+
+	// 超时
+	msgs, err := s.XRead(ctx, "orders", "$", 0, time.Millisecond*10)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+
+	// ctx 结束
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	_, err = s.XRead(tctx, "orders", "$", 0, time.Minute)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// 被 XAdd 唤醒，并且其他 stream 的消息不会让它返回
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		_, _ = s.XAdd(ctx, "users", "*", map[string]any{"id": 1})
+		time.Sleep(time.Millisecond * 10)
+		_, _ = s.XAdd(ctx, "orders", "5-0", map[string]any{"id": 2})
+	}()
+	msgs, err = s.XRead(ctx, "orders", "$", 0, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.StreamMessage{{ID: "5-0", Values: map[string]string{"id": "2"}}}, msgs)
+}
+
+func TestStream_ConsumerGroup(t *testing.T) {
+	ctx := context.Background()
+	s := NewStream()
+	now := time.UnixMilli(1000)
+	s.now = func() time.Time {
+		return now
+	}
+
+	_, err := s.XReadGroup(ctx, "orders", "billing", "c1", 0, 0)
+	assert.Equal(t, errs.ErrStreamGroupNotExist, err)
+	_, err = s.XPending(ctx, "orders", "billing", 0)
+	assert.Equal(t, errs.ErrStreamGroupNotExist, err)
+
+	_, err = s.XAdd(ctx, "orders", "1-0", map[string]any{"id": 1})
+	require.NoError(t, err)
+	// 只消费新消息
+	require.NoError(t, s.XGroupCreate(ctx, "orders", "billing", "$"))
+	assert.Equal(t, errs.ErrStreamGroupExist, s.XGroupCreate(ctx, "orders", "billing", "0"))
+	// 从头开始消费
+	require.NoError(t, s.XGroupCreate(ctx, "orders", "audit", "0"))
+	assert.True(t, errors.Is(s.XGroupCreate(ctx, "orders", "invalid", "x"), errInvalidID))
+
+	for _, id := range []string{"2-0", "3-0", "4-0"} {
+		_, err = s.XAdd(ctx, "orders", id, map[string]any{"id": id})
+		require.NoError(t, err)
+	}
+
+	msgs, err := s.XReadGroup(ctx, "orders", "billing", "c1", 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2-0", "3-0"}, ids(msgs))
+	msgs, err = s.XReadGroup(ctx, "orders", "billing", "c2", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4-0"}, ids(msgs))
+	// 没有新消息了
+	msgs, err = s.XReadGroup(ctx, "orders", "billing", "c2", 0, time.Millisecond*10)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+	// 不同的消费者组互不影响
+	msgs, err = s.XReadGroup(ctx, "orders", "audit", "c1", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1-0", "2-0", "3-0", "4-0"}, ids(msgs))
+
+	cnt, err := s.XAck(ctx, "orders", "billing", "2-0", "2-0", "9-0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+	cnt, err = s.XAck(ctx, "orders", "unknown", "3-0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+	_, err = s.XAck(ctx, "orders", "billing", "x")
+	assert.True(t, errors.Is(err, errInvalidID))
+
+	now = now.Add(time.Second)
+	pending, err := s.XPending(ctx, "orders", "billing", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.PendingMessage{
+		{ID: "3-0", Consumer: "c1", Idle: time.Second, RetryCount: 1},
+		{ID: "4-0", Consumer: "c2", Idle: time.Second, RetryCount: 1},
+	}, pending)
+	pending, err = s.XPending(ctx, "orders", "billing", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3-0"}, []string{pending[0].ID})
+
+	// c1 崩溃了，c2 认领它的消息，空闲时间不够的不会被认领
+	msgs, err = s.XClaim(ctx, "orders", "billing", "c2", time.Minute, "3-0")
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+	msgs, err = s.XClaim(ctx, "orders", "billing", "c2", time.Second, "3-0", "9-0")
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.StreamMessage{{ID: "3-0", Values: map[string]string{"id": "3-0"}}}, msgs)
+	pending, err = s.XPending(ctx, "orders", "billing", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.PendingMessage{
+		{ID: "3-0", Consumer: "c2", Idle: 0, RetryCount: 2},
+		{ID: "4-0", Consumer: "c2", Idle: time.Second, RetryCount: 1},
+	}, pending)
+	_, err = s.XClaim(ctx, "orders", "billing", "c2", 0, "x")
+	assert.True(t, errors.Is(err, errInvalidID))
+	_, err = s.XClaim(ctx, "orders", "unknown", "c2", 0, "3-0")
+	assert.Equal(t, errs.ErrStreamGroupNotExist, err)
+
+	// 被删除的消息会从 pending 列表中移除
+	cnt, err = s.XTrim(ctx, "orders", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), cnt)
+	msgs, err = s.XClaim(ctx, "orders", "billing", "c1", 0, "3-0", "4-0")
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+	pending, err = s.XPending(ctx, "orders", "billing", 0)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestStream_XTrim(t *testing.T) {
+	ctx := context.Background()
+	s := NewStream()
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		_, err := s.XAdd(ctx, "orders", id, map[string]any{"id": id})
+		require.NoError(t, err)
+	}
+
+	cnt, err := s.XTrim(ctx, "users", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+	cnt, err = s.XTrim(ctx, "orders", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+	cnt, err = s.XTrim(ctx, "orders", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+	msgs, err := s.XRead(ctx, "orders", "0", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3-0"}, ids(msgs))
+
+	// 删除之后 ID 依旧不能变小
+	_, err = s.XAdd(ctx, "orders", "2-0", map[string]any{"id": 2})
+	assert.Equal(t, errSmallerID, err)
+	cnt, err = s.XTrim(ctx, "orders", -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+}
+
+func ids(msgs []ecache.StreamMessage) []string {
+	res := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		res = append(res, msg.ID)
+	}
+	return res
+}
@@ -56,6 +56,63 @@ func (m *MockCache) EXPECT() *MockCacheMockRecorder {
 	return m.recorder
 }
 
+// BLMove mocks base method.
+func (m *MockCache) BLMove(ctx context.Context, source, destination string, srcPos, destPos ListDirection, timeout time.Duration) Value {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BLMove", ctx, source, destination, srcPos, destPos, timeout)
+	ret0, _ := ret[0].(Value)
+	return ret0
+}
+
+// BLMove indicates an expected call of BLMove.
+func (mr *MockCacheMockRecorder) BLMove(ctx, source, destination, srcPos, destPos, timeout interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BLMove", reflect.TypeOf((*MockCache)(nil).BLMove), ctx, source, destination, srcPos, destPos, timeout)
+}
+
+// BLPop mocks base method.
+func (m *MockCache) BLPop(ctx context.Context, key string, timeout time.Duration) Value {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BLPop", ctx, key, timeout)
+	ret0, _ := ret[0].(Value)
+	return ret0
+}
+
+// BLPop indicates an expected call of BLPop.
+func (mr *MockCacheMockRecorder) BLPop(ctx, key, timeout interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BLPop", reflect.TypeOf((*MockCache)(nil).BLPop), ctx, key, timeout)
+}
+
+// BRPop mocks base method.
+func (m *MockCache) BRPop(ctx context.Context, key string, timeout time.Duration) Value {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BRPop", ctx, key, timeout)
+	ret0, _ := ret[0].(Value)
+	return ret0
+}
+
+// BRPop indicates an expected call of BRPop.
+func (mr *MockCacheMockRecorder) BRPop(ctx, key, timeout interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BRPop", reflect.TypeOf((*MockCache)(nil).BRPop), ctx, key, timeout)
+}
+
+// CompareAndSwap mocks base method.
+func (m *MockCache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompareAndSwap", ctx, key, old, new, expiration)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompareAndSwap indicates an expected call of CompareAndSwap.
+func (mr *MockCacheMockRecorder) CompareAndSwap(ctx, key, old, new, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareAndSwap", reflect.TypeOf((*MockCache)(nil).CompareAndSwap), ctx, key, old, new, expiration)
+}
+
 // DecrBy mocks base method.
 func (m *MockCache) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
 	m.ctrl.T.Helper()
@@ -149,6 +206,20 @@ func (mr *MockCacheMockRecorder) IncrByFloat(ctx, key, value interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrByFloat", reflect.TypeOf((*MockCache)(nil).IncrByFloat), ctx, key, value)
 }
 
+// LMove mocks base method.
+func (m *MockCache) LMove(ctx context.Context, source, destination string, srcPos, destPos ListDirection) Value {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LMove", ctx, source, destination, srcPos, destPos)
+	ret0, _ := ret[0].(Value)
+	return ret0
+}
+
+// LMove indicates an expected call of LMove.
+func (mr *MockCacheMockRecorder) LMove(ctx, source, destination, srcPos, destPos interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LMove", reflect.TypeOf((*MockCache)(nil).LMove), ctx, source, destination, srcPos, destPos)
+}
+
 // LPop mocks base method.
 func (m *MockCache) LPop(ctx context.Context, key string) Value {
 	m.ctrl.T.Helper()
@@ -183,6 +254,35 @@ func (mr *MockCacheMockRecorder) LPush(ctx, key interface{}, val ...interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LPush", reflect.TypeOf((*MockCache)(nil).LPush), varargs...)
 }
 
+// LRem mocks base method.
+func (m *MockCache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LRem", ctx, key, count, val)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LRem indicates an expected call of LRem.
+func (mr *MockCacheMockRecorder) LRem(ctx, key, count, val interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LRem", reflect.TypeOf((*MockCache)(nil).LRem), ctx, key, count, val)
+}
+
+// RPopLPush mocks base method.
+func (m *MockCache) RPopLPush(ctx context.Context, source, destination string) Value {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RPopLPush", ctx, source, destination)
+	ret0, _ := ret[0].(Value)
+	return ret0
+}
+
+// RPopLPush indicates an expected call of RPopLPush.
+func (mr *MockCacheMockRecorder) RPopLPush(ctx, source, destination interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RPopLPush", reflect.TypeOf((*MockCache)(nil).RPopLPush), ctx, source, destination)
+}
+
 // SAdd mocks base method.
 func (m *MockCache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
 	m.ctrl.T.Helper()
@@ -251,3 +351,18 @@ func (mr *MockCacheMockRecorder) SetNX(ctx, key, val, expiration interface{}) *g
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNX", reflect.TypeOf((*MockCache)(nil).SetNX), ctx, key, val, expiration)
 }
+
+// SetXX mocks base method.
+func (m *MockCache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetXX", ctx, key, val, expiration)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetXX indicates an expected call of SetXX.
+func (mr *MockCacheMockRecorder) SetXX(ctx, key, val, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetXX", reflect.TypeOf((*MockCache)(nil).SetXX), ctx, key, val, expiration)
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/ecodeclub/ecache/memcached (interfaces: Client)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	memcache "github.com/bradfitz/gomemcache/memcache"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMemcachedClient is a mock of Client interface.
+type MockMemcachedClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockMemcachedClientMockRecorder
+}
+
+// MockMemcachedClientMockRecorder is the mock recorder for MockMemcachedClient.
+type MockMemcachedClientMockRecorder struct {
+	mock *MockMemcachedClient
+}
+
+// NewMockMemcachedClient creates a new mock instance.
+func NewMockMemcachedClient(ctrl *gomock.Controller) *MockMemcachedClient {
+	mock := &MockMemcachedClient{ctrl: ctrl}
+	mock.recorder = &MockMemcachedClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMemcachedClient) EXPECT() *MockMemcachedClientMockRecorder {
+	return m.recorder
+}
+
+// Add mocks base method.
+func (m *MockMemcachedClient) Add(arg0 *memcache.Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Add", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Add indicates an expected call of Add.
+func (mr *MockMemcachedClientMockRecorder) Add(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockMemcachedClient)(nil).Add), arg0)
+}
+
+// CompareAndSwap mocks base method.
+func (m *MockMemcachedClient) CompareAndSwap(arg0 *memcache.Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompareAndSwap", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompareAndSwap indicates an expected call of CompareAndSwap.
+func (mr *MockMemcachedClientMockRecorder) CompareAndSwap(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareAndSwap", reflect.TypeOf((*MockMemcachedClient)(nil).CompareAndSwap), arg0)
+}
+
+// Decrement mocks base method.
+func (m *MockMemcachedClient) Decrement(arg0 string, arg1 uint64) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decrement", arg0, arg1)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Decrement indicates an expected call of Decrement.
+func (mr *MockMemcachedClientMockRecorder) Decrement(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decrement", reflect.TypeOf((*MockMemcachedClient)(nil).Decrement), arg0, arg1)
+}
+
+// Delete mocks base method.
+func (m *MockMemcachedClient) Delete(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockMemcachedClientMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockMemcachedClient)(nil).Delete), arg0)
+}
+
+// Get mocks base method.
+func (m *MockMemcachedClient) Get(arg0 string) (*memcache.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0)
+	ret0, _ := ret[0].(*memcache.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockMemcachedClientMockRecorder) Get(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockMemcachedClient)(nil).Get), arg0)
+}
+
+// Increment mocks base method.
+func (m *MockMemcachedClient) Increment(arg0 string, arg1 uint64) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Increment", arg0, arg1)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Increment indicates an expected call of Increment.
+func (mr *MockMemcachedClientMockRecorder) Increment(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Increment", reflect.TypeOf((*MockMemcachedClient)(nil).Increment), arg0, arg1)
+}
+
+// Replace mocks base method.
+func (m *MockMemcachedClient) Replace(arg0 *memcache.Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockMemcachedClientMockRecorder) Replace(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockMemcachedClient)(nil).Replace), arg0)
+}
+
+// Set mocks base method.
+func (m *MockMemcachedClient) Set(arg0 *memcache.Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockMemcachedClientMockRecorder) Set(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockMemcachedClient)(nil).Set), arg0)
+}
@@ -17,64 +17,109 @@ package ecache
 import (
 	"context"
 	"time"
+
+	"github.com/ecodeclub/ecache/internal/hashtag"
 )
 
 type NamespaceCache struct {
 	C         Cache
 	Namespace string
+	// HashTag 为 true 的时候 Namespace 会被当作 Redis Cluster 的 hash tag，
+	// 例如 Namespace 为 app1: 的时候 key 会变成 {app1:}key。
+	// 这样同一个命名空间下的 key 都在同一个 slot 上，LMove、RPopLPush 之类的多 key 命令在集群上也可以执行，
+	// 代价是整个命名空间的数据都在同一个节点上
+	HashTag bool
+}
+
+func (c *NamespaceCache) key(key string) string {
+	return hashtag.Prefix(c.Namespace, c.HashTag) + key
 }
 
 func (c *NamespaceCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
-	return c.C.Set(ctx, c.Namespace+key, val, expiration)
+	return c.C.Set(ctx, c.key(key), val, expiration)
 }
 
 func (c *NamespaceCache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
-	return c.C.SetNX(ctx, c.Namespace+key, val, expiration)
+	return c.C.SetNX(ctx, c.key(key), val, expiration)
+}
+
+func (c *NamespaceCache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	return c.C.SetXX(ctx, c.key(key), val, expiration)
+}
+
+func (c *NamespaceCache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	return c.C.CompareAndSwap(ctx, c.key(key), old, new, expiration)
 }
 
 func (c *NamespaceCache) GetSet(ctx context.Context, key string, val string) Value {
-	return c.C.GetSet(ctx, c.Namespace+key, val)
+	return c.C.GetSet(ctx, c.key(key), val)
 }
 
 func (c *NamespaceCache) Delete(ctx context.Context, key ...string) (int64, error) {
 	if len(key) == 1 {
-		return c.C.Delete(ctx, c.Namespace+key[0])
+		return c.C.Delete(ctx, c.key(key[0]))
 	}
 	newkey := make([]string, len(key))
 	for i, v := range key {
-		newkey[i] = c.Namespace + v
+		newkey[i] = c.key(v)
 	}
 	return c.C.Delete(ctx, newkey...)
 }
 
 func (c *NamespaceCache) LPush(ctx context.Context, key string, val ...any) (int64, error) {
-	return c.C.LPush(ctx, c.Namespace+key, val...)
+	return c.C.LPush(ctx, c.key(key), val...)
 }
 
 func (c *NamespaceCache) LPop(ctx context.Context, key string) Value {
-	return c.C.LPop(ctx, c.Namespace+key)
+	return c.C.LPop(ctx, c.key(key))
+}
+
+func (c *NamespaceCache) BLPop(ctx context.Context, key string, timeout time.Duration) Value {
+	return c.C.BLPop(ctx, c.key(key), timeout)
+}
+
+func (c *NamespaceCache) BRPop(ctx context.Context, key string, timeout time.Duration) Value {
+	return c.C.BRPop(ctx, c.key(key), timeout)
+}
+
+func (c *NamespaceCache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ListDirection, timeout time.Duration) Value {
+	return c.C.BLMove(ctx, c.key(source), c.key(destination), srcPos, destPos, timeout)
+}
+
+func (c *NamespaceCache) LMove(ctx context.Context, source, destination string,
+	srcPos, destPos ListDirection) Value {
+	return c.C.LMove(ctx, c.key(source), c.key(destination), srcPos, destPos)
+}
+
+func (c *NamespaceCache) RPopLPush(ctx context.Context, source, destination string) Value {
+	return c.C.RPopLPush(ctx, c.key(source), c.key(destination))
+}
+
+func (c *NamespaceCache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	return c.C.LRem(ctx, c.key(key), count, val)
 }
 
 func (c *NamespaceCache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
-	return c.C.SAdd(ctx, c.Namespace+key, members...)
+	return c.C.SAdd(ctx, c.key(key), members...)
 }
 
 func (c *NamespaceCache) SRem(ctx context.Context, key string, members ...any) (int64, error) {
-	return c.C.SRem(ctx, c.Namespace+key, members...)
+	return c.C.SRem(ctx, c.key(key), members...)
 }
 
 func (c *NamespaceCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
-	return c.C.IncrBy(ctx, c.Namespace+key, value)
+	return c.C.IncrBy(ctx, c.key(key), value)
 }
 
 func (c *NamespaceCache) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
-	return c.C.DecrBy(ctx, c.Namespace+key, value)
+	return c.C.DecrBy(ctx, c.key(key), value)
 }
 
 func (c *NamespaceCache) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
-	return c.C.IncrByFloat(ctx, c.Namespace+key, value)
+	return c.C.IncrByFloat(ctx, c.key(key), value)
 }
 
 func (c *NamespaceCache) Get(ctx context.Context, key string) Value {
-	return c.C.Get(ctx, c.Namespace+key)
+	return c.C.Get(ctx, c.key(key))
 }
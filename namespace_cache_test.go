@@ -654,3 +654,441 @@ func TestNamespaceCache_Get(t *testing.T) {
 		})
 	}
 }
+
+func TestNamespaceCache_SetXX(t *testing.T) {
+	type fields struct {
+		C         *MockCache
+		Namespace string
+	}
+	type args struct {
+		ctx        context.Context
+		key        string
+		val        any
+		expiration time.Duration
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "test_setxx",
+			fields: fields{
+				C:         NewMockCache(gomock.NewController(t)),
+				Namespace: "app1:",
+			},
+			args: args{
+				ctx:        context.Background(),
+				key:        "key",
+				val:        "val",
+				expiration: time.Second,
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NamespaceCache{
+				C:         tt.fields.C,
+				Namespace: tt.fields.Namespace,
+			}
+			tt.fields.C.EXPECT().SetXX(tt.args.ctx, tt.fields.Namespace+tt.args.key, tt.args.val, tt.args.expiration).Return(tt.want, nil)
+			got, err := c.SetXX(tt.args.ctx, tt.args.key, tt.args.val, tt.args.expiration)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetXX() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("SetXX() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceCache_CompareAndSwap(t *testing.T) {
+	type fields struct {
+		C         *MockCache
+		Namespace string
+	}
+	type args struct {
+		ctx        context.Context
+		key        string
+		old        any
+		new        any
+		expiration time.Duration
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "test_compare_and_swap",
+			fields: fields{
+				C:         NewMockCache(gomock.NewController(t)),
+				Namespace: "app1:",
+			},
+			args: args{
+				ctx:        context.Background(),
+				key:        "key",
+				old:        "old",
+				new:        "new",
+				expiration: time.Second,
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NamespaceCache{
+				C:         tt.fields.C,
+				Namespace: tt.fields.Namespace,
+			}
+			tt.fields.C.EXPECT().CompareAndSwap(tt.args.ctx, tt.fields.Namespace+tt.args.key, tt.args.old, tt.args.new, tt.args.expiration).Return(tt.want, nil)
+			got, err := c.CompareAndSwap(tt.args.ctx, tt.args.key, tt.args.old, tt.args.new, tt.args.expiration)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompareAndSwap() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CompareAndSwap() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceCache_BLPop(t *testing.T) {
+	type fields struct {
+		C         *MockCache
+		Namespace string
+	}
+	type args struct {
+		ctx     context.Context
+		key     string
+		timeout time.Duration
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   Value
+	}{
+		{
+			name: "test_blpop",
+			fields: fields{
+				C:         NewMockCache(gomock.NewController(t)),
+				Namespace: "app1:",
+			},
+			args: args{
+				ctx:     context.Background(),
+				key:     "key",
+				timeout: time.Second,
+			},
+			want: Value{
+				AnyValue: ekit.AnyValue{
+					Val: "val",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NamespaceCache{
+				C:         tt.fields.C,
+				Namespace: tt.fields.Namespace,
+			}
+			tt.fields.C.EXPECT().BLPop(tt.args.ctx, tt.fields.Namespace+tt.args.key, tt.args.timeout).Return(tt.want)
+			if got := c.BLPop(tt.args.ctx, tt.args.key, tt.args.timeout); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BLPop() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceCache_BRPop(t *testing.T) {
+	type fields struct {
+		C         *MockCache
+		Namespace string
+	}
+	type args struct {
+		ctx     context.Context
+		key     string
+		timeout time.Duration
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   Value
+	}{
+		{
+			name: "test_brpop",
+			fields: fields{
+				C:         NewMockCache(gomock.NewController(t)),
+				Namespace: "app1:",
+			},
+			args: args{
+				ctx:     context.Background(),
+				key:     "key",
+				timeout: time.Second,
+			},
+			want: Value{
+				AnyValue: ekit.AnyValue{
+					Val: "val",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NamespaceCache{
+				C:         tt.fields.C,
+				Namespace: tt.fields.Namespace,
+			}
+			tt.fields.C.EXPECT().BRPop(tt.args.ctx, tt.fields.Namespace+tt.args.key, tt.args.timeout).Return(tt.want)
+			if got := c.BRPop(tt.args.ctx, tt.args.key, tt.args.timeout); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BRPop() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceCache_BLMove(t *testing.T) {
+	type fields struct {
+		C         *MockCache
+		Namespace string
+	}
+	type args struct {
+		ctx         context.Context
+		source      string
+		destination string
+		srcPos      ListDirection
+		destPos     ListDirection
+		timeout     time.Duration
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   Value
+	}{
+		{
+			name: "test_blmove",
+			fields: fields{
+				C:         NewMockCache(gomock.NewController(t)),
+				Namespace: "app1:",
+			},
+			args: args{
+				ctx:         context.Background(),
+				source:      "jobs",
+				destination: "processing",
+				srcPos:      ListRight,
+				destPos:     ListLeft,
+				timeout:     time.Second,
+			},
+			want: Value{
+				AnyValue: ekit.AnyValue{
+					Val: "val",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NamespaceCache{
+				C:         tt.fields.C,
+				Namespace: tt.fields.Namespace,
+			}
+			tt.fields.C.EXPECT().BLMove(tt.args.ctx, tt.fields.Namespace+tt.args.source,
+				tt.fields.Namespace+tt.args.destination, tt.args.srcPos, tt.args.destPos, tt.args.timeout).Return(tt.want)
+			if got := c.BLMove(tt.args.ctx, tt.args.source, tt.args.destination,
+				tt.args.srcPos, tt.args.destPos, tt.args.timeout); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BLMove() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceCache_LMove(t *testing.T) {
+	type fields struct {
+		C         *MockCache
+		Namespace string
+	}
+	type args struct {
+		ctx         context.Context
+		source      string
+		destination string
+		srcPos      ListDirection
+		destPos     ListDirection
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   Value
+	}{
+		{
+			name: "test_lmove",
+			fields: fields{
+				C:         NewMockCache(gomock.NewController(t)),
+				Namespace: "app1:",
+			},
+			args: args{
+				ctx:         context.Background(),
+				source:      "processing",
+				destination: "jobs",
+				srcPos:      ListLeft,
+				destPos:     ListRight,
+			},
+			want: Value{
+				AnyValue: ekit.AnyValue{
+					Val: "val",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NamespaceCache{
+				C:         tt.fields.C,
+				Namespace: tt.fields.Namespace,
+			}
+			tt.fields.C.EXPECT().LMove(tt.args.ctx, tt.fields.Namespace+tt.args.source,
+				tt.fields.Namespace+tt.args.destination, tt.args.srcPos, tt.args.destPos).Return(tt.want)
+			if got := c.LMove(tt.args.ctx, tt.args.source, tt.args.destination,
+				tt.args.srcPos, tt.args.destPos); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LMove() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceCache_RPopLPush(t *testing.T) {
+	type fields struct {
+		C         *MockCache
+		Namespace string
+	}
+	type args struct {
+		ctx         context.Context
+		source      string
+		destination string
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   Value
+	}{
+		{
+			name: "test_rpoplpush",
+			fields: fields{
+				C:         NewMockCache(gomock.NewController(t)),
+				Namespace: "app1:",
+			},
+			args: args{
+				ctx:         context.Background(),
+				source:      "jobs",
+				destination: "processing",
+			},
+			want: Value{
+				AnyValue: ekit.AnyValue{
+					Val: "val",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NamespaceCache{
+				C:         tt.fields.C,
+				Namespace: tt.fields.Namespace,
+			}
+			tt.fields.C.EXPECT().RPopLPush(tt.args.ctx, tt.fields.Namespace+tt.args.source,
+				tt.fields.Namespace+tt.args.destination).Return(tt.want)
+			if got := c.RPopLPush(tt.args.ctx, tt.args.source, tt.args.destination); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RPopLPush() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceCache_LRem(t *testing.T) {
+	type fields struct {
+		C         *MockCache
+		Namespace string
+	}
+	type args struct {
+		ctx   context.Context
+		key   string
+		count int64
+		val   any
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "test_lrem",
+			fields: fields{
+				C:         NewMockCache(gomock.NewController(t)),
+				Namespace: "app1:",
+			},
+			args: args{
+				ctx:   context.Background(),
+				key:   "processing",
+				count: -1,
+				val:   "job1",
+			},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NamespaceCache{
+				C:         tt.fields.C,
+				Namespace: tt.fields.Namespace,
+			}
+			tt.fields.C.EXPECT().LRem(tt.args.ctx, tt.fields.Namespace+tt.args.key,
+				tt.args.count, tt.args.val).Return(tt.want, nil)
+			got, err := c.LRem(tt.args.ctx, tt.args.key, tt.args.count, tt.args.val)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LRem() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("LRem() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceCache_HashTag(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockCache(gomock.NewController(t))
+	c := &NamespaceCache{
+		C:         mock,
+		Namespace: "app1:",
+		HashTag:   true,
+	}
+
+	mock.EXPECT().Delete(ctx, "{app1:}key1", "{app1:}key2").Return(int64(2), nil)
+	got, err := c.Delete(ctx, "key1", "key2")
+	if err != nil || got != 2 {
+		t.Errorf("Delete() got = %v, err = %v", got, err)
+	}
+
+	want := Value{AnyValue: ekit.AnyValue{Val: "job1"}}
+	mock.EXPECT().LMove(ctx, "{app1:}queue", "{app1:}processing", ListRight, ListLeft).Return(want)
+	if res := c.LMove(ctx, "queue", "processing", ListRight, ListLeft); !reflect.DeepEqual(res, want) {
+		t.Errorf("LMove() = %v, want %v", res, want)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecache
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+)
+
+// Pipeliner 支持批量执行的缓存
+type Pipeliner interface {
+	// Pipeline 创建一个新的 Pipeline，Pipeline 不是线程安全的
+	Pipeline() Pipeline
+}
+
+// Pipeline 先把操作排队，再通过 Exec 一次性执行。
+// 对于 Redis 来说所有的操作只需要一次网络往返，对于本地缓存来说只需要加一次锁。
+// 每个方法的含义都和 Cache 中的同名方法一样，结果通过返回的 Future 获取
+type Pipeline interface {
+	Set(key string, val any, expiration time.Duration) *Future[struct{}]
+	SetNX(key string, val any, expiration time.Duration) *Future[bool]
+	Get(key string) *Future[Value]
+	GetSet(key string, val string) *Future[Value]
+	Delete(key ...string) *Future[int64]
+	LPush(key string, val ...any) *Future[int64]
+	LPop(key string) *Future[Value]
+	SAdd(key string, members ...any) *Future[int64]
+	SRem(key string, members ...any) *Future[int64]
+	IncrBy(key string, value int64) *Future[int64]
+	DecrBy(key string, value int64) *Future[int64]
+	IncrByFloat(key string, value float64) *Future[float64]
+	// Exec 执行所有排队的操作，执行之后队列会被清空，Pipeline 可以继续使用。
+	// 返回第一个失败的操作的错误，但是 key 不存在不算失败。
+	// 即便返回了错误，其余操作的结果依旧可以通过各自的 Future 获取
+	Exec(ctx context.Context) error
+}
+
+// Future 代表 Pipeline 中一个操作的结果。
+// 在 Pipeline.Exec 之前，或者 Exec 中途失败导致该操作没有执行，都会返回 errs.ErrPipelineNotExecuted
+type Future[T any] struct {
+	val  T
+	err  error
+	done bool
+}
+
+// Resolve 设置操作的结果，由 Pipeline 的实现者在执行之后调用
+func (f *Future[T]) Resolve(val T, err error) {
+	f.val, f.err, f.done = val, err, true
+}
+
+func (f *Future[T]) Result() (T, error) {
+	if !f.done {
+		var t T
+		return t, errs.ErrPipelineNotExecuted
+	}
+	return f.val, f.err
+}
+
+func (f *Future[T]) Val() T {
+	val, _ := f.Result()
+	return val
+}
+
+func (f *Future[T]) Err() error {
+	_, err := f.Result()
+	return err
+}
@@ -0,0 +1,38 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuture(t *testing.T) {
+	f := &Future[int64]{}
+	_, err := f.Result()
+	assert.Equal(t, errs.ErrPipelineNotExecuted, err)
+	assert.Equal(t, int64(0), f.Val())
+
+	f.Resolve(12, nil)
+	val, err := f.Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), val)
+
+	f.Resolve(0, errors.New("mock error"))
+	assert.Equal(t, errors.New("mock error"), f.Err())
+}
@@ -0,0 +1,47 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecache
+
+import "context"
+
+// PubSub 发布订阅，一般用于广播缓存失效之类的事件
+type PubSub interface {
+	// Publish 发布消息，返回收到消息的订阅数量。
+	// 同时通过频道和模式匹配上的订阅会收到两次，也会被计算两次
+	Publish(ctx context.Context, channel string, msg any) (int64, error)
+	// Subscribe 订阅一个或者多个频道
+	Subscribe(ctx context.Context, channels ...string) (Subscription, error)
+	// PSubscribe 按照模式订阅，例如 user.* 可以收到 user.created 和 user.deleted 的消息。
+	// 支持 *、? 和 [...] 三种通配符
+	PSubscribe(ctx context.Context, patterns ...string) (Subscription, error)
+}
+
+// Subscription 一个订阅，可以通过 Next 逐条读取消息
+type Subscription interface {
+	// Next 阻塞直到收到下一条消息，或者 ctx 结束。
+	// 订阅关闭之后返回 ErrSubscriptionClosed
+	Next(ctx context.Context) (Message, error)
+	// Close 取消订阅并且释放资源，可以重复调用
+	Close() error
+}
+
+// Message 通过订阅收到的消息
+type Message struct {
+	// Channel 消息发布的频道
+	Channel string
+	// Pattern 通过 PSubscribe 收到的消息才有，是匹配上的模式
+	Pattern string
+	Payload string
+}
@@ -0,0 +1,233 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+var (
+	// ErrQueueEmpty 在超时之前队列里面一直没有元素
+	ErrQueueEmpty = errors.New("ecache: 队列为空")
+	// ErrItemNotProcessing 元素不在处理列表中，一般是因为处理超时，已经被重新放回队列
+	ErrItemNotProcessing = errors.New("ecache: 元素不在处理列表中")
+	// ErrInvalidTimeout Pop 的超时时间必须大于 0 并且小于 visibilityTimeout，
+	// 否则 Worker 还阻塞在 Pop 上的时候心跳就已经过期了
+	ErrInvalidTimeout = errors.New("ecache: Pop 的超时时间必须大于 0 并且小于 visibilityTimeout")
+)
+
+// Queue 基于列表的可靠队列，保证每个元素至少被处理一次（at-least-once）。
+// 元素从队列的头部写入，从尾部取出，取出的同时原子地放入 Worker 自己的处理列表，
+// 处理完成之后调用 Ack 从处理列表中删除。
+// 每个 Worker 都有一个过期时间为 visibilityTimeout 的心跳，心跳过期的 Worker 被认为已经宕机，
+// Requeue 会把它处理列表中的元素重新放回队列。
+// 所有的状态都保存在 ecache.Cache 里面，所以 Redis 和本地缓存都可以使用
+type Queue struct {
+	cache             ecache.Cache
+	name              string
+	visibilityTimeout time.Duration
+}
+
+// NewQueue 创建一个名字为 name 的队列，name 也是保存元素的列表的 key
+func NewQueue(cache ecache.Cache, name string, opts ...option.Option[Queue]) *Queue {
+	res := &Queue{
+		cache:             cache,
+		name:              name,
+		visibilityTimeout: time.Second * 30,
+	}
+	option.Apply(res, opts...)
+	return res
+}
+
+// WithVisibilityTimeout 设置 Worker 心跳的过期时间，
+// Worker 超过这个时间没有调用 Pop 或者 Heartbeat，它正在处理的元素就会被重新放回队列
+func WithVisibilityTimeout(timeout time.Duration) option.Option[Queue] {
+	return func(q *Queue) {
+		q.visibilityTimeout = timeout
+	}
+}
+
+// Push 向队列写入元素，返回队列的长度
+func (q *Queue) Push(ctx context.Context, items ...string) (int64, error) {
+	vals := make([]any, 0, len(items))
+	for _, item := range items {
+		vals = append(vals, item)
+	}
+	return q.cache.LPush(ctx, q.name, vals...)
+}
+
+// Worker 创建并且注册一个 Worker，同一个 id 同时只应该被一个 Worker 使用。
+// Worker 重启之后使用相同的 id，可以在 Requeue 之前继续处理上一次没有确认的元素
+func (q *Queue) Worker(ctx context.Context, id string) (*Worker, error) {
+	w := q.newWorker(id)
+	if err := w.register(ctx); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Requeue 把心跳已经过期的 Worker 的处理列表中的元素放回队列的尾部，也就是下一个会被取出的位置，
+// 并且注销这些 Worker，返回放回队列的元素的数量。
+// 一般由一个 goroutine 按照 visibilityTimeout 的间隔定期调用
+func (q *Queue) Requeue(ctx context.Context) (int64, error) {
+	var cnt int64
+	// Cache 没有办法读取整个列表，所以通过把尾部的元素移动到头部来轮转 Worker 列表，
+	// 遇到已经检查过的 Worker 说明已经轮转了一圈
+	seen := make(map[string]struct{}, 8)
+	for {
+		val := q.cache.RPopLPush(ctx, q.workersKey(), q.workersKey())
+		if val.KeyNotFound() {
+			return cnt, nil
+		}
+		if val.Err != nil {
+			return cnt, val.Err
+		}
+		id, err := val.String()
+		if err != nil {
+			return cnt, err
+		}
+		if _, ok := seen[id]; ok {
+			return cnt, nil
+		}
+		seen[id] = struct{}{}
+
+		w := q.newWorker(id)
+		alive := q.cache.Get(ctx, w.heartbeat)
+		if alive.Err == nil {
+			continue
+		}
+		if !alive.KeyNotFound() {
+			return cnt, alive.Err
+		}
+		n, err := w.requeue(ctx)
+		cnt += n
+		if err != nil {
+			return cnt, err
+		}
+		if _, err = q.cache.LRem(ctx, q.workersKey(), 0, id); err != nil {
+			return cnt, err
+		}
+	}
+}
+
+func (q *Queue) newWorker(id string) *Worker {
+	return &Worker{
+		queue:      q,
+		id:         id,
+		processing: q.name + ":processing:" + id,
+		heartbeat:  q.name + ":heartbeat:" + id,
+	}
+}
+
+func (q *Queue) workersKey() string {
+	return q.name + ":workers"
+}
+
+// Worker 从队列中取出元素进行处理。Worker 不是线程安全的，每个 goroutine 都应该使用自己的 Worker
+type Worker struct {
+	queue      *Queue
+	id         string
+	processing string
+	heartbeat  string
+}
+
+// Pop 从队列中取出一个元素并且放入处理列表，队列为空的时候最多阻塞 timeout，超时返回 ErrQueueEmpty。
+// Pop 只在阻塞之前刷新一次心跳，所以 timeout 必须大于 0 并且小于 visibilityTimeout，否则返回 ErrInvalidTimeout。
+// 注意 Redis 的阻塞时间会被四舍五入到整秒，所以使用 Redis 的时候 visibilityTimeout 应该明显大于一秒
+func (w *Worker) Pop(ctx context.Context, timeout time.Duration) (string, error) {
+	if timeout <= 0 || timeout >= w.queue.visibilityTimeout {
+		return "", ErrInvalidTimeout
+	}
+	if err := w.Heartbeat(ctx); err != nil {
+		return "", err
+	}
+	val := w.queue.cache.BLMove(ctx, w.queue.name, w.processing, ecache.ListRight, ecache.ListLeft, timeout)
+	if val.KeyNotFound() {
+		return "", ErrQueueEmpty
+	}
+	if val.Err != nil {
+		return "", val.Err
+	}
+	return val.String()
+}
+
+// Ack 确认元素已经处理完毕，将它从处理列表中删除。
+// 返回 ErrItemNotProcessing 说明处理超时，元素已经被放回队列，可能会被重复处理
+func (w *Worker) Ack(ctx context.Context, item string) error {
+	// 处理列表的尾部是最早取出的元素，相同的元素优先确认最早的那个
+	n, err := w.queue.cache.LRem(ctx, w.processing, -1, item)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrItemNotProcessing
+	}
+	return nil
+}
+
+// Heartbeat 刷新心跳，处理单个元素的时间可能超过 visibilityTimeout 的时候需要定期调用。
+// 如果心跳已经过期，那么重新注册，之前没有确认的元素可能已经被放回队列
+func (w *Worker) Heartbeat(ctx context.Context) error {
+	ok, err := w.queue.cache.SetXX(ctx, w.heartbeat, w.id, w.queue.visibilityTimeout)
+	if err != nil || ok {
+		return err
+	}
+	return w.register(ctx)
+}
+
+// Close 把还没有确认的元素放回队列，并且注销 Worker
+func (w *Worker) Close(ctx context.Context) error {
+	if _, err := w.requeue(ctx); err != nil {
+		return err
+	}
+	if _, err := w.queue.cache.LRem(ctx, w.queue.workersKey(), 0, w.id); err != nil {
+		return err
+	}
+	_, err := w.queue.cache.Delete(ctx, w.heartbeat)
+	return err
+}
+
+// register 先写入心跳再加入 Worker 列表，避免 Requeue 把刚注册的 Worker 当作已经宕机。
+// 先删除再写入，保证 Worker 列表里面同一个 id 只出现一次
+func (w *Worker) register(ctx context.Context) error {
+	if err := w.queue.cache.Set(ctx, w.heartbeat, w.id, w.queue.visibilityTimeout); err != nil {
+		return err
+	}
+	if _, err := w.queue.cache.LRem(ctx, w.queue.workersKey(), 0, w.id); err != nil {
+		return err
+	}
+	_, err := w.queue.cache.LPush(ctx, w.queue.workersKey(), w.id)
+	return err
+}
+
+// requeue 把处理列表中的元素按照取出的先后顺序放回队列的尾部
+func (w *Worker) requeue(ctx context.Context) (int64, error) {
+	var cnt int64
+	for {
+		val := w.queue.cache.LMove(ctx, w.processing, w.queue.name, ecache.ListLeft, ecache.ListRight)
+		if val.KeyNotFound() {
+			return cnt, nil
+		}
+		if val.Err != nil {
+			return cnt, val.Err
+		}
+		cnt++
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rcache "github.com/ecodeclub/ecache/redis"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_e2e(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	require.NoError(t, rdb.Del(ctx, "e2e_jobs", "e2e_jobs:workers",
+		"e2e_jobs:processing:dead", "e2e_jobs:processing:alive").Err())
+	q := NewQueue(rcache.NewCache(rdb), "e2e_jobs", WithVisibilityTimeout(time.Second*2))
+
+	dead, err := q.Worker(ctx, "dead")
+	require.NoError(t, err)
+	_, err = q.Push(ctx, "a", "b")
+	require.NoError(t, err)
+	item, err := dead.Pop(ctx, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "a", item)
+
+	time.Sleep(time.Millisecond * 2500)
+	alive, err := q.Worker(ctx, "alive")
+	require.NoError(t, err)
+	n, err := q.Requeue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	for _, want := range []string{"a", "b"} {
+		item, err = alive.Pop(ctx, time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, want, item)
+		require.NoError(t, alive.Ack(ctx, item))
+	}
+	assert.Equal(t, ErrItemNotProcessing, dead.Ack(ctx, "a"))
+	require.NoError(t, alive.Close(ctx))
+}
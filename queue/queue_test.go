@@ -0,0 +1,200 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/ecodeclub/ecache/memory/priority"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func backends(t *testing.T) map[string]func() ecache.Cache {
+	return map[string]func() ecache.Cache{
+		"lru": func() ecache.Cache {
+			return lru.NewCache(100)
+		},
+		"priority": func() ecache.Cache {
+			c, err := priority.NewRBTreePriorityCache()
+			require.NoError(t, err)
+			return c
+		},
+	}
+}
+
+func TestQueue_PopAck(t *testing.T) {
+	for name, newCache := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q := NewQueue(newCache(), "jobs")
+			w, err := q.Worker(ctx, "w1")
+			require.NoError(t, err)
+
+			_, err = q.Push(ctx, "a", "b")
+			require.NoError(t, err)
+			// 先写入的先取出
+			item, err := w.Pop(ctx, time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, "a", item)
+			item, err = w.Pop(ctx, time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, "b", item)
+
+			_, err = w.Pop(ctx, time.Millisecond*10)
+			assert.Equal(t, ErrQueueEmpty, err)
+
+			require.NoError(t, w.Ack(ctx, "a"))
+			assert.Equal(t, ErrItemNotProcessing, w.Ack(ctx, "a"))
+			require.NoError(t, w.Ack(ctx, "b"))
+
+			// 所有的元素都确认了，没有需要放回队列的元素
+			n, err := q.Requeue(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), n)
+		})
+	}
+}
+
+func TestQueue_PopBlocking(t *testing.T) {
+	for name, newCache := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q := NewQueue(newCache(), "jobs")
+			w, err := q.Worker(ctx, "w1")
+			require.NoError(t, err)
+
+			go func() {
+				time.Sleep(time.Millisecond * 20)
+				_, _ = q.Push(ctx, "a")
+			}()
+			item, err := w.Pop(ctx, time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, "a", item)
+		})
+	}
+}
+
+// TestWorker_PopInvalidTimeout 阻塞的时间不能超过心跳的过期时间
+func TestWorker_PopInvalidTimeout(t *testing.T) {
+	for name, newCache := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q := NewQueue(newCache(), "jobs", WithVisibilityTimeout(time.Millisecond*50))
+			w, err := q.Worker(ctx, "worker")
+			require.NoError(t, err)
+			_, err = q.Push(ctx, "a")
+			require.NoError(t, err)
+			for _, timeout := range []time.Duration{0, -time.Second, time.Millisecond * 50, time.Second} {
+				_, err = w.Pop(ctx, timeout)
+				assert.Equal(t, ErrInvalidTimeout, err)
+			}
+			// 元素没有被取出
+			item, err := w.Pop(ctx, time.Millisecond*10)
+			require.NoError(t, err)
+			assert.Equal(t, "a", item)
+		})
+	}
+}
+
+func TestQueue_Requeue(t *testing.T) {
+	for name, newCache := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q := NewQueue(newCache(), "jobs", WithVisibilityTimeout(time.Millisecond*50))
+			dead, err := q.Worker(ctx, "dead")
+			require.NoError(t, err)
+			_, err = q.Push(ctx, "a", "b", "c")
+			require.NoError(t, err)
+			for _, want := range []string{"a", "b"} {
+				item, err := dead.Pop(ctx, time.Millisecond*10)
+				require.NoError(t, err)
+				assert.Equal(t, want, item)
+			}
+
+			time.Sleep(time.Millisecond * 100)
+			alive, err := q.Worker(ctx, "alive")
+			require.NoError(t, err)
+			item, err := alive.Pop(ctx, time.Millisecond*10)
+			require.NoError(t, err)
+			assert.Equal(t, "c", item)
+
+			// 只有心跳过期的 Worker 的元素会被放回队列
+			n, err := q.Requeue(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), n)
+			n, err = q.Requeue(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), n)
+
+			// 放回队列的元素保持原来的顺序，并且优先于后面写入的元素
+			_, err = q.Push(ctx, "d")
+			require.NoError(t, err)
+			for _, want := range []string{"a", "b", "d"} {
+				item, err = alive.Pop(ctx, time.Millisecond*10)
+				require.NoError(t, err)
+				assert.Equal(t, want, item)
+			}
+			require.NoError(t, alive.Ack(ctx, "c"))
+
+			// 超时的 Worker 不能再确认被放回队列的元素，刷新心跳之后重新注册
+			assert.Equal(t, ErrItemNotProcessing, dead.Ack(ctx, "a"))
+			require.NoError(t, dead.Heartbeat(ctx))
+			_, err = q.Push(ctx, "e")
+			require.NoError(t, err)
+			item, err = dead.Pop(ctx, time.Millisecond*10)
+			require.NoError(t, err)
+			assert.Equal(t, "e", item)
+			time.Sleep(time.Millisecond * 100)
+			n, err = q.Requeue(ctx)
+			require.NoError(t, err)
+			// 两个 Worker 的心跳都过期了：alive 处理中的 a、b、d 以及 dead 处理中的 e
+			assert.Equal(t, int64(4), n)
+		})
+	}
+}
+
+func TestWorker_Close(t *testing.T) {
+	for name, newCache := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q := NewQueue(newCache(), "jobs")
+			w1, err := q.Worker(ctx, "w1")
+			require.NoError(t, err)
+			_, err = q.Push(ctx, "a", "b")
+			require.NoError(t, err)
+			for _, want := range []string{"a", "b"} {
+				item, err := w1.Pop(ctx, time.Second)
+				require.NoError(t, err)
+				assert.Equal(t, want, item)
+			}
+			require.NoError(t, w1.Ack(ctx, "a"))
+			require.NoError(t, w1.Close(ctx))
+
+			// 没有确认的元素被放回队列
+			w2, err := q.Worker(ctx, "w2")
+			require.NoError(t, err)
+			item, err := w2.Pop(ctx, time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, "b", item)
+			_, err = w2.Pop(ctx, time.Millisecond*10)
+			assert.Equal(t, ErrQueueEmpty, err)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+)
+
+// maxAttempts 事务冲突之后最多执行的次数
+const maxAttempts = 16
+
+// memoryLimiter 基于本地缓存的实现。
+// 读取状态和写回状态在同一个乐观锁事务里面执行，发生冲突的时候重新执行，
+// 所以共享同一个缓存的多个 Limiter，或者别的写入方，都不会破坏原子性。
+// 同一个 Limiter 的事务在 mutex 里面串行执行，彼此之间不会冲突，
+// 只有和别的写入方冲突的时候才需要重试，超过 maxAttempts 次返回 ecache.ErrTxConflict。
+// 状态以值的形式保存在缓存里面，每次修改都要写回去
+type memoryLimiter struct {
+	mutex sync.Mutex
+	cache ecache.TransactionalCache
+	now   func() time.Time
+	allow func(ctx context.Context, tx ecache.Tx, key string, n int64, now time.Time) (Result, error)
+}
+
+func (l *memoryLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *memoryLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var res Result
+	err := ecache.Transaction(ctx, l.cache, maxAttempts, func(tx ecache.Tx) error {
+		var err error
+		res, err = l.allow(ctx, tx, key, n, l.now())
+		return err
+	}, key)
+	if err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
+
+// load 读取 key 对应的状态，不存在或者类型不对的时候返回 false
+func load[T any](ctx context.Context, tx ecache.Tx, key string) (T, bool, error) {
+	var state T
+	val := tx.Get(ctx, key)
+	if val.KeyNotFound() {
+		return state, false, nil
+	}
+	if val.Err != nil {
+		return state, false, val.Err
+	}
+	state, ok := val.Val.(T)
+	return state, ok, nil
+}
+
+// store 在事务中写回 key 对应的状态，key 在读取之后被修改过的话返回 ecache.ErrTxConflict
+func store(ctx context.Context, tx ecache.Tx, key string, state any, expiration time.Duration) error {
+	return tx.Pipelined(ctx, func(p ecache.Pipeline) error {
+		p.Set(key, state, expiration)
+		return nil
+	})
+}
+
+type fixedWindow struct {
+	count   int64
+	resetAt time.Time
+}
+
+// NewMemoryFixedWindowLimiter 固定窗口限流，每个 window 内最多通过 limit 个请求。
+// limit 必须大于 0，window 至少为一毫秒
+func NewMemoryFixedWindowLimiter(cache ecache.TransactionalCache, limit int64, window time.Duration) (Limiter, error) {
+	if err := checkWindow(limit, window); err != nil {
+		return nil, err
+	}
+	return &memoryLimiter{
+		cache: cache,
+		now:   time.Now,
+		allow: func(ctx context.Context, tx ecache.Tx, key string, n int64, now time.Time) (Result, error) {
+			state, ok, err := load[fixedWindow](ctx, tx, key)
+			if err != nil {
+				return Result{}, err
+			}
+			if !ok || !now.Before(state.resetAt) {
+				state = fixedWindow{resetAt: now.Add(window)}
+			}
+			if state.count+n > limit {
+				return Result{
+					Remaining:  positive(limit - state.count),
+					RetryAfter: state.resetAt.Sub(now),
+				}, nil
+			}
+			state.count += n
+			if err = store(ctx, tx, key, state, state.resetAt.Sub(now)); err != nil {
+				return Result{}, err
+			}
+			return Result{Allowed: true, Remaining: limit - state.count}, nil
+		},
+	}, nil
+}
+
+// slidingWindow 按照时间先后记录窗口内每一个请求的时间
+type slidingWindow struct {
+	logs []time.Time
+}
+
+// NewMemorySlidingWindowLimiter 滑动窗口日志限流，任意 window 长度的时间段内最多通过 limit 个请求。
+// limit 必须大于 0，window 至少为一毫秒
+func NewMemorySlidingWindowLimiter(cache ecache.TransactionalCache, limit int64, window time.Duration) (Limiter, error) {
+	if err := checkWindow(limit, window); err != nil {
+		return nil, err
+	}
+	return &memoryLimiter{
+		cache: cache,
+		now:   time.Now,
+		allow: func(ctx context.Context, tx ecache.Tx, key string, n int64, now time.Time) (Result, error) {
+			state, _, err := load[slidingWindow](ctx, tx, key)
+			if err != nil {
+				return Result{}, err
+			}
+			start := now.Add(-window)
+			idx := 0
+			for idx < len(state.logs) && !state.logs[idx].After(start) {
+				idx++
+			}
+			logs := state.logs[idx:]
+			cnt := int64(len(logs))
+			if cnt+n > limit {
+				res := Result{Remaining: positive(limit - cnt), RetryAfter: window}
+				if n <= limit {
+					res.RetryAfter = logs[cnt+n-limit-1].Add(window).Sub(now)
+				}
+				return res, nil
+			}
+			// 别的读取方可能还持有旧的切片，所以复制一份再追加
+			state.logs = make([]time.Time, 0, cnt+n)
+			state.logs = append(state.logs, logs...)
+			for i := int64(0); i < n; i++ {
+				state.logs = append(state.logs, now)
+			}
+			// 每次通过都重新写回去，从而刷新过期时间
+			if err = store(ctx, tx, key, state, window); err != nil {
+				return Result{}, err
+			}
+			return Result{Allowed: true, Remaining: limit - cnt - n}, nil
+		},
+	}, nil
+}
+
+type tokenBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// NewMemoryTokenBucketLimiter 令牌桶限流，每秒生成 rate 个令牌，桶里最多有 capacity 个令牌。
+// rate 和 capacity 都必须大于 0
+func NewMemoryTokenBucketLimiter(cache ecache.TransactionalCache, rate float64, capacity int64) (Limiter, error) {
+	if err := checkTokenBucket(rate, capacity); err != nil {
+		return nil, err
+	}
+	// 桶被填满之后，状态就没有必要继续保留了
+	fullAfter := time.Duration(math.Ceil(float64(capacity) / rate * float64(time.Second)))
+	return &memoryLimiter{
+		cache: cache,
+		now:   time.Now,
+		allow: func(ctx context.Context, tx ecache.Tx, key string, n int64, now time.Time) (Result, error) {
+			state, ok, err := load[tokenBucket](ctx, tx, key)
+			if err != nil {
+				return Result{}, err
+			}
+			if !ok {
+				state = tokenBucket{tokens: float64(capacity), ts: now}
+			}
+			if now.After(state.ts) {
+				state.tokens = math.Min(float64(capacity), state.tokens+now.Sub(state.ts).Seconds()*rate)
+				state.ts = now
+			}
+			res := Result{}
+			if state.tokens >= float64(n) {
+				state.tokens -= float64(n)
+				res.Allowed = true
+			} else if n <= capacity {
+				res.RetryAfter = time.Duration(math.Ceil((float64(n) - state.tokens) / rate * float64(time.Second)))
+			} else {
+				res.RetryAfter = time.Duration(-1)
+			}
+			res.Remaining = int64(state.tokens)
+			if err = store(ctx, tx, key, state, fullAfter); err != nil {
+				return Result{}, err
+			}
+			return res, nil
+		},
+	}, nil
+}
+
+func positive(val int64) int64 {
+	if val < 0 {
+		return 0
+	}
+	return val
+}
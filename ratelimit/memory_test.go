@@ -0,0 +1,299 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/ecodeclub/ecache/memory/priority"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryCaches(t *testing.T) map[string]ecache.TransactionalCache {
+	rbTree, err := priority.NewRBTreePriorityCache()
+	require.NoError(t, err)
+	return map[string]ecache.TransactionalCache{
+		"lru":      lru.NewCache(100),
+		"priority": rbTree,
+	}
+}
+
+// clock 测试用的时钟，只有调用 advance 才会前进
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func withClock(l Limiter) *clock {
+	c := &clock{now: time.Now()}
+	l.(*memoryLimiter).now = c.Now
+	return c
+}
+
+func TestMemoryFixedWindowLimiter(t *testing.T) {
+	for name, cache := range newMemoryCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			l, err := NewMemoryFixedWindowLimiter(cache, 3, time.Minute)
+			require.NoError(t, err)
+			c := withClock(l)
+			ctx := context.Background()
+
+			res, err := l.AllowN(ctx, "api", 2)
+			require.NoError(t, err)
+			assert.Equal(t, Result{Allowed: true, Remaining: 1}, res)
+
+			c.advance(time.Second * 10)
+			// 被拒绝的请求不会消耗配额
+			res, err = l.AllowN(ctx, "api", 2)
+			require.NoError(t, err)
+			assert.Equal(t, Result{Remaining: 1, RetryAfter: time.Second * 50}, res)
+			res, err = l.Allow(ctx, "api")
+			require.NoError(t, err)
+			assert.Equal(t, Result{Allowed: true}, res)
+
+			// 别的 key 互不影响
+			res, err = l.Allow(ctx, "other")
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+
+			// 新的窗口
+			c.advance(time.Second * 50)
+			res, err = l.Allow(ctx, "api")
+			require.NoError(t, err)
+			assert.Equal(t, Result{Allowed: true, Remaining: 2}, res)
+		})
+	}
+}
+
+func TestMemorySlidingWindowLimiter(t *testing.T) {
+	for name, cache := range newMemoryCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			l, err := NewMemorySlidingWindowLimiter(cache, 3, time.Minute)
+			require.NoError(t, err)
+			c := withClock(l)
+			ctx := context.Background()
+
+			res, err := l.Allow(ctx, "api")
+			require.NoError(t, err)
+			assert.Equal(t, Result{Allowed: true, Remaining: 2}, res)
+			c.advance(time.Second * 20)
+			res, err = l.AllowN(ctx, "api", 2)
+			require.NoError(t, err)
+			assert.Equal(t, Result{Allowed: true}, res)
+
+			// 要等最早的请求滑出窗口
+			c.advance(time.Second * 20)
+			res, err = l.Allow(ctx, "api")
+			require.NoError(t, err)
+			assert.Equal(t, Result{RetryAfter: time.Second * 20}, res)
+			// 要等前三个请求都滑出窗口
+			res, err = l.AllowN(ctx, "api", 2)
+			require.NoError(t, err)
+			assert.Equal(t, Result{RetryAfter: time.Second * 40}, res)
+			// 永远不可能通过
+			res, err = l.AllowN(ctx, "api", 4)
+			require.NoError(t, err)
+			assert.Equal(t, Result{RetryAfter: time.Minute}, res)
+
+			c.advance(time.Second * 20)
+			res, err = l.Allow(ctx, "api")
+			require.NoError(t, err)
+			assert.Equal(t, Result{Allowed: true}, res)
+		})
+	}
+}
+
+func TestMemoryTokenBucketLimiter(t *testing.T) {
+	for name, cache := range newMemoryCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			l, err := NewMemoryTokenBucketLimiter(cache, 2, 4)
+			require.NoError(t, err)
+			c := withClock(l)
+			ctx := context.Background()
+
+			// 一开始桶是满的
+			res, err := l.AllowN(ctx, "api", 4)
+			require.NoError(t, err)
+			assert.Equal(t, Result{Allowed: true}, res)
+			res, err = l.Allow(ctx, "api")
+			require.NoError(t, err)
+			assert.Equal(t, Result{RetryAfter: time.Millisecond * 500}, res)
+
+			c.advance(time.Millisecond * 750)
+			res, err = l.Allow(ctx, "api")
+			require.NoError(t, err)
+			assert.Equal(t, Result{Allowed: true}, res)
+			res, err = l.Allow(ctx, "api")
+			require.NoError(t, err)
+			assert.Equal(t, Result{RetryAfter: time.Millisecond * 250}, res)
+			res, err = l.AllowN(ctx, "api", 5)
+			require.NoError(t, err)
+			assert.Equal(t, time.Duration(-1), res.RetryAfter)
+
+			// 令牌不会超过容量
+			c.advance(time.Hour)
+			res, err = l.Allow(ctx, "api")
+			require.NoError(t, err)
+			assert.Equal(t, Result{Allowed: true, Remaining: 3}, res)
+		})
+	}
+}
+
+// TestMemoryLimiter_Concurrent 共享同一个缓存的多个 Limiter 并发限流，通过的请求数量不能超过 limit
+func TestMemoryLimiter_Concurrent(t *testing.T) {
+	testCases := []struct {
+		name string
+		new  func(cache ecache.TransactionalCache) (Limiter, error)
+	}{
+		{
+			name: "fixed window",
+			new: func(cache ecache.TransactionalCache) (Limiter, error) {
+				return NewMemoryFixedWindowLimiter(cache, 50, time.Minute)
+			},
+		},
+		{
+			name: "sliding window",
+			new: func(cache ecache.TransactionalCache) (Limiter, error) {
+				return NewMemorySlidingWindowLimiter(cache, 50, time.Minute)
+			},
+		},
+		{
+			name: "token bucket",
+			new: func(cache ecache.TransactionalCache) (Limiter, error) {
+				return NewMemoryTokenBucketLimiter(cache, 0.001, 50)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		for name, cache := range newMemoryCaches(t) {
+			t.Run(tc.name+" "+name, func(t *testing.T) {
+				l1, err := tc.new(cache)
+				require.NoError(t, err)
+				l2, err := tc.new(cache)
+				require.NoError(t, err)
+				limiters := []Limiter{l1, l2}
+				var allowed int64
+				var wg sync.WaitGroup
+				for i := 0; i < 200; i++ {
+					wg.Add(1)
+					go func(l Limiter) {
+						defer wg.Done()
+						res, err := l.Allow(context.Background(), "api")
+						assert.NoError(t, err)
+						if res.Allowed {
+							atomic.AddInt64(&allowed, 1)
+						}
+					}(limiters[i%len(limiters)])
+				}
+				wg.Wait()
+				assert.Equal(t, int64(50), allowed)
+			})
+		}
+	}
+}
+
+// conflictCache 每次事务都冲突
+type conflictCache struct {
+	ecache.TransactionalCache
+	calls int
+}
+
+func (c *conflictCache) Watch(ctx context.Context, fn func(tx ecache.Tx) error, keys ...string) error {
+	c.calls++
+	return ecache.ErrTxConflict
+}
+
+// TestMemoryLimiter_Conflict 一直冲突的时候不会无限重试
+func TestMemoryLimiter_Conflict(t *testing.T) {
+	cache := &conflictCache{TransactionalCache: lru.NewCache(100)}
+	l, err := NewMemoryFixedWindowLimiter(cache, 3, time.Minute)
+	require.NoError(t, err)
+	_, err = l.Allow(context.Background(), "api")
+	assert.Equal(t, ecache.ErrTxConflict, err)
+	assert.Equal(t, maxAttempts, cache.calls)
+}
+
+func TestMemoryLimiter_InvalidArgs(t *testing.T) {
+	cache := lru.NewCache(100)
+	testCases := []struct {
+		name string
+		new  func() (Limiter, error)
+	}{
+		{
+			name: "fixed window zero limit",
+			new: func() (Limiter, error) {
+				return NewMemoryFixedWindowLimiter(cache, 0, time.Minute)
+			},
+		},
+		{
+			name: "fixed window less than one millisecond",
+			new: func() (Limiter, error) {
+				return NewMemoryFixedWindowLimiter(cache, 3, time.Microsecond)
+			},
+		},
+		{
+			name: "sliding window negative limit",
+			new: func() (Limiter, error) {
+				return NewMemorySlidingWindowLimiter(cache, -1, time.Minute)
+			},
+		},
+		{
+			name: "sliding window negative window",
+			new: func() (Limiter, error) {
+				return NewMemorySlidingWindowLimiter(cache, 3, -time.Minute)
+			},
+		},
+		{
+			name: "token bucket zero rate",
+			new: func() (Limiter, error) {
+				return NewMemoryTokenBucketLimiter(cache, 0, 4)
+			},
+		},
+		{
+			name: "token bucket NaN rate",
+			new: func() (Limiter, error) {
+				return NewMemoryTokenBucketLimiter(cache, math.NaN(), 4)
+			},
+		},
+		{
+			name: "token bucket zero capacity",
+			new: func() (Limiter, error) {
+				return NewMemoryTokenBucketLimiter(cache, 2, 0)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := tc.new()
+			assert.Error(t, err)
+			assert.Nil(t, l)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ecodeclub/ecache/redis"
+)
+
+var (
+	// fixedWindowScript 返回 {是否通过, 剩余配额, 窗口剩余时间（毫秒）}
+	fixedWindowScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local cnt = redis.call("INCRBY", KEYS[1], n)
+if cnt == n then
+    redis.call("PEXPIRE", KEYS[1], window)
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+    redis.call("PEXPIRE", KEYS[1], window)
+    ttl = window
+end
+if cnt > limit then
+    redis.call("DECRBY", KEYS[1], n)
+    return {0, math.max(limit - cnt + n, 0), ttl}
+end
+return {1, limit - cnt, 0}`)
+
+	// slidingWindowScript 使用有序集合记录窗口内每一个请求的时间，
+	// 返回 {是否通过, 剩余配额, 需要等待的时间（毫秒）}
+	slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local cnt = redis.call("ZCARD", KEYS[1])
+if cnt + n > limit then
+    local retry = window
+    if n <= limit then
+        local idx = cnt + n - limit - 1
+        local oldest = redis.call("ZRANGE", KEYS[1], idx, idx, "WITHSCORES")
+        retry = tonumber(oldest[2]) + window - now
+    end
+    return {0, limit - cnt, retry}
+end
+for i = 1, n do
+    redis.call("ZADD", KEYS[1], now, ARGV[5] .. ":" .. i)
+end
+redis.call("PEXPIRE", KEYS[1], window)
+return {1, limit - cnt - n, 0}`)
+
+	// tokenBucketScript 使用哈希结构记录令牌数量和上一次更新的时间，
+	// 返回 {是否通过, 剩余令牌, 需要等待的时间（毫秒）}
+	tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1]) or capacity
+local ts = tonumber(data[2]) or now
+if now > ts then
+    tokens = math.min(capacity, tokens + (now - ts) * rate)
+else
+    now = ts
+end
+local allowed = 0
+local retry = 0
+if tokens >= n then
+    tokens = tokens - n
+    allowed = 1
+elseif n <= capacity then
+    retry = math.ceil((n - tokens) / rate)
+else
+    retry = -1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate))
+return {allowed, math.floor(tokens), retry}`)
+)
+
+// redisLimiter 所有的限流算法都通过 Lua 脚本原子地执行
+type redisLimiter struct {
+	cache  *redis.Cache
+	script *redis.Script
+	args   func(n int64) ([]any, error)
+}
+
+// NewRedisFixedWindowLimiter 固定窗口限流，每个 window 内最多通过 limit 个请求。
+// limit 必须大于 0，window 至少为一毫秒
+func NewRedisFixedWindowLimiter(cache *redis.Cache, limit int64, window time.Duration) (Limiter, error) {
+	if err := checkWindow(limit, window); err != nil {
+		return nil, err
+	}
+	return &redisLimiter{
+		cache:  cache,
+		script: fixedWindowScript,
+		args: func(n int64) ([]any, error) {
+			return []any{n, window.Milliseconds(), limit}, nil
+		},
+	}, nil
+}
+
+// NewRedisSlidingWindowLimiter 滑动窗口日志限流，任意 window 长度的时间段内最多通过 limit 个请求。
+// 请求的时间取的是客户端的时间，所以各个客户端的时钟需要大体一致。limit 必须大于 0，window 至少为一毫秒
+func NewRedisSlidingWindowLimiter(cache *redis.Cache, limit int64, window time.Duration) (Limiter, error) {
+	if err := checkWindow(limit, window); err != nil {
+		return nil, err
+	}
+	return &redisLimiter{
+		cache:  cache,
+		script: slidingWindowScript,
+		args: func(n int64) ([]any, error) {
+			member, err := randomMember()
+			if err != nil {
+				return nil, err
+			}
+			return []any{time.Now().UnixMilli(), window.Milliseconds(), limit, n, member}, nil
+		},
+	}, nil
+}
+
+// NewRedisTokenBucketLimiter 令牌桶限流，每秒生成 rate 个令牌，桶里最多有 capacity 个令牌。
+// 时间取的是客户端的时间，所以各个客户端的时钟需要大体一致。rate 和 capacity 都必须大于 0
+func NewRedisTokenBucketLimiter(cache *redis.Cache, rate float64, capacity int64) (Limiter, error) {
+	if err := checkTokenBucket(rate, capacity); err != nil {
+		return nil, err
+	}
+	return &redisLimiter{
+		cache:  cache,
+		script: tokenBucketScript,
+		args: func(n int64) ([]any, error) {
+			return []any{strconv.FormatFloat(rate/1000, 'f', -1, 64), capacity, time.Now().UnixMilli(), n}, nil
+		},
+	}, nil
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *redisLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	args, err := l.args(n)
+	if err != nil {
+		return Result{}, err
+	}
+	val := l.cache.Run(ctx, l.script, []string{key}, args...)
+	if val.Err != nil {
+		return Result{}, val.Err
+	}
+	res, err := int64s(val.Val)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    res[0] == 1,
+		Remaining:  res[1],
+		RetryAfter: retryAfter(res[2]),
+	}, nil
+}
+
+// int64s 把脚本返回的 {是否通过, 剩余配额, 需要等待的时间} 转换为 int64
+func int64s(val any) ([]int64, error) {
+	vals, ok := val.([]any)
+	if !ok || len(vals) != 3 {
+		return nil, fmt.Errorf("ecache: 限流脚本返回了非法的结果 %v", val)
+	}
+	res := make([]int64, 0, len(vals))
+	for _, v := range vals {
+		i, ok := v.(int64)
+		if !ok {
+			return nil, fmt.Errorf("ecache: 限流脚本返回了非法的结果 %v", val)
+		}
+		res = append(res, i)
+	}
+	return res, nil
+}
+
+// retryAfter 小于 0 表示永远不可能通过
+func retryAfter(ms int64) time.Duration {
+	if ms < 0 {
+		return time.Duration(-1)
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func randomMember() (string, error) {
+	bs := make([]byte, 8)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bs), nil
+}
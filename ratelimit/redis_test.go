@@ -0,0 +1,190 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/mocks"
+	rcache "github.com/ecodeclub/ecache/redis"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRedisLimiter(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+		new  func(cache *rcache.Cache) (Limiter, error)
+		n    int64
+
+		wantRes Result
+		wantErr error
+	}{
+		{
+			name: "fixed window allowed",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), fixedWindowScript.Hash(), []string{"api"},
+					int64(1), int64(60000), int64(10)).Return(sliceCmd(nil, 1, 9, 0))
+				return cmd
+			},
+			new: func(cache *rcache.Cache) (Limiter, error) {
+				return NewRedisFixedWindowLimiter(cache, 10, time.Minute)
+			},
+			n:       1,
+			wantRes: Result{Allowed: true, Remaining: 9},
+		},
+		{
+			name: "fixed window rejected",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), fixedWindowScript.Hash(), []string{"api"},
+					int64(3), int64(60000), int64(10)).Return(sliceCmd(nil, 0, 2, 1500))
+				return cmd
+			},
+			new: func(cache *rcache.Cache) (Limiter, error) {
+				return NewRedisFixedWindowLimiter(cache, 10, time.Minute)
+			},
+			n:       3,
+			wantRes: Result{Remaining: 2, RetryAfter: time.Millisecond * 1500},
+		},
+		{
+			name: "sliding window",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), slidingWindowScript.Hash(), []string{"api"},
+					gomock.Any(), int64(60000), int64(10), int64(1), gomock.Any()).
+					Return(sliceCmd(nil, 0, 0, 200))
+				return cmd
+			},
+			new: func(cache *rcache.Cache) (Limiter, error) {
+				return NewRedisSlidingWindowLimiter(cache, 10, time.Minute)
+			},
+			n:       1,
+			wantRes: Result{RetryAfter: time.Millisecond * 200},
+		},
+		{
+			name: "token bucket",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), tokenBucketScript.Hash(), []string{"api"},
+					"0.5", int64(100), gomock.Any(), int64(200)).
+					Return(sliceCmd(nil, 0, 100, -1))
+				return cmd
+			},
+			new: func(cache *rcache.Cache) (Limiter, error) {
+				return NewRedisTokenBucketLimiter(cache, 500, 100)
+			},
+			n:       200,
+			wantRes: Result{Remaining: 100, RetryAfter: time.Duration(-1)},
+		},
+		{
+			name: "script not loaded",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), fixedWindowScript.Hash(), []string{"api"},
+					int64(1), int64(60000), int64(10)).
+					Return(sliceCmd(redisError("NOSCRIPT No matching script")))
+				cmd.EXPECT().Eval(gomock.Any(), gomock.Any(), []string{"api"},
+					int64(1), int64(60000), int64(10)).Return(sliceCmd(nil, 1, 9, 0))
+				return cmd
+			},
+			new: func(cache *rcache.Cache) (Limiter, error) {
+				return NewRedisFixedWindowLimiter(cache, 10, time.Minute)
+			},
+			n:       1,
+			wantRes: Result{Allowed: true, Remaining: 9},
+		},
+		{
+			name: "invalid result",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), fixedWindowScript.Hash(), []string{"api"},
+					int64(1), int64(60000), int64(10)).Return(sliceCmd(nil, 1, 9))
+				return cmd
+			},
+			new: func(cache *rcache.Cache) (Limiter, error) {
+				return NewRedisFixedWindowLimiter(cache, 10, time.Minute)
+			},
+			n:       1,
+			wantErr: errors.New("ecache: 限流脚本返回了非法的结果 [1 9]"),
+		},
+		{
+			name: "network error",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(gomock.Any(), fixedWindowScript.Hash(), []string{"api"},
+					int64(1), int64(60000), int64(10)).Return(sliceCmd(errors.New("network error")))
+				return cmd
+			},
+			new: func(cache *rcache.Cache) (Limiter, error) {
+				return NewRedisFixedWindowLimiter(cache, 10, time.Minute)
+			},
+			n:       1,
+			wantErr: errors.New("network error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			l, err := tc.new(rcache.NewCache(tc.mock(ctrl)))
+			require.NoError(t, err)
+			res, err := l.AllowN(context.Background(), "api", tc.n)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestRedisLimiter_InvalidArgs(t *testing.T) {
+	cache := rcache.NewCache(nil)
+	_, err := NewRedisFixedWindowLimiter(cache, 10, time.Microsecond*500)
+	assert.Error(t, err)
+	_, err = NewRedisSlidingWindowLimiter(cache, 0, time.Minute)
+	assert.Error(t, err)
+	_, err = NewRedisTokenBucketLimiter(cache, -1, 100)
+	assert.Error(t, err)
+	_, err = NewRedisTokenBucketLimiter(cache, 500, 0)
+	assert.Error(t, err)
+}
+
+func sliceCmd(err error, vals ...int64) *redis.Cmd {
+	cmd := redis.NewCmd(context.Background())
+	if vals != nil {
+		res := make([]any, 0, len(vals))
+		for _, val := range vals {
+			res = append(res, val)
+		}
+		cmd.SetVal(res)
+	}
+	cmd.SetErr(err)
+	return cmd
+}
+
+// redisError 模拟 Redis 服务端返回的错误
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+func (redisError) RedisError() {}
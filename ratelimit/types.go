@@ -0,0 +1,63 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Limiter 限流器，key 一般是用户 ID、IP 或者接口名字
+type Limiter interface {
+	// Allow 等价于 AllowN(ctx, key, 1)
+	Allow(ctx context.Context, key string) (Result, error)
+	// AllowN 判断 key 能否在当前时刻通过 n 个请求，通过的时候会消耗对应的配额
+	AllowN(ctx context.Context, key string, n int64) (Result, error)
+}
+
+// Result 限流的结果
+type Result struct {
+	// Allowed 是否允许通过
+	Allowed bool
+	// Remaining 剩余的配额
+	Remaining int64
+	// RetryAfter 被拒绝的时候，至少需要等待多久才有可能通过。
+	// 小于 0 表示永远不可能通过，例如令牌桶一次请求的令牌超过了桶的容量
+	RetryAfter time.Duration
+}
+
+// checkWindow 窗口限流的参数检查，Redis 的过期时间以毫秒为单位，所以 window 至少为一毫秒
+func checkWindow(limit int64, window time.Duration) error {
+	if limit <= 0 {
+		return fmt.Errorf("ecache: 限流的配额必须大于 0，实际为 %d", limit)
+	}
+	if window < time.Millisecond {
+		return fmt.Errorf("ecache: 限流的窗口必须至少为一毫秒，实际为 %s", window)
+	}
+	return nil
+}
+
+// checkTokenBucket 令牌桶的参数检查
+func checkTokenBucket(rate float64, capacity int64) error {
+	if !(rate > 0) || math.IsInf(rate, 1) {
+		return fmt.Errorf("ecache: 令牌的生成速率必须是大于 0 的有限值，实际为 %v", rate)
+	}
+	if capacity <= 0 {
+		return fmt.Errorf("ecache: 令牌桶的容量必须大于 0，实际为 %d", capacity)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/bitmap"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ ecache.BitmapCache = (*Cache)(nil)
+
+func (c *Cache) SetBit(ctx context.Context, key string, offset int64, value int) (int64, error) {
+	return c.client.SetBit(ctx, key, offset, value).Result()
+}
+
+func (c *Cache) GetBit(ctx context.Context, key string, offset int64) (int64, error) {
+	return c.client.GetBit(ctx, key, offset).Result()
+}
+
+func (c *Cache) BitCount(ctx context.Context, key string, start, end int64) (int64, error) {
+	return c.client.BitCount(ctx, key, &redis.BitCount{Start: start, End: end}).Result()
+}
+
+func (c *Cache) BitOp(ctx context.Context, op ecache.BitOperation, destKey string, keys ...string) (int64, error) {
+	if err := c.sameShard(append([]string{destKey}, keys...)...); err != nil {
+		return 0, err
+	}
+	switch op {
+	case ecache.BitOpAnd:
+		return c.client.BitOpAnd(ctx, destKey, keys...).Result()
+	case ecache.BitOpOr:
+		return c.client.BitOpOr(ctx, destKey, keys...).Result()
+	case ecache.BitOpXor:
+		return c.client.BitOpXor(ctx, destKey, keys...).Result()
+	case ecache.BitOpNot:
+		if len(keys) != 1 {
+			return 0, bitmap.ErrInvalidOp
+		}
+		return c.client.BitOpNot(ctx, destKey, keys[0]).Result()
+	default:
+		return 0, bitmap.ErrInvalidOp
+	}
+}
+
+func (c *Cache) BitPos(ctx context.Context, key string, bit int64, pos ...int64) (int64, error) {
+	// go-redis 在 pos 超过两个的时候会 panic
+	if len(pos) > 2 {
+		return 0, bitmap.ErrInvalidPos
+	}
+	return c.client.BitPos(ctx, key, bit, pos...).Result()
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_e2e_Bitmap(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	c := NewCache(rdb)
+	require.NoError(t, rdb.Del(ctx, "bitmap_dau", "bitmap_key1", "bitmap_key2", "bitmap_dest").Err())
+
+	old, err := c.SetBit(ctx, "bitmap_dau", 7, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), old)
+	// 和本地缓存一样，offset 为 0 的位是第一个字节的最高位
+	assert.Equal(t, "\x01", c.Get(ctx, "bitmap_dau").Val)
+	bit, err := c.GetBit(ctx, "bitmap_dau", 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), bit)
+
+	require.NoError(t, c.Set(ctx, "bitmap_key1", "foobar", time.Minute))
+	require.NoError(t, c.Set(ctx, "bitmap_key2", "abcdef", time.Minute))
+	cnt, err := c.BitCount(ctx, "bitmap_key1", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(26), cnt)
+	n, err := c.BitOp(ctx, ecache.BitOpAnd, "bitmap_dest", "bitmap_key1", "bitmap_key2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+	assert.Equal(t, "`bc`ab", c.Get(ctx, "bitmap_dest").Val)
+	pos, err := c.BitPos(ctx, "bitmap_key1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pos)
+
+	_, err = c.Delete(ctx, "bitmap_dau", "bitmap_key1", "bitmap_key2", "bitmap_dest")
+	require.NoError(t, err)
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/bitmap"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCache_Bitmap(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := NewCache(cmd)
+	ctx := context.Background()
+
+	cmd.EXPECT().SetBit(ctx, "dau", int64(7), 1).Return(redis.NewIntResult(0, nil))
+	old, err := c.SetBit(ctx, "dau", 7, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), old)
+
+	cmd.EXPECT().GetBit(ctx, "dau", int64(7)).Return(redis.NewIntResult(1, nil))
+	bit, err := c.GetBit(ctx, "dau", 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), bit)
+
+	cmd.EXPECT().BitCount(ctx, "dau", &redis.BitCount{Start: 0, End: -1}).Return(redis.NewIntResult(1, nil))
+	cnt, err := c.BitCount(ctx, "dau", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+
+	cmd.EXPECT().BitPos(ctx, "dau", int64(1), int64(0), int64(-1)).Return(redis.NewIntResult(7, nil))
+	pos, err := c.BitPos(ctx, "dau", 1, 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), pos)
+	_, err = c.BitPos(ctx, "dau", 1, 0, -1, 1)
+	assert.Equal(t, bitmap.ErrInvalidPos, err)
+}
+
+func TestCache_BitOp(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(cmd *mocks.MockCmdable)
+		op      ecache.BitOperation
+		keys    []string
+		wantVal int64
+		wantErr error
+	}{
+		{
+			name: "and",
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().BitOpAnd(gomock.Any(), "dest", "a", "b").Return(redis.NewIntResult(6, nil))
+			},
+			op:      ecache.BitOpAnd,
+			keys:    []string{"a", "b"},
+			wantVal: 6,
+		},
+		{
+			name: "or",
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().BitOpOr(gomock.Any(), "dest", "a", "b").Return(redis.NewIntResult(6, nil))
+			},
+			op:      ecache.BitOpOr,
+			keys:    []string{"a", "b"},
+			wantVal: 6,
+		},
+		{
+			name: "xor",
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().BitOpXor(gomock.Any(), "dest", "a", "b").Return(redis.NewIntResult(6, nil))
+			},
+			op:      ecache.BitOpXor,
+			keys:    []string{"a", "b"},
+			wantVal: 6,
+		},
+		{
+			name: "not",
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().BitOpNot(gomock.Any(), "dest", "a").Return(redis.NewIntResult(3, nil))
+			},
+			op:      ecache.BitOpNot,
+			keys:    []string{"a"},
+			wantVal: 3,
+		},
+		{
+			name:    "not with two keys",
+			mock:    func(cmd *mocks.MockCmdable) {},
+			op:      ecache.BitOpNot,
+			keys:    []string{"a", "b"},
+			wantErr: bitmap.ErrInvalidOp,
+		},
+		{
+			name:    "unknown",
+			mock:    func(cmd *mocks.MockCmdable) {},
+			op:      "NAND",
+			keys:    []string{"a", "b"},
+			wantErr: bitmap.ErrInvalidOp,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			cmd := mocks.NewMockCmdable(ctrl)
+			tc.mock(cmd)
+			n, err := NewCache(cmd).BitOp(context.Background(), tc.op, "dest", tc.keys...)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantVal, n)
+		})
+	}
+}
@@ -26,12 +26,26 @@ import (
 
 var _ ecache.Cache = (*Cache)(nil)
 
+// compareAndSwapScript ARGV[3] 是过期时间（毫秒），0 表示永不过期
+var compareAndSwapScript = NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+    return 0
+end
+if tonumber(ARGV[3]) > 0 then
+    redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+    redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1`)
+
 type Cache struct {
 	client redis.Cmdable
+	// shard 不为 nil 的时候多 key 的命令需要按照分片拆分，参考 NewClusterCache
+	shard shardFunc
 }
 
 func NewCache(client redis.Cmdable) *Cache {
-	return &Cache{client: client}
+	return &Cache{client: client, shard: shardFuncOf(client)}
 }
 
 func (c *Cache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
@@ -42,8 +56,35 @@ func (c *Cache) SetNX(ctx context.Context, key string, val any, expiration time.
 	return c.client.SetNX(ctx, key, val, expiration).Result()
 }
 
+func (c *Cache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	return c.client.SetXX(ctx, key, val, expiration).Result()
+}
+
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	ms := expiration.Milliseconds()
+	if expiration > 0 && ms == 0 {
+		// 不足一毫秒的按照一毫秒处理，避免变成永不过期
+		ms = 1
+	}
+	res, err := c.Run(ctx, compareAndSwapScript, []string{key}, old, new, ms).Int64()
+	return res == 1, err
+}
+
 func (c *Cache) Delete(ctx context.Context, key ...string) (int64, error) {
-	return c.client.Del(ctx, key...).Result()
+	groups := groupKeys(c.shard, key)
+	if len(groups) == 1 {
+		return c.client.Del(ctx, key...).Result()
+	}
+	// 不在同一个分片上的 key 拆分成多个 DEL，一次网络往返发送。
+	// 每个命令的错误都记录在命令自身上，部分失败的时候返回成功删除的数量和第一个错误
+	cmds := make([]*redis.IntCmd, 0, len(groups))
+	_, _ = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, group := range groups {
+			cmds = append(cmds, pipe.Del(ctx, group...))
+		}
+		return nil
+	})
+	return sumIntCmds(cmds)
 }
 
 func (c *Cache) Get(ctx context.Context, key string) (val ecache.Value) {
@@ -74,6 +115,116 @@ func (c *Cache) LPop(ctx context.Context, key string) (result ecache.Value) {
 	return
 }
 
+func (c *Cache) BLPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return blocking(ctx, timeout, func(timeout time.Duration) (result ecache.Value) {
+		res, err := c.client.BLPop(ctx, timeout, key).Result()
+		if err != nil {
+			result.Err = blockingError(ctx, err)
+			return
+		}
+		// 返回值是 key 和弹出的元素
+		result.Val = res[1]
+		return
+	})
+}
+
+func (c *Cache) BRPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return blocking(ctx, timeout, func(timeout time.Duration) (result ecache.Value) {
+		res, err := c.client.BRPop(ctx, timeout, key).Result()
+		if err != nil {
+			result.Err = blockingError(ctx, err)
+			return
+		}
+		result.Val = res[1]
+		return
+	})
+}
+
+func (c *Cache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) (result ecache.Value) {
+	if result.Err = c.sameShard(source, destination); result.Err != nil {
+		return
+	}
+	return blocking(ctx, timeout, func(timeout time.Duration) (result ecache.Value) {
+		result.Val, result.Err = c.client.BLMove(ctx, source, destination,
+			string(srcPos), string(destPos), timeout).Result()
+		if result.Err != nil {
+			result.Err = blockingError(ctx, result.Err)
+		}
+		return
+	})
+}
+
+func (c *Cache) LMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection) (result ecache.Value) {
+	if result.Err = c.sameShard(source, destination); result.Err != nil {
+		return
+	}
+	result.Val, result.Err = c.client.LMove(ctx, source, destination, string(srcPos), string(destPos)).Result()
+	if result.Err != nil && errors.Is(result.Err, redis.Nil) {
+		result.Err = errs.ErrKeyNotExist
+	}
+	return
+}
+
+func (c *Cache) RPopLPush(ctx context.Context, source, destination string) (result ecache.Value) {
+	if result.Err = c.sameShard(source, destination); result.Err != nil {
+		return
+	}
+	result.Val, result.Err = c.client.RPopLPush(ctx, source, destination).Result()
+	if result.Err != nil && errors.Is(result.Err, redis.Nil) {
+		result.Err = errs.ErrKeyNotExist
+	}
+	return
+}
+
+func (c *Cache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	return c.client.LRem(ctx, key, count, val).Result()
+}
+
+// blockingSlice 每次阻塞的时间。Redis 阻塞命令的超时时间以秒为单位，
+// go-redis 会把不到一秒的超时时间改成一秒并且打印警告，所以每次都阻塞整整一秒
+const blockingSlice = time.Second
+
+// blocking go-redis 不会在 ctx 被取消的时候中断阻塞的命令，只会按照 ctx 的 deadline 设置读超时，
+// 所以把一次阻塞拆分成多次 blockingSlice 的阻塞，每次之间检查 ctx，
+// 这样即使 timeout 为 0 并且 ctx 没有 deadline，ctx 被取消之后最多再等待 blockingSlice 就会返回。
+// 因为每次阻塞都是整秒，timeout 会被四舍五入到整秒，最少一秒，
+// 例如 1500ms 会阻塞两秒，1400ms 会阻塞一秒
+func blocking(ctx context.Context, timeout time.Duration, pop func(timeout time.Duration) ecache.Value) (res ecache.Value) {
+	// times 阻塞的次数，0 表示一直阻塞
+	var times int64
+	if timeout > 0 {
+		times = int64(timeout.Round(blockingSlice) / blockingSlice)
+		if times < 1 {
+			times = 1
+		}
+	}
+	for i := int64(0); times == 0 || i < times; i++ {
+		if res.Err = ctx.Err(); res.Err != nil {
+			return
+		}
+		// ctx 的剩余时间不到 blockingSlice 的时候 go-redis 会在 deadline 的时候中断读取，
+		// 不会一直占用连接
+		res = pop(blockingSlice)
+		if !errors.Is(res.Err, errs.ErrKeyNotExist) {
+			return
+		}
+	}
+	return
+}
+
+// blockingError 超时的时候 Redis 返回 nil，因为 ctx 结束导致的网络错误统一返回 ctx.Err()
+func blockingError(ctx context.Context, err error) error {
+	if errors.Is(err, redis.Nil) {
+		return errs.ErrKeyNotExist
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
 func (c *Cache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
 	return c.client.SAdd(ctx, key, members...).Result()
 }
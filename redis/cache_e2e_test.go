@@ -813,3 +813,98 @@ func newRedisClient() *redis.Client {
 		Addr: "localhost:6379",
 	})
 }
+
+func TestCache_e2e_CompareAndSwap(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	c := NewCache(rdb)
+
+	ok, err := c.SetXX(ctx, "cas_name", "大明", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.NoError(t, c.Set(ctx, "cas_name", "大明", time.Minute))
+	ok, err = c.SetXX(ctx, "cas_name", "中明", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = c.CompareAndSwap(ctx, "cas_name", "大明", "小明", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = c.CompareAndSwap(ctx, "cas_name", "中明", "小明", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "小明", c.Get(ctx, "cas_name").Val)
+	ttl, err := rdb.PTTL(ctx, "cas_name").Result()
+	require.NoError(t, err)
+	assert.True(t, ttl > 0)
+
+	_, err = c.Delete(ctx, "cas_name")
+	require.NoError(t, err)
+}
+
+func TestCache_e2e_BLPop(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	c := NewCache(rdb)
+	require.NoError(t, rdb.Del(ctx, "blpop_jobs", "blpop_processing").Err())
+
+	// 超时
+	val := c.BLPop(ctx, "blpop_jobs", time.Second)
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+
+	// 被其他人的 LPush 唤醒
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		_, _ = c.LPush(ctx, "blpop_jobs", "a", "b")
+	}()
+	val = c.BLPop(ctx, "blpop_jobs", time.Second*3)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "b", val.Val)
+
+	val = c.BLMove(ctx, "blpop_jobs", "blpop_processing", ecache.ListLeft, ecache.ListRight, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	val = c.BRPop(ctx, "blpop_processing", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+
+	// ctx 的剩余时间比 timeout 更短
+	tctx, tcancel := context.WithTimeout(ctx, time.Millisecond*500)
+	defer tcancel()
+	val = c.BLPop(tctx, "blpop_jobs", 0)
+	assert.Error(t, val.Err)
+}
+
+func TestCache_e2e_LMove(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	c := NewCache(rdb)
+	require.NoError(t, rdb.Del(ctx, "lmove_jobs", "lmove_processing").Err())
+
+	_, err := c.LPush(ctx, "lmove_jobs", "a", "b", "a")
+	require.NoError(t, err)
+	val := c.RPopLPush(ctx, "lmove_jobs", "lmove_processing")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	val = c.LMove(ctx, "lmove_processing", "lmove_jobs", ecache.ListLeft, ecache.ListRight)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	val = c.LMove(ctx, "lmove_processing", "lmove_jobs", ecache.ListLeft, ecache.ListRight)
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+
+	n, err := c.LRem(ctx, "lmove_jobs", 0, "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	n, err = c.LRem(ctx, "lmove_jobs", 0, "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	_, err = c.Delete(ctx, "lmove_jobs")
+	require.NoError(t, err)
+}
@@ -16,11 +16,13 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/ecodeclub/ecache"
 	"github.com/ecodeclub/ecache/internal/errs"
 	"github.com/ecodeclub/ecache/mocks"
 	"github.com/redis/go-redis/v9"
@@ -198,6 +200,151 @@ func TestCache_SetNX(t *testing.T) {
 	}
 }
 
+func TestCache_SetXX(t *testing.T) {
+	testCases := []struct {
+		name       string
+		mock       func(*gomock.Controller) redis.Cmdable
+		key        string
+		val        string
+		expiration time.Duration
+
+		result  bool
+		wantErr error
+	}{
+		{
+			name: "setxx value",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				boolCmd := redis.NewBoolCmd(context.Background())
+				boolCmd.SetVal(true)
+				cmd.EXPECT().
+					SetXX(context.Background(), "setxx_key", "hello ecache", time.Second*10).
+					Return(boolCmd)
+				return cmd
+			},
+			key:        "setxx_key",
+			val:        "hello ecache",
+			expiration: time.Second * 10,
+			result:     true,
+		},
+		{
+			name: "key not exist",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				boolCmd := redis.NewBoolCmd(context.Background())
+				boolCmd.SetVal(false)
+				cmd.EXPECT().
+					SetXX(context.Background(), "setxx_key", "hello ecache", time.Second*10).
+					Return(boolCmd)
+				return cmd
+			},
+			key:        "setxx_key",
+			val:        "hello ecache",
+			expiration: time.Second * 10,
+			result:     false,
+		},
+		{
+			name: "timeout",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				boolCmd := redis.NewBoolCmd(context.Background())
+				boolCmd.SetErr(context.DeadlineExceeded)
+				cmd.EXPECT().
+					SetXX(context.Background(), "setxx_key", "hello ecache", time.Second*10).
+					Return(boolCmd)
+				return cmd
+			},
+			key:        "setxx_key",
+			val:        "hello ecache",
+			expiration: time.Second * 10,
+			wantErr:    context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			c := NewCache(tc.mock(ctrl))
+			val, err := c.SetXX(context.Background(), tc.key, tc.val, tc.expiration)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.result, val)
+		})
+	}
+}
+
+func TestCache_CompareAndSwap(t *testing.T) {
+	testCases := []struct {
+		name       string
+		mock       func(*gomock.Controller) redis.Cmdable
+		expiration time.Duration
+
+		result  bool
+		wantErr error
+	}{
+		{
+			name: "swapped",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().
+					EvalSha(context.Background(), compareAndSwapScript.Hash(), []string{"name"}, "大明", "小明", int64(60000)).
+					Return(evalCmd(int64(1), nil))
+				return cmd
+			},
+			expiration: time.Minute,
+			result:     true,
+		},
+		{
+			name: "not equal",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().
+					EvalSha(context.Background(), compareAndSwapScript.Hash(), []string{"name"}, "大明", "小明", int64(0)).
+					Return(evalCmd(int64(0), nil))
+				return cmd
+			},
+			result: false,
+		},
+		{
+			name: "less than one millisecond",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().
+					EvalSha(context.Background(), compareAndSwapScript.Hash(), []string{"name"}, "大明", "小明", int64(1)).
+					Return(evalCmd(int64(1), nil))
+				return cmd
+			},
+			expiration: time.Microsecond,
+			result:     true,
+		},
+		{
+			name: "timeout",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().
+					EvalSha(context.Background(), compareAndSwapScript.Hash(), []string{"name"}, "大明", "小明", int64(60000)).
+					Return(evalCmd(nil, context.DeadlineExceeded))
+				return cmd
+			},
+			expiration: time.Minute,
+			wantErr:    context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			c := NewCache(tc.mock(ctrl))
+			ok, err := c.CompareAndSwap(context.Background(), "name", "大明", "小明", tc.expiration)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.result, ok)
+		})
+	}
+}
+
 func TestCache_GetSet(t *testing.T) {
 	testCase := []struct {
 		name    string
@@ -450,6 +597,330 @@ func TestCache_LPop(t *testing.T) {
 	}
 }
 
+func TestCache_BLPop(t *testing.T) {
+	testCase := []struct {
+		name    string
+		mock    func(*gomock.Controller) redis.Cmdable
+		ctx     func() (context.Context, context.CancelFunc)
+		timeout time.Duration
+		wantVal any
+		wantErr error
+	}{
+		{
+			name: "blpop value",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().
+					BLPop(gomock.Any(), time.Second, "test_cache_blpop").
+					Return(redis.NewStringSliceResult([]string{"test_cache_blpop", "test"}, nil))
+				return cmd
+			},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			timeout: time.Second,
+			wantVal: "test",
+		},
+		{
+			name: "blpop timeout",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().
+					BLPop(gomock.Any(), time.Second, "test_cache_blpop").
+					Return(redis.NewStringSliceResult(nil, redis.Nil))
+				return cmd
+			},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			timeout: time.Second,
+			wantErr: errs.ErrKeyNotExist,
+		},
+		{
+			name: "ctx deadline shorter than timeout",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().
+					BLPop(gomock.Any(), gomock.Any(), "test_cache_blpop").
+					DoAndReturn(func(ctx context.Context, timeout time.Duration, keys ...string) *redis.StringSliceCmd {
+						if timeout <= 0 || timeout > time.Minute {
+							return redis.NewStringSliceResult(nil, errors.New("unexpected timeout"))
+						}
+						<-ctx.Done()
+						return redis.NewStringSliceResult(nil, errors.New("i/o timeout"))
+					})
+				return cmd
+			},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond*10)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "ctx canceled",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				return mocks.NewMockCmdable(ctrl)
+			},
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			ctx, cancel := tc.ctx()
+			defer cancel()
+
+			c := NewCache(tc.mock(ctrl))
+			val := c.BLPop(ctx, "test_cache_blpop", tc.timeout)
+			assert.Equal(t, tc.wantVal, val.Val)
+			assert.Equal(t, tc.wantErr, val.Err)
+		})
+	}
+}
+
+// TestCache_BlockingCancel timeout 为 0 并且 ctx 没有 deadline 的时候，ctx 被取消之后也能返回
+func TestCache_BlockingCancel(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(cmd *mocks.MockCmdable, block func(timeout time.Duration))
+		call func(ctx context.Context, c *Cache) ecache.Value
+	}{
+		{
+			name: "blpop",
+			mock: func(cmd *mocks.MockCmdable, block func(timeout time.Duration)) {
+				cmd.EXPECT().BLPop(gomock.Any(), gomock.Any(), "list").
+					DoAndReturn(func(ctx context.Context, timeout time.Duration, keys ...string) *redis.StringSliceCmd {
+						block(timeout)
+						return redis.NewStringSliceResult(nil, redis.Nil)
+					}).MinTimes(2)
+			},
+			call: func(ctx context.Context, c *Cache) ecache.Value {
+				return c.BLPop(ctx, "list", 0)
+			},
+		},
+		{
+			name: "brpop",
+			mock: func(cmd *mocks.MockCmdable, block func(timeout time.Duration)) {
+				cmd.EXPECT().BRPop(gomock.Any(), gomock.Any(), "list").
+					DoAndReturn(func(ctx context.Context, timeout time.Duration, keys ...string) *redis.StringSliceCmd {
+						block(timeout)
+						return redis.NewStringSliceResult(nil, redis.Nil)
+					}).MinTimes(2)
+			},
+			call: func(ctx context.Context, c *Cache) ecache.Value {
+				return c.BRPop(ctx, "list", 0)
+			},
+		},
+		{
+			name: "blmove",
+			mock: func(cmd *mocks.MockCmdable, block func(timeout time.Duration)) {
+				cmd.EXPECT().BLMove(gomock.Any(), "jobs", "processing", "LEFT", "RIGHT", gomock.Any()).
+					DoAndReturn(func(ctx context.Context, source, destination, srcPos, destPos string,
+						timeout time.Duration) *redis.StringCmd {
+						block(timeout)
+						return redis.NewStringResult("", redis.Nil)
+					}).MinTimes(2)
+			},
+			call: func(ctx context.Context, c *Cache) ecache.Value {
+				return c.BLMove(ctx, "jobs", "processing", ecache.ListLeft, ecache.ListRight, 0)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			cmd := mocks.NewMockCmdable(ctrl)
+			// 模拟 Redis 的阻塞，每次阻塞都必须有上限，否则 ctx 被取消之后也不会返回
+			tc.mock(cmd, func(timeout time.Duration) {
+				assert.Equal(t, blockingSlice, timeout)
+				time.Sleep(time.Millisecond * 10)
+			})
+			c := NewCache(cmd)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				time.Sleep(time.Millisecond * 50)
+				cancel()
+			}()
+			start := time.Now()
+			val := tc.call(ctx, c)
+			assert.Equal(t, context.Canceled, val.Err)
+			assert.Less(t, time.Since(start), blockingSlice)
+		})
+	}
+}
+
+func TestCache_BlockingTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := NewCache(cmd)
+
+	// timeout 超过 blockingSlice 的时候分多次阻塞，每次都是 blockingSlice
+	gomock.InOrder(
+		cmd.EXPECT().BLPop(gomock.Any(), blockingSlice, "list").
+			Return(redis.NewStringSliceResult(nil, redis.Nil)),
+		cmd.EXPECT().BLPop(gomock.Any(), blockingSlice, "list").
+			Return(redis.NewStringSliceResult([]string{"list", "value"}, nil)),
+	)
+	val := c.BLPop(context.Background(), "list", blockingSlice+time.Millisecond*500)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "value", val.Val)
+}
+
+// TestCache_BlockingWait 一直没有元素的时候，累计阻塞的时间和 timeout 的差距不超过半秒，
+// 并且每次传给 Redis 的都是整秒
+func TestCache_BlockingWait(t *testing.T) {
+	testCases := []struct {
+		name    string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{
+			name:    "less than one second",
+			timeout: time.Millisecond * 300,
+			want:    time.Second,
+		},
+		{
+			name:    "one second",
+			timeout: time.Second,
+			want:    time.Second,
+		},
+		{
+			name:    "round down",
+			timeout: time.Millisecond * 1400,
+			want:    time.Second,
+		},
+		{
+			name:    "round up",
+			timeout: time.Millisecond * 1500,
+			want:    time.Second * 2,
+		},
+		{
+			name:    "whole seconds",
+			timeout: time.Second * 3,
+			want:    time.Second * 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			cmd := mocks.NewMockCmdable(ctrl)
+			c := NewCache(cmd)
+
+			var total time.Duration
+			cmd.EXPECT().BLPop(gomock.Any(), gomock.Any(), "list").
+				DoAndReturn(func(ctx context.Context, timeout time.Duration, keys ...string) *redis.StringSliceCmd {
+					assert.Equal(t, time.Duration(0), timeout%time.Second)
+					total += timeout
+					return redis.NewStringSliceResult(nil, redis.Nil)
+				}).AnyTimes()
+			val := c.BLPop(context.Background(), "list", tc.timeout)
+			assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+			assert.Equal(t, tc.want, total)
+			assert.LessOrEqual(t, total-tc.timeout, time.Millisecond*700)
+			assert.LessOrEqual(t, tc.timeout-total, time.Millisecond*500)
+		})
+	}
+}
+
+func TestCache_BRPop(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := NewCache(cmd)
+
+	cmd.EXPECT().BRPop(gomock.Any(), time.Second, "test_cache_brpop").
+		Return(redis.NewStringSliceResult([]string{"test_cache_brpop", "test"}, nil))
+	val := c.BRPop(context.Background(), "test_cache_brpop", time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "test", val.Val)
+
+	cmd.EXPECT().BRPop(gomock.Any(), time.Second, "test_cache_brpop").
+		Return(redis.NewStringSliceResult(nil, redis.Nil))
+	val = c.BRPop(context.Background(), "test_cache_brpop", time.Second)
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+}
+
+func TestCache_BLMove(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := NewCache(cmd)
+
+	cmd.EXPECT().BLMove(gomock.Any(), "jobs", "processing", "RIGHT", "LEFT", time.Second).
+		Return(redis.NewStringResult("job1", nil))
+	val := c.BLMove(context.Background(), "jobs", "processing", ecache.ListRight, ecache.ListLeft, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "job1", val.Val)
+
+	cmd.EXPECT().BLMove(gomock.Any(), "jobs", "processing", "LEFT", "LEFT", time.Second).
+		Return(redis.NewStringResult("", redis.Nil))
+	val = c.BLMove(context.Background(), "jobs", "processing", ecache.ListLeft, ecache.ListLeft, time.Second)
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+
+	cmd.EXPECT().BLMove(gomock.Any(), "jobs", "processing", "LEFT", "LEFT", time.Second).
+		Return(redis.NewStringResult("", errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")))
+	val = c.BLMove(context.Background(), "jobs", "processing", ecache.ListLeft, ecache.ListLeft, time.Second)
+	assert.Equal(t, errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"), val.Err)
+}
+
+func TestCache_LMove(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := NewCache(cmd)
+
+	cmd.EXPECT().LMove(gomock.Any(), "processing", "jobs", "LEFT", "RIGHT").
+		Return(redis.NewStringResult("job1", nil))
+	val := c.LMove(context.Background(), "processing", "jobs", ecache.ListLeft, ecache.ListRight)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "job1", val.Val)
+
+	cmd.EXPECT().LMove(gomock.Any(), "processing", "jobs", "LEFT", "RIGHT").
+		Return(redis.NewStringResult("", redis.Nil))
+	val = c.LMove(context.Background(), "processing", "jobs", ecache.ListLeft, ecache.ListRight)
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+
+	cmd.EXPECT().RPopLPush(gomock.Any(), "jobs", "processing").
+		Return(redis.NewStringResult("job2", nil))
+	val = c.RPopLPush(context.Background(), "jobs", "processing")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "job2", val.Val)
+
+	cmd.EXPECT().RPopLPush(gomock.Any(), "jobs", "processing").
+		Return(redis.NewStringResult("", redis.Nil))
+	val = c.RPopLPush(context.Background(), "jobs", "processing")
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+}
+
+func TestCache_LRem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := NewCache(cmd)
+
+	cmd.EXPECT().LRem(gomock.Any(), "processing", int64(-1), "job1").
+		Return(redis.NewIntResult(1, nil))
+	n, err := c.LRem(context.Background(), "processing", -1, "job1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	cmd.EXPECT().LRem(gomock.Any(), "processing", int64(0), "job1").
+		Return(redis.NewIntResult(0, errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")))
+	_, err = c.LRem(context.Background(), "processing", 0, "job1")
+	assert.Equal(t, errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"), err)
+}
+
 func TestCache_SAdd(t *testing.T) {
 	testCase := []struct {
 		name    string
@@ -0,0 +1,133 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/ecodeclub/ecache/internal/hashtag"
+	"github.com/redis/go-redis/v9"
+)
+
+// slotCount Redis Cluster 的 slot 数量
+const slotCount = 16384
+
+// ErrCrossSlot 没办法拆分的多 key 命令的 key 不在同一个分片上
+var ErrCrossSlot = errors.New("ecache: 多个 key 不在同一个分片上，需要使用相同的 hash tag")
+
+// NewClusterCache 基于 Redis Cluster 创建缓存。
+// 多个 key 的 Delete 会按照 slot 拆分之后通过 pipeline 发送，结果是各个 slot 删除数量之和。
+// 其余的多 key 命令没办法拆分之后再合并结果：
+// LMove、RPopLPush、BLMove 需要原子地在两个 key 之间移动元素，BitOp 和 PFMerge 需要把结果写入 destKey，
+// 多个 key 的 PFCount 返回的是并集的基数，不能把每个 slot 的基数相加。
+// 这些命令以及 Watch、多个 key 的 Eval 要求所有的 key 在同一个 slot 上，否则返回 ErrCrossSlot 或者 Redis 的 CROSSSLOT 错误。
+// 可以通过 hash tag 做到，例如 ecache.NamespaceCache 的 HashTag
+func NewClusterCache(opts *redis.ClusterOptions) *Cache {
+	return NewCache(redis.NewClusterClient(opts))
+}
+
+// NewSentinelCache 基于 Redis Sentinel 创建缓存，主从切换之后会自动连接到新的主节点
+func NewSentinelCache(opts *redis.FailoverOptions) *Cache {
+	return NewCache(redis.NewFailoverClient(opts))
+}
+
+// NewRingCache 基于 go-redis 的 Ring 创建缓存，Ring 在客户端使用一致性哈希把 key 分布到多个独立的 Redis 上。
+// 多个 key 的 Delete 会按照 hash tag 拆分，其它多 key 命令的限制和 NewClusterCache 一样。
+// Ring 只会把命令发给第一个 key 所在的分片，不会报错，所以一定要使用相同的 hash tag
+func NewRingCache(opts *redis.RingOptions) *Cache {
+	return NewCache(redis.NewRing(opts))
+}
+
+// shardFunc 返回 key 所在分片的标识，标识相同的 key 可以放在同一个命令里面
+type shardFunc func(key string) string
+
+// shardFuncOf 根据客户端的类型决定多 key 命令怎么拆分，单机和 Sentinel 不需要拆分
+func shardFuncOf(client redis.Cmdable) shardFunc {
+	switch client.(type) {
+	case *redis.ClusterClient:
+		return func(key string) string {
+			return strconv.Itoa(Slot(key))
+		}
+	case *redis.Ring:
+		// Ring 按照 hash tag 做一致性哈希，hash tag 相同的 key 一定在同一个分片上
+		return hashtag.Key
+	default:
+		return nil
+	}
+}
+
+// groupKeys 按照分片把 key 分组，保持 key 第一次出现的顺序
+func groupKeys(shard shardFunc, keys []string) [][]string {
+	if shard == nil || len(keys) <= 1 {
+		return [][]string{keys}
+	}
+	idx := make(map[string]int, len(keys))
+	groups := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		s := shard(key)
+		i, ok := idx[s]
+		if !ok {
+			i = len(groups)
+			idx[s] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], key)
+	}
+	return groups
+}
+
+// sameShard 没办法拆分的多 key 命令要求所有的 key 在同一个分片上。
+// Cluster 会返回 CROSSSLOT 错误，但是 Ring 会悄悄地在第一个 key 所在的分片上执行，所以在客户端检查
+func (c *Cache) sameShard(keys ...string) error {
+	if len(groupKeys(c.shard, keys)) > 1 {
+		return ErrCrossSlot
+	}
+	return nil
+}
+
+// Slot 返回 key 在 Redis Cluster 中的 slot，如果 key 中有 hash tag 则只计算 hash tag
+func Slot(key string) int {
+	return int(crc16(hashtag.Key(key)) % slotCount)
+}
+
+// crc16 Redis Cluster 使用的 CRC16-CCITT（XMODEM）
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// sumIntCmds 累加拆分之后每个命令的结果，返回第一个错误
+func sumIntCmds(cmds []*redis.IntCmd) (int64, error) {
+	var cnt int64
+	var firstErr error
+	for _, cmd := range cmds {
+		cnt += cmd.Val()
+		if err := cmd.Err(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return cnt, firstErr
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_e2e_Cluster(t *testing.T) {
+	c := NewClusterCache(&redis.ClusterOptions{
+		Addrs: []string{"localhost:7000", "localhost:7001", "localhost:7002",
+			"localhost:7003", "localhost:7004", "localhost:7005"},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	require.NoError(t, c.client.Ping(ctx).Err())
+	testCrossSlot(t, c)
+
+	// 使用 hash tag 之后，多 key 命令在集群上也可以执行
+	ns := &ecache.NamespaceCache{C: c, Namespace: "queue:", HashTag: true}
+	_, err := ns.Delete(ctx, "pending", "processing")
+	require.NoError(t, err)
+	_, err = ns.LPush(ctx, "pending", "job1")
+	require.NoError(t, err)
+	val := ns.LMove(ctx, "pending", "processing", ecache.ListRight, ecache.ListLeft)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "job1", val.Val)
+	// 不使用 hash tag 的时候 Redis 会返回 CROSSSLOT
+	val = c.LMove(ctx, "cluster_pending", "cluster_processing", ecache.ListRight, ecache.ListLeft)
+	assert.ErrorContains(t, val.Err, "CROSSSLOT")
+}
+
+func TestCache_e2e_Ring(t *testing.T) {
+	// 两个分片指向同一个 Redis，只是为了验证按照分片拆分的逻辑
+	c := NewRingCache(&redis.RingOptions{
+		Addrs: map[string]string{"shard1": "localhost:6379", "shard2": "localhost:6379"},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	require.NoError(t, c.client.Ping(ctx).Err())
+	testCrossSlot(t, c)
+}
+
+func testCrossSlot(t *testing.T, c *Cache) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	keys := []string{"{user1}:name", "{user1}:age", "cross_slot_1", "cross_slot_2", "cross_slot_3"}
+	for _, key := range keys {
+		require.NoError(t, c.Set(ctx, key, "value", time.Minute))
+	}
+	cnt, err := c.Delete(ctx, append(keys, "cross_slot_not_exist")...)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(keys)), cnt)
+
+	p := c.Pipeline()
+	for _, key := range keys {
+		p.Set(key, "value", time.Minute)
+	}
+	del := p.Delete(keys...)
+	require.NoError(t, p.Exec(ctx))
+	cnt, err = del.Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(keys)), cnt)
+}
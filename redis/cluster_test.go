@@ -0,0 +1,131 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSlot(t *testing.T) {
+	// 测试数据来自 Redis Cluster 的规范文档
+	assert.Equal(t, uint16(0x31C3), crc16("123456789"))
+	testCases := []struct {
+		key  string
+		slot int
+	}{
+		{key: "foo", slot: 12182},
+		{key: "bar", slot: 5061},
+		{key: "{user1000}.following", slot: Slot("user1000")},
+		{key: "{user1000}.followers", slot: Slot("user1000")},
+		{key: "foo{}{bar}", slot: Slot("foo{}{bar}")},
+		{key: "foo{{bar}}zap", slot: Slot("{bar")},
+		{key: "foo{bar}{zap}", slot: 5061},
+		{key: "foo{bar", slot: Slot("foo{bar")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			assert.Equal(t, tc.slot, Slot(tc.key))
+		})
+	}
+}
+
+func TestGroupKeys(t *testing.T) {
+	keys := []string{"{user1}:a", "foo", "{user1}:b", "bar", "foo"}
+	assert.Equal(t, [][]string{keys}, groupKeys(nil, keys))
+	assert.Equal(t, [][]string{{"{user1}:a", "{user1}:b"}, {"foo", "foo"}, {"bar"}},
+		groupKeys(shardFuncOf(&redis.ClusterClient{}), keys))
+	assert.Equal(t, [][]string{{"foo"}}, groupKeys(shardFuncOf(&redis.Ring{}), []string{"foo"}))
+}
+
+func TestCache_DeleteCrossSlot(t *testing.T) {
+	var dels [][]any
+	hook := pipelineHook(func(cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			dels = append(dels, cmd.Args()[1:])
+			c := cmd.(*redis.IntCmd)
+			if cmd.Args()[1] == "bar" {
+				c.SetErr(errors.New("mock error"))
+				continue
+			}
+			c.SetVal(int64(len(cmd.Args()) - 1))
+		}
+		return nil
+	})
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"localhost:0"}})
+	cluster.AddHook(hook)
+	ring := redis.NewRing(&redis.RingOptions{Addrs: map[string]string{"shard1": "localhost:0"}})
+	ring.AddHook(hook)
+	ctx := context.Background()
+
+	for name, c := range map[string]*Cache{"cluster": NewCache(cluster), "ring": NewCache(ring)} {
+		t.Run(name, func(t *testing.T) {
+			dels = nil
+			cnt, err := c.Delete(ctx, "{user1}:a", "foo", "{user1}:b")
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), cnt)
+			assert.Equal(t, [][]any{{"{user1}:a", "{user1}:b"}, {"foo"}}, dels)
+
+			// 部分失败的时候返回成功删除的数量和错误
+			cnt, err = c.Delete(ctx, "foo", "bar")
+			assert.Equal(t, errors.New("mock error"), err)
+			assert.Equal(t, int64(1), cnt)
+
+			dels = nil
+			p := c.Pipeline()
+			del := p.Delete("{user1}:a", "foo", "{user1}:b")
+			require.NoError(t, p.Exec(ctx))
+			cnt, err = del.Result()
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), cnt)
+			assert.Equal(t, [][]any{{"{user1}:a", "{user1}:b"}, {"foo"}}, dels)
+		})
+	}
+}
+
+// TestCache_CrossSlot 没办法拆分的多 key 命令在客户端检查 key 是否在同一个分片上
+func TestCache_CrossSlot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	for _, shard := range []shardFunc{shardFuncOf(&redis.ClusterClient{}), shardFuncOf(&redis.Ring{})} {
+		c := &Cache{client: cmd, shard: shard}
+		ctx := context.Background()
+
+		_, err := c.PFCount(ctx, "foo", "bar")
+		assert.Equal(t, ErrCrossSlot, err)
+		assert.Equal(t, ErrCrossSlot, c.PFMerge(ctx, "dest", "foo"))
+		_, err = c.BitOp(ctx, ecache.BitOpAnd, "dest", "foo", "bar")
+		assert.Equal(t, ErrCrossSlot, err)
+		assert.Equal(t, ErrCrossSlot, c.LMove(ctx, "foo", "bar", ecache.ListLeft, ecache.ListRight).Err)
+		assert.Equal(t, ErrCrossSlot, c.RPopLPush(ctx, "foo", "bar").Err)
+		assert.Equal(t, ErrCrossSlot, c.BLMove(ctx, "foo", "bar", ecache.ListLeft, ecache.ListRight, time.Second).Err)
+
+		// 使用相同的 hash tag 之后可以正常执行
+		cmd.EXPECT().PFCount(ctx, "{user1}:a", "{user1}:b").Return(redis.NewIntResult(3, nil))
+		cnt, err := c.PFCount(ctx, "{user1}:a", "{user1}:b")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), cnt)
+	}
+}
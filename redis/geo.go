@@ -0,0 +1,93 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ ecache.GeoCache = (*Cache)(nil)
+
+func (c *Cache) GeoAdd(ctx context.Context, key string, locations ...ecache.GeoLocation) (int64, error) {
+	geoLocations := make([]*redis.GeoLocation, 0, len(locations))
+	for _, loc := range locations {
+		geoLocations = append(geoLocations, &redis.GeoLocation{
+			Name:      loc.Member,
+			Longitude: loc.Longitude,
+			Latitude:  loc.Latitude,
+		})
+	}
+	return c.client.GeoAdd(ctx, key, geoLocations...).Result()
+}
+
+func (c *Cache) GeoDist(ctx context.Context, key string, member1, member2 string, unit ecache.GeoUnit) (float64, error) {
+	dist, err := c.client.GeoDist(ctx, key, member1, member2, string(geoUnit(unit))).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, errs.ErrKeyNotExist
+	}
+	return dist, err
+}
+
+func (c *Cache) GeoSearch(ctx context.Context, key string, query ecache.GeoSearchQuery) ([]ecache.GeoLocation, error) {
+	unit := string(geoUnit(query.Unit))
+	q := &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Member:     query.Member,
+			Longitude:  query.Longitude,
+			Latitude:   query.Latitude,
+			Radius:     query.Radius,
+			RadiusUnit: unit,
+			BoxWidth:   query.Width,
+			BoxHeight:  query.Height,
+			BoxUnit:    unit,
+			Sort:       string(query.Sort),
+			Count:      query.Count,
+		},
+		WithCoord: true,
+		WithDist:  true,
+	}
+	locations, err := c.client.GeoSearchLocation(ctx, key, q).Result()
+	if err != nil {
+		// 以成员为中心但是成员不存在
+		if strings.Contains(err.Error(), "could not decode requested zset member") {
+			return nil, errs.ErrKeyNotExist
+		}
+		return nil, err
+	}
+	res := make([]ecache.GeoLocation, 0, len(locations))
+	for _, loc := range locations {
+		res = append(res, ecache.GeoLocation{
+			Member:    loc.Name,
+			Longitude: loc.Longitude,
+			Latitude:  loc.Latitude,
+			Dist:      loc.Dist,
+		})
+	}
+	return res, nil
+}
+
+// geoUnit 和本地缓存保持一致，默认使用米，而不是 go-redis 默认的千米
+func geoUnit(unit ecache.GeoUnit) ecache.GeoUnit {
+	if unit == "" {
+		return ecache.GeoUnitM
+	}
+	return unit
+}
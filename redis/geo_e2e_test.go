@@ -0,0 +1,79 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_e2e_Geo(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	c := NewCache(rdb)
+	require.NoError(t, rdb.Del(ctx, "geo_sicily").Err())
+
+	// 本地缓存使用和 Redis 一样的编码和距离公式，结果应该一致
+	local := lru.NewCache(10)
+	locations := []ecache.GeoLocation{
+		{Member: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+		{Member: "Catania", Longitude: 15.087269, Latitude: 37.502669},
+		{Member: "edge1", Longitude: 12.758489, Latitude: 38.788135},
+		{Member: "edge2", Longitude: 17.241510, Latitude: 38.788135},
+	}
+	n, err := c.GeoAdd(ctx, "geo_sicily", locations...)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+	_, err = local.GeoAdd(ctx, "geo_sicily", locations...)
+	require.NoError(t, err)
+
+	dist, err := c.GeoDist(ctx, "geo_sicily", "Palermo", "Catania", ecache.GeoUnitKM)
+	require.NoError(t, err)
+	assert.Equal(t, 166.2742, dist)
+	_, err = c.GeoDist(ctx, "geo_sicily", "Palermo", "Rome", ecache.GeoUnitKM)
+	assert.Equal(t, errs.ErrKeyNotExist, err)
+
+	queries := []ecache.GeoSearchQuery{
+		{Longitude: 15, Latitude: 37, Radius: 200, Unit: ecache.GeoUnitKM, Sort: ecache.GeoSortAsc},
+		{Longitude: 15, Latitude: 37, Width: 400, Height: 400, Unit: ecache.GeoUnitKM, Sort: ecache.GeoSortDesc},
+		{Member: "Palermo", Radius: 100000, Count: 1},
+	}
+	for _, q := range queries {
+		res, err := c.GeoSearch(ctx, "geo_sicily", q)
+		require.NoError(t, err)
+		localRes, err := local.GeoSearch(ctx, "geo_sicily", q)
+		require.NoError(t, err)
+		require.Equal(t, len(res), len(localRes))
+		for i := range res {
+			assert.Equal(t, res[i].Member, localRes[i].Member)
+			assert.Equal(t, res[i].Dist, localRes[i].Dist)
+			assert.InDelta(t, res[i].Longitude, localRes[i].Longitude, 1e-9)
+			assert.InDelta(t, res[i].Latitude, localRes[i].Latitude, 1e-9)
+		}
+	}
+	_, err = c.GeoSearch(ctx, "geo_sicily", ecache.GeoSearchQuery{Member: "Rome", Radius: 100})
+	assert.Equal(t, errs.ErrKeyNotExist, err)
+}
@@ -0,0 +1,171 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCache_GeoAdd(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := NewCache(cmd)
+	ctx := context.Background()
+
+	cmd.EXPECT().GeoAdd(ctx, "Sicily",
+		&redis.GeoLocation{Name: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+		&redis.GeoLocation{Name: "Catania", Longitude: 15.087269, Latitude: 37.502669},
+	).Return(redis.NewIntResult(2, nil))
+	n, err := c.GeoAdd(ctx, "Sicily",
+		ecache.GeoLocation{Member: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+		ecache.GeoLocation{Member: "Catania", Longitude: 15.087269, Latitude: 37.502669})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
+
+func TestCache_GeoDist(t *testing.T) {
+	testCases := []struct {
+		name     string
+		unit     ecache.GeoUnit
+		mock     func(cmd *mocks.MockCmdable)
+		wantDist float64
+		wantErr  error
+	}{
+		{
+			name: "km",
+			unit: ecache.GeoUnitKM,
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().GeoDist(gomock.Any(), "Sicily", "Palermo", "Catania", "km").
+					Return(redis.NewFloatResult(166.2742, nil))
+			},
+			wantDist: 166.2742,
+		},
+		{
+			name: "default unit",
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().GeoDist(gomock.Any(), "Sicily", "Palermo", "Catania", "m").
+					Return(redis.NewFloatResult(166274.1516, nil))
+			},
+			wantDist: 166274.1516,
+		},
+		{
+			name: "member not exist",
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().GeoDist(gomock.Any(), "Sicily", "Palermo", "Catania", "m").
+					Return(redis.NewFloatResult(0, redis.Nil))
+			},
+			wantErr: errs.ErrKeyNotExist,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			cmd := mocks.NewMockCmdable(ctrl)
+			tc.mock(cmd)
+			dist, err := NewCache(cmd).GeoDist(context.Background(), "Sicily", "Palermo", "Catania", tc.unit)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantDist, dist)
+		})
+	}
+}
+
+func TestCache_GeoSearch(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   ecache.GeoSearchQuery
+		mock    func(cmd *mocks.MockCmdable)
+		want    []ecache.GeoLocation
+		wantErr error
+	}{
+		{
+			name: "radius",
+			query: ecache.GeoSearchQuery{Longitude: 15, Latitude: 37,
+				Radius: 200, Unit: ecache.GeoUnitKM, Sort: ecache.GeoSortAsc, Count: 1},
+			mock: func(cmd *mocks.MockCmdable) {
+				res := redis.NewGeoSearchLocationCmd(context.Background(), nil)
+				res.SetVal([]redis.GeoLocation{
+					{Name: "Catania", Longitude: 15.087269, Latitude: 37.502669, Dist: 56.4413},
+				})
+				cmd.EXPECT().GeoSearchLocation(gomock.Any(), "Sicily", &redis.GeoSearchLocationQuery{
+					GeoSearchQuery: redis.GeoSearchQuery{Longitude: 15, Latitude: 37,
+						Radius: 200, RadiusUnit: "km", BoxUnit: "km", Sort: "ASC", Count: 1},
+					WithCoord: true,
+					WithDist:  true,
+				}).Return(res)
+			},
+			want: []ecache.GeoLocation{
+				{Member: "Catania", Longitude: 15.087269, Latitude: 37.502669, Dist: 56.4413},
+			},
+		},
+		{
+			name:  "box",
+			query: ecache.GeoSearchQuery{Member: "Palermo", Width: 400, Height: 200},
+			mock: func(cmd *mocks.MockCmdable) {
+				res := redis.NewGeoSearchLocationCmd(context.Background(), nil)
+				res.SetVal([]redis.GeoLocation{})
+				cmd.EXPECT().GeoSearchLocation(gomock.Any(), "Sicily", &redis.GeoSearchLocationQuery{
+					GeoSearchQuery: redis.GeoSearchQuery{Member: "Palermo",
+						RadiusUnit: "m", BoxWidth: 400, BoxHeight: 200, BoxUnit: "m"},
+					WithCoord: true,
+					WithDist:  true,
+				}).Return(res)
+			},
+			want: []ecache.GeoLocation{},
+		},
+		{
+			name:  "member not exist",
+			query: ecache.GeoSearchQuery{Member: "Rome", Radius: 100},
+			mock: func(cmd *mocks.MockCmdable) {
+				res := redis.NewGeoSearchLocationCmd(context.Background(), nil)
+				res.SetErr(errors.New("ERR could not decode requested zset member"))
+				cmd.EXPECT().GeoSearchLocation(gomock.Any(), "Sicily", gomock.Any()).Return(res)
+			},
+			wantErr: errs.ErrKeyNotExist,
+		},
+		{
+			name:  "wrong type",
+			query: ecache.GeoSearchQuery{Member: "Rome", Radius: 100},
+			mock: func(cmd *mocks.MockCmdable) {
+				res := redis.NewGeoSearchLocationCmd(context.Background(), nil)
+				res.SetErr(errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"))
+				cmd.EXPECT().GeoSearchLocation(gomock.Any(), "Sicily", gomock.Any()).Return(res)
+			},
+			wantErr: errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			cmd := mocks.NewMockCmdable(ctrl)
+			tc.mock(cmd)
+			res, err := NewCache(cmd).GeoSearch(context.Background(), "Sicily", tc.query)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, res)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+
+	"github.com/ecodeclub/ecache"
+)
+
+var _ ecache.HyperLogLogCache = (*Cache)(nil)
+
+func (c *Cache) PFAdd(ctx context.Context, key string, els ...any) (int64, error) {
+	return c.client.PFAdd(ctx, key, els...).Result()
+}
+
+func (c *Cache) PFCount(ctx context.Context, keys ...string) (int64, error) {
+	if err := c.sameShard(keys...); err != nil {
+		return 0, err
+	}
+	return c.client.PFCount(ctx, keys...).Result()
+}
+
+func (c *Cache) PFMerge(ctx context.Context, dest string, keys ...string) error {
+	if err := c.sameShard(append([]string{dest}, keys...)...); err != nil {
+		return err
+	}
+	return c.client.PFMerge(ctx, dest, keys...).Err()
+}
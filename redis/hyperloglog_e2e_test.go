@@ -0,0 +1,68 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_e2e_HyperLogLog(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	c := NewCache(rdb)
+	require.NoError(t, rdb.Del(ctx, "hll_uv1", "hll_uv2", "hll_dest").Err())
+
+	// 本地缓存使用相同的算法，估算的误差应该在同一个范围内
+	local := lru.NewCache(10)
+	for i := 0; i < 10000; i++ {
+		_, err := c.PFAdd(ctx, "hll_uv1", "user:"+strconv.Itoa(i))
+		require.NoError(t, err)
+		_, err = local.PFAdd(ctx, "hll_uv1", "user:"+strconv.Itoa(i))
+		require.NoError(t, err)
+	}
+	changed, err := c.PFAdd(ctx, "hll_uv1", "user:1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), changed)
+	_, err = c.PFAdd(ctx, "hll_uv2", "user:1", "user:10000")
+	require.NoError(t, err)
+
+	cnt, err := c.PFCount(ctx, "hll_uv1")
+	require.NoError(t, err)
+	assert.InDelta(t, 10000, cnt, 10000*0.0081*3)
+	localCnt, err := local.PFCount(ctx, "hll_uv1")
+	require.NoError(t, err)
+	assert.InDelta(t, cnt, localCnt, 10000*0.0081*3)
+
+	require.NoError(t, c.PFMerge(ctx, "hll_dest", "hll_uv1", "hll_uv2"))
+	merged, err := c.PFCount(ctx, "hll_dest")
+	require.NoError(t, err)
+	union, err := c.PFCount(ctx, "hll_uv1", "hll_uv2")
+	require.NoError(t, err)
+	assert.Equal(t, union, merged)
+
+	_, err = c.Delete(ctx, "hll_uv1", "hll_uv2", "hll_dest")
+	require.NoError(t, err)
+}
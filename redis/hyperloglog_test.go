@@ -0,0 +1,51 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCache_HyperLogLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := NewCache(cmd)
+	ctx := context.Background()
+
+	cmd.EXPECT().PFAdd(ctx, "uv", "a", 1).Return(redis.NewIntResult(1, nil))
+	changed, err := c.PFAdd(ctx, "uv", "a", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), changed)
+
+	cmd.EXPECT().PFCount(ctx, "uv", "uv2").Return(redis.NewIntResult(2, nil))
+	cnt, err := c.PFCount(ctx, "uv", "uv2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+
+	cmd.EXPECT().PFMerge(ctx, "dest", "uv", "uv2").Return(redis.NewStatusResult("OK", nil))
+	require.NoError(t, c.PFMerge(ctx, "dest", "uv", "uv2"))
+	cmd.EXPECT().PFMerge(ctx, "dest", "uv").
+		Return(redis.NewStatusResult("", errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")))
+	assert.Equal(t, errors.New("WRONGTYPE Key is not a valid HyperLogLog string value."), c.PFMerge(ctx, "dest", "uv"))
+}
@@ -0,0 +1,198 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/hashtag"
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyEvent 键空间事件的类型
+type KeyEvent string
+
+const (
+	// KeyEventExpired key 过期了
+	KeyEventExpired KeyEvent = "expired"
+	// KeyEventEvicted key 因为内存不足被淘汰了
+	KeyEventEvicted KeyEvent = "evicted"
+	// KeyEventDel key 被 DEL 命令删除了
+	KeyEventDel KeyEvent = "del"
+)
+
+// keyEventFlags 开启各个事件需要的 notify-keyspace-events 配置
+var keyEventFlags = map[KeyEvent]string{
+	KeyEventExpired: "x",
+	KeyEventEvicted: "e",
+	KeyEventDel:     "g",
+}
+
+// KeyspaceClient 监听键空间通知需要用到的客户端，例如 *redis.Client
+type KeyspaceClient interface {
+	Subscriber
+	ConfigGet(ctx context.Context, parameter string) *redis.MapStringStringCmd
+	ConfigSet(ctx context.Context, parameter, value string) *redis.StatusCmd
+}
+
+// KeyspaceListener 监听 __keyevent@<db>__:expired、evicted 和 del 通知，
+// 并且通过和 lru.EvictCallback 一样的回调通知出去。
+// Redis 的通知里面没有值，所以回调中的 value 是事件的类型 KeyEvent。
+// 注意 Redis 的通知是不可靠的，断线期间的事件会丢失
+type KeyspaceListener struct {
+	client    KeyspaceClient
+	callback  func(key string, value any)
+	db        int
+	events    []KeyEvent
+	namespace string
+	hashTag   bool
+	config    bool
+
+	subscribe func(ctx context.Context, channels ...string) (ecache.Subscription, error)
+	sub       ecache.Subscription
+	done      chan struct{}
+}
+
+// WithKeyEvents 设置需要监听的事件，默认监听 expired、evicted 和 del
+func WithKeyEvents(events ...KeyEvent) option.Option[KeyspaceListener] {
+	return func(l *KeyspaceListener) {
+		l.events = events
+	}
+}
+
+// WithKeyspaceDB 设置监听的数据库。
+// 默认情况下如果 client 是 *redis.Client，那么使用它连接的数据库，否则是 0
+func WithKeyspaceDB(db int) option.Option[KeyspaceListener] {
+	return func(l *KeyspaceListener) {
+		l.db = db
+	}
+}
+
+// WithKeyspaceNamespace 只通知以 namespace 开头的 key，并且回调中的 key 会去掉 namespace，
+// 一般和 ecache.NamespaceCache 的 Namespace 保持一致
+func WithKeyspaceNamespace(namespace string) option.Option[KeyspaceListener] {
+	return func(l *KeyspaceListener) {
+		l.namespace = namespace
+	}
+}
+
+// WithKeyspaceHashTag 把 namespace 当作 hash tag，也就是只通知以 {namespace} 开头的 key，
+// 和 ecache.NamespaceCache 的 HashTag 保持一致
+func WithKeyspaceHashTag() option.Option[KeyspaceListener] {
+	return func(l *KeyspaceListener) {
+		l.hashTag = true
+	}
+}
+
+// WithoutKeyspaceConfig 不通过 CONFIG SET 开启 notify-keyspace-events。
+// 一些云服务禁用了 CONFIG 命令，这个时候需要在控制台上手动开启
+func WithoutKeyspaceConfig() option.Option[KeyspaceListener] {
+	return func(l *KeyspaceListener) {
+		l.config = false
+	}
+}
+
+func NewKeyspaceListener(client KeyspaceClient, callback func(key string, value any),
+	opts ...option.Option[KeyspaceListener]) *KeyspaceListener {
+	res := &KeyspaceListener{
+		client:   client,
+		callback: callback,
+		events:   []KeyEvent{KeyEventExpired, KeyEventEvicted, KeyEventDel},
+		config:   true,
+		done:     make(chan struct{}),
+	}
+	if c, ok := client.(interface{ Options() *redis.Options }); ok {
+		res.db = c.Options().DB
+	}
+	res.subscribe = NewPubSub(client).Subscribe
+	option.Apply(res, opts...)
+	return res
+}
+
+// Start 开启通知并且订阅，之后在后台调用回调，直到 Close
+func (l *KeyspaceListener) Start(ctx context.Context) error {
+	if l.config {
+		if err := l.enable(ctx); err != nil {
+			return err
+		}
+	}
+	channels := make([]string, 0, len(l.events))
+	for _, event := range l.events {
+		channels = append(channels, fmt.Sprintf("__keyevent@%d__:%s", l.db, event))
+	}
+	sub, err := l.subscribe(ctx, channels...)
+	if err != nil {
+		return err
+	}
+	l.sub = sub
+	go l.run()
+	return nil
+}
+
+// enable 在已有的配置上加上需要的标记，不会覆盖其他人开启的通知
+func (l *KeyspaceListener) enable(ctx context.Context) error {
+	cfg, err := l.client.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return err
+	}
+	flags := cfg["notify-keyspace-events"]
+	res := flags
+	if !strings.Contains(res, "E") {
+		res += "E"
+	}
+	for _, event := range l.events {
+		flag := keyEventFlags[event]
+		// A 是 g$lshzxetd 的别名
+		if !strings.Contains(res, flag) && !strings.Contains(res, "A") {
+			res += flag
+		}
+	}
+	if res == flags {
+		return nil
+	}
+	return l.client.ConfigSet(ctx, "notify-keyspace-events", res).Err()
+}
+
+func (l *KeyspaceListener) run() {
+	defer close(l.done)
+	prefix := hashtag.Prefix(l.namespace, l.hashTag)
+	for {
+		msg, err := l.sub.Next(context.Background())
+		if err != nil {
+			// 只有关闭订阅才会返回错误
+			return
+		}
+		key, ok := strings.CutPrefix(msg.Payload, prefix)
+		if !ok {
+			continue
+		}
+		idx := strings.LastIndexByte(msg.Channel, ':')
+		l.callback(key, KeyEvent(msg.Channel[idx+1:]))
+	}
+}
+
+// Close 停止监听，并且等待正在执行的回调结束。不会关闭已经开启的通知
+func (l *KeyspaceListener) Close() error {
+	if l.sub == nil {
+		return nil
+	}
+	err := l.sub.Close()
+	<-l.done
+	return err
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyspaceListener_e2e(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	var mu sync.Mutex
+	got := make(map[string]any)
+	l := NewKeyspaceListener(rdb, func(key string, value any) {
+		mu.Lock()
+		defer mu.Unlock()
+		got[key] = value
+	}, WithKeyspaceNamespace("e2e_keyspace:"))
+	require.NoError(t, l.Start(ctx))
+	defer func() {
+		assert.NoError(t, l.Close())
+	}()
+
+	c := &ecache.NamespaceCache{C: NewCache(rdb), Namespace: "e2e_keyspace:"}
+	require.NoError(t, c.Set(ctx, "expired", "value", time.Millisecond*100))
+	require.NoError(t, c.Set(ctx, "deleted", "value", time.Minute))
+	_, err := c.Delete(ctx, "deleted")
+	require.NoError(t, err)
+	require.NoError(t, rdb.Set(ctx, "e2e_other", "value", time.Millisecond*100).Err())
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 2
+	}, time.Second*5, time.Millisecond*50)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]any{
+		"expired": KeyEventExpired,
+		"deleted": KeyEventDel,
+	}, got)
+}
@@ -0,0 +1,203 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/memory/pubsub"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestKeyspaceListener_Start(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) *mocks.MockCmdable
+		opts []option.Option[KeyspaceListener]
+
+		wantErr error
+	}{
+		{
+			name: "enable notifications",
+			mock: func(ctrl *gomock.Controller) *mocks.MockCmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().ConfigGet(gomock.Any(), "notify-keyspace-events").
+					Return(configCmd(""))
+				cmd.EXPECT().ConfigSet(gomock.Any(), "notify-keyspace-events", "Exeg").
+					Return(redis.NewStatusResult("OK", nil))
+				return cmd
+			},
+		},
+		{
+			name: "keep existing flags",
+			mock: func(ctrl *gomock.Controller) *mocks.MockCmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().ConfigGet(gomock.Any(), "notify-keyspace-events").
+					Return(configCmd("Kl"))
+				cmd.EXPECT().ConfigSet(gomock.Any(), "notify-keyspace-events", "KlEx").
+					Return(redis.NewStatusResult("OK", nil))
+				return cmd
+			},
+			opts: []option.Option[KeyspaceListener]{WithKeyEvents(KeyEventExpired)},
+		},
+		{
+			name: "already enabled",
+			mock: func(ctrl *gomock.Controller) *mocks.MockCmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().ConfigGet(gomock.Any(), "notify-keyspace-events").
+					Return(configCmd("AE"))
+				return cmd
+			},
+		},
+		{
+			name: "without config",
+			mock: func(ctrl *gomock.Controller) *mocks.MockCmdable {
+				return mocks.NewMockCmdable(ctrl)
+			},
+			opts: []option.Option[KeyspaceListener]{WithoutKeyspaceConfig()},
+		},
+		{
+			name: "config get error",
+			mock: func(ctrl *gomock.Controller) *mocks.MockCmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewMapStringStringCmd(context.Background())
+				res.SetErr(errors.New("ERR unknown command 'CONFIG'"))
+				cmd.EXPECT().ConfigGet(gomock.Any(), "notify-keyspace-events").Return(res)
+				return cmd
+			},
+			wantErr: errors.New("ERR unknown command 'CONFIG'"),
+		},
+		{
+			name: "config set error",
+			mock: func(ctrl *gomock.Controller) *mocks.MockCmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().ConfigGet(gomock.Any(), "notify-keyspace-events").
+					Return(configCmd(""))
+				cmd.EXPECT().ConfigSet(gomock.Any(), "notify-keyspace-events", "Exeg").
+					Return(redis.NewStatusResult("", errors.New("network error")))
+				return cmd
+			},
+			wantErr: errors.New("network error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			l := NewKeyspaceListener(&fakeSubscriber{MockCmdable: tc.mock(ctrl)},
+				func(key string, value any) {}, tc.opts...)
+			l.subscribe = pubsub.NewPubSub().Subscribe
+			err := l.Start(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, l.Close())
+		})
+	}
+}
+
+func TestKeyspaceListener_Callback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	ps := pubsub.NewPubSub()
+
+	var mu sync.Mutex
+	got := make(map[string]any)
+	l := NewKeyspaceListener(&fakeSubscriber{MockCmdable: mocks.NewMockCmdable(ctrl)},
+		func(key string, value any) {
+			mu.Lock()
+			defer mu.Unlock()
+			got[key] = value
+		}, WithoutKeyspaceConfig(), WithKeyspaceDB(2), WithKeyspaceNamespace("user:"))
+	l.subscribe = ps.Subscribe
+	require.NoError(t, l.Start(ctx))
+
+	_, err := ps.Publish(ctx, "__keyevent@2__:expired", "user:1")
+	require.NoError(t, err)
+	_, err = ps.Publish(ctx, "__keyevent@2__:evicted", "user:2")
+	require.NoError(t, err)
+	_, err = ps.Publish(ctx, "__keyevent@2__:del", "user:3")
+	require.NoError(t, err)
+	// 其他 namespace 的
+	_, err = ps.Publish(ctx, "__keyevent@2__:del", "order:1")
+	require.NoError(t, err)
+	// 其他数据库的
+	_, err = ps.Publish(ctx, "__keyevent@0__:del", "user:4")
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 3
+	}, time.Second, time.Millisecond*10)
+	require.NoError(t, l.Close())
+	assert.Equal(t, map[string]any{
+		"1": KeyEventExpired,
+		"2": KeyEventEvicted,
+		"3": KeyEventDel,
+	}, got)
+}
+
+func TestKeyspaceListener_HashTag(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	ps := pubsub.NewPubSub()
+
+	keys := make(chan string, 10)
+	l := NewKeyspaceListener(&fakeSubscriber{MockCmdable: mocks.NewMockCmdable(ctrl)},
+		func(key string, value any) {
+			keys <- key
+		}, WithoutKeyspaceConfig(), WithKeyspaceNamespace("user:"), WithKeyspaceHashTag())
+	l.subscribe = ps.Subscribe
+	require.NoError(t, l.Start(ctx))
+
+	// 不带 hash tag 的和其他 namespace 的都会被忽略
+	_, err := ps.Publish(ctx, "__keyevent@0__:del", "user:1")
+	require.NoError(t, err)
+	_, err = ps.Publish(ctx, "__keyevent@0__:del", "{order:}1")
+	require.NoError(t, err)
+	_, err = ps.Publish(ctx, "__keyevent@0__:del", "{user:}2")
+	require.NoError(t, err)
+
+	select {
+	case key := <-keys:
+		assert.Equal(t, "2", key)
+	case <-time.After(time.Second):
+		t.Fatal("没有收到 {user:}2 的通知")
+	}
+	require.NoError(t, l.Close())
+	assert.Empty(t, keys)
+}
+
+func TestNewKeyspaceListener_DB(t *testing.T) {
+	l := NewKeyspaceListener(redis.NewClient(&redis.Options{DB: 3}), func(key string, value any) {})
+	assert.Equal(t, 3, l.db)
+	assert.NoError(t, l.Close())
+}
+
+func configCmd(flags string) *redis.MapStringStringCmd {
+	cmd := redis.NewMapStringStringCmd(context.Background())
+	cmd.SetVal(map[string]string{"notify-keyspace-events": flags})
+	return cmd
+}
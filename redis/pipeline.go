@@ -0,0 +1,163 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ ecache.Pipeliner = (*Cache)(nil)
+	_ ecache.Pipeline  = (*pipeline)(nil)
+)
+
+// pipeline 基于 go-redis 的 Pipeliner 实现，所有的操作只需要一次网络往返
+type pipeline struct {
+	pipe  redis.Pipeliner
+	shard shardFunc
+	// resolves 在 Exec 之后把每个命令的结果设置到对应的 Future 里面
+	resolves []func() error
+}
+
+// Pipeline 创建一个 Pipeline，排队的操作通过 Exec 一次性发送给 Redis。
+// 注意这并不是事务，其它客户端的命令可能会穿插在其中执行
+func (c *Cache) Pipeline() ecache.Pipeline {
+	return &pipeline{pipe: c.client.Pipeline(), shard: c.shard}
+}
+
+func (p *pipeline) Set(key string, val any, expiration time.Duration) *ecache.Future[struct{}] {
+	cmd := p.pipe.Set(context.Background(), key, val, expiration)
+	f := &ecache.Future[struct{}]{}
+	p.resolves = append(p.resolves, func() error {
+		f.Resolve(struct{}{}, cmd.Err())
+		return cmd.Err()
+	})
+	return f
+}
+
+func (p *pipeline) SetNX(key string, val any, expiration time.Duration) *ecache.Future[bool] {
+	return resolveCmd[bool](p, p.pipe.SetNX(context.Background(), key, val, expiration))
+}
+
+func (p *pipeline) Get(key string) *ecache.Future[ecache.Value] {
+	return resolveValue(p, p.pipe.Get(context.Background(), key))
+}
+
+func (p *pipeline) GetSet(key string, val string) *ecache.Future[ecache.Value] {
+	return resolveValue(p, p.pipe.GetSet(context.Background(), key, val))
+}
+
+func (p *pipeline) Delete(key ...string) *ecache.Future[int64] {
+	groups := groupKeys(p.shard, key)
+	if len(groups) == 1 {
+		return resolveCmd[int64](p, p.pipe.Del(context.Background(), key...))
+	}
+	cmds := make([]*redis.IntCmd, 0, len(groups))
+	for _, group := range groups {
+		cmds = append(cmds, p.pipe.Del(context.Background(), group...))
+	}
+	f := &ecache.Future[int64]{}
+	p.resolves = append(p.resolves, func() error {
+		cnt, err := sumIntCmds(cmds)
+		f.Resolve(cnt, err)
+		return err
+	})
+	return f
+}
+
+func (p *pipeline) LPush(key string, val ...any) *ecache.Future[int64] {
+	return resolveCmd[int64](p, p.pipe.LPush(context.Background(), key, val...))
+}
+
+func (p *pipeline) LPop(key string) *ecache.Future[ecache.Value] {
+	return resolveValue(p, p.pipe.LPop(context.Background(), key))
+}
+
+func (p *pipeline) SAdd(key string, members ...any) *ecache.Future[int64] {
+	return resolveCmd[int64](p, p.pipe.SAdd(context.Background(), key, members...))
+}
+
+func (p *pipeline) SRem(key string, members ...any) *ecache.Future[int64] {
+	return resolveCmd[int64](p, p.pipe.SRem(context.Background(), key, members...))
+}
+
+func (p *pipeline) IncrBy(key string, value int64) *ecache.Future[int64] {
+	return resolveCmd[int64](p, p.pipe.IncrBy(context.Background(), key, value))
+}
+
+func (p *pipeline) DecrBy(key string, value int64) *ecache.Future[int64] {
+	return resolveCmd[int64](p, p.pipe.DecrBy(context.Background(), key, value))
+}
+
+func (p *pipeline) IncrByFloat(key string, value float64) *ecache.Future[float64] {
+	return resolveCmd[float64](p, p.pipe.IncrByFloat(context.Background(), key, value))
+}
+
+func (p *pipeline) Exec(ctx context.Context) error {
+	if len(p.resolves) == 0 {
+		return nil
+	}
+	// 每个命令的错误都记录在命令自身上，resolve 的时候统一处理
+	_, _ = p.pipe.Exec(ctx)
+	return p.resolve()
+}
+
+// resolve 把命令的结果设置到 Future 里面，返回第一个错误，但是 key 不存在不算错误
+func (p *pipeline) resolve() error {
+	resolves := p.resolves
+	p.resolves = nil
+	var firstErr error
+	for _, resolve := range resolves {
+		if err := resolve(); err != nil && firstErr == nil && !errors.Is(err, errs.ErrKeyNotExist) {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// resultCmd go-redis 中返回单个结果的命令
+type resultCmd[T any] interface {
+	Result() (T, error)
+}
+
+func resolveCmd[T any](p *pipeline, cmd resultCmd[T]) *ecache.Future[T] {
+	f := &ecache.Future[T]{}
+	p.resolves = append(p.resolves, func() error {
+		val, err := cmd.Result()
+		f.Resolve(val, err)
+		return err
+	})
+	return f
+}
+
+func resolveValue(p *pipeline, cmd *redis.StringCmd) *ecache.Future[ecache.Value] {
+	f := &ecache.Future[ecache.Value]{}
+	p.resolves = append(p.resolves, func() error {
+		var val ecache.Value
+		val.Val, val.Err = cmd.Result()
+		if val.Err != nil && errors.Is(val.Err, redis.Nil) {
+			val.Err = errs.ErrKeyNotExist
+		}
+		f.Resolve(val, val.Err)
+		return val.Err
+	})
+	return f
+}
@@ -0,0 +1,47 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_e2e_Pipeline(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	p := NewCache(rdb).Pipeline()
+	set := p.Set("pipeline_name", "大明", time.Minute)
+	get := p.Get("pipeline_name")
+	miss := p.Get("pipeline_not_exist")
+	incr := p.IncrBy("pipeline_cnt", 2)
+	del := p.Delete("pipeline_name", "pipeline_cnt")
+	require.NoError(t, p.Exec(ctx))
+
+	assert.NoError(t, set.Err())
+	assert.Equal(t, "大明", get.Val().Val)
+	assert.True(t, miss.Val().KeyNotFound())
+	assert.Equal(t, int64(2), incr.Val())
+	assert.Equal(t, int64(2), del.Val())
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Pipeline(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	var names []string
+	rdb.AddHook(pipelineHook(func(cmds []redis.Cmder) error {
+		names = names[:0]
+		for _, cmd := range cmds {
+			names = append(names, cmd.Name())
+			switch c := cmd.(type) {
+			case *redis.StatusCmd:
+				c.SetVal("OK")
+			case *redis.BoolCmd:
+				c.SetVal(true)
+			case *redis.StringCmd:
+				if c.Args()[1] == "not-exist" {
+					c.SetErr(redis.Nil)
+					continue
+				}
+				c.SetVal("大明")
+			case *redis.IntCmd:
+				c.SetVal(2)
+			case *redis.FloatCmd:
+				c.SetVal(1.5)
+			}
+		}
+		return nil
+	}))
+	c := NewCache(rdb)
+	ctx := context.Background()
+
+	p := c.Pipeline()
+	set := p.Set("name", "大明", time.Minute)
+	setNX := p.SetNX("name", "大明", time.Minute)
+	get := p.Get("name")
+	miss := p.Get("not-exist")
+	getSet := p.GetSet("name", "小明")
+	del := p.Delete("name")
+	push := p.LPush("list", "a")
+	pop := p.LPop("list")
+	sadd := p.SAdd("set", "a")
+	srem := p.SRem("set", "a")
+	incr := p.IncrBy("cnt", 2)
+	decr := p.DecrBy("cnt", 2)
+	incrFloat := p.IncrByFloat("price", 1.5)
+	assert.Equal(t, errs.ErrPipelineNotExecuted, set.Err())
+
+	require.NoError(t, p.Exec(ctx))
+	assert.Equal(t, []string{"set", "set", "get", "get", "getset", "del", "lpush",
+		"lpop", "sadd", "srem", "incrby", "decrby", "incrbyfloat"}, names)
+	assert.NoError(t, set.Err())
+	assert.True(t, setNX.Val())
+	assert.Equal(t, "大明", get.Val().Val)
+	assert.True(t, miss.Val().KeyNotFound())
+	assert.Equal(t, "大明", getSet.Val().Val)
+	assert.Equal(t, "大明", pop.Val().Val)
+	for _, f := range []interface{ Val() int64 }{del, push, sadd, srem, incr, decr} {
+		assert.Equal(t, int64(2), f.Val())
+	}
+	assert.Equal(t, 1.5, incrFloat.Val())
+
+	// 没有排队的操作
+	names = nil
+	require.NoError(t, p.Exec(ctx))
+	assert.Nil(t, names)
+}
+
+func TestCache_PipelineError(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	rdb.AddHook(pipelineHook(func(cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			cmd.SetErr(context.DeadlineExceeded)
+		}
+		return context.DeadlineExceeded
+	}))
+	p := NewCache(rdb).Pipeline()
+	set := p.Set("name", "大明", time.Minute)
+	get := p.Get("name")
+	assert.Equal(t, context.DeadlineExceeded, p.Exec(context.Background()))
+	assert.Equal(t, context.DeadlineExceeded, set.Err())
+	assert.True(t, errors.Is(get.Err(), context.DeadlineExceeded))
+}
+
+// pipelineHook 拦截 pipeline 的执行，不会真的发送给 Redis
+type pipelineHook func(cmds []redis.Cmder) error
+
+func (h pipelineHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("不应该建立连接")
+	}
+}
+
+func (h pipelineHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return next
+}
+
+func (h pipelineHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return h(cmds)
+	}
+}
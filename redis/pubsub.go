@@ -0,0 +1,107 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ ecache.PubSub       = (*PubSub)(nil)
+	_ ecache.Subscription = (*subscription)(nil)
+)
+
+// Subscriber 支持发布订阅的客户端，例如 *redis.Client、*redis.ClusterClient 和 *redis.Ring
+type Subscriber interface {
+	Publish(ctx context.Context, channel string, message any) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+	PSubscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// PubSub 基于 Redis PUBLISH/SUBSCRIBE 的实现。
+// 每个订阅都会占用一个单独的连接，所以用完之后一定要 Close
+type PubSub struct {
+	client Subscriber
+}
+
+func NewPubSub(client Subscriber) *PubSub {
+	return &PubSub{client: client}
+}
+
+func (p *PubSub) Publish(ctx context.Context, channel string, msg any) (int64, error) {
+	return p.client.Publish(ctx, channel, msg).Result()
+}
+
+func (p *PubSub) Subscribe(ctx context.Context, channels ...string) (ecache.Subscription, error) {
+	return newSubscription(ctx, p.client.Subscribe(ctx, channels...))
+}
+
+func (p *PubSub) PSubscribe(ctx context.Context, patterns ...string) (ecache.Subscription, error) {
+	return newSubscription(ctx, p.client.PSubscribe(ctx, patterns...))
+}
+
+// receiver 抽象出 *redis.PubSub 中用到的方法
+type receiver interface {
+	Receive(ctx context.Context) (any, error)
+	Channel(opts ...redis.ChannelOption) <-chan *redis.Message
+	Close() error
+}
+
+type subscription struct {
+	ps     receiver
+	msgs   <-chan *redis.Message
+	closed atomic.Bool
+}
+
+// newSubscription 等待 Redis 确认订阅之后再返回，避免丢失紧接着发布的消息。
+// 消息通过 go-redis 的 Channel 接收，它会在后台检测连接是否正常并且自动重连
+func newSubscription(ctx context.Context, ps receiver) (*subscription, error) {
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+	return &subscription{ps: ps, msgs: ps.Channel()}, nil
+}
+
+func (s *subscription) Next(ctx context.Context) (ecache.Message, error) {
+	if s.closed.Load() {
+		return ecache.Message{}, errs.ErrSubscriptionClosed
+	}
+	select {
+	case msg, ok := <-s.msgs:
+		if !ok {
+			return ecache.Message{}, errs.ErrSubscriptionClosed
+		}
+		return ecache.Message{
+			Channel: msg.Channel,
+			Pattern: msg.Pattern,
+			Payload: msg.Payload,
+		}, nil
+	case <-ctx.Done():
+		return ecache.Message{}, ctx.Err()
+	}
+}
+
+func (s *subscription) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	return s.ps.Close()
+}
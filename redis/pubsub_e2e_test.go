@@ -0,0 +1,57 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubSub_e2e(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	p := NewPubSub(rdb)
+
+	sub, err := p.Subscribe(ctx, "e2e_user.deleted")
+	require.NoError(t, err)
+	psub, err := p.PSubscribe(ctx, "e2e_user.*")
+	require.NoError(t, err)
+
+	cnt, err := p.Publish(ctx, "e2e_user.deleted", 123)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+
+	msg, err := sub.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "e2e_user.deleted", msg.Channel)
+	assert.Equal(t, "123", msg.Payload)
+	msg, err = psub.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "e2e_user.*", msg.Pattern)
+
+	require.NoError(t, sub.Close())
+	require.NoError(t, psub.Close())
+	_, err = sub.Next(ctx)
+	assert.Equal(t, errs.ErrSubscriptionClosed, err)
+}
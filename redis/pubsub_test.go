@@ -0,0 +1,117 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPubSub_Publish(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	intCmd := redis.NewIntCmd(context.Background())
+	intCmd.SetVal(2)
+	cmd.EXPECT().Publish(context.Background(), "user", "deleted").Return(intCmd)
+
+	p := NewPubSub(&fakeSubscriber{MockCmdable: cmd})
+	cnt, err := p.Publish(context.Background(), "user", "deleted")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+}
+
+func TestSubscription(t *testing.T) {
+	ctx := context.Background()
+	ps := &fakeReceiver{msgs: make(chan *redis.Message, 1)}
+	s, err := newSubscription(ctx, ps)
+	require.NoError(t, err)
+
+	ps.msgs <- &redis.Message{Channel: "user.deleted", Pattern: "user.*", Payload: "123"}
+	msg, err := s.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "user.deleted", msg.Channel)
+	assert.Equal(t, "user.*", msg.Pattern)
+	assert.Equal(t, "123", msg.Payload)
+
+	// 超时
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	_, err = s.Next(tctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Close())
+	assert.Equal(t, 1, ps.closeCnt)
+	_, err = s.Next(ctx)
+	assert.Equal(t, errs.ErrSubscriptionClosed, err)
+}
+
+func TestSubscription_ChannelClosed(t *testing.T) {
+	ps := &fakeReceiver{msgs: make(chan *redis.Message)}
+	s, err := newSubscription(context.Background(), ps)
+	require.NoError(t, err)
+	// 连接被关闭了，例如 Redis 客户端被关闭
+	close(ps.msgs)
+	_, err = s.Next(context.Background())
+	assert.Equal(t, errs.ErrSubscriptionClosed, err)
+}
+
+func TestSubscription_ReceiveError(t *testing.T) {
+	ps := &fakeReceiver{receiveErr: errors.New("network error")}
+	_, err := newSubscription(context.Background(), ps)
+	assert.Equal(t, errors.New("network error"), err)
+	assert.Equal(t, 1, ps.closeCnt)
+}
+
+type fakeSubscriber struct {
+	*mocks.MockCmdable
+}
+
+func (f *fakeSubscriber) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	panic("implement me")
+}
+
+func (f *fakeSubscriber) PSubscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	panic("implement me")
+}
+
+type fakeReceiver struct {
+	receiveErr error
+	msgs       chan *redis.Message
+	closeCnt   int
+}
+
+func (f *fakeReceiver) Receive(ctx context.Context) (any, error) {
+	return &redis.Subscription{}, f.receiveErr
+}
+
+func (f *fakeReceiver) Channel(opts ...redis.ChannelOption) <-chan *redis.Message {
+	return f.msgs
+}
+
+func (f *fakeReceiver) Close() error {
+	f.closeCnt++
+	return nil
+}
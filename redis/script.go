@@ -0,0 +1,86 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/redis/go-redis/v9"
+)
+
+// Script Lua 脚本，SHA1 在创建的时候就计算好了。
+// 一般定义成包级别的变量，被多个 Cache 共享
+type Script struct {
+	src  string
+	hash string
+}
+
+// NewScript 创建一个 Lua 脚本
+func NewScript(src string) *Script {
+	h := sha1.Sum([]byte(src))
+	return &Script{
+		src:  src,
+		hash: hex.EncodeToString(h[:]),
+	}
+}
+
+// Hash 脚本的 SHA1，也就是 EVALSHA 使用的参数
+func (s *Script) Hash() string {
+	return s.hash
+}
+
+// Source 脚本的源码
+func (s *Script) Source() string {
+	return s.src
+}
+
+// Load 通过 SCRIPT LOAD 预先加载脚本。不调用也可以，Run 会在服务端没有缓存脚本的时候自动加载
+func (c *Cache) Load(ctx context.Context, script *Script) error {
+	return c.client.ScriptLoad(ctx, script.src).Err()
+}
+
+// Run 执行脚本，优先使用 EVALSHA。
+// 如果服务端没有缓存脚本，例如 Redis 重启或者执行过 SCRIPT FLUSH，那么就退化为 EVAL，
+// EVAL 执行之后服务端就缓存了该脚本，后面的 EVALSHA 就能成功了
+func (c *Cache) Run(ctx context.Context, script *Script, keys []string, args ...any) ecache.Value {
+	val := c.EvalSha(ctx, script.hash, keys, args...)
+	if val.Err != nil && redis.HasErrorPrefix(val.Err, "NOSCRIPT") {
+		return c.Eval(ctx, script.src, keys, args...)
+	}
+	return val
+}
+
+// Eval 执行脚本。脚本返回 nil 的时候，Value.Err 是 errs.ErrKeyNotExist
+func (c *Cache) Eval(ctx context.Context, script string, keys []string, args ...any) ecache.Value {
+	return scriptValue(c.client.Eval(ctx, script, keys, args...))
+}
+
+// EvalSha 执行服务端已经缓存的脚本。脚本返回 nil 的时候，Value.Err 是 errs.ErrKeyNotExist
+func (c *Cache) EvalSha(ctx context.Context, sha string, keys []string, args ...any) ecache.Value {
+	return scriptValue(c.client.EvalSha(ctx, sha, keys, args...))
+}
+
+func scriptValue(cmd *redis.Cmd) (val ecache.Value) {
+	val.Val, val.Err = cmd.Result()
+	if val.Err != nil && errors.Is(val.Err, redis.Nil) {
+		val.Err = errs.ErrKeyNotExist
+	}
+	return
+}
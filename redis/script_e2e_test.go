@@ -0,0 +1,51 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_e2e_Run(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	c := NewCache(rdb)
+	script := NewScript(`return redis.call("INCRBY", KEYS[1], ARGV[1])`)
+
+	// 服务端没有缓存脚本的时候退化为 EVAL
+	require.NoError(t, rdb.ScriptFlush(ctx).Err())
+	val := c.Run(ctx, script, []string{"script_cnt"}, 2)
+	require.NoError(t, val.Err)
+	assert.Equal(t, int64(2), val.Val)
+	val = c.EvalSha(ctx, script.Hash(), []string{"script_cnt"}, 3)
+	require.NoError(t, val.Err)
+	assert.Equal(t, int64(5), val.Val)
+
+	val = c.Eval(ctx, `return redis.call("GET", KEYS[1])`, []string{"script_not_exist"})
+	assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+
+	_, err := rdb.Del(ctx, "script_cnt").Result()
+	require.NoError(t, err)
+}
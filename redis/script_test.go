@@ -0,0 +1,127 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+var testScript = NewScript(`return redis.call("GET", KEYS[1])`)
+
+func TestScript_Hash(t *testing.T) {
+	assert.Equal(t, "e0e1f9fabfc9d4800c877a703b823ac0578ff8db", NewScript("return 1").Hash())
+	assert.Equal(t, "return 1", NewScript("return 1").Source())
+}
+
+func TestCache_Run(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(*gomock.Controller) redis.Cmdable
+
+		wantVal any
+		wantErr error
+	}{
+		{
+			name: "evalsha",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(context.Background(), testScript.Hash(), []string{"name"}, 1).
+					Return(evalCmd("大明", nil))
+				return cmd
+			},
+			wantVal: "大明",
+		},
+		{
+			name: "noscript fallback to eval",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(context.Background(), testScript.Hash(), []string{"name"}, 1).
+					Return(evalCmd(nil, scriptError("NOSCRIPT No matching script. Please use EVAL.")))
+				cmd.EXPECT().Eval(context.Background(), testScript.Source(), []string{"name"}, 1).
+					Return(evalCmd("大明", nil))
+				return cmd
+			},
+			wantVal: "大明",
+		},
+		{
+			name: "nil result",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(context.Background(), testScript.Hash(), []string{"name"}, 1).
+					Return(evalCmd(nil, redis.Nil))
+				return cmd
+			},
+			wantErr: errs.ErrKeyNotExist,
+		},
+		{
+			name: "script error",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().EvalSha(context.Background(), testScript.Hash(), []string{"name"}, 1).
+					Return(evalCmd(nil, errors.New("ERR user_script:1: Script attempted to access nonexistent global variable")))
+				return cmd
+			},
+			wantErr: errors.New("ERR user_script:1: Script attempted to access nonexistent global variable"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCache(tc.mock(ctrl))
+			val := c.Run(context.Background(), testScript, []string{"name"}, 1)
+			assert.Equal(t, tc.wantErr, val.Err)
+			if val.Err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, val.Val)
+		})
+	}
+}
+
+func TestCache_Load(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	status := redis.NewStringCmd(context.Background())
+	status.SetVal(testScript.Hash())
+	cmd.EXPECT().ScriptLoad(context.Background(), testScript.Source()).Return(status)
+	require.NoError(t, NewCache(cmd).Load(context.Background(), testScript))
+}
+
+func evalCmd(val any, err error) *redis.Cmd {
+	cmd := redis.NewCmd(context.Background())
+	cmd.SetVal(val)
+	cmd.SetErr(err)
+	return cmd
+}
+
+// scriptError 模拟 Redis 服务端返回的错误
+type scriptError string
+
+func (e scriptError) Error() string {
+	return string(e)
+}
+
+func (scriptError) RedisError() {}
@@ -0,0 +1,264 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/internal/refresh"
+	"github.com/ecodeclub/ecache/internal/resp"
+)
+
+// envelopePrefix 用于区分信封格式的值和普通的值
+const envelopePrefix = "ecache:swr:"
+
+// envelope 软过期模式下写入 Redis 的值
+type envelope struct {
+	Val json.RawMessage `json:"v"`
+	// StaleAt 软过期时间，毫秒时间戳
+	StaleAt int64         `json:"s"`
+	SoftTTL time.Duration `json:"st"`
+	HardTTL time.Duration `json:"ht"`
+}
+
+// SoftTTLCache 在 Cache 的基础上支持软过期。
+// 通过 SetWithSoftTTL 写入的值会被包装成一个信封，里面记录了软过期时间，
+// 而硬过期时间直接使用 Redis 的过期时间。
+// 读到软过期的值时会返回旧值并且把 Value.Stale 标记为 true，同时在后台通过 loader 刷新。
+// 注意，同一个 key 只会有一个刷新任务，这个保证仅限于当前进程。
+//
+// GetSet、CompareAndSwap 以及 Pipeline 和 Watch 中的 Get、GetSet 读到的信封都会被还原成普通的值，
+// 软过期的值同样会被标记为 Stale，但是只有 Get 会触发刷新。
+// 其余的方法直接操作 Redis 里面的值，例如 Set 写入的是普通的值，会覆盖掉原来的信封
+type SoftTTLCache struct {
+	*Cache
+	loader       ecache.Loader
+	refreshGroup *refresh.Group
+}
+
+func NewSoftTTLCache(c *Cache, loader ecache.Loader) *SoftTTLCache {
+	return &SoftTTLCache{
+		Cache:        c,
+		loader:       loader,
+		refreshGroup: refresh.NewGroup(0),
+	}
+}
+
+// SetWithSoftTTL 设置一个同时带有软过期时间和硬过期时间的键值对。
+// 当 hardTTL 为 0 时，表示永不过期，否则 softTTL 必须小于 hardTTL，不然返回 ecache.ErrInvalidSoftTTL
+func (c *SoftTTLCache) SetWithSoftTTL(ctx context.Context, key string, val any, softTTL, hardTTL time.Duration) error {
+	if hardTTL > 0 && softTTL >= hardTTL {
+		return errs.ErrInvalidSoftTTL
+	}
+	data, err := encodeEnvelope(val, softTTL, hardTTL)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, key, data, hardTTL).Err()
+}
+
+// Get 返回一个 Value。
+// 如果值不是通过 SetWithSoftTTL 写入的，那么按照普通的值返回
+func (c *SoftTTLCache) Get(ctx context.Context, key string) ecache.Value {
+	val, raw, env, ok := unwrap(c.Cache.Get(ctx, key))
+	if ok && val.Stale {
+		c.refresh(key, raw, env)
+	}
+	return val
+}
+
+// GetSet 返回的旧值如果是信封，那么还原成普通的值
+func (c *SoftTTLCache) GetSet(ctx context.Context, key string, val string) ecache.Value {
+	res, _, _, _ := unwrap(c.Cache.GetSet(ctx, key, val))
+	return res
+}
+
+// CompareAndSwap old 和还原之后的值比较，相等的时候写入普通的值 new。
+// 最终的写入比较的是读出来的原始值，所以期间 key 被修改过的话返回 false
+func (c *SoftTTLCache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	val, raw, _, ok := unwrap(c.Cache.Get(ctx, key))
+	if val.KeyNotFound() {
+		return false, nil
+	}
+	if val.Err != nil {
+		return false, val.Err
+	}
+	if !ok {
+		return c.Cache.CompareAndSwap(ctx, key, old, new, expiration)
+	}
+	str, err := resp.String(old)
+	if err != nil {
+		return false, err
+	}
+	if val.Val != str {
+		return false, nil
+	}
+	return c.Cache.CompareAndSwap(ctx, key, raw, new, expiration)
+}
+
+// Pipeline 中 Get 和 GetSet 读到的信封会被还原成普通的值
+func (c *SoftTTLCache) Pipeline() ecache.Pipeline {
+	return &softTTLPipeline{Pipeline: c.Cache.Pipeline()}
+}
+
+// Watch 事务中 Get 读到的信封会被还原成普通的值
+func (c *SoftTTLCache) Watch(ctx context.Context, fn func(tx ecache.Tx) error, keys ...string) error {
+	return c.Cache.Watch(ctx, func(tx ecache.Tx) error {
+		return fn(softTTLTx{Tx: tx})
+	}, keys...)
+}
+
+// unwrap 把信封还原成普通的值，已经软过期的话把 Stale 标记为 true。
+// raw 是原始的信封，值不是信封的时候 ok 为 false
+func unwrap(val ecache.Value) (res ecache.Value, raw string, env envelope, ok bool) {
+	res = val
+	if val.Err != nil {
+		return
+	}
+	raw, _ = val.Val.(string)
+	env, ok = decodeEnvelope(raw)
+	if !ok {
+		return
+	}
+	res.Val = env.value()
+	res.Stale = time.Now().UnixMilli() >= env.StaleAt
+	return
+}
+
+// refresh 通过 loader 在后台重新加载软过期的 key，raw 是读出来的信封。
+// 只有 key 的值依旧是 raw 的时候才写入，避免加载期间被删除或者被覆盖的 key 又被写回旧数据
+func (c *SoftTTLCache) refresh(key, raw string, env envelope) {
+	if c.loader == nil {
+		return
+	}
+	c.refreshGroup.Go(key, func() {
+		ctx := context.Background()
+		val, err := c.loader(ctx, key)
+		if err != nil {
+			// 刷新失败就继续使用旧值，直到硬过期
+			return
+		}
+		data, err := encodeEnvelope(val, env.SoftTTL, env.HardTTL)
+		if err != nil {
+			return
+		}
+		_, _ = c.Cache.CompareAndSwap(ctx, key, raw, data, env.HardTTL)
+	})
+}
+
+func encodeEnvelope(val any, softTTL, hardTTL time.Duration) (string, error) {
+	// []byte 直接序列化会变成 base64，所以先转成 string
+	if bs, ok := val.([]byte); ok {
+		val = string(bs)
+	}
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(envelope{
+		Val:     raw,
+		StaleAt: time.Now().Add(softTTL).UnixMilli(),
+		SoftTTL: softTTL,
+		HardTTL: hardTTL,
+	})
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + string(data), nil
+}
+
+func decodeEnvelope(data string) (envelope, bool) {
+	var env envelope
+	if !strings.HasPrefix(data, envelopePrefix) {
+		return env, false
+	}
+	err := json.Unmarshal([]byte(data[len(envelopePrefix):]), &env)
+	return env, err == nil
+}
+
+// value 返回和普通 Get 一致的字符串形式的值
+func (e envelope) value() string {
+	var str string
+	if err := json.Unmarshal(e.Val, &str); err == nil {
+		return str
+	}
+	return string(e.Val)
+}
+
+type softTTLPipeline struct {
+	ecache.Pipeline
+	// resolves 在 Exec 之后还原信封
+	resolves []func()
+}
+
+func (p *softTTLPipeline) Get(key string) *ecache.Future[ecache.Value] {
+	return p.unwrap(p.Pipeline.Get(key))
+}
+
+func (p *softTTLPipeline) GetSet(key string, val string) *ecache.Future[ecache.Value] {
+	return p.unwrap(p.Pipeline.GetSet(key, val))
+}
+
+func (p *softTTLPipeline) Exec(ctx context.Context) error {
+	err := p.Pipeline.Exec(ctx)
+	p.resolve()
+	return err
+}
+
+func (p *softTTLPipeline) unwrap(f *ecache.Future[ecache.Value]) *ecache.Future[ecache.Value] {
+	res := &ecache.Future[ecache.Value]{}
+	p.resolves = append(p.resolves, func() {
+		val, err := f.Result()
+		// 没有执行的操作保持没有执行的状态
+		if errors.Is(err, errs.ErrPipelineNotExecuted) {
+			return
+		}
+		val, _, _, _ = unwrap(val)
+		res.Resolve(val, err)
+	})
+	return res
+}
+
+func (p *softTTLPipeline) resolve() {
+	for _, fn := range p.resolves {
+		fn()
+	}
+	p.resolves = nil
+}
+
+type softTTLTx struct {
+	ecache.Tx
+}
+
+func (t softTTLTx) Get(ctx context.Context, key string) ecache.Value {
+	val, _, _, _ := unwrap(t.Tx.Get(ctx, key))
+	return val
+}
+
+func (t softTTLTx) Pipelined(ctx context.Context, fn func(p ecache.Pipeline) error) error {
+	p := &softTTLPipeline{}
+	err := t.Tx.Pipelined(ctx, func(pipe ecache.Pipeline) error {
+		p.Pipeline = pipe
+		return fn(p)
+	})
+	p.resolve()
+	return err
+}
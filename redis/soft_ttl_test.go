@@ -0,0 +1,352 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSoftTTLCache_SetWithSoftTTL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	status := redis.NewStatusCmd(context.Background())
+	status.SetVal("OK")
+	cmd.EXPECT().
+		Set(context.Background(), "name", gomock.Any(), time.Minute).
+		DoAndReturn(func(ctx context.Context, key string, val any, expiration time.Duration) *redis.StatusCmd {
+			env, ok := decodeEnvelope(val.(string))
+			require.True(t, ok)
+			assert.Equal(t, "大明", env.value())
+			assert.Equal(t, time.Second, env.SoftTTL)
+			assert.Equal(t, time.Minute, env.HardTTL)
+			return status
+		})
+	c := NewSoftTTLCache(NewCache(cmd), nil)
+	err := c.SetWithSoftTTL(context.Background(), "name", "大明", time.Second, time.Minute)
+	require.NoError(t, err)
+}
+
+func TestSoftTTLCache_SetWithSoftTTL_Invalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	c := NewSoftTTLCache(NewCache(mocks.NewMockCmdable(ctrl)), nil)
+	err := c.SetWithSoftTTL(context.Background(), "name", "大明", time.Minute, time.Minute)
+	assert.Equal(t, errs.ErrInvalidSoftTTL, err)
+}
+
+func TestSoftTTLCache_Get(t *testing.T) {
+	fresh, err := encodeEnvelope("大明", time.Minute, time.Hour)
+	require.NoError(t, err)
+	stale, err := encodeEnvelope([]byte("大明"), -time.Second, time.Hour)
+	require.NoError(t, err)
+	number, err := encodeEnvelope(12, time.Minute, time.Hour)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+
+		mock   func(*gomock.Controller) redis.Cmdable
+		loader func(ctx context.Context, key string) (any, error)
+
+		wantErr   error
+		wantVal   string
+		wantStale bool
+	}{
+		{
+			name: "fresh value",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetVal(fresh)
+				cmd.EXPECT().Get(context.Background(), "name").Return(str)
+				return cmd
+			},
+			wantVal: "大明",
+		},
+		{
+			name: "number value",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetVal(number)
+				cmd.EXPECT().Get(context.Background(), "name").Return(str)
+				return cmd
+			},
+			wantVal: "12",
+		},
+		{
+			name: "plain value",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetVal("大明")
+				cmd.EXPECT().Get(context.Background(), "name").Return(str)
+				return cmd
+			},
+			wantVal: "大明",
+		},
+		{
+			name: "key not exist",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetErr(redis.Nil)
+				cmd.EXPECT().Get(context.Background(), "name").Return(str)
+				return cmd
+			},
+			wantErr: errs.ErrKeyNotExist,
+		},
+		{
+			name: "stale value and refresh failed",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetVal(stale)
+				cmd.EXPECT().Get(context.Background(), "name").Return(str)
+				return cmd
+			},
+			loader: func(ctx context.Context, key string) (any, error) {
+				return nil, errors.New("mock error")
+			},
+			wantVal:   "大明",
+			wantStale: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewSoftTTLCache(NewCache(tc.mock(ctrl)), tc.loader)
+			val := c.Get(context.Background(), "name")
+			assert.Equal(t, tc.wantErr, val.Err)
+			if val.Err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, val.Val)
+			assert.Equal(t, tc.wantStale, val.Stale)
+		})
+	}
+}
+
+func TestSoftTTLCache_refresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stale, err := encodeEnvelope("旧值", -time.Second, time.Hour)
+	require.NoError(t, err)
+	cmd := mocks.NewMockCmdable(ctrl)
+	str := redis.NewStringCmd(context.Background())
+	str.SetVal(stale)
+	cmd.EXPECT().Get(context.Background(), "name").Return(str).Times(2)
+
+	refreshed := make(chan struct{})
+	// 只有 key 的值依旧是读出来的信封的时候才写入
+	cmd.EXPECT().
+		EvalSha(gomock.Any(), compareAndSwapScript.Hash(), []string{"name"}, stale, gomock.Any(), int64(time.Hour/time.Millisecond)).
+		DoAndReturn(func(ctx context.Context, sha string, keys []string, args ...any) *redis.Cmd {
+			env, ok := decodeEnvelope(args[1].(string))
+			require.True(t, ok)
+			assert.Equal(t, "新值", env.value())
+			assert.Equal(t, -time.Second, env.SoftTTL)
+			close(refreshed)
+			return redis.NewCmdResult(int64(1), nil)
+		})
+
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (any, error) {
+		<-release
+		return "新值", nil
+	}
+	c := NewSoftTTLCache(NewCache(cmd), loader)
+	// 两次读取只会触发一次刷新
+	for i := 0; i < 2; i++ {
+		val := c.Get(context.Background(), "name")
+		require.NoError(t, val.Err)
+		assert.Equal(t, "旧值", val.Val)
+		assert.True(t, val.Stale)
+	}
+	close(release)
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("没有在后台刷新")
+	}
+}
+
+func TestSoftTTLCache_GetSet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	old, err := encodeEnvelope("大明", -time.Second, time.Hour)
+	require.NoError(t, err)
+	cmd := mocks.NewMockCmdable(ctrl)
+	str := redis.NewStringCmd(context.Background())
+	str.SetVal(old)
+	cmd.EXPECT().GetSet(context.Background(), "name", "小明").Return(str)
+
+	c := NewSoftTTLCache(NewCache(cmd), nil)
+	val := c.GetSet(context.Background(), "name", "小明")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "大明", val.Val)
+	assert.True(t, val.Stale)
+}
+
+func TestSoftTTLCache_CompareAndSwap(t *testing.T) {
+	raw, err := encodeEnvelope(12, time.Minute, time.Hour)
+	require.NoError(t, err)
+	getCmd := func(val string, err error) *redis.StringCmd {
+		str := redis.NewStringCmd(context.Background())
+		str.SetVal(val)
+		str.SetErr(err)
+		return str
+	}
+
+	testCases := []struct {
+		name   string
+		mock   func(cmd *mocks.MockCmdable)
+		old    any
+		wantOk bool
+	}{
+		{
+			name: "envelope matched",
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().Get(context.Background(), "name").Return(getCmd(raw, nil))
+				// 比较的是读出来的原始信封
+				cmd.EXPECT().
+					EvalSha(context.Background(), compareAndSwapScript.Hash(), []string{"name"}, raw, "13", int64(60000)).
+					Return(evalCmd(int64(1), nil))
+			},
+			old:    12,
+			wantOk: true,
+		},
+		{
+			name: "envelope not matched",
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().Get(context.Background(), "name").Return(getCmd(raw, nil))
+			},
+			old: 11,
+		},
+		{
+			name: "plain value",
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().Get(context.Background(), "name").Return(getCmd("12", nil))
+				cmd.EXPECT().
+					EvalSha(context.Background(), compareAndSwapScript.Hash(), []string{"name"}, 12, "13", int64(60000)).
+					Return(evalCmd(int64(1), nil))
+			},
+			old:    12,
+			wantOk: true,
+		},
+		{
+			name: "key not exist",
+			mock: func(cmd *mocks.MockCmdable) {
+				cmd.EXPECT().Get(context.Background(), "name").Return(getCmd("", redis.Nil))
+			},
+			old: 12,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			cmd := mocks.NewMockCmdable(ctrl)
+			tc.mock(cmd)
+			c := NewSoftTTLCache(NewCache(cmd), nil)
+			ok, err := c.CompareAndSwap(context.Background(), "name", tc.old, "13", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantOk, ok)
+		})
+	}
+}
+
+func TestSoftTTLCache_Pipeline(t *testing.T) {
+	raw, err := encodeEnvelope("大明", time.Minute, time.Hour)
+	require.NoError(t, err)
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	rdb.AddHook(pipelineHook(func(cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			if c, ok := cmd.(*redis.StringCmd); ok {
+				c.SetVal(raw)
+			}
+		}
+		return nil
+	}))
+	c := NewSoftTTLCache(NewCache(rdb), nil)
+
+	p := c.Pipeline()
+	get := p.Get("name")
+	getSet := p.GetSet("name", "小明")
+	assert.Equal(t, errs.ErrPipelineNotExecuted, get.Err())
+	require.NoError(t, p.Exec(context.Background()))
+	assert.Equal(t, "大明", get.Val().Val)
+	assert.Equal(t, "大明", getSet.Val().Val)
+}
+
+func TestSoftTTLCache_Tx(t *testing.T) {
+	raw, err := encodeEnvelope("大明", time.Minute, time.Hour)
+	require.NoError(t, err)
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	rdb.AddHook(pipelineHook(func(cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			if c, ok := cmd.(*redis.StringCmd); ok {
+				c.SetVal(raw)
+			}
+		}
+		return nil
+	}))
+	tx := softTTLTx{Tx: &stubTx{val: raw, pipe: NewCache(rdb).Pipeline()}}
+
+	val := tx.Get(context.Background(), "name")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "大明", val.Val)
+
+	var get *ecache.Future[ecache.Value]
+	err = tx.Pipelined(context.Background(), func(p ecache.Pipeline) error {
+		get = p.Get("name")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "大明", get.Val().Val)
+}
+
+// stubTx Get 总是返回 val，Pipelined 通过 pipe 执行
+type stubTx struct {
+	val  string
+	pipe ecache.Pipeline
+}
+
+func (t *stubTx) Get(ctx context.Context, key string) ecache.Value {
+	var val ecache.Value
+	val.Val = t.val
+	return val
+}
+
+func (t *stubTx) Pipelined(ctx context.Context, fn func(p ecache.Pipeline) error) error {
+	if err := fn(t.pipe); err != nil {
+		return err
+	}
+	return t.pipe.Exec(ctx)
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ ecache.Stream = (*Stream)(nil)
+
+// Stream 基于 Redis Streams 的实现
+type Stream struct {
+	client redis.Cmdable
+}
+
+func NewStream(client redis.Cmdable) *Stream {
+	return &Stream{client: client}
+}
+
+func (s *Stream) XAdd(ctx context.Context, stream, id string, values map[string]any) (string, error) {
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		ID:     id,
+		Values: values,
+	}).Result()
+}
+
+func (s *Stream) XRead(ctx context.Context, stream, id string, count int64, block time.Duration) ([]ecache.StreamMessage, error) {
+	res, err := s.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{stream, id},
+		Count:   count,
+		Block:   blockArg(block),
+	}).Result()
+	return streamMessages(res, err)
+}
+
+func (s *Stream) XGroupCreate(ctx context.Context, stream, group, start string) error {
+	return streamError(s.client.XGroupCreateMkStream(ctx, stream, group, start).Err())
+}
+
+func (s *Stream) XReadGroup(ctx context.Context, stream, group, consumer string,
+	count int64, block time.Duration) ([]ecache.StreamMessage, error) {
+	res, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    blockArg(block),
+	}).Result()
+	return streamMessages(res, err)
+}
+
+func (s *Stream) XAck(ctx context.Context, stream, group string, ids ...string) (int64, error) {
+	return s.client.XAck(ctx, stream, group, ids...).Result()
+}
+
+func (s *Stream) XPending(ctx context.Context, stream, group string, count int64) ([]ecache.PendingMessage, error) {
+	if count <= 0 {
+		count = math.MaxInt64
+	}
+	res, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if err != nil {
+		return nil, streamError(err)
+	}
+	msgs := make([]ecache.PendingMessage, 0, len(res))
+	for _, p := range res {
+		msgs = append(msgs, ecache.PendingMessage{
+			ID:         p.ID,
+			Consumer:   p.Consumer,
+			Idle:       p.Idle,
+			RetryCount: p.RetryCount,
+		})
+	}
+	return msgs, nil
+}
+
+func (s *Stream) XClaim(ctx context.Context, stream, group, consumer string,
+	minIdle time.Duration, ids ...string) ([]ecache.StreamMessage, error) {
+	res, err := s.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, streamError(err)
+	}
+	return toStreamMessages(res), nil
+}
+
+func (s *Stream) XTrim(ctx context.Context, stream string, maxLen int64) (int64, error) {
+	return s.client.XTrimMaxLen(ctx, stream, maxLen).Result()
+}
+
+// blockArg go-redis 中 Block 为 0 表示永远阻塞，小于 0 才表示不阻塞
+func blockArg(block time.Duration) time.Duration {
+	if block <= 0 {
+		return -1
+	}
+	return block
+}
+
+// streamMessages 只读取了一个 stream，没有消息的时候 Redis 返回 nil
+func streamMessages(res []redis.XStream, err error) ([]ecache.StreamMessage, error) {
+	if errors.Is(err, redis.Nil) {
+		return []ecache.StreamMessage{}, nil
+	}
+	if err != nil {
+		return nil, streamError(err)
+	}
+	if len(res) == 0 {
+		return []ecache.StreamMessage{}, nil
+	}
+	return toStreamMessages(res[0].Messages), nil
+}
+
+func toStreamMessages(msgs []redis.XMessage) []ecache.StreamMessage {
+	res := make([]ecache.StreamMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		values := make(map[string]string, len(msg.Values))
+		for k, v := range msg.Values {
+			values[k], _ = v.(string)
+		}
+		res = append(res, ecache.StreamMessage{ID: msg.ID, Values: values})
+	}
+	return res
+}
+
+// streamError 将消费者组相关的错误转换为 ecache 中的错误
+func streamError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "NOGROUP"):
+		return errs.ErrStreamGroupNotExist
+	case strings.HasPrefix(msg, "BUSYGROUP"):
+		return errs.ErrStreamGroupExist
+	default:
+		return err
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_e2e(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	key := "e2e_stream_orders"
+	require.NoError(t, rdb.Del(ctx, key).Err())
+	defer rdb.Del(ctx, key)
+	s := NewStream(rdb)
+
+	_, err := s.XReadGroup(ctx, key, "billing", "c1", 0, 0)
+	assert.Equal(t, errs.ErrStreamGroupNotExist, err)
+	require.NoError(t, s.XGroupCreate(ctx, key, "billing", "$"))
+	assert.Equal(t, errs.ErrStreamGroupExist, s.XGroupCreate(ctx, key, "billing", "$"))
+
+	id1, err := s.XAdd(ctx, key, "*", map[string]any{"id": 1})
+	require.NoError(t, err)
+	id2, err := s.XAdd(ctx, key, "*", map[string]any{"id": 2})
+	require.NoError(t, err)
+
+	msgs, err := s.XRead(ctx, key, "0", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.StreamMessage{
+		{ID: id1, Values: map[string]string{"id": "1"}},
+		{ID: id2, Values: map[string]string{"id": "2"}},
+	}, msgs)
+	msgs, err = s.XRead(ctx, key, "$", 0, time.Millisecond*100)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+
+	msgs, err = s.XReadGroup(ctx, key, "billing", "c1", 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.StreamMessage{{ID: id1, Values: map[string]string{"id": "1"}}}, msgs)
+	msgs, err = s.XReadGroup(ctx, key, "billing", "c1", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.StreamMessage{{ID: id2, Values: map[string]string{"id": "2"}}}, msgs)
+
+	cnt, err := s.XAck(ctx, key, "billing", id1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+	pending, err := s.XPending(ctx, key, "billing", 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, id2, pending[0].ID)
+	assert.Equal(t, "c1", pending[0].Consumer)
+
+	time.Sleep(time.Millisecond * 50)
+	msgs, err = s.XClaim(ctx, key, "billing", "c2", time.Millisecond*10, id2)
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.StreamMessage{{ID: id2, Values: map[string]string{"id": "2"}}}, msgs)
+	pending, err = s.XPending(ctx, key, "billing", 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "c2", pending[0].Consumer)
+	assert.Equal(t, int64(2), pending[0].RetryCount)
+
+	cnt, err = s.XTrim(ctx, key, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+}
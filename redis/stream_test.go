@@ -0,0 +1,187 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStream_XAdd(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	cmd.EXPECT().XAdd(gomock.Any(), &redis.XAddArgs{
+		Stream: "orders",
+		ID:     "*",
+		Values: map[string]any{"id": 1},
+	}).Return(redis.NewStringResult("1-0", nil))
+
+	id, err := NewStream(cmd).XAdd(context.Background(), "orders", "*", map[string]any{"id": 1})
+	require.NoError(t, err)
+	assert.Equal(t, "1-0", id)
+}
+
+func TestStream_XRead(t *testing.T) {
+	testCases := []struct {
+		name  string
+		mock  func(ctrl *gomock.Controller) redis.Cmdable
+		block time.Duration
+
+		wantMsgs []ecache.StreamMessage
+		wantErr  error
+	}{
+		{
+			name: "messages",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().XRead(gomock.Any(), &redis.XReadArgs{
+					Streams: []string{"orders", "0"},
+					Count:   10,
+					Block:   -1,
+				}).Return(redis.NewXStreamSliceCmdResult([]redis.XStream{
+					{
+						Stream: "orders",
+						Messages: []redis.XMessage{
+							{ID: "1-0", Values: map[string]any{"id": "1"}},
+						},
+					},
+				}, nil))
+				return cmd
+			},
+			wantMsgs: []ecache.StreamMessage{{ID: "1-0", Values: map[string]string{"id": "1"}}},
+		},
+		{
+			name: "timeout",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().XRead(gomock.Any(), &redis.XReadArgs{
+					Streams: []string{"orders", "0"},
+					Count:   10,
+					Block:   time.Second,
+				}).Return(redis.NewXStreamSliceCmdResult(nil, redis.Nil))
+				return cmd
+			},
+			block:    time.Second,
+			wantMsgs: []ecache.StreamMessage{},
+		},
+		{
+			name: "error",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				cmd.EXPECT().XRead(gomock.Any(), gomock.Any()).
+					Return(redis.NewXStreamSliceCmdResult(nil, context.DeadlineExceeded))
+				return cmd
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			msgs, err := NewStream(tc.mock(ctrl)).XRead(context.Background(), "orders", "0", 10, tc.block)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantMsgs, msgs)
+		})
+	}
+}
+
+func TestStream_ConsumerGroup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	cmd := mocks.NewMockCmdable(ctrl)
+	s := NewStream(cmd)
+
+	cmd.EXPECT().XGroupCreateMkStream(ctx, "orders", "billing", "$").
+		Return(redis.NewStatusResult("OK", nil))
+	require.NoError(t, s.XGroupCreate(ctx, "orders", "billing", "$"))
+	cmd.EXPECT().XGroupCreateMkStream(ctx, "orders", "billing", "$").
+		Return(redis.NewStatusResult("", errors.New("BUSYGROUP Consumer Group name already exists")))
+	assert.Equal(t, errs.ErrStreamGroupExist, s.XGroupCreate(ctx, "orders", "billing", "$"))
+
+	cmd.EXPECT().XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "billing",
+		Consumer: "c1",
+		Streams:  []string{"orders", ">"},
+		Block:    -1,
+	}).Return(redis.NewXStreamSliceCmdResult([]redis.XStream{
+		{
+			Stream:   "orders",
+			Messages: []redis.XMessage{{ID: "1-0", Values: map[string]any{"id": "1"}}},
+		},
+	}, nil))
+	msgs, err := s.XReadGroup(ctx, "orders", "billing", "c1", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.StreamMessage{{ID: "1-0", Values: map[string]string{"id": "1"}}}, msgs)
+	cmd.EXPECT().XReadGroup(ctx, gomock.Any()).Return(redis.NewXStreamSliceCmdResult(nil,
+		errors.New("NOGROUP No such key 'orders' or consumer group 'unknown' in XREADGROUP with GROUP option")))
+	_, err = s.XReadGroup(ctx, "orders", "unknown", "c1", 0, 0)
+	assert.Equal(t, errs.ErrStreamGroupNotExist, err)
+
+	pendingCmd := redis.NewXPendingExtCmd(ctx)
+	pendingCmd.SetVal([]redis.XPendingExt{{ID: "1-0", Consumer: "c1", Idle: time.Second, RetryCount: 1}})
+	cmd.EXPECT().XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: "orders",
+		Group:  "billing",
+		Start:  "-",
+		End:    "+",
+		Count:  math.MaxInt64,
+	}).Return(pendingCmd)
+	pending, err := s.XPending(ctx, "orders", "billing", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.PendingMessage{{ID: "1-0", Consumer: "c1", Idle: time.Second, RetryCount: 1}}, pending)
+	pendingCmd = redis.NewXPendingExtCmd(ctx)
+	pendingCmd.SetErr(errors.New("network error"))
+	cmd.EXPECT().XPendingExt(ctx, gomock.Any()).Return(pendingCmd)
+	_, err = s.XPending(ctx, "orders", "billing", 10)
+	assert.Equal(t, errors.New("network error"), err)
+
+	cmd.EXPECT().XClaim(ctx, &redis.XClaimArgs{
+		Stream:   "orders",
+		Group:    "billing",
+		Consumer: "c2",
+		MinIdle:  time.Second,
+		Messages: []string{"1-0"},
+	}).Return(redis.NewXMessageSliceCmdResult([]redis.XMessage{{ID: "1-0", Values: map[string]any{"id": "1"}}}, nil))
+	msgs, err = s.XClaim(ctx, "orders", "billing", "c2", time.Second, "1-0")
+	require.NoError(t, err)
+	assert.Equal(t, []ecache.StreamMessage{{ID: "1-0", Values: map[string]string{"id": "1"}}}, msgs)
+	cmd.EXPECT().XClaim(ctx, gomock.Any()).Return(redis.NewXMessageSliceCmdResult(nil, errors.New("NOGROUP No such key")))
+	_, err = s.XClaim(ctx, "orders", "unknown", "c2", time.Second, "1-0")
+	assert.Equal(t, errs.ErrStreamGroupNotExist, err)
+
+	cmd.EXPECT().XAck(ctx, "orders", "billing", "1-0").Return(redis.NewIntResult(1, nil))
+	cnt, err := s.XAck(ctx, "orders", "billing", "1-0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+
+	cmd.EXPECT().XTrimMaxLen(ctx, "orders", int64(100)).Return(redis.NewIntResult(2, nil))
+	cnt, err = s.XTrim(ctx, "orders", 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+}
@@ -0,0 +1,440 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ ecache.Cache = (*TrackingCache)(nil)
+
+// invalidateChannel Redis 发送失效消息的频道
+const invalidateChannel = "__redis__:invalidate"
+
+// TrackingCache 利用 Redis 6 的 CLIENT TRACKING 实现的客户端缓存。
+// 通过 Get 读取到的值会保存在本地的一个有容量上限的存储里面，
+// 当 Redis 里面对应的 key 发生变化的时候，Redis 会发送失效消息，收到之后删除本地的副本。
+//
+// 失效消息通过一个单独的订阅连接接收（RESP2 的 REDIRECT 模式），
+// 所有的数据连接在建立的时候都会执行 CLIENT TRACKING ON REDIRECT。
+// 如果订阅连接断开重连，那么会重新创建数据连接并且清空本地的存储。
+//
+// 它没有做成 Cache 的一个选项：CLIENT TRACKING 必须在每一个数据连接建立的时候执行，
+// 订阅连接重连之后还要用新的 ID 重建数据连接，所以连接只能由 TrackingCache 根据 redis.Options 自己创建和管理，
+// 没办法套在 NewCache 传入的任意 redis.Cmdable 上。
+// TrackingCache 实现了 ecache.Cache，其余的操作都委托给内部的 Cache，可以直接替换掉原来的 Cache
+type TrackingCache struct {
+	opt *redis.Options
+
+	capacity int
+	localTTL time.Duration
+	bcast    bool
+	prefixes []string
+
+	local *localStore
+
+	// sub 和 pubsub 用于接收失效消息
+	sub        *redis.Client
+	pubsub     *redis.PubSub
+	redirectID atomic.Int64
+
+	mutex  sync.Mutex
+	client *redis.Client
+	closed bool
+	cache  atomic.Pointer[Cache]
+}
+
+// NewTrackingCache 根据 opt 创建订阅连接和数据连接，并且开始接收失效消息
+func NewTrackingCache(ctx context.Context, opt *redis.Options,
+	opts ...option.Option[TrackingCache]) (*TrackingCache, error) {
+	res := &TrackingCache{
+		opt:      opt,
+		capacity: 10000,
+		localTTL: time.Minute,
+	}
+	option.Apply(res, opts...)
+	res.local = newLocalStore(res.capacity)
+
+	subOpt := *opt
+	subOpt.Protocol = 2
+	subOpt.OnConnect = res.onSubscriberConnect
+	res.sub = redis.NewClient(&subOpt)
+	res.pubsub = res.sub.Subscribe(ctx, invalidateChannel)
+	// 等待订阅成功，这个时候已经拿到了订阅连接的 ID
+	if _, err := res.pubsub.Receive(ctx); err != nil {
+		_ = res.pubsub.Close()
+		_ = res.sub.Close()
+		return nil, err
+	}
+	res.rebuild()
+	go res.listen()
+	return res, nil
+}
+
+// WithTrackingCapacity 设置本地最多保存多少个 key
+func WithTrackingCapacity(capacity int) option.Option[TrackingCache] {
+	return func(c *TrackingCache) {
+		c.capacity = capacity
+	}
+}
+
+// WithTrackingLocalTTL 设置本地副本的过期时间，作为失效消息丢失时的兜底，0 表示永不过期
+func WithTrackingLocalTTL(ttl time.Duration) option.Option[TrackingCache] {
+	return func(c *TrackingCache) {
+		c.localTTL = ttl
+	}
+}
+
+// WithTrackingBroadcast 使用广播模式（BCAST）。
+// 广播模式下 Redis 不再记录每个连接读过的 key，而是把匹配前缀的所有 key 的失效消息都发送过来，
+// 这个时候只有匹配前缀的 key 才会保存到本地；没有传入前缀表示所有的 key
+func WithTrackingBroadcast(prefixes ...string) option.Option[TrackingCache] {
+	return func(c *TrackingCache) {
+		c.bcast = true
+		c.prefixes = prefixes
+	}
+}
+
+// onSubscriberConnect 记录订阅连接的 ID，如果是重连，那么需要重建数据连接
+func (c *TrackingCache) onSubscriberConnect(ctx context.Context, cn *redis.Conn) error {
+	if c.opt.OnConnect != nil {
+		if err := c.opt.OnConnect(ctx, cn); err != nil {
+			return err
+		}
+	}
+	id, err := cn.ClientID(ctx).Result()
+	if err != nil {
+		return err
+	}
+	if old := c.redirectID.Swap(id); old != 0 && old != id {
+		go c.rebuild()
+	}
+	return nil
+}
+
+// rebuild 使用最新的订阅连接 ID 创建数据连接，并且清空本地的存储
+func (c *TrackingCache) rebuild() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	args := c.trackingArgs(c.redirectID.Load())
+	dataOpt := *c.opt
+	dataOpt.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		if c.opt.OnConnect != nil {
+			if err := c.opt.OnConnect(ctx, cn); err != nil {
+				return err
+			}
+		}
+		return cn.Process(ctx, redis.NewCmd(ctx, args...))
+	}
+	old := c.client
+	c.client = redis.NewClient(&dataOpt)
+	c.cache.Store(NewCache(c.client))
+	c.local.flush()
+	if old != nil {
+		_ = old.Close()
+	}
+}
+
+func (c *TrackingCache) trackingArgs(redirectID int64) []any {
+	args := []any{"CLIENT", "TRACKING", "ON", "REDIRECT", redirectID}
+	if c.bcast {
+		args = append(args, "BCAST")
+		for _, prefix := range c.prefixes {
+			args = append(args, "PREFIX", prefix)
+		}
+	}
+	return args
+}
+
+// listen 处理失效消息，直到 pubsub 被关闭
+func (c *TrackingCache) listen() {
+	for msg := range c.pubsub.Channel() {
+		c.handleInvalidation(msg)
+	}
+}
+
+func (c *TrackingCache) handleInvalidation(msg *redis.Message) {
+	switch {
+	case len(msg.PayloadSlice) > 0:
+		c.local.invalidate(msg.PayloadSlice...)
+	case msg.Payload != "":
+		c.local.invalidate(msg.Payload)
+	default:
+		// 失效消息为空，表示执行了 FLUSHALL 或者 FLUSHDB
+		c.local.flush()
+	}
+}
+
+// trackable 判断 key 能不能保存在本地
+func (c *TrackingCache) trackable(key string) bool {
+	if !c.bcast || len(c.prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range c.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close 关闭订阅连接和数据连接
+func (c *TrackingCache) Close() error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.closed = true
+	client := c.client
+	c.mutex.Unlock()
+
+	err := c.pubsub.Close()
+	if subErr := c.sub.Close(); err == nil {
+		err = subErr
+	}
+	if client != nil {
+		if clientErr := client.Close(); err == nil {
+			err = clientErr
+		}
+	}
+	return err
+}
+
+func (c *TrackingCache) Get(ctx context.Context, key string) (val ecache.Value) {
+	if !c.trackable(key) {
+		return c.cache.Load().Get(ctx, key)
+	}
+	if local, ok := c.local.get(key); ok {
+		val.Val = local
+		return
+	}
+	seq := c.local.sequence()
+	val = c.cache.Load().Get(ctx, key)
+	if val.Err == nil {
+		if str, ok := val.Val.(string); ok {
+			c.local.setIfUnchanged(seq, key, str, c.localTTL)
+		}
+	}
+	return
+}
+
+func (c *TrackingCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	c.local.invalidate(key)
+	return c.cache.Load().Set(ctx, key, val, expiration)
+}
+
+func (c *TrackingCache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	c.local.invalidate(key)
+	return c.cache.Load().SetNX(ctx, key, val, expiration)
+}
+
+func (c *TrackingCache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	c.local.invalidate(key)
+	return c.cache.Load().SetXX(ctx, key, val, expiration)
+}
+
+func (c *TrackingCache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	c.local.invalidate(key)
+	return c.cache.Load().CompareAndSwap(ctx, key, old, new, expiration)
+}
+
+func (c *TrackingCache) GetSet(ctx context.Context, key string, val string) ecache.Value {
+	c.local.invalidate(key)
+	return c.cache.Load().GetSet(ctx, key, val)
+}
+
+func (c *TrackingCache) Delete(ctx context.Context, key ...string) (int64, error) {
+	c.local.invalidate(key...)
+	return c.cache.Load().Delete(ctx, key...)
+}
+
+func (c *TrackingCache) LPush(ctx context.Context, key string, val ...any) (int64, error) {
+	c.local.invalidate(key)
+	return c.cache.Load().LPush(ctx, key, val...)
+}
+
+func (c *TrackingCache) LPop(ctx context.Context, key string) ecache.Value {
+	c.local.invalidate(key)
+	return c.cache.Load().LPop(ctx, key)
+}
+
+func (c *TrackingCache) BLPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	c.local.invalidate(key)
+	return c.cache.Load().BLPop(ctx, key, timeout)
+}
+
+func (c *TrackingCache) BRPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	c.local.invalidate(key)
+	return c.cache.Load().BRPop(ctx, key, timeout)
+}
+
+func (c *TrackingCache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) ecache.Value {
+	c.local.invalidate(source, destination)
+	return c.cache.Load().BLMove(ctx, source, destination, srcPos, destPos, timeout)
+}
+
+func (c *TrackingCache) LMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection) ecache.Value {
+	c.local.invalidate(source, destination)
+	return c.cache.Load().LMove(ctx, source, destination, srcPos, destPos)
+}
+
+func (c *TrackingCache) RPopLPush(ctx context.Context, source, destination string) ecache.Value {
+	c.local.invalidate(source, destination)
+	return c.cache.Load().RPopLPush(ctx, source, destination)
+}
+
+func (c *TrackingCache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	c.local.invalidate(key)
+	return c.cache.Load().LRem(ctx, key, count, val)
+}
+
+func (c *TrackingCache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
+	c.local.invalidate(key)
+	return c.cache.Load().SAdd(ctx, key, members...)
+}
+
+func (c *TrackingCache) SRem(ctx context.Context, key string, members ...any) (int64, error) {
+	c.local.invalidate(key)
+	return c.cache.Load().SRem(ctx, key, members...)
+}
+
+func (c *TrackingCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	c.local.invalidate(key)
+	return c.cache.Load().IncrBy(ctx, key, value)
+}
+
+func (c *TrackingCache) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
+	c.local.invalidate(key)
+	return c.cache.Load().DecrBy(ctx, key, value)
+}
+
+func (c *TrackingCache) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
+	c.local.invalidate(key)
+	return c.cache.Load().IncrByFloat(ctx, key, value)
+}
+
+// localStore 本地的 LRU 存储。
+// seq 在每次失效的时候递增，用于避免把读取过程中已经失效的值写入本地
+type localStore struct {
+	mutex    sync.Mutex
+	capacity int
+	list     *list.List
+	items    map[string]*list.Element
+	seq      uint64
+}
+
+type localItem struct {
+	key       string
+	val       string
+	expiresAt time.Time
+}
+
+func newLocalStore(capacity int) *localStore {
+	return &localStore{
+		capacity: capacity,
+		list:     list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+func (s *localStore) get(key string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	elem, ok := s.items[key]
+	if !ok {
+		return "", false
+	}
+	item := elem.Value.(*localItem)
+	if !item.expiresAt.IsZero() && !time.Now().Before(item.expiresAt) {
+		s.removeElement(elem)
+		return "", false
+	}
+	s.list.MoveToFront(elem)
+	return item.val, true
+}
+
+func (s *localStore) sequence() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.seq
+}
+
+// setIfUnchanged 只有在 seq 之后没有发生过失效的情况下才写入
+func (s *localStore) setIfUnchanged(seq uint64, key string, val string, ttl time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.seq != seq || s.capacity <= 0 {
+		return false
+	}
+	item := &localItem{key: key, val: val}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+	if elem, ok := s.items[key]; ok {
+		elem.Value = item
+		s.list.MoveToFront(elem)
+		return true
+	}
+	if s.list.Len() >= s.capacity {
+		s.removeElement(s.list.Back())
+	}
+	s.items[key] = s.list.PushFront(item)
+	return true
+}
+
+func (s *localStore) invalidate(keys ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.seq++
+	for _, key := range keys {
+		if elem, ok := s.items[key]; ok {
+			s.removeElement(elem)
+		}
+	}
+}
+
+func (s *localStore) flush() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.seq++
+	s.list.Init()
+	s.items = make(map[string]*list.Element, s.capacity)
+}
+
+func (s *localStore) len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.list.Len()
+}
+
+func (s *localStore) removeElement(elem *list.Element) {
+	s.list.Remove(elem)
+	delete(s.items, elem.Value.(*localItem).key)
+}
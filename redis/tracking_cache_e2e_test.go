@@ -0,0 +1,71 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackingCache_e2e(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []option.Option[TrackingCache]
+	}{
+		{
+			name: "default mode",
+		},
+		{
+			name: "broadcast mode",
+			opts: []option.Option[TrackingCache]{WithTrackingBroadcast("tracking:")},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancel()
+			rdb := newRedisClient()
+			require.NoError(t, rdb.Set(ctx, "tracking:name", "大明", time.Minute).Err())
+			defer rdb.Del(ctx, "tracking:name")
+
+			c, err := NewTrackingCache(ctx, &redis.Options{Addr: "localhost:6379"}, tc.opts...)
+			require.NoError(t, err)
+			defer func() {
+				_ = c.Close()
+			}()
+
+			val := c.Get(ctx, "tracking:name")
+			require.NoError(t, val.Err)
+			assert.Equal(t, "大明", val.Val)
+			assert.Equal(t, 1, c.local.len())
+
+			// 其它客户端修改之后本地副本会失效
+			require.NoError(t, rdb.Set(ctx, "tracking:name", "小明", time.Minute).Err())
+			assert.Eventually(t, func() bool {
+				return c.local.len() == 0
+			}, time.Second*3, time.Millisecond*10)
+			val = c.Get(ctx, "tracking:name")
+			assert.Equal(t, "小明", val.Val)
+		})
+	}
+}
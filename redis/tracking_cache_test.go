@@ -0,0 +1,199 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestTrackingCache(cmd redis.Cmdable, capacity int) *TrackingCache {
+	c := &TrackingCache{
+		local:    newLocalStore(capacity),
+		localTTL: time.Minute,
+	}
+	c.cache.Store(NewCache(cmd))
+	return c
+}
+
+func expectGet(cmd *mocks.MockCmdable, key string, val string, times int) {
+	str := redis.NewStringCmd(context.Background())
+	if val == "" {
+		str.SetErr(redis.Nil)
+	} else {
+		str.SetVal(val)
+	}
+	cmd.EXPECT().Get(gomock.Any(), key).Return(str).Times(times)
+}
+
+func TestTrackingCache_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := newTestTrackingCache(cmd, 10)
+	ctx := context.Background()
+
+	// 第二次读取走本地
+	expectGet(cmd, "name", "大明", 1)
+	for i := 0; i < 2; i++ {
+		val := c.Get(ctx, "name")
+		require.NoError(t, val.Err)
+		assert.Equal(t, "大明", val.Val)
+	}
+
+	// 收到失效消息之后重新读取
+	c.handleInvalidation(&redis.Message{Channel: invalidateChannel, PayloadSlice: []string{"name"}})
+	expectGet(cmd, "name", "小明", 1)
+	val := c.Get(ctx, "name")
+	assert.Equal(t, "小明", val.Val)
+
+	// key 不存在不会保存在本地
+	expectGet(cmd, "not-exist", "", 2)
+	for i := 0; i < 2; i++ {
+		val = c.Get(ctx, "not-exist")
+		assert.Equal(t, errs.ErrKeyNotExist, val.Err)
+	}
+
+	// FLUSHALL 的时候清空本地
+	c.handleInvalidation(&redis.Message{Channel: invalidateChannel})
+	assert.Equal(t, 0, c.local.len())
+}
+
+func TestTrackingCache_InvalidateDuringGet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := newTestTrackingCache(cmd, 10)
+
+	// 读取的过程中收到了失效消息，那么读到的值不能保存在本地
+	cmd.EXPECT().Get(gomock.Any(), "name").
+		DoAndReturn(func(ctx context.Context, key string) *redis.StringCmd {
+			c.handleInvalidation(&redis.Message{Channel: invalidateChannel, Payload: "name"})
+			str := redis.NewStringCmd(ctx)
+			str.SetVal("大明")
+			return str
+		})
+	val := c.Get(context.Background(), "name")
+	assert.Equal(t, "大明", val.Val)
+	assert.Equal(t, 0, c.local.len())
+}
+
+func TestTrackingCache_Write(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := newTestTrackingCache(cmd, 10)
+	ctx := context.Background()
+
+	expectGet(cmd, "name", "大明", 1)
+	c.Get(ctx, "name")
+	assert.Equal(t, 1, c.local.len())
+
+	// 写操作会删除本地的副本
+	status := redis.NewStatusCmd(ctx)
+	status.SetVal("OK")
+	cmd.EXPECT().Set(ctx, "name", "小明", time.Minute).Return(status)
+	require.NoError(t, c.Set(ctx, "name", "小明", time.Minute))
+	assert.Equal(t, 0, c.local.len())
+
+	// BLMove 会同时删除 source 和 destination
+	expectGet(cmd, "jobs", "a", 1)
+	expectGet(cmd, "processing", "b", 1)
+	c.Get(ctx, "jobs")
+	c.Get(ctx, "processing")
+	assert.Equal(t, 2, c.local.len())
+	cmd.EXPECT().BLMove(ctx, "jobs", "processing", "LEFT", "RIGHT", time.Second).
+		Return(redis.NewStringResult("a", nil))
+	val := c.BLMove(ctx, "jobs", "processing", ecache.ListLeft, ecache.ListRight, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, 0, c.local.len())
+
+	// RPopLPush 同样会删除 source 和 destination
+	expectGet(cmd, "jobs", "a", 1)
+	expectGet(cmd, "processing", "b", 1)
+	c.Get(ctx, "jobs")
+	c.Get(ctx, "processing")
+	assert.Equal(t, 2, c.local.len())
+	cmd.EXPECT().RPopLPush(ctx, "jobs", "processing").
+		Return(redis.NewStringResult("a", nil))
+	val = c.RPopLPush(ctx, "jobs", "processing")
+	require.NoError(t, val.Err)
+	assert.Equal(t, 0, c.local.len())
+}
+
+func TestTrackingCache_Broadcast(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	c := newTestTrackingCache(cmd, 10)
+	c.bcast = true
+	c.prefixes = []string{"user:"}
+	ctx := context.Background()
+
+	expectGet(cmd, "user:1", "大明", 1)
+	expectGet(cmd, "order:1", "订单", 2)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, "大明", c.Get(ctx, "user:1").Val)
+		assert.Equal(t, "订单", c.Get(ctx, "order:1").Val)
+	}
+
+	assert.Equal(t, []any{"CLIENT", "TRACKING", "ON", "REDIRECT", int64(12),
+		"BCAST", "PREFIX", "user:"}, c.trackingArgs(12))
+	c.bcast = false
+	assert.Equal(t, []any{"CLIENT", "TRACKING", "ON", "REDIRECT", int64(12)}, c.trackingArgs(12))
+}
+
+func TestLocalStore(t *testing.T) {
+	s := newLocalStore(2)
+	assert.True(t, s.setIfUnchanged(s.sequence(), "key1", "value1", 0))
+	assert.True(t, s.setIfUnchanged(s.sequence(), "key2", "value2", time.Millisecond))
+	// 更新已有的 key
+	assert.True(t, s.setIfUnchanged(s.sequence(), "key1", "value1-1", 0))
+	val, ok := s.get("key1")
+	assert.True(t, ok)
+	assert.Equal(t, "value1-1", val)
+
+	// 过期
+	time.Sleep(time.Millisecond * 2)
+	_, ok = s.get("key2")
+	assert.False(t, ok)
+
+	// 超过容量淘汰最久没有使用的
+	assert.True(t, s.setIfUnchanged(s.sequence(), "key2", "value2", 0))
+	assert.True(t, s.setIfUnchanged(s.sequence(), "key3", "value3", 0))
+	_, ok = s.get("key1")
+	assert.False(t, ok)
+	assert.Equal(t, 2, s.len())
+
+	// 序号变化之后不能写入
+	seq := s.sequence()
+	s.invalidate("key2")
+	assert.False(t, s.setIfUnchanged(seq, "key2", "value2", 0))
+	_, ok = s.get("key2")
+	assert.False(t, ok)
+
+	// 容量为 0 表示不保存
+	s = newLocalStore(0)
+	assert.False(t, s.setIfUnchanged(s.sequence(), "key1", "value1", 0))
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ ecache.Transactional = (*Cache)(nil)
+	_ ecache.Tx            = (*tx)(nil)
+)
+
+// watcher 支持 WATCH 的客户端，例如 *redis.Client、*redis.ClusterClient 和 *redis.Ring
+type watcher interface {
+	Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error
+}
+
+// Watch 基于 WATCH/MULTI/EXEC 的乐观锁事务。
+// 如果创建 Cache 时传入的客户端不支持 WATCH，例如 Pipeliner，返回 errs.ErrNotSupported
+func (c *Cache) Watch(ctx context.Context, fn func(tx ecache.Tx) error, keys ...string) error {
+	w, ok := c.client.(watcher)
+	if !ok {
+		return errs.ErrNotSupported
+	}
+	return w.Watch(ctx, func(rtx *redis.Tx) error {
+		return fn(&tx{tx: rtx})
+	}, keys...)
+}
+
+type tx struct {
+	tx *redis.Tx
+}
+
+func (t *tx) Get(ctx context.Context, key string) ecache.Value {
+	return NewCache(t.tx).Get(ctx, key)
+}
+
+func (t *tx) Pipelined(ctx context.Context, fn func(p ecache.Pipeline) error) error {
+	p := &pipeline{}
+	var fnErr error
+	_, err := t.tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		p.pipe = pipe
+		fnErr = fn(p)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if errors.Is(err, redis.TxFailedErr) {
+		return errs.ErrTxConflict
+	}
+	return p.resolve()
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_e2e_Watch(t *testing.T) {
+	rdb := newRedisClient()
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	c := NewCache(rdb)
+	require.NoError(t, c.Set(ctx, "tx_cnt", 10, time.Minute))
+
+	// 读取之后被其他人修改了
+	err := c.Watch(ctx, func(tx ecache.Tx) error {
+		val, err := tx.Get(ctx, "tx_cnt").Int64()
+		require.NoError(t, err)
+		require.NoError(t, rdb.Set(ctx, "tx_cnt", 20, time.Minute).Err())
+		return tx.Pipelined(ctx, func(p ecache.Pipeline) error {
+			p.Set("tx_cnt", val+1, time.Minute)
+			return nil
+		})
+	}, "tx_cnt")
+	assert.Equal(t, errs.ErrTxConflict, err)
+
+	var set *ecache.Future[struct{}]
+	err = c.Watch(ctx, func(tx ecache.Tx) error {
+		val, err := tx.Get(ctx, "tx_cnt").Int64()
+		require.NoError(t, err)
+		return tx.Pipelined(ctx, func(p ecache.Pipeline) error {
+			set = p.Set("tx_cnt", val+1, time.Minute)
+			return nil
+		})
+	}, "tx_cnt")
+	require.NoError(t, err)
+	assert.NoError(t, set.Err())
+	assert.Equal(t, "21", c.Get(ctx, "tx_cnt").Val)
+
+	_, err = c.Delete(ctx, "tx_cnt")
+	require.NoError(t, err)
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCache_Watch(t *testing.T) {
+	testCases := []struct {
+		name string
+		exec func(cmds []redis.Cmder) error
+		fn   func(t *testing.T, tx ecache.Tx) error
+
+		wantCmds []string
+		wantErr  error
+	}{
+		{
+			name: "committed",
+			exec: func(cmds []redis.Cmder) error {
+				cmds[1].(*redis.IntCmd).SetVal(11)
+				return nil
+			},
+			fn: func(t *testing.T, tx ecache.Tx) error {
+				val := tx.Get(context.Background(), "cnt")
+				require.NoError(t, val.Err)
+				var incr *ecache.Future[int64]
+				err := tx.Pipelined(context.Background(), func(p ecache.Pipeline) error {
+					incr = p.IncrBy("cnt", 1)
+					return nil
+				})
+				require.NoError(t, err)
+				assert.Equal(t, int64(11), incr.Val())
+				return nil
+			},
+			wantCmds: []string{"watch", "get", "multi", "incrby", "exec", "unwatch"},
+		},
+		{
+			name: "conflict",
+			exec: func(cmds []redis.Cmder) error {
+				return redis.TxFailedErr
+			},
+			fn: func(t *testing.T, tx ecache.Tx) error {
+				return tx.Pipelined(context.Background(), func(p ecache.Pipeline) error {
+					p.Set("cnt", 11, time.Minute)
+					return nil
+				})
+			},
+			wantCmds: []string{"watch", "multi", "set", "exec", "unwatch"},
+			wantErr:  errs.ErrTxConflict,
+		},
+		{
+			name: "pipelined fn error",
+			fn: func(t *testing.T, tx ecache.Tx) error {
+				return tx.Pipelined(context.Background(), func(p ecache.Pipeline) error {
+					return errors.New("mock error")
+				})
+			},
+			wantCmds: []string{"watch", "unwatch"},
+			wantErr:  errors.New("mock error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+			hook := &txHook{exec: tc.exec}
+			rdb.AddHook(hook)
+			err := NewCache(rdb).Watch(context.Background(), func(tx ecache.Tx) error {
+				return tc.fn(t, tx)
+			}, "cnt")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantCmds, hook.names)
+		})
+	}
+}
+
+func TestCache_WatchNotSupported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	err := NewCache(mocks.NewMockCmdable(ctrl)).Watch(context.Background(), func(tx ecache.Tx) error {
+		return nil
+	}, "cnt")
+	assert.Equal(t, errs.ErrNotSupported, err)
+}
+
+// txHook 拦截所有的命令，不会真的发送给 Redis
+type txHook struct {
+	names []string
+	exec  func(cmds []redis.Cmder) error
+}
+
+func (h *txHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("不应该建立连接")
+	}
+}
+
+func (h *txHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.names = append(h.names, cmd.Name())
+		if c, ok := cmd.(*redis.StringCmd); ok {
+			c.SetVal("10")
+		}
+		return nil
+	}
+}
+
+func (h *txHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.names = append(h.names, cmd.Name())
+		}
+		err := h.exec(cmds)
+		if err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+		}
+		return err
+	}
+}
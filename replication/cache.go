@@ -0,0 +1,480 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/internal/resp"
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+var _ ecache.Cache = (*Cache)(nil)
+
+var (
+	// ErrWriteQuorum 写入成功的副本数量没有达到 quorum
+	ErrWriteQuorum = errors.New("ecache: 写入成功的副本数量没有达到 quorum")
+	// ErrNoReplica 没有任何副本
+	ErrNoReplica = errors.New("ecache: 没有可用的副本")
+)
+
+// ReadPreference 读请求优先发送给哪个副本
+type ReadPreference int
+
+const (
+	// ReadPrimary 优先读第一个副本，也就是主副本
+	ReadPrimary ReadPreference = iota
+	// ReadNearest 优先读平均耗时最短的副本
+	ReadNearest
+	// ReadRandom 随机选择一个副本
+	ReadRandom
+)
+
+// Cache 在多个缓存上保存相同的数据，例如部署在不同机架上的两个 Redis。
+// 写请求并发地发送给所有副本，成功的副本数量达到 quorum 就立刻返回，返回值以这时已经成功的副本中序号最小的为准（优先主副本），
+// 剩余的副本在后台继续写入。
+// 读请求按照 ReadPreference 发送给一个副本，失败的时候依次尝试其它副本。
+//
+// LPop、BLPop、BRPop、BLMove、LMove 和 RPopLPush 这类会取走元素的操作只在一个副本上执行：
+// 按照序号依次尝试，第一个没有出错的副本（优先主副本）的结果就是最终结果，key 不存在也是最终结果。
+// 取到了元素之后，其它副本在后台删除同一个元素（移动类的操作在其它副本上执行不阻塞的 LMove），
+// 这样元素不会因为各个副本各自取走不同的元素而丢失。
+//
+// IncrBy、DecrBy 和 IncrByFloat 不支持，直接返回 errs.ErrNotSupported：
+// 各个副本各自累加的结果一旦不一致就无法恢复，读修复也会丢掉计数器原本的过期时间。
+//
+// 副本之间没有任何协调：写入失败的副本以及 SetNX、CompareAndSwap 这类条件写入都可能导致副本不一致，
+// 可以通过 WithReadRepair 在读取之后修复不一致的副本
+type Cache struct {
+	replicas   []ecache.Cache
+	quorum     int
+	preference ReadPreference
+	// latencies 每个副本读请求的平均耗时，单位为纳秒，用于 ReadNearest
+	latencies []atomic.Int64
+
+	readRepair    bool
+	repairTTL     time.Duration
+	repairTimeout time.Duration
+}
+
+// NewCache 第一个副本是主副本。默认的写 quorum 是副本数量的一半加一
+func NewCache(replicas []ecache.Cache, opts ...option.Option[Cache]) *Cache {
+	res := &Cache{
+		replicas:      replicas,
+		quorum:        len(replicas)/2 + 1,
+		preference:    ReadPrimary,
+		latencies:     make([]atomic.Int64, len(replicas)),
+		repairTimeout: time.Second,
+	}
+	option.Apply(res, opts...)
+	return res
+}
+
+// WithWriteQuorum 设置写请求至少在多少个副本上成功才算成功
+func WithWriteQuorum(quorum int) option.Option[Cache] {
+	return func(c *Cache) {
+		c.quorum = quorum
+	}
+}
+
+// WithReadPreference 设置读请求优先发送给哪个副本，默认为 ReadPrimary
+func WithReadPreference(preference ReadPreference) option.Option[Cache] {
+	return func(c *Cache) {
+		c.preference = preference
+	}
+}
+
+// WithReadRepair 开启读修复。Get 成功之后会在后台读取所有的副本，按照多数派的结果修复其它副本：
+// 多数副本上存在的值以 ttl 的过期时间写回缺失或者不一致的副本，多数副本上不存在的 key 从其它副本上删除。
+// 因为 Get 拿不到过期时间，所以修复写入的过期时间固定为 ttl
+func WithReadRepair(ttl time.Duration) option.Option[Cache] {
+	return func(c *Cache) {
+		c.readRepair = true
+		c.repairTTL = ttl
+	}
+}
+
+// WithRepairTimeout 设置一次读修复的超时时间，默认为一秒
+func WithRepairTimeout(timeout time.Duration) option.Option[Cache] {
+	return func(c *Cache) {
+		c.repairTimeout = timeout
+	}
+}
+
+func (c *Cache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	_, err := write(c, func(replica ecache.Cache) (struct{}, error) {
+		return struct{}{}, replica.Set(ctx, key, val, expiration)
+	})
+	return err
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	return write(c, func(replica ecache.Cache) (bool, error) {
+		return replica.SetNX(ctx, key, val, expiration)
+	})
+}
+
+func (c *Cache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	return write(c, func(replica ecache.Cache) (bool, error) {
+		return replica.SetXX(ctx, key, val, expiration)
+	})
+}
+
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	return write(c, func(replica ecache.Cache) (bool, error) {
+		return replica.CompareAndSwap(ctx, key, old, new, expiration)
+	})
+}
+
+// Get 按照 ReadPreference 读取一个副本，出错的时候依次尝试其它副本，key 不存在不算出错
+func (c *Cache) Get(ctx context.Context, key string) (res ecache.Value) {
+	if len(c.replicas) == 0 {
+		res.Err = ErrNoReplica
+		return
+	}
+	for _, i := range c.readOrder() {
+		start := time.Now()
+		res = c.replicas[i].Get(ctx, key)
+		if res.Err != nil && !res.KeyNotFound() {
+			// 出错的副本按照一秒计算耗时，避免 ReadNearest 继续优先读它
+			c.observe(i, time.Second)
+			continue
+		}
+		c.observe(i, time.Since(start))
+		if c.readRepair && len(c.replicas) > 1 {
+			go c.repair(key, i, res)
+		}
+		return
+	}
+	return
+}
+
+func (c *Cache) GetSet(ctx context.Context, key string, val string) ecache.Value {
+	return writeValue(c, func(replica ecache.Cache) ecache.Value {
+		return replica.GetSet(ctx, key, val)
+	})
+}
+
+func (c *Cache) Delete(ctx context.Context, key ...string) (int64, error) {
+	return write(c, func(replica ecache.Cache) (int64, error) {
+		return replica.Delete(ctx, key...)
+	})
+}
+
+func (c *Cache) LPush(ctx context.Context, key string, val ...any) (int64, error) {
+	return write(c, func(replica ecache.Cache) (int64, error) {
+		return replica.LPush(ctx, key, val...)
+	})
+}
+
+func (c *Cache) LPop(ctx context.Context, key string) ecache.Value {
+	return c.pop(func(replica ecache.Cache) ecache.Value {
+		return replica.LPop(ctx, key)
+	}, func(ctx context.Context, replica ecache.Cache, val ecache.Value) {
+		_, _ = replica.LRem(ctx, key, 1, val.Val)
+	})
+}
+
+func (c *Cache) BLPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return c.pop(func(replica ecache.Cache) ecache.Value {
+		return replica.BLPop(ctx, key, timeout)
+	}, func(ctx context.Context, replica ecache.Cache, val ecache.Value) {
+		_, _ = replica.LRem(ctx, key, 1, val.Val)
+	})
+}
+
+func (c *Cache) BRPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return c.pop(func(replica ecache.Cache) ecache.Value {
+		return replica.BRPop(ctx, key, timeout)
+	}, func(ctx context.Context, replica ecache.Cache, val ecache.Value) {
+		_, _ = replica.LRem(ctx, key, -1, val.Val)
+	})
+}
+
+func (c *Cache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) ecache.Value {
+	return c.pop(func(replica ecache.Cache) ecache.Value {
+		return replica.BLMove(ctx, source, destination, srcPos, destPos, timeout)
+	}, func(ctx context.Context, replica ecache.Cache, _ ecache.Value) {
+		_ = replica.LMove(ctx, source, destination, srcPos, destPos)
+	})
+}
+
+func (c *Cache) LMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection) ecache.Value {
+	return c.pop(func(replica ecache.Cache) ecache.Value {
+		return replica.LMove(ctx, source, destination, srcPos, destPos)
+	}, func(ctx context.Context, replica ecache.Cache, _ ecache.Value) {
+		_ = replica.LMove(ctx, source, destination, srcPos, destPos)
+	})
+}
+
+func (c *Cache) RPopLPush(ctx context.Context, source, destination string) ecache.Value {
+	return c.pop(func(replica ecache.Cache) ecache.Value {
+		return replica.RPopLPush(ctx, source, destination)
+	}, func(ctx context.Context, replica ecache.Cache, _ ecache.Value) {
+		_ = replica.LMove(ctx, source, destination, ecache.ListRight, ecache.ListLeft)
+	})
+}
+
+func (c *Cache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	return write(c, func(replica ecache.Cache) (int64, error) {
+		return replica.LRem(ctx, key, count, val)
+	})
+}
+
+func (c *Cache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
+	return write(c, func(replica ecache.Cache) (int64, error) {
+		return replica.SAdd(ctx, key, members...)
+	})
+}
+
+func (c *Cache) SRem(ctx context.Context, key string, members ...any) (int64, error) {
+	return write(c, func(replica ecache.Cache) (int64, error) {
+		return replica.SRem(ctx, key, members...)
+	})
+}
+
+// IncrBy 不支持，参考 Cache 的说明
+func (c *Cache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	return 0, errs.ErrNotSupported
+}
+
+// DecrBy 不支持，参考 Cache 的说明
+func (c *Cache) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
+	return 0, errs.ErrNotSupported
+}
+
+// IncrByFloat 不支持，参考 Cache 的说明
+func (c *Cache) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
+	return 0, errs.ErrNotSupported
+}
+
+// pop 按照序号依次在副本上执行 fn，直到某个副本没有出错，key 不存在也算没有出错。
+// 取到了元素之后，在后台通过 replicate 让其它副本删除或者移动同一个元素。
+// 后台的操作不使用调用者的 ctx，超时时间和读修复一样
+func (c *Cache) pop(fn func(replica ecache.Cache) ecache.Value,
+	replicate func(ctx context.Context, replica ecache.Cache, val ecache.Value)) (val ecache.Value) {
+	if len(c.replicas) == 0 {
+		val.Err = ErrNoReplica
+		return
+	}
+	for i, replica := range c.replicas {
+		val = fn(replica)
+		if val.KeyNotFound() {
+			return
+		}
+		if val.Err != nil {
+			continue
+		}
+		go func(idx int) {
+			ctx, cancel := context.WithTimeout(context.Background(), c.repairTimeout)
+			defer cancel()
+			var wg sync.WaitGroup
+			for j, other := range c.replicas {
+				if j == idx {
+					continue
+				}
+				wg.Add(1)
+				go func(other ecache.Cache) {
+					defer wg.Done()
+					replicate(ctx, other, val)
+				}(other)
+			}
+			wg.Wait()
+		}(i)
+		return
+	}
+	return
+}
+
+type result[T any] struct {
+	idx int
+	val T
+	err error
+}
+
+// write 并发地在所有副本上执行 fn，成功的副本数量达到 quorum 之后立刻返回，
+// 返回值以这时已经成功的副本中序号最小的为准，剩余的副本在后台继续写入，不会拖慢这次写入。
+// 因为后台的写入使用的依旧是调用者的 ctx，所以 ctx 被取消之后它们可能会失败，不一致的副本可以通过读修复修复。
+// key 不存在不算失败
+func write[T any](c *Cache, fn func(replica ecache.Cache) (T, error)) (T, error) {
+	// 带缓冲，返回之后还没有完成的副本也不会阻塞
+	results := make(chan result[T], len(c.replicas))
+	for i, replica := range c.replicas {
+		go func(i int, replica ecache.Cache) {
+			val, err := fn(replica)
+			results <- result[T]{idx: i, val: val, err: err}
+		}(i, replica)
+	}
+
+	succeeded, failed := 0, 0
+	first := result[T]{idx: -1}
+	var firstErr error
+	for range c.replicas {
+		res := <-results
+		if res.err == nil || errors.Is(res.err, errs.ErrKeyNotExist) {
+			succeeded++
+			if first.idx < 0 || res.idx < first.idx {
+				first = res
+			}
+		} else {
+			failed++
+			if firstErr == nil {
+				firstErr = res.err
+			}
+		}
+		if succeeded >= c.quorum && first.idx >= 0 {
+			return first.val, first.err
+		}
+		// 剩下的副本全部成功也达不到 quorum 了
+		if len(c.replicas)-failed < c.quorum {
+			break
+		}
+	}
+	var zero T
+	if firstErr == nil {
+		return zero, ErrWriteQuorum
+	}
+	return zero, fmt.Errorf("%w: %w", ErrWriteQuorum, firstErr)
+}
+
+func writeValue(c *Cache, fn func(replica ecache.Cache) ecache.Value) ecache.Value {
+	val, err := write(c, func(replica ecache.Cache) (ecache.Value, error) {
+		val := fn(replica)
+		return val, val.Err
+	})
+	// 没有达到 quorum 的时候 val 是零值
+	val.Err = err
+	return val
+}
+
+// readOrder 按照 ReadPreference 返回读取副本的顺序
+func (c *Cache) readOrder() []int {
+	order := make([]int, len(c.replicas))
+	for i := range order {
+		order[i] = i
+	}
+	switch c.preference {
+	case ReadNearest:
+		sort.SliceStable(order, func(i, j int) bool {
+			return c.latencies[order[i]].Load() < c.latencies[order[j]].Load()
+		})
+	case ReadRandom:
+		rand.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+	}
+	return order
+}
+
+// observe 使用指数加权移动平均记录副本的耗时，新的耗时占五分之一
+func (c *Cache) observe(i int, latency time.Duration) {
+	for {
+		old := c.latencies[i].Load()
+		updated := int64(latency)
+		if old > 0 {
+			updated = old - old/5 + int64(latency)/5
+		}
+		if c.latencies[i].CompareAndSwap(old, updated) {
+			return
+		}
+	}
+}
+
+// repair 读取所有副本，把和多数派不一致的副本修复为多数派的结果，
+// read 是第 readIdx 个副本刚刚读出来的结果，平票的时候以它为准
+func (c *Cache) repair(key string, readIdx int, read ecache.Value) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.repairTimeout)
+	defer cancel()
+
+	values := make([]ecache.Value, len(c.replicas))
+	var wg sync.WaitGroup
+	for i, replica := range c.replicas {
+		if i == readIdx {
+			values[i] = read
+			continue
+		}
+		wg.Add(1)
+		go func(i int, replica ecache.Cache) {
+			defer wg.Done()
+			values[i] = replica.Get(ctx, key)
+		}(i, replica)
+	}
+	wg.Wait()
+
+	// 出错的副本不参与投票，也不修复。先统计所有副本的票数，再选出票数最多的结果
+	votes := make([]int, len(values))
+	for i, val := range values {
+		if !usable(val) {
+			continue
+		}
+		for j := range values {
+			if usable(values[j]) && sameValue(values[j], val) {
+				votes[i]++
+			}
+		}
+	}
+	winner := readIdx
+	for i := range values {
+		if votes[i] > votes[winner] {
+			winner = i
+		}
+	}
+
+	want := values[winner]
+	for i, val := range values {
+		if !usable(val) || sameValue(val, want) {
+			continue
+		}
+		if want.KeyNotFound() {
+			_, _ = c.replicas[i].Delete(ctx, key)
+		} else {
+			_ = c.replicas[i].Set(ctx, key, want.Val, c.repairTTL)
+		}
+	}
+}
+
+// usable 出错的副本读出来的结果不可用，key 不存在是可用的结果
+func usable(val ecache.Value) bool {
+	return val.Err == nil || val.KeyNotFound()
+}
+
+// sameValue 判断两个副本读出来的结果是否一致。
+// Redis 返回的都是字符串，本地缓存返回的是原本的类型，所以按照写入 Redis 时的规则转换为字符串之后比较
+func sameValue(a, b ecache.Value) bool {
+	if a.KeyNotFound() || b.KeyNotFound() {
+		return a.KeyNotFound() && b.KeyNotFound()
+	}
+	if a.Err != nil || b.Err != nil {
+		return false
+	}
+	as, aErr := resp.String(a.Val)
+	bs, bErr := resp.String(b.Val)
+	if aErr != nil || bErr != nil {
+		return reflect.DeepEqual(a.Val, b.Val)
+	}
+	return as == bs
+}
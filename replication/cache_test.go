@@ -0,0 +1,392 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/ecodeclub/ekit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_WriteQuorum(t *testing.T) {
+	ctx := context.Background()
+	replicas := newReplicas(3)
+	c := NewCache(replicas.caches())
+
+	require.NoError(t, c.Set(ctx, "key", "v1", time.Minute))
+	// 达到 quorum 就返回了，剩下的副本在后台写入
+	for _, r := range replicas {
+		r := r
+		assert.Eventually(t, func() bool {
+			return r.Cache.Get(ctx, "key").Val == "v1"
+		}, time.Second, time.Millisecond*10)
+	}
+
+	// 一个副本失败，仍然达到默认的 quorum 2
+	replicas[2].fail.Store(true)
+	require.NoError(t, c.Set(ctx, "key", "v2", time.Minute))
+	assert.Equal(t, "v2", replicas[0].Cache.Get(ctx, "key").Val)
+	assert.Equal(t, "v1", replicas[2].Cache.Get(ctx, "key").Val)
+
+	// 两个副本失败
+	replicas[1].fail.Store(true)
+	err := c.Set(ctx, "key", "v3", time.Minute)
+	assert.ErrorIs(t, err, ErrWriteQuorum)
+	assert.ErrorIs(t, err, errMock)
+	_, err = c.Delete(ctx, "key")
+	assert.ErrorIs(t, err, ErrWriteQuorum)
+
+	// 要求所有副本都成功
+	replicas[1].fail.Store(false)
+	c = NewCache(replicas.caches(), WithWriteQuorum(3))
+	assert.ErrorIs(t, c.Set(ctx, "key", "v3", time.Minute), ErrWriteQuorum)
+	replicas[2].fail.Store(false)
+	require.NoError(t, c.Set(ctx, "key", "v3", time.Minute))
+
+	assert.Equal(t, ErrWriteQuorum, NewCache(nil).Set(ctx, "key", "v1", time.Minute))
+}
+
+func TestCache_WriteResult(t *testing.T) {
+	ctx := context.Background()
+	replicas := newReplicas(3)
+	// 要求所有副本都成功，返回的时候所有副本都已经写入了
+	c := NewCache(replicas.caches(), WithWriteQuorum(3))
+
+	// 副本不一致的时候以主副本的结果为准
+	require.NoError(t, replicas[0].Cache.Set(ctx, "key", "v0", time.Minute))
+	val := c.GetSet(ctx, "key", "v1")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "v0", val.Val)
+	// 主副本失败的时候以序号最小的成功的副本为准
+	replicas[0].fail.Store(true)
+	val = NewCache(replicas.caches()).GetSet(ctx, "key", "v2")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "v1", val.Val)
+	replicas[0].fail.Store(false)
+
+	// 计数器不支持
+	_, err := c.IncrBy(ctx, "cnt", 1)
+	assert.Equal(t, errs.ErrNotSupported, err)
+	_, err = c.DecrBy(ctx, "cnt", 1)
+	assert.Equal(t, errs.ErrNotSupported, err)
+	_, err = c.IncrByFloat(ctx, "cnt", 1)
+	assert.Equal(t, errs.ErrNotSupported, err)
+
+	// key 不存在不算出错
+	val = c.LPop(ctx, "list")
+	assert.True(t, val.KeyNotFound())
+	n, err := c.LPush(ctx, "list", "a", "b")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	val = c.LPop(ctx, "list")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "b", val.Val)
+	for _, r := range replicas {
+		r := r
+		assert.Eventually(t, func() bool {
+			return r.Cache.LPop(ctx, "list").Val == "a"
+		}, time.Second, time.Millisecond*10)
+	}
+
+	ok, err := c.SetNX(ctx, "nx", 1, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = c.SetNX(ctx, "nx", 1, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestCache_Pop 取走元素的操作只在一个副本上执行，其它副本在后台删除同一个元素
+func TestCache_Pop(t *testing.T) {
+	ctx := context.Background()
+	replicas := newReplicas(3)
+	c := NewCache(replicas.caches(), WithWriteQuorum(1))
+
+	// 主副本上没有元素，其它副本上的元素不能被取走
+	_, err := replicas[1].Cache.LPush(ctx, "list", "a")
+	require.NoError(t, err)
+	assert.True(t, c.LPop(ctx, "list").KeyNotFound())
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, "a", replicas[1].Cache.LPop(ctx, "list").Val)
+
+	// 主副本取走的元素在其它副本上也会被删除，即便它在其它副本上的位置不一样
+	_, err = replicas[0].Cache.LPush(ctx, "list2", "b", "a")
+	require.NoError(t, err)
+	_, err = replicas[1].Cache.LPush(ctx, "list2", "a", "b")
+	require.NoError(t, err)
+	_, err = replicas[2].Cache.LPush(ctx, "list2", "c", "a")
+	require.NoError(t, err)
+	val := c.LPop(ctx, "list2")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	// 等待后台的删除完成
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, "b", replicas[1].Cache.LPop(ctx, "list2").Val)
+	assert.Equal(t, "c", replicas[2].Cache.LPop(ctx, "list2").Val)
+
+	// 主副本出错的时候在下一个副本上执行
+	replicas[0].fail.Store(true)
+	_, err = replicas[1].Cache.LPush(ctx, "list3", "c")
+	require.NoError(t, err)
+	_, err = replicas[2].Cache.LPush(ctx, "list3", "d", "c")
+	require.NoError(t, err)
+	val = c.LPop(ctx, "list3")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "c", val.Val)
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, "d", replicas[2].Cache.LPop(ctx, "list3").Val)
+
+	// 所有副本都出错
+	for _, r := range replicas {
+		r.fail.Store(true)
+	}
+	assert.Equal(t, errMock, c.LPop(ctx, "list").Err)
+	assert.Equal(t, ErrNoReplica, NewCache(nil).LPop(ctx, "list").Err)
+}
+
+// TestCache_BlockingPop 阻塞的操作只在主副本上阻塞，其它副本在后台执行不阻塞的版本
+func TestCache_BlockingPop(t *testing.T) {
+	ctx := context.Background()
+	replicas := newReplicas(2)
+	c := NewCache(replicas.caches(), WithWriteQuorum(1))
+
+	for _, r := range replicas {
+		_, err := r.Cache.LPush(ctx, "src", "a")
+		require.NoError(t, err)
+	}
+	val := c.BLMove(ctx, "src", "dst", ecache.ListRight, ecache.ListLeft, time.Second)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "a", val.Val)
+	assert.Eventually(t, func() bool {
+		return replicas[1].Cache.LPop(ctx, "dst").Val == "a"
+	}, time.Second, time.Millisecond*10)
+
+	// 超时之后返回 key 不存在，其它副本不受影响
+	_, err := replicas[1].Cache.LPush(ctx, "list", "b")
+	require.NoError(t, err)
+	assert.True(t, c.BRPop(ctx, "list", time.Millisecond*10).KeyNotFound())
+	assert.Equal(t, "b", replicas[1].Cache.LPop(ctx, "list").Val)
+}
+
+func TestCache_WriteNotBlockedBySlowReplica(t *testing.T) {
+	ctx := context.Background()
+	replicas := newReplicas(3)
+	block := make(chan struct{})
+	replicas[0].block = block
+	c := NewCache(replicas.caches())
+
+	// 主副本卡住了，另外两个副本达到了 quorum
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Set(ctx, "key", "v1", time.Minute)
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("写入被卡住的副本阻塞了")
+	}
+	assert.Equal(t, "v1", replicas[1].Cache.Get(ctx, "key").Val)
+	assert.True(t, replicas[0].Cache.Get(ctx, "key").KeyNotFound())
+
+	// 卡住的副本恢复之后在后台完成写入
+	close(block)
+	assert.Eventually(t, func() bool {
+		return replicas[0].Cache.Get(ctx, "key").Val == "v1"
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestCache_ReadPreference(t *testing.T) {
+	ctx := context.Background()
+	replicas := newReplicas(3)
+	for i, r := range replicas {
+		require.NoError(t, r.Cache.Set(ctx, "key", i, time.Minute))
+	}
+
+	c := NewCache(replicas.caches())
+	assert.Equal(t, 0, c.Get(ctx, "key").Val)
+	// 主副本失败的时候读其它副本
+	replicas[0].fail.Store(true)
+	assert.Equal(t, 1, c.Get(ctx, "key").Val)
+	replicas[1].fail.Store(true)
+	assert.Equal(t, 2, c.Get(ctx, "key").Val)
+	replicas[2].fail.Store(true)
+	assert.ErrorIs(t, c.Get(ctx, "key").Err, errMock)
+	for _, r := range replicas {
+		r.fail.Store(false)
+	}
+
+	c = NewCache(replicas.caches(), WithReadPreference(ReadRandom))
+	seen := make(map[any]struct{}, 3)
+	for i := 0; i < 100; i++ {
+		seen[c.Get(ctx, "key").Val] = struct{}{}
+	}
+	assert.Len(t, seen, 3)
+
+	replicas[0].delay = time.Millisecond * 20
+	replicas[1].delay = time.Millisecond * 10
+	c = NewCache(replicas.caches(), WithReadPreference(ReadNearest))
+	// 每个副本都至少读一次之后，总是读最快的副本
+	for i := 0; i < 3; i++ {
+		c.Get(ctx, "key")
+	}
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, 2, c.Get(ctx, "key").Val)
+	}
+	// 最快的副本出错之后不再优先读它
+	replicas[2].fail.Store(true)
+	c.Get(ctx, "key")
+	replicas[2].fail.Store(false)
+	assert.Equal(t, 1, c.Get(ctx, "key").Val)
+
+	assert.Equal(t, ErrNoReplica, NewCache(nil).Get(ctx, "key").Err)
+}
+
+func TestCache_ReadRepair(t *testing.T) {
+	ctx := context.Background()
+	replicas := newReplicas(3)
+	c := NewCache(replicas.caches(), WithReadRepair(time.Minute))
+
+	// 写入的时候一个副本失败，读取之后修复
+	replicas[2].fail.Store(true)
+	require.NoError(t, c.Set(ctx, "missing", "v1", time.Minute))
+	replicas[2].fail.Store(false)
+	assert.Equal(t, "v1", c.Get(ctx, "missing").Val)
+	assert.Eventually(t, func() bool {
+		return replicas[2].Cache.Get(ctx, "missing").Val == "v1"
+	}, time.Second, time.Millisecond*10)
+
+	// 主副本上是旧值，读出来的是旧值，但是会按照多数派修复
+	require.NoError(t, replicas[0].Cache.Set(ctx, "stale", "old", time.Minute))
+	require.NoError(t, replicas[1].Cache.Set(ctx, "stale", "new", time.Minute))
+	require.NoError(t, replicas[2].Cache.Set(ctx, "stale", "new", time.Minute))
+	assert.Equal(t, "old", c.Get(ctx, "stale").Val)
+	assert.Eventually(t, func() bool {
+		return replicas[0].Cache.Get(ctx, "stale").Val == "new"
+	}, time.Second, time.Millisecond*10)
+
+	// 多数副本上不存在的 key 会被删除
+	require.NoError(t, replicas[0].Cache.Set(ctx, "ghost", "boo", time.Minute))
+	assert.Equal(t, "boo", c.Get(ctx, "ghost").Val)
+	assert.Eventually(t, func() bool {
+		return replicas[0].Cache.Get(ctx, "ghost").KeyNotFound()
+	}, time.Second, time.Millisecond*10)
+
+	// 按照字符串比较，int 1 和 "1" 是一致的，出错的副本不参与投票
+	require.NoError(t, replicas[0].Cache.Set(ctx, "num", 1, time.Minute))
+	require.NoError(t, replicas[1].Cache.Set(ctx, "num", "1", time.Minute))
+	require.NoError(t, replicas[2].Cache.Set(ctx, "num", "2", time.Minute))
+	replicas[1].fail.Store(true)
+	assert.Equal(t, 1, c.Get(ctx, "num").Val)
+	replicas[1].fail.Store(false)
+	assert.Eventually(t, func() bool {
+		return replicas[2].Cache.Get(ctx, "num").Val == 1
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, "1", replicas[1].Cache.Get(ctx, "num").Val)
+}
+
+func TestCache_RepairTie(t *testing.T) {
+	ctx := context.Background()
+	replicas := newReplicas(2)
+	c := NewCache(replicas.caches(), WithReadRepair(time.Minute))
+
+	// 平票的时候以读出来的结果为准：从缺失的副本读，删除另一个副本上的值
+	require.NoError(t, replicas[0].Cache.Set(ctx, "key", "x", time.Minute))
+	c.repair("key", 1, replicas[1].Get(ctx, "key"))
+	assert.True(t, replicas[1].Cache.Get(ctx, "key").KeyNotFound())
+	assert.True(t, replicas[0].Cache.Get(ctx, "key").KeyNotFound())
+
+	// 从有值的副本读，把值写回缺失的副本
+	require.NoError(t, replicas[0].Cache.Set(ctx, "key", "x", time.Minute))
+	c.repair("key", 0, replicas[0].Get(ctx, "key"))
+	assert.Equal(t, "x", replicas[1].Cache.Get(ctx, "key").Val)
+}
+
+var errMock = errors.New("mock error")
+
+// faultyCache 用于注入故障，fail 为 true 的时候所有操作都失败，delay 模拟 Get 的网络延迟，
+// block 不为 nil 的时候 Set 会一直阻塞到它被关闭，模拟卡住的副本
+type faultyCache struct {
+	ecache.Cache
+	fail  atomic.Bool
+	delay time.Duration
+	block chan struct{}
+}
+
+type faultyCaches []*faultyCache
+
+func newReplicas(n int) faultyCaches {
+	res := make(faultyCaches, 0, n)
+	for i := 0; i < n; i++ {
+		res = append(res, &faultyCache{Cache: lru.NewCache(100)})
+	}
+	return res
+}
+
+func (f faultyCaches) caches() []ecache.Cache {
+	res := make([]ecache.Cache, 0, len(f))
+	for _, c := range f {
+		res = append(res, c)
+	}
+	return res
+}
+
+func (f *faultyCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if f.block != nil {
+		<-f.block
+	}
+	if f.fail.Load() {
+		return errMock
+	}
+	return f.Cache.Set(ctx, key, val, expiration)
+}
+
+func (f *faultyCache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	if f.fail.Load() {
+		return false, errMock
+	}
+	return f.Cache.SetNX(ctx, key, val, expiration)
+}
+
+func (f *faultyCache) Get(ctx context.Context, key string) ecache.Value {
+	time.Sleep(f.delay)
+	if f.fail.Load() {
+		return ecache.Value{AnyValue: ekit.AnyValue{Err: errMock}}
+	}
+	return f.Cache.Get(ctx, key)
+}
+
+func (f *faultyCache) Delete(ctx context.Context, key ...string) (int64, error) {
+	if f.fail.Load() {
+		return 0, errMock
+	}
+	return f.Cache.Delete(ctx, key...)
+}
+
+func (f *faultyCache) LPop(ctx context.Context, key string) ecache.Value {
+	if f.fail.Load() {
+		return ecache.Value{AnyValue: ekit.AnyValue{Err: errMock}}
+	}
+	return f.Cache.LPop(ctx, key)
+}
@@ -0,0 +1,310 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+var _ ecache.Cache = (*Cache)(nil)
+
+// Op 缓存操作的名字，用于指定哪些操作可以重试
+type Op string
+
+const (
+	OpSet         Op = "Set"
+	OpSetNX       Op = "SetNX"
+	OpSetXX       Op = "SetXX"
+	OpCAS         Op = "CompareAndSwap"
+	OpGet         Op = "Get"
+	OpGetSet      Op = "GetSet"
+	OpDelete      Op = "Delete"
+	OpLPush       Op = "LPush"
+	OpLPop        Op = "LPop"
+	OpBLPop       Op = "BLPop"
+	OpBRPop       Op = "BRPop"
+	OpBLMove      Op = "BLMove"
+	OpLMove       Op = "LMove"
+	OpRPopLPush   Op = "RPopLPush"
+	OpLRem        Op = "LRem"
+	OpSAdd        Op = "SAdd"
+	OpSRem        Op = "SRem"
+	OpIncrBy      Op = "IncrBy"
+	OpDecrBy      Op = "DecrBy"
+	OpIncrByFloat Op = "IncrByFloat"
+)
+
+// idempotentOps 默认可以重试的幂等操作
+var idempotentOps = []Op{OpGet, OpSet, OpDelete, OpSAdd, OpSRem}
+
+// Cache 带重试的缓存装饰器。
+// 默认只重试幂等的操作：Get、Set、Delete、SAdd、SRem。
+// 像 IncrBy、LPush、LPop、BLPop、CompareAndSwap 这种非幂等的操作，如果请求已经执行成功但是响应丢失了，
+// 重试会导致重复执行，所以只有通过 WithRetryOn 明确指定之后才会重试。
+// 重试的间隔按照指数退避增长，并且加上随机抖动；如果 ctx 的剩余时间不足以等到下一次重试，那么直接返回
+type Cache struct {
+	ecache.Cache
+	ops         map[Op]struct{}
+	maxAttempts int
+	initial     time.Duration
+	max         time.Duration
+	retryable   func(err error) bool
+}
+
+func NewCache(c ecache.Cache, opts ...option.Option[Cache]) *Cache {
+	res := &Cache{
+		Cache:       c,
+		ops:         make(map[Op]struct{}, len(idempotentOps)),
+		maxAttempts: 3,
+		initial:     time.Millisecond * 10,
+		max:         time.Second,
+		retryable:   isRetryable,
+	}
+	for _, op := range idempotentOps {
+		res.ops[op] = struct{}{}
+	}
+	option.Apply(res, opts...)
+	return res
+}
+
+// WithMaxAttempts 设置最多执行多少次，包括第一次执行
+func WithMaxAttempts(attempts int) option.Option[Cache] {
+	return func(c *Cache) {
+		c.maxAttempts = attempts
+	}
+}
+
+// WithBackoff 设置指数退避的初始间隔和最大间隔
+func WithBackoff(initial, max time.Duration) option.Option[Cache] {
+	return func(c *Cache) {
+		c.initial = initial
+		c.max = max
+	}
+}
+
+// WithRetryOn 额外指定可以重试的操作，一般用于非幂等的操作。
+// 只有在业务能够接受重复执行的时候才应该使用
+func WithRetryOn(ops ...Op) option.Option[Cache] {
+	return func(c *Cache) {
+		for _, op := range ops {
+			c.ops[op] = struct{}{}
+		}
+	}
+}
+
+// WithRetryable 设置判断一个错误是否可以重试的方法。
+// 默认情况下只重试网络相关的错误
+func WithRetryable(fn func(err error) bool) option.Option[Cache] {
+	return func(c *Cache) {
+		c.retryable = fn
+	}
+}
+
+func (c *Cache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	_, err := do(ctx, c, OpSet, func() (struct{}, error) {
+		return struct{}{}, c.Cache.Set(ctx, key, val, expiration)
+	})
+	return err
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	return do(ctx, c, OpSetNX, func() (bool, error) {
+		return c.Cache.SetNX(ctx, key, val, expiration)
+	})
+}
+
+func (c *Cache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	return do(ctx, c, OpSetXX, func() (bool, error) {
+		return c.Cache.SetXX(ctx, key, val, expiration)
+	})
+}
+
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	return do(ctx, c, OpCAS, func() (bool, error) {
+		return c.Cache.CompareAndSwap(ctx, key, old, new, expiration)
+	})
+}
+
+func (c *Cache) Get(ctx context.Context, key string) ecache.Value {
+	return doValue(ctx, c, OpGet, func() ecache.Value {
+		return c.Cache.Get(ctx, key)
+	})
+}
+
+func (c *Cache) GetSet(ctx context.Context, key string, val string) ecache.Value {
+	return doValue(ctx, c, OpGetSet, func() ecache.Value {
+		return c.Cache.GetSet(ctx, key, val)
+	})
+}
+
+func (c *Cache) Delete(ctx context.Context, key ...string) (int64, error) {
+	return do(ctx, c, OpDelete, func() (int64, error) {
+		return c.Cache.Delete(ctx, key...)
+	})
+}
+
+func (c *Cache) LPush(ctx context.Context, key string, val ...any) (int64, error) {
+	return do(ctx, c, OpLPush, func() (int64, error) {
+		return c.Cache.LPush(ctx, key, val...)
+	})
+}
+
+func (c *Cache) LPop(ctx context.Context, key string) ecache.Value {
+	return doValue(ctx, c, OpLPop, func() ecache.Value {
+		return c.Cache.LPop(ctx, key)
+	})
+}
+
+func (c *Cache) BLPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return doValue(ctx, c, OpBLPop, func() ecache.Value {
+		return c.Cache.BLPop(ctx, key, timeout)
+	})
+}
+
+func (c *Cache) BRPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	return doValue(ctx, c, OpBRPop, func() ecache.Value {
+		return c.Cache.BRPop(ctx, key, timeout)
+	})
+}
+
+func (c *Cache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) ecache.Value {
+	return doValue(ctx, c, OpBLMove, func() ecache.Value {
+		return c.Cache.BLMove(ctx, source, destination, srcPos, destPos, timeout)
+	})
+}
+
+func (c *Cache) LMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection) ecache.Value {
+	return doValue(ctx, c, OpLMove, func() ecache.Value {
+		return c.Cache.LMove(ctx, source, destination, srcPos, destPos)
+	})
+}
+
+func (c *Cache) RPopLPush(ctx context.Context, source, destination string) ecache.Value {
+	return doValue(ctx, c, OpRPopLPush, func() ecache.Value {
+		return c.Cache.RPopLPush(ctx, source, destination)
+	})
+}
+
+func (c *Cache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	return do(ctx, c, OpLRem, func() (int64, error) {
+		return c.Cache.LRem(ctx, key, count, val)
+	})
+}
+
+func (c *Cache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
+	return do(ctx, c, OpSAdd, func() (int64, error) {
+		return c.Cache.SAdd(ctx, key, members...)
+	})
+}
+
+func (c *Cache) SRem(ctx context.Context, key string, members ...any) (int64, error) {
+	return do(ctx, c, OpSRem, func() (int64, error) {
+		return c.Cache.SRem(ctx, key, members...)
+	})
+}
+
+func (c *Cache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	return do(ctx, c, OpIncrBy, func() (int64, error) {
+		return c.Cache.IncrBy(ctx, key, value)
+	})
+}
+
+func (c *Cache) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
+	return do(ctx, c, OpDecrBy, func() (int64, error) {
+		return c.Cache.DecrBy(ctx, key, value)
+	})
+}
+
+func (c *Cache) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
+	return do(ctx, c, OpIncrByFloat, func() (float64, error) {
+		return c.Cache.IncrByFloat(ctx, key, value)
+	})
+}
+
+func doValue(ctx context.Context, c *Cache, op Op, fn func() ecache.Value) ecache.Value {
+	val, _ := do(ctx, c, op, func() (ecache.Value, error) {
+		val := fn()
+		return val, val.Err
+	})
+	return val
+}
+
+// do 执行 fn，如果 op 允许重试并且错误可以重试，那么按照退避策略重试
+func do[T any](ctx context.Context, c *Cache, op Op, fn func() (T, error)) (T, error) {
+	res, err := fn()
+	if _, ok := c.ops[op]; !ok {
+		return res, err
+	}
+	for attempt := 1; attempt < c.maxAttempts && err != nil && c.retryable(err); attempt++ {
+		if !c.wait(ctx, c.backoff(attempt)) {
+			return res, err
+		}
+		res, err = fn()
+	}
+	return res, err
+}
+
+// backoff 计算第 attempt 次重试之前需要等待的时间，在指数退避的基础上随机取 [d/2, d)
+func (c *Cache) backoff(attempt int) time.Duration {
+	d := c.initial << (attempt - 1)
+	if d <= 0 || d > c.max {
+		d = c.max
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// wait 等待 d 时间，如果 ctx 已经结束或者剩余时间不足 d，那么返回 false
+func (c *Cache) wait(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// isRetryable 默认只重试网络相关的错误
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,239 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyCache 测试用的缓存，前 failures 次调用返回 err
+type flakyCache struct {
+	*lru.Cache
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyCache) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.Cache.Set(ctx, key, val, expiration)
+}
+
+func (f *flakyCache) Get(ctx context.Context, key string) ecache.Value {
+	if err := f.fail(); err != nil {
+		var val ecache.Value
+		val.Err = err
+		return val
+	}
+	return f.Cache.Get(ctx, key)
+}
+
+func (f *flakyCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	if err := f.fail(); err != nil {
+		return 0, err
+	}
+	return f.Cache.IncrBy(ctx, key, value)
+}
+
+func (f *flakyCache) BLPop(ctx context.Context, key string, timeout time.Duration) ecache.Value {
+	if err := f.fail(); err != nil {
+		var val ecache.Value
+		val.Err = err
+		return val
+	}
+	return f.Cache.BLPop(ctx, key, timeout)
+}
+
+func TestCache(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []option.Option[Cache]
+		failures int
+		err      error
+		call     func(ctx context.Context, c *Cache) error
+
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:     "retry idempotent operation",
+			failures: 2,
+			err:      io.EOF,
+			call: func(ctx context.Context, c *Cache) error {
+				return c.Set(ctx, "name", "大明", time.Minute)
+			},
+			wantCalls: 3,
+		},
+		{
+			name:     "exceed max attempts",
+			failures: 5,
+			err:      io.EOF,
+			call: func(ctx context.Context, c *Cache) error {
+				return c.Get(ctx, "name").Err
+			},
+			wantCalls: 3,
+			wantErr:   io.EOF,
+		},
+		{
+			name:     "not retry non-idempotent operation",
+			failures: 1,
+			err:      syscall.ECONNRESET,
+			call: func(ctx context.Context, c *Cache) error {
+				_, err := c.IncrBy(ctx, "counter", 1)
+				return err
+			},
+			wantCalls: 1,
+			wantErr:   syscall.ECONNRESET,
+		},
+		{
+			name:     "retry non-idempotent operation explicitly",
+			opts:     []option.Option[Cache]{WithRetryOn(OpIncrBy)},
+			failures: 1,
+			err:      &net.OpError{Op: "read", Err: errors.New("mock error")},
+			call: func(ctx context.Context, c *Cache) error {
+				_, err := c.IncrBy(ctx, "counter", 1)
+				return err
+			},
+			wantCalls: 2,
+		},
+		{
+			name:     "not retry blocking pop",
+			failures: 1,
+			err:      io.EOF,
+			call: func(ctx context.Context, c *Cache) error {
+				return c.BLPop(ctx, "list", time.Millisecond).Err
+			},
+			wantCalls: 1,
+			wantErr:   io.EOF,
+		},
+		{
+			name:     "retry blocking pop explicitly",
+			opts:     []option.Option[Cache]{WithRetryOn(OpBLPop)},
+			failures: 1,
+			err:      io.EOF,
+			call: func(ctx context.Context, c *Cache) error {
+				if _, err := c.LPush(ctx, "list", "job"); err != nil {
+					return err
+				}
+				return c.BLPop(ctx, "list", time.Millisecond).Err
+			},
+			wantCalls: 2,
+		},
+		{
+			name:     "not retryable error",
+			failures: 1,
+			err:      errors.New("mock error"),
+			call: func(ctx context.Context, c *Cache) error {
+				return c.Set(ctx, "name", "大明", time.Minute)
+			},
+			wantCalls: 1,
+			wantErr:   errors.New("mock error"),
+		},
+		{
+			name:     "key not found",
+			failures: 1,
+			err:      errs.ErrKeyNotExist,
+			call: func(ctx context.Context, c *Cache) error {
+				return c.Get(ctx, "name").Err
+			},
+			wantCalls: 1,
+			wantErr:   errs.ErrKeyNotExist,
+		},
+		{
+			name: "custom retryable",
+			opts: []option.Option[Cache]{WithRetryable(func(err error) bool {
+				return err != nil
+			})},
+			failures: 1,
+			err:      errors.New("mock error"),
+			call: func(ctx context.Context, c *Cache) error {
+				return c.Set(ctx, "name", "大明", time.Minute)
+			},
+			wantCalls: 2,
+		},
+		{
+			name:     "deadline too close",
+			failures: 1,
+			err:      io.EOF,
+			opts:     []option.Option[Cache]{WithBackoff(time.Second, time.Second)},
+			call: func(ctx context.Context, c *Cache) error {
+				ctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+				defer cancel()
+				return c.Set(ctx, "name", "大明", time.Minute)
+			},
+			wantCalls: 1,
+			wantErr:   io.EOF,
+		},
+		{
+			name:     "context canceled",
+			failures: 1,
+			err:      io.EOF,
+			call: func(ctx context.Context, c *Cache) error {
+				ctx, cancel := context.WithCancel(ctx)
+				cancel()
+				return c.Set(ctx, "name", "大明", time.Minute)
+			},
+			wantCalls: 1,
+			wantErr:   io.EOF,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := &flakyCache{Cache: lru.NewCache(10), failures: tc.failures, err: tc.err}
+			opts := append([]option.Option[Cache]{WithBackoff(time.Millisecond, time.Millisecond*5)}, tc.opts...)
+			c := NewCache(backend, opts...)
+			err := tc.call(context.Background(), c)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantCalls, backend.calls)
+		})
+	}
+}
+
+func TestCache_backoff(t *testing.T) {
+	c := NewCache(lru.NewCache(10), WithBackoff(time.Millisecond*10, time.Millisecond*50))
+	for attempt, want := range []time.Duration{
+		time.Millisecond * 10, time.Millisecond * 20, time.Millisecond * 40,
+		time.Millisecond * 50, time.Millisecond * 50,
+	} {
+		d := c.backoff(attempt + 1)
+		require.GreaterOrEqual(t, d, want/2)
+		require.Less(t, d, want)
+	}
+	// 溢出
+	assert.Less(t, c.backoff(100), time.Millisecond*50)
+}
@@ -0,0 +1,355 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharding
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/hashtag"
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+var _ ecache.Cache = (*Cache)(nil)
+
+var (
+	// ErrNoShard 没有任何分片
+	ErrNoShard = errors.New("ecache: 没有可用的分片")
+	// ErrCrossShard LMove 之类的命令的 source 和 destination 不在同一个分片上，
+	// 可以通过 hash tag 把它们放到同一个分片上，例如 {queue}:pending 和 {queue}:processing
+	ErrCrossShard = errors.New("ecache: source 和 destination 不在同一个分片上")
+)
+
+// Cache 在客户端通过一致性哈希把 key 分布到多个独立的缓存上，例如多个没有组成集群的 Redis。
+// 和 Redis Cluster 一样，如果 key 中有 hash tag，那么只根据 hash tag 计算分片。
+// 增加或者删除分片的时候不会迁移数据，重新映射的那部分 key 会变成缓存未命中
+type Cache struct {
+	mutex  sync.RWMutex
+	ring   *ring
+	shards map[string]ecache.Cache
+}
+
+// NewCache shards 的 key 是分片的名字，分片在哈希环上的位置只和名字有关
+func NewCache(shards map[string]ecache.Cache, opts ...option.Option[Cache]) *Cache {
+	res := &Cache{
+		ring: &ring{
+			replicas: 160,
+			hash:     crc32.ChecksumIEEE,
+		},
+		shards: make(map[string]ecache.Cache, len(shards)),
+	}
+	option.Apply(res, opts...)
+	for name, c := range shards {
+		res.AddShard(name, c)
+	}
+	return res
+}
+
+// WithReplicas 设置每个分片的虚拟节点数量，越多分布越均匀，默认为 160
+func WithReplicas(replicas int) option.Option[Cache] {
+	return func(c *Cache) {
+		c.ring.replicas = replicas
+	}
+}
+
+// WithHash 设置哈希函数，默认为 crc32.ChecksumIEEE
+func WithHash(hash func(data []byte) uint32) option.Option[Cache] {
+	return func(c *Cache) {
+		c.ring.hash = hash
+	}
+}
+
+// AddShard 增加一个分片，分片已经存在的时候替换掉原本的缓存
+func (c *Cache) AddShard(name string, cache ecache.Cache) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.shards[name]; !ok {
+		c.ring.add(name)
+	}
+	c.shards[name] = cache
+}
+
+// RemoveShard 删除一个分片，原本在这个分片上的 key 会重新映射到其它分片上
+func (c *Cache) RemoveShard(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.shards[name]; !ok {
+		return
+	}
+	c.ring.remove(name)
+	delete(c.shards, name)
+}
+
+// ShardOf 返回 key 所在分片的名字
+func (c *Cache) ShardOf(key string) (string, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	name, ok := c.ring.get(hashtag.Key(key))
+	if !ok {
+		return "", ErrNoShard
+	}
+	return name, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	shard, err := c.shard(key)
+	if err != nil {
+		return err
+	}
+	return shard.Set(ctx, key, val, expiration)
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	shard, err := c.shard(key)
+	if err != nil {
+		return false, err
+	}
+	return shard.SetNX(ctx, key, val, expiration)
+}
+
+func (c *Cache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error) {
+	shard, err := c.shard(key)
+	if err != nil {
+		return false, err
+	}
+	return shard.SetXX(ctx, key, val, expiration)
+}
+
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error) {
+	shard, err := c.shard(key)
+	if err != nil {
+		return false, err
+	}
+	return shard.CompareAndSwap(ctx, key, old, new, expiration)
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (res ecache.Value) {
+	shard, err := c.shard(key)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	return shard.Get(ctx, key)
+}
+
+func (c *Cache) GetSet(ctx context.Context, key string, val string) (res ecache.Value) {
+	shard, err := c.shard(key)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	return shard.GetSet(ctx, key, val)
+}
+
+// Delete 按照分片拆分 key，并发地在每个分片上删除。
+// 部分分片失败的时候返回成功删除的数量和第一个错误
+func (c *Cache) Delete(ctx context.Context, key ...string) (int64, error) {
+	groups, shards, err := c.group(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(groups) == 1 {
+		for name, keys := range groups {
+			return shards[name].Delete(ctx, keys...)
+		}
+	}
+
+	type result struct {
+		cnt int64
+		err error
+	}
+	results := make([]result, 0, len(groups))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, keys := range groups {
+		wg.Add(1)
+		go func(shard ecache.Cache, keys []string) {
+			defer wg.Done()
+			cnt, err := shard.Delete(ctx, keys...)
+			mutex.Lock()
+			results = append(results, result{cnt: cnt, err: err})
+			mutex.Unlock()
+		}(shards[name], keys)
+	}
+	wg.Wait()
+
+	var cnt int64
+	for _, res := range results {
+		cnt += res.cnt
+		if res.err != nil && err == nil {
+			err = res.err
+		}
+	}
+	return cnt, err
+}
+
+func (c *Cache) LPush(ctx context.Context, key string, val ...any) (int64, error) {
+	shard, err := c.shard(key)
+	if err != nil {
+		return 0, err
+	}
+	return shard.LPush(ctx, key, val...)
+}
+
+func (c *Cache) LPop(ctx context.Context, key string) (res ecache.Value) {
+	shard, err := c.shard(key)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	return shard.LPop(ctx, key)
+}
+
+func (c *Cache) BLPop(ctx context.Context, key string, timeout time.Duration) (res ecache.Value) {
+	shard, err := c.shard(key)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	return shard.BLPop(ctx, key, timeout)
+}
+
+func (c *Cache) BRPop(ctx context.Context, key string, timeout time.Duration) (res ecache.Value) {
+	shard, err := c.shard(key)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	return shard.BRPop(ctx, key, timeout)
+}
+
+func (c *Cache) BLMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection, timeout time.Duration) (res ecache.Value) {
+	shard, err := c.sameShard(source, destination)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	return shard.BLMove(ctx, source, destination, srcPos, destPos, timeout)
+}
+
+func (c *Cache) LMove(ctx context.Context, source, destination string,
+	srcPos, destPos ecache.ListDirection) (res ecache.Value) {
+	shard, err := c.sameShard(source, destination)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	return shard.LMove(ctx, source, destination, srcPos, destPos)
+}
+
+func (c *Cache) RPopLPush(ctx context.Context, source, destination string) (res ecache.Value) {
+	shard, err := c.sameShard(source, destination)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	return shard.RPopLPush(ctx, source, destination)
+}
+
+func (c *Cache) LRem(ctx context.Context, key string, count int64, val any) (int64, error) {
+	shard, err := c.shard(key)
+	if err != nil {
+		return 0, err
+	}
+	return shard.LRem(ctx, key, count, val)
+}
+
+func (c *Cache) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
+	shard, err := c.shard(key)
+	if err != nil {
+		return 0, err
+	}
+	return shard.SAdd(ctx, key, members...)
+}
+
+func (c *Cache) SRem(ctx context.Context, key string, members ...any) (int64, error) {
+	shard, err := c.shard(key)
+	if err != nil {
+		return 0, err
+	}
+	return shard.SRem(ctx, key, members...)
+}
+
+func (c *Cache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	shard, err := c.shard(key)
+	if err != nil {
+		return 0, err
+	}
+	return shard.IncrBy(ctx, key, value)
+}
+
+func (c *Cache) DecrBy(ctx context.Context, key string, value int64) (int64, error) {
+	shard, err := c.shard(key)
+	if err != nil {
+		return 0, err
+	}
+	return shard.DecrBy(ctx, key, value)
+}
+
+func (c *Cache) IncrByFloat(ctx context.Context, key string, value float64) (float64, error) {
+	shard, err := c.shard(key)
+	if err != nil {
+		return 0, err
+	}
+	return shard.IncrByFloat(ctx, key, value)
+}
+
+// shard 返回 key 所在的分片
+func (c *Cache) shard(key string) (ecache.Cache, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	name, ok := c.ring.get(hashtag.Key(key))
+	if !ok {
+		return nil, ErrNoShard
+	}
+	return c.shards[name], nil
+}
+
+// sameShard 返回 source 和 destination 所在的分片，不在同一个分片上的时候返回 ErrCrossShard
+func (c *Cache) sameShard(source, destination string) (ecache.Cache, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	src, ok := c.ring.get(hashtag.Key(source))
+	if !ok {
+		return nil, ErrNoShard
+	}
+	dest, _ := c.ring.get(hashtag.Key(destination))
+	if src != dest {
+		return nil, ErrCrossShard
+	}
+	return c.shards[src], nil
+}
+
+// group 按照分片把 key 分组
+func (c *Cache) group(keys []string) (map[string][]string, map[string]ecache.Cache, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	groups := make(map[string][]string, len(c.shards))
+	shards := make(map[string]ecache.Cache, len(c.shards))
+	for _, key := range keys {
+		name, ok := c.ring.get(hashtag.Key(key))
+		if !ok {
+			return nil, nil, ErrNoShard
+		}
+		groups[name] = append(groups[name], key)
+		shards[name] = c.shards[name]
+	}
+	return groups, shards, nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharding
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newShards(names ...string) map[string]ecache.Cache {
+	shards := make(map[string]ecache.Cache, len(names))
+	for _, name := range names {
+		shards[name] = lru.NewCache(1000)
+	}
+	return shards
+}
+
+func TestCache_Route(t *testing.T) {
+	ctx := context.Background()
+	shards := newShards("shard1", "shard2", "shard3")
+	c := NewCache(shards)
+
+	for i := 0; i < 100; i++ {
+		key := "key:" + strconv.Itoa(i)
+		require.NoError(t, c.Set(ctx, key, i, time.Minute))
+		name, err := c.ShardOf(key)
+		require.NoError(t, err)
+		// 只写入了 key 所在的分片
+		for n, shard := range shards {
+			val := shard.Get(ctx, key)
+			if n == name {
+				assert.Equal(t, i, val.Val)
+			} else {
+				assert.True(t, val.KeyNotFound())
+			}
+		}
+		assert.Equal(t, i, c.Get(ctx, key).Val)
+	}
+
+	// hash tag 相同的 key 在同一个分片上
+	name1, err := c.ShardOf("{user1}:name")
+	require.NoError(t, err)
+	name2, err := c.ShardOf("{user1}:age")
+	require.NoError(t, err)
+	assert.Equal(t, name1, name2)
+}
+
+func TestCache_Delete(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(newShards("shard1", "shard2", "shard3"))
+	keys := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		key := "key:" + strconv.Itoa(i)
+		keys = append(keys, key)
+		require.NoError(t, c.Set(ctx, key, i, time.Minute))
+	}
+	cnt, err := c.Delete(ctx, append(keys, "not-exist")...)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), cnt)
+	for _, key := range keys {
+		assert.True(t, c.Get(ctx, key).KeyNotFound())
+	}
+
+	// 一个分片失败，其它分片照常删除
+	shards := newShards("shard1", "shard2")
+	shards["shard3"] = &failingCache{Cache: lru.NewCache(10)}
+	c = NewCache(shards)
+	for _, key := range keys {
+		require.NoError(t, c.Set(ctx, key, 1, time.Minute))
+	}
+	var healthy int64
+	for _, key := range keys {
+		if name, _ := c.ShardOf(key); name != "shard3" {
+			healthy++
+		}
+	}
+	cnt, err = c.Delete(ctx, keys...)
+	assert.Equal(t, errMock, err)
+	assert.Equal(t, healthy, cnt)
+}
+
+func TestCache_CrossShard(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(newShards("shard1", "shard2", "shard3"))
+
+	_, err := c.LPush(ctx, "{queue}:pending", "job1")
+	require.NoError(t, err)
+	val := c.LMove(ctx, "{queue}:pending", "{queue}:processing", ecache.ListRight, ecache.ListLeft)
+	require.NoError(t, val.Err)
+	assert.Equal(t, "job1", val.Val)
+	val = c.RPopLPush(ctx, "{queue}:processing", "{queue}:pending")
+	require.NoError(t, val.Err)
+	assert.Equal(t, "job1", val.Val)
+
+	// 找到两个不在同一个分片上的 key
+	src, dest := "key:0", ""
+	srcShard, _ := c.ShardOf(src)
+	for i := 1; dest == ""; i++ {
+		if name, _ := c.ShardOf("key:" + strconv.Itoa(i)); name != srcShard {
+			dest = "key:" + strconv.Itoa(i)
+		}
+	}
+	assert.Equal(t, ErrCrossShard, c.LMove(ctx, src, dest, ecache.ListRight, ecache.ListLeft).Err)
+	assert.Equal(t, ErrCrossShard, c.RPopLPush(ctx, src, dest).Err)
+	assert.Equal(t, ErrCrossShard, c.BLMove(ctx, src, dest, ecache.ListRight, ecache.ListLeft, time.Millisecond).Err)
+}
+
+func TestCache_AddRemoveShard(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(nil, WithReplicas(50))
+	assert.Equal(t, ErrNoShard, c.Set(ctx, "key", 1, time.Minute))
+	assert.Equal(t, ErrNoShard, c.Get(ctx, "key").Err)
+	_, err := c.Delete(ctx, "key")
+	assert.Equal(t, ErrNoShard, err)
+
+	c.AddShard("shard1", lru.NewCache(1000))
+	keys := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		key := "key:" + strconv.Itoa(i)
+		keys = append(keys, key)
+		require.NoError(t, c.Set(ctx, key, i, time.Minute))
+	}
+
+	// 新的分片是空的，迁移到新分片的 key 变成缓存未命中，其它 key 不受影响
+	c.AddShard("shard2", lru.NewCache(1000))
+	var missed int
+	for _, key := range keys {
+		val := c.Get(ctx, key)
+		name, _ := c.ShardOf(key)
+		if name == "shard2" {
+			assert.True(t, val.KeyNotFound())
+			missed++
+		} else {
+			assert.NoError(t, val.Err)
+		}
+	}
+	assert.Greater(t, missed, 0)
+	assert.Less(t, missed, len(keys))
+
+	// 删除之后原本的 key 又回到 shard1
+	c.RemoveShard("shard2")
+	c.RemoveShard("not-exist")
+	for i, key := range keys {
+		assert.Equal(t, i, c.Get(ctx, key).Val)
+	}
+}
+
+func TestCache_NoShard(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(nil)
+	_, err := c.SetNX(ctx, "key", 1, time.Minute)
+	assert.Equal(t, ErrNoShard, err)
+	_, err = c.IncrBy(ctx, "key", 1)
+	assert.Equal(t, ErrNoShard, err)
+	assert.Equal(t, ErrNoShard, c.LPop(ctx, "key").Err)
+	assert.Equal(t, ErrNoShard, c.LMove(ctx, "a", "b", ecache.ListLeft, ecache.ListRight).Err)
+	_, err = c.ShardOf("key")
+	assert.Equal(t, ErrNoShard, err)
+}
+
+var errMock = errors.New("mock error")
+
+// failingCache 所有的 Delete 都失败
+type failingCache struct {
+	ecache.Cache
+}
+
+func (f *failingCache) Delete(ctx context.Context, key ...string) (int64, error) {
+	return 0, errMock
+}
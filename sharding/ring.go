@@ -0,0 +1,72 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharding
+
+import (
+	"sort"
+	"strconv"
+)
+
+// ring 一致性哈希环，每个分片在环上有 replicas 个虚拟节点。
+// 增加或者删除一个分片的时候，只有落在这个分片的虚拟节点上的 key 会重新映射
+type ring struct {
+	replicas int
+	hash     func(data []byte) uint32
+	nodes    []node
+}
+
+type node struct {
+	hash  uint32
+	shard string
+}
+
+func (r *ring) add(shard string) {
+	for i := 0; i < r.replicas; i++ {
+		r.nodes = append(r.nodes, node{
+			hash:  r.hash([]byte(shard + "#" + strconv.Itoa(i))),
+			shard: shard,
+		})
+	}
+	// 哈希值相同的时候按照分片的名字排序，保证结果和添加的顺序无关
+	sort.Slice(r.nodes, func(i, j int) bool {
+		if r.nodes[i].hash != r.nodes[j].hash {
+			return r.nodes[i].hash < r.nodes[j].hash
+		}
+		return r.nodes[i].shard < r.nodes[j].shard
+	})
+}
+
+func (r *ring) remove(shard string) {
+	nodes := r.nodes[:0]
+	for _, n := range r.nodes {
+		if n.shard != shard {
+			nodes = append(nodes, n)
+		}
+	}
+	r.nodes = nodes
+}
+
+// get 返回 key 所在的分片，也就是顺时针方向第一个虚拟节点所属的分片
+func (r *ring) get(key string) (string, bool) {
+	if len(r.nodes) == 0 {
+		return "", false
+	}
+	h := r.hash([]byte(key))
+	i := sort.Search(len(r.nodes), func(i int) bool { return r.nodes[i].hash >= h })
+	if i == len(r.nodes) {
+		i = 0
+	}
+	return r.nodes[i].shard, true
+}
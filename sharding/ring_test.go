@@ -0,0 +1,84 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharding
+
+import (
+	"hash/crc32"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRing(shards ...string) *ring {
+	r := &ring{replicas: 160, hash: crc32.ChecksumIEEE}
+	for _, shard := range shards {
+		r.add(shard)
+	}
+	return r
+}
+
+func TestRing_Distribution(t *testing.T) {
+	r := newRing("shard1", "shard2", "shard3")
+	cnt := make(map[string]int, 3)
+	for i := 0; i < 30000; i++ {
+		shard, ok := r.get("key:" + strconv.Itoa(i))
+		assert.True(t, ok)
+		cnt[shard]++
+	}
+	for shard, n := range cnt {
+		assert.InDelta(t, 10000, n, 2000, shard)
+	}
+
+	// 和添加的顺序无关
+	other := newRing("shard3", "shard1", "shard2")
+	assert.Equal(t, r.nodes, other.nodes)
+
+	_, ok := newRing().get("key")
+	assert.False(t, ok)
+}
+
+func TestRing_Remap(t *testing.T) {
+	const total = 30000
+	r := newRing("shard1", "shard2", "shard3")
+	before := make([]string, total)
+	for i := range before {
+		before[i], _ = r.get("key:" + strconv.Itoa(i))
+	}
+
+	// 增加分片的时候，只有迁移到新分片上的 key 会变化
+	r.add("shard4")
+	moved := 0
+	for i := range before {
+		shard, _ := r.get("key:" + strconv.Itoa(i))
+		if shard != before[i] {
+			assert.Equal(t, "shard4", shard)
+			moved++
+		}
+	}
+	assert.InDelta(t, total/4, moved, total/20)
+
+	// 删除分片之后，其它分片上的 key 不受影响
+	r.remove("shard4")
+	r.remove("shard2")
+	for i := range before {
+		shard, _ := r.get("key:" + strconv.Itoa(i))
+		if before[i] != "shard2" {
+			assert.Equal(t, before[i], shard)
+		} else {
+			assert.NotEqual(t, "shard2", shard)
+		}
+	}
+}
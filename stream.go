@@ -0,0 +1,66 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecache
+
+import (
+	"context"
+	"time"
+)
+
+// Stream 基于 Redis Streams 语义的持久化消息队列。
+// 和 LPush/LPop 不同，通过消费者组读到的消息在 XAck 之前一直处于 pending 状态，
+// 消费者崩溃之后可以被其他消费者通过 XClaim 认领，从而做到至少一次投递
+type Stream interface {
+	// XAdd 追加一条消息并返回消息的 ID。
+	// id 为 "*" 或者空字符串的时候自动生成，否则必须大于最后一条消息的 ID
+	XAdd(ctx context.Context, stream, id string, values map[string]any) (string, error)
+	// XRead 读取 ID 大于 id 的消息，最多 count 条，count <= 0 表示不限制。
+	// id 为 "$" 表示只读取调用之后追加的消息。
+	// block > 0 的时候，如果没有消息会一直阻塞直到有新消息、超时或者 ctx 结束，超时返回空切片
+	XRead(ctx context.Context, stream, id string, count int64, block time.Duration) ([]StreamMessage, error)
+	// XGroupCreate 创建消费者组，stream 不存在的时候会一并创建。
+	// start 为 "$" 表示只消费新消息，"0" 表示从头开始消费。
+	// 消费者组已经存在的时候返回 ErrStreamGroupExist
+	XGroupCreate(ctx context.Context, stream, group, start string) error
+	// XReadGroup 以 consumer 的身份读取消费者组中还没有投递过的消息，读到的消息进入 pending 列表。
+	// count 和 block 的含义和 XRead 一样，消费者组不存在的时候返回 ErrStreamGroupNotExist
+	XReadGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]StreamMessage, error)
+	// XAck 确认消息已经处理完毕，将其从 pending 列表中移除，返回确认成功的数量
+	XAck(ctx context.Context, stream, group string, ids ...string) (int64, error)
+	// XPending 按照 ID 从小到大返回已经投递但是还没有确认的消息，最多 count 条，count <= 0 表示不限制
+	XPending(ctx context.Context, stream, group string, count int64) ([]PendingMessage, error)
+	// XClaim 将空闲时间不小于 minIdle 的 pending 消息转移给 consumer，并返回转移成功的消息。
+	// 已经被 XTrim 删除的消息会从 pending 列表中移除，不会返回
+	XClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamMessage, error)
+	// XTrim 删除最旧的消息，只保留 maxLen 条，返回删除的数量
+	XTrim(ctx context.Context, stream string, maxLen int64) (int64, error)
+}
+
+// StreamMessage stream 中的一条消息。
+// 和 Redis 一样，写入的值都会被转换为字符串
+type StreamMessage struct {
+	ID     string
+	Values map[string]string
+}
+
+// PendingMessage 已经投递给消费者但是还没有确认的消息
+type PendingMessage struct {
+	ID       string
+	Consumer string
+	// Idle 距离上一次投递过去了多久
+	Idle time.Duration
+	// RetryCount 投递的次数，XClaim 每认领一次都会加一
+	RetryCount int64
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+)
+
+// Transactional 支持乐观锁事务的缓存
+type Transactional interface {
+	// Watch 监视 keys 并执行 fn。
+	// 在 fn 中通过 Tx.Get 读取数据，然后通过 Tx.Pipelined 提交写操作。
+	// 如果从 Watch 开始到 Tx.Pipelined 执行之间，被监视的 key 被修改了，
+	// 那么写操作都不会执行，并且返回 ErrTxConflict。
+	// Watch 本身不会重试，需要重试的可以使用 Transaction
+	Watch(ctx context.Context, fn func(tx Tx) error, keys ...string) error
+}
+
+// TransactionalCache 支持乐观锁事务的 Cache，例如 lru.Cache 和 RBTreePriorityCache
+type TransactionalCache interface {
+	Cache
+	Transactional
+}
+
+// Tx 乐观锁事务
+type Tx interface {
+	// Get 立刻读取 key 的值
+	Get(ctx context.Context, key string) Value
+	// Pipelined 在 fn 中排队写操作，fn 返回 nil 之后这些操作会被原子地执行，不需要调用 Pipeline.Exec。
+	// 在 Pipelined 返回之后，可以通过 Future 拿到每个操作的结果。
+	// 执行之后就不再监视 key 了，所以同一个 Watch 中只有第一次 Pipelined 会检测冲突
+	Pipelined(ctx context.Context, fn func(p Pipeline) error) error
+}
+
+// Transaction 执行乐观锁事务，发生冲突的时候会重新执行 fn，最多执行 maxAttempts 次。
+// 所以 fn 里面不应该有除了操作 Tx 以外的副作用
+func Transaction(ctx context.Context, c Transactional, maxAttempts int,
+	fn func(tx Tx) error, keys ...string) error {
+	var err error
+	for i := 0; i < maxAttempts; i++ {
+		err = c.Watch(ctx, fn, keys...)
+		if !errors.Is(err, errs.ErrTxConflict) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransaction(t *testing.T) {
+	testCases := []struct {
+		name string
+		errs []error
+
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name:      "committed",
+			errs:      []error{nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "retry on conflict",
+			errs:      []error{ErrTxConflict, ErrTxConflict, nil},
+			wantCalls: 3,
+		},
+		{
+			name:      "too many conflicts",
+			errs:      []error{ErrTxConflict, ErrTxConflict, ErrTxConflict},
+			wantErr:   ErrTxConflict,
+			wantCalls: 3,
+		},
+		{
+			name:      "other error",
+			errs:      []error{errors.New("mock error")},
+			wantErr:   errors.New("mock error"),
+			wantCalls: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &fakeTransactional{errs: tc.errs}
+			err := Transaction(context.Background(), c, 3, func(tx Tx) error {
+				return nil
+			}, "key")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantCalls, c.calls)
+		})
+	}
+}
+
+// fakeTransactional 按照顺序返回 errs 中的错误
+type fakeTransactional struct {
+	errs  []error
+	calls int
+}
+
+func (f *fakeTransactional) Watch(ctx context.Context, fn func(tx Tx) error, keys ...string) error {
+	err := f.errs[f.calls]
+	f.calls++
+	return err
+}
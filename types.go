@@ -30,6 +30,12 @@ type Cache interface {
 	// SetNX 设置一个键值对如果key不存在则写入反之失败，并且设置过期时间.
 	// 当过期时间为0时,表示永不过期
 	SetNX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error)
+	// SetXX 只有在 key 已经存在的时候才写入，并且设置过期时间。
+	// 当过期时间为0时,表示永不过期
+	SetXX(ctx context.Context, key string, val any, expiration time.Duration) (bool, error)
+	// CompareAndSwap 只有在 key 当前的值等于 old 的时候，才把值设置为 new，并且设置过期时间。
+	// key 不存在的时候返回 false。Redis 比较的是序列化之后的字符串，本地缓存使用 reflect.DeepEqual 比较
+	CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (bool, error)
 	// Get 返回一个 Value
 	// 如果你需要检测 Err，可以使用 Value.Err
 	// 如果你需要知道 Key 是否存在，可以使用 Value.KeyNotFound
@@ -44,6 +50,25 @@ type Cache interface {
 	LPush(ctx context.Context, key string, val ...any) (int64, error)
 	// LPop 命令用于移除并返回列表的第一个元素。
 	LPop(ctx context.Context, key string) Value
+	// BLPop 和 LPop 一样移除并返回列表的第一个元素，但是列表为空的时候会阻塞，
+	// 直到其他人向列表写入元素、超过 timeout 或者 ctx 结束。
+	// timeout 为 0 表示一直阻塞到 ctx 结束。超时返回 errs.ErrKeyNotExist，ctx 结束返回 ctx.Err()。
+	// 一次写入只会唤醒一个阻塞的调用者
+	BLPop(ctx context.Context, key string, timeout time.Duration) Value
+	// BRPop 和 BLPop 一样，只是移除并返回列表的最后一个元素
+	BRPop(ctx context.Context, key string, timeout time.Duration) Value
+	// BLMove 从 source 的 srcPos 一端移除一个元素，放入 destination 的 destPos 一端，并返回该元素。
+	// source 为空的时候和 BLPop 一样阻塞
+	BLMove(ctx context.Context, source, destination string, srcPos, destPos ListDirection, timeout time.Duration) Value
+	// LMove 从 source 的 srcPos 一端移除一个元素，放入 destination 的 destPos 一端，并返回该元素。
+	// source 不存在或者为空的时候返回 errs.ErrKeyNotExist。source 和 destination 可以是同一个列表，用于轮转列表
+	LMove(ctx context.Context, source, destination string, srcPos, destPos ListDirection) Value
+	// RPopLPush 等价于 LMove(ctx, source, destination, ListRight, ListLeft)
+	RPopLPush(ctx context.Context, source, destination string) Value
+	// LRem 移除列表中和 val 相等的元素，返回移除的数量。
+	// count > 0 从头部开始移除 count 个，count < 0 从尾部开始移除 -count 个，count = 0 移除全部。
+	// key 不存在的时候返回 0。Redis 比较的是序列化之后的字符串，本地缓存使用 reflect.DeepEqual 比较
+	LRem(ctx context.Context, key string, count int64, val any) (int64, error)
 	// SAdd 命令将一个或多个成员元素加入到集合中，已经存在于集合的成员元素将被忽略。
 	SAdd(ctx context.Context, key string, members ...any) (int64, error)
 	// SRem 移除集合中的一个或多个成员元素，不存在的成员元素会被忽略。
@@ -60,9 +85,28 @@ type Cache interface {
 	IncrByFloat(ctx context.Context, key string, value float64) (float64, error)
 }
 
+// ListDirection 列表的方向，用于 LMove 之类需要指定从哪一端操作的命令
+type ListDirection string
+
+const (
+	// ListLeft 列表的头部，也就是 LPush 和 LPop 操作的一端
+	ListLeft ListDirection = "LEFT"
+	// ListRight 列表的尾部
+	ListRight ListDirection = "RIGHT"
+)
+
+// Loader 用于从数据源加载 key 对应的值，一般用于缓存的后台刷新
+type Loader func(ctx context.Context, key string) (any, error)
+
 // Value 代表一个从缓存中读取出来的值
 type Value struct {
 	ekit.AnyValue
+	// Stale 为 true 表示该值已经过了软过期时间，但是还没有到硬过期时间。
+	// 此时返回的是旧值，缓存会在后台异步刷新
+	Stale bool
+	// Version 值的版本，每次修改 key 都会变化。
+	// 只有实现了 VersionedCache 的缓存才会返回版本，否则为 0
+	Version uint64
 }
 
 func (v Value) KeyNotFound() bool {
@@ -0,0 +1,28 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecache
+
+import (
+	"context"
+	"time"
+)
+
+// VersionedCache 支持版本号的缓存，Get 返回的 Value.Version 是 key 当前的版本
+type VersionedCache interface {
+	Cache
+	// SetIfVersion 只有在 key 当前的版本等于 version 的时候才写入，version 为 0 表示 key 不存在。
+	// 配合 Get 返回的版本，可以在不使用事务的情况下实现读取-修改-写入
+	SetIfVersion(ctx context.Context, key string, val any, version uint64, expiration time.Duration) (bool, error)
+}
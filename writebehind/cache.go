@@ -0,0 +1,352 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writebehind
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/ecache"
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/ecodeclub/ekit/retry"
+)
+
+var _ ecache.Cache = (*Cache)(nil)
+
+// pendingOp 等待刷新到 Store 的操作，同一个 key 只保留最后一次操作
+type pendingOp struct {
+	val     any
+	deleted bool
+}
+
+// Cache 写回（write-behind）缓存。
+// 写操作在写入缓存之后立刻返回，之后再批量刷新到 Store。
+// 目前只有 Set、SetNX、GetSet、Delete 和计数类操作会被刷新到 Store，
+// 其余的操作直接交给被装饰的缓存处理。
+// 为了保证刷新到 Store 的是最后一次写入的值，这些写操作在同一个锁里面串行执行
+type Cache struct {
+	ecache.Cache
+	store Store
+
+	mutex   sync.Mutex
+	pending map[string]pendingOp
+	closed  bool
+
+	flushInterval time.Duration
+	batchSize     int
+
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	maxRetries      int32
+	errHandler      ErrorHandler
+
+	flushCh chan struct{}
+	closeCh chan struct{}
+	doneCh  chan struct{}
+}
+
+// NewCache 创建一个写回缓存，并且启动后台刷新的 goroutine。
+// 使用完毕之后需要调用 Close，否则可能丢失还没有刷新的数据
+func NewCache(c ecache.Cache, store Store, opts ...option.Option[Cache]) *Cache {
+	res := &Cache{
+		Cache:           c,
+		store:           store,
+		pending:         make(map[string]pendingOp),
+		flushInterval:   time.Second,
+		batchSize:       100,
+		initialInterval: time.Millisecond * 100,
+		maxInterval:     time.Second,
+		maxRetries:      3,
+		flushCh:         make(chan struct{}, 1),
+		closeCh:         make(chan struct{}),
+		doneCh:          make(chan struct{}),
+	}
+	option.Apply(res, opts...)
+	go res.loop()
+	return res
+}
+
+// WithFlushInterval 设置定时刷新的间隔
+func WithFlushInterval(interval time.Duration) option.Option[Cache] {
+	return func(c *Cache) {
+		c.flushInterval = interval
+	}
+}
+
+// WithBatchSize 设置等待刷新的 key 的数量达到多少的时候立刻刷新
+func WithBatchSize(size int) option.Option[Cache] {
+	return func(c *Cache) {
+		c.batchSize = size
+	}
+}
+
+// WithRetry 设置刷新失败时的指数退避重试参数，maxRetries 为 0 表示不重试
+func WithRetry(initialInterval, maxInterval time.Duration, maxRetries int32) option.Option[Cache] {
+	return func(c *Cache) {
+		c.initialInterval = initialInterval
+		c.maxInterval = maxInterval
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithErrorHandler 设置重试之后依旧刷新失败时的回调
+func WithErrorHandler(handler ErrorHandler) option.Option[Cache] {
+	return func(c *Cache) {
+		c.errHandler = handler
+	}
+}
+
+func (c *Cache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	return c.write(func() error {
+		if err := c.Cache.Set(ctx, key, val, expiration); err != nil {
+			return err
+		}
+		c.pending[key] = pendingOp{val: val}
+		return nil
+	})
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, val any, expiration time.Duration) (ok bool, err error) {
+	err = c.write(func() error {
+		ok, err = c.Cache.SetNX(ctx, key, val, expiration)
+		if err == nil && ok {
+			c.pending[key] = pendingOp{val: val}
+		}
+		return err
+	})
+	return
+}
+
+func (c *Cache) SetXX(ctx context.Context, key string, val any, expiration time.Duration) (ok bool, err error) {
+	err = c.write(func() error {
+		ok, err = c.Cache.SetXX(ctx, key, val, expiration)
+		if err == nil && ok {
+			c.pending[key] = pendingOp{val: val}
+		}
+		return err
+	})
+	return
+}
+
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old, new any, expiration time.Duration) (ok bool, err error) {
+	err = c.write(func() error {
+		ok, err = c.Cache.CompareAndSwap(ctx, key, old, new, expiration)
+		if err == nil && ok {
+			c.pending[key] = pendingOp{val: new}
+		}
+		return err
+	})
+	return
+}
+
+func (c *Cache) GetSet(ctx context.Context, key string, val string) (res ecache.Value) {
+	err := c.write(func() error {
+		res = c.Cache.GetSet(ctx, key, val)
+		if res.Err == nil || res.KeyNotFound() {
+			c.pending[key] = pendingOp{val: val}
+		}
+		return nil
+	})
+	if err != nil {
+		res.Err = err
+	}
+	return
+}
+
+// Delete 删除缓存中的 key，并且会从 Store 中删除所有传入的 key，不管它在缓存中是否存在
+func (c *Cache) Delete(ctx context.Context, key ...string) (n int64, err error) {
+	err = c.write(func() error {
+		n, err = c.Cache.Delete(ctx, key...)
+		if err != nil {
+			return err
+		}
+		for _, k := range key {
+			c.pending[k] = pendingOp{deleted: true}
+		}
+		return nil
+	})
+	return
+}
+
+func (c *Cache) IncrBy(ctx context.Context, key string, value int64) (res int64, err error) {
+	err = c.write(func() error {
+		res, err = c.Cache.IncrBy(ctx, key, value)
+		if err == nil {
+			c.pending[key] = pendingOp{val: res}
+		}
+		return err
+	})
+	return
+}
+
+func (c *Cache) DecrBy(ctx context.Context, key string, value int64) (res int64, err error) {
+	err = c.write(func() error {
+		res, err = c.Cache.DecrBy(ctx, key, value)
+		if err == nil {
+			c.pending[key] = pendingOp{val: res}
+		}
+		return err
+	})
+	return
+}
+
+func (c *Cache) IncrByFloat(ctx context.Context, key string, value float64) (res float64, err error) {
+	err = c.write(func() error {
+		res, err = c.Cache.IncrByFloat(ctx, key, value)
+		if err == nil {
+			c.pending[key] = pendingOp{val: res}
+		}
+		return err
+	})
+	return
+}
+
+// Close 停止后台刷新，并且把剩余的数据刷新到 Store
+func (c *Cache) Close() error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mutex.Unlock()
+
+	close(c.closeCh)
+	<-c.doneCh
+	return nil
+}
+
+// write 在 mutex 里面执行 fn，fn 写入缓存之后在 pending 中记录等待刷新的操作，同一个 key 后面的操作会覆盖前面的操作。
+// 写缓存和记录操作都在锁里面，所以同一个 key 在 pending 中的顺序和写入缓存的顺序一致。
+// 关闭之后直接返回 errs.ErrCacheClosed，不会修改缓存
+func (c *Cache) write(fn func() error) error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return errs.ErrCacheClosed
+	}
+	err := fn()
+	full := len(c.pending) >= c.batchSize
+	c.mutex.Unlock()
+
+	if full {
+		select {
+		case c.flushCh <- struct{}{}:
+		default:
+			// 已经有刷新的信号了
+		}
+	}
+	return err
+}
+
+func (c *Cache) loop() {
+	defer close(c.doneCh)
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.flush()
+		case <-c.flushCh:
+			c.flush()
+		case <-c.closeCh:
+			c.flush()
+			return
+		}
+	}
+}
+
+// flush 把当前等待刷新的操作批量写入 Store
+func (c *Cache) flush() {
+	c.mutex.Lock()
+	pending := c.pending
+	c.pending = make(map[string]pendingOp, len(pending))
+	c.mutex.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	entries := make([]Entry, 0, len(pending))
+	keys := make([]string, 0)
+	for key, op := range pending {
+		if op.deleted {
+			keys = append(keys, key)
+			continue
+		}
+		entries = append(entries, Entry{Key: key, Val: op.val})
+	}
+
+	ctx := context.Background()
+	// 每一次调用 Store 最多 batchSize 个 key
+	for _, batch := range split(entries, c.batchSize) {
+		if err := c.withRetry(func() error {
+			return c.store.Write(ctx, batch)
+		}); err != nil {
+			c.handleErr(err, batch, nil)
+		}
+	}
+	for _, batch := range split(keys, c.batchSize) {
+		if err := c.withRetry(func() error {
+			return c.store.Delete(ctx, batch)
+		}); err != nil {
+			c.handleErr(err, nil, batch)
+		}
+	}
+}
+
+// split 把 items 按照 size 拆分为多批，size 小于等于 0 的时候不拆分
+func split[T any](items []T, size int) [][]T {
+	if len(items) == 0 {
+		return nil
+	}
+	if size <= 0 {
+		return [][]T{items}
+	}
+	res := make([][]T, 0, (len(items)+size-1)/size)
+	for len(items) > size {
+		res = append(res, items[:size])
+		items = items[size:]
+	}
+	return append(res, items)
+}
+
+func (c *Cache) withRetry(fn func() error) error {
+	err := fn()
+	if err == nil || c.maxRetries <= 0 {
+		return err
+	}
+	strategy, strategyErr := retry.NewExponentialBackoffRetryStrategy(c.initialInterval, c.maxInterval, c.maxRetries)
+	if strategyErr != nil {
+		return err
+	}
+	for {
+		interval, ok := strategy.Next()
+		if !ok {
+			return err
+		}
+		time.Sleep(interval)
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+}
+
+func (c *Cache) handleErr(err error, entries []Entry, keys []string) {
+	if c.errHandler != nil {
+		c.errHandler(err, entries, keys)
+	}
+}
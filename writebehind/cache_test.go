@@ -0,0 +1,277 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writebehind
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ecache/internal/errs"
+	"github.com/ecodeclub/ecache/memory/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore 测试用的内存 Store
+type fakeStore struct {
+	mutex sync.Mutex
+	data  map[string]any
+	// writeBatches 记录每一批写入的数量
+	writeBatches []int
+	// failures 接下来多少次调用会失败
+	failures int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{data: make(map[string]any)}
+}
+
+func (s *fakeStore) Write(ctx context.Context, entries []Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("mock error")
+	}
+	s.writeBatches = append(s.writeBatches, len(entries))
+	for _, e := range entries {
+		s.data[e.Key] = e.Val
+	}
+	return nil
+}
+
+func (s *fakeStore) Delete(ctx context.Context, keys []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("mock error")
+	}
+	for _, key := range keys {
+		delete(s.data, key)
+	}
+	return nil
+}
+
+func (s *fakeStore) get(key string) (any, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	val, ok := s.data[key]
+	return val, ok
+}
+
+func (s *fakeStore) batches() []int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]int(nil), s.writeBatches...)
+}
+
+func TestCache_Close(t *testing.T) {
+	store := newFakeStore()
+	c := NewCache(lru.NewCache(100), store, WithFlushInterval(time.Hour))
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "name", "大明", time.Minute))
+	ok, err := c.SetNX(ctx, "name", "小明", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = c.SetNX(ctx, "age", 18, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	res := c.GetSet(ctx, "title", "engineer")
+	assert.True(t, res.KeyNotFound())
+
+	// 还没有刷新
+	_, ok = store.get("name")
+	assert.False(t, ok)
+	// 读取直接走缓存
+	val := c.Get(ctx, "name")
+	assert.Equal(t, "大明", val.Val)
+
+	require.NoError(t, c.Close())
+	val2, _ := store.get("name")
+	assert.Equal(t, "大明", val2)
+	val2, _ = store.get("age")
+	assert.Equal(t, 18, val2)
+	val2, _ = store.get("title")
+	assert.Equal(t, "engineer", val2)
+
+	// 关闭之后不能再写，缓存也不会被修改
+	err = c.Set(ctx, "name", "小明", time.Minute)
+	assert.Equal(t, errs.ErrCacheClosed, err)
+	assert.Equal(t, "大明", c.Get(ctx, "name").Val)
+	_, err = c.IncrBy(ctx, "visits", 1)
+	assert.Equal(t, errs.ErrCacheClosed, err)
+	assert.True(t, c.Get(ctx, "visits").KeyNotFound())
+	// 重复关闭
+	assert.NoError(t, c.Close())
+}
+
+func TestCache_Coalesce(t *testing.T) {
+	store := newFakeStore()
+	c := NewCache(lru.NewCache(100), store, WithFlushInterval(time.Hour))
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_, err := c.IncrBy(ctx, "visits", 1)
+		require.NoError(t, err)
+	}
+	_, err := c.DecrBy(ctx, "visits", 2)
+	require.NoError(t, err)
+	_, err = c.IncrByFloat(ctx, "score", 1.5)
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, "deleted", "value", time.Minute))
+	_, err = c.Delete(ctx, "deleted")
+	require.NoError(t, err)
+
+	require.NoError(t, c.Close())
+	// 多次写入同一个 key 只会写一次
+	assert.Equal(t, []int{2}, store.batches())
+	val, _ := store.get("visits")
+	assert.Equal(t, int64(8), val)
+	val, _ = store.get("score")
+	assert.Equal(t, 1.5, val)
+	_, ok := store.get("deleted")
+	assert.False(t, ok)
+}
+
+func TestCache_FlushBySize(t *testing.T) {
+	store := newFakeStore()
+	c := NewCache(lru.NewCache(100), store,
+		WithFlushInterval(time.Hour), WithBatchSize(3))
+	defer func() {
+		_ = c.Close()
+	}()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "key1", "value1", time.Minute))
+	require.NoError(t, c.Set(ctx, "key2", "value2", time.Minute))
+	assert.Empty(t, store.batches())
+	require.NoError(t, c.Set(ctx, "key3", "value3", time.Minute))
+	assert.Eventually(t, func() bool {
+		return len(store.batches()) == 1
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, []int{3}, store.batches())
+}
+
+// TestCache_FlushSplit 一次刷新的数据超过 batchSize 的时候拆分为多次调用 Store
+func TestCache_FlushSplit(t *testing.T) {
+	store := newFakeStore()
+	c := NewCache(lru.NewCache(100), store,
+		WithFlushInterval(time.Hour), WithBatchSize(2))
+	defer func() {
+		_ = c.Close()
+	}()
+	// 模拟 Store 很慢的时候积攒下来的数据
+	c.mutex.Lock()
+	for _, key := range []string{"key1", "key2", "key3", "key4", "key5"} {
+		c.pending[key] = pendingOp{val: key}
+	}
+	c.mutex.Unlock()
+
+	c.flush()
+	assert.Equal(t, []int{2, 2, 1}, store.batches())
+	for _, key := range []string{"key1", "key2", "key3", "key4", "key5"} {
+		val, _ := store.get(key)
+		assert.Equal(t, key, val)
+	}
+}
+
+// TestCache_ConcurrentSameKey 并发修改同一个 key，最终刷新到 Store 的一定是缓存中最新的值
+func TestCache_ConcurrentSameKey(t *testing.T) {
+	store := newFakeStore()
+	c := NewCache(lru.NewCache(100), store, WithFlushInterval(time.Millisecond))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, err := c.IncrBy(ctx, "visits", 1)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, c.Close())
+	val, _ := store.get("visits")
+	assert.Equal(t, int64(1000), val)
+}
+
+func TestCache_FlushByInterval(t *testing.T) {
+	store := newFakeStore()
+	c := NewCache(lru.NewCache(100), store, WithFlushInterval(time.Millisecond*50))
+	defer func() {
+		_ = c.Close()
+	}()
+	require.NoError(t, c.Set(context.Background(), "key1", "value1", time.Minute))
+	assert.Eventually(t, func() bool {
+		val, _ := store.get("key1")
+		return val == "value1"
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestCache_Retry(t *testing.T) {
+	testCases := []struct {
+		name     string
+		failures int
+
+		wantHandled []string
+		wantStored  bool
+	}{
+		{
+			name:       "retry success",
+			failures:   2,
+			wantStored: true,
+		},
+		{
+			name:        "retry failed",
+			failures:    10,
+			wantHandled: []string{"key1", "key2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeStore()
+			store.failures = tc.failures
+			var handled []string
+			c := NewCache(lru.NewCache(100), store,
+				WithFlushInterval(time.Hour),
+				WithRetry(time.Millisecond, time.Millisecond*5, 3),
+				WithErrorHandler(func(err error, entries []Entry, keys []string) {
+					for _, e := range entries {
+						handled = append(handled, e.Key)
+					}
+					handled = append(handled, keys...)
+				}))
+			ctx := context.Background()
+			require.NoError(t, c.Set(ctx, "key1", "value1", time.Minute))
+			_, err := c.Delete(ctx, "key2")
+			require.NoError(t, err)
+			require.NoError(t, c.Close())
+
+			sort.Strings(handled)
+			assert.Equal(t, tc.wantHandled, handled)
+			_, ok := store.get("key1")
+			assert.Equal(t, tc.wantStored, ok)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2023 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writebehind
+
+import "context"
+
+// Store 写回缓存背后的持久化存储，例如数据库
+type Store interface {
+	// Write 批量写入，同一批里面 key 不会重复
+	Write(ctx context.Context, entries []Entry) error
+	// Delete 批量删除，同一批里面 key 不会重复
+	Delete(ctx context.Context, keys []string) error
+}
+
+// Entry 需要写入 Store 的键值对
+type Entry struct {
+	Key string
+	Val any
+}
+
+// ErrorHandler 在重试之后依旧刷新失败的时候调用。
+// entries 和 keys 分别是没有写入和没有删除的数据，调用之后这些数据会被丢弃
+type ErrorHandler func(err error, entries []Entry, keys []string)